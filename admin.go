@@ -0,0 +1,144 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/hunterhug/fafacms/core/model"
+	"github.com/hunterhug/fafacms/core/session"
+	"github.com/hunterhug/fafacms/core/util"
+	"strings"
+	"time"
+)
+
+const adminUsage = `Usage:
+  fafacms [flags] admin create -name=root -email=root@example.com [-password=xxx]
+  fafacms [flags] admin reset-password -name=root [-password=xxx]
+Password will be random and print out when empty.`
+
+// Bootstrap the super admin from command line, it is the only way to gain a super admin
+func adminCommand(args []string) error {
+	if len(args) == 0 {
+		return errors.New(adminUsage)
+	}
+
+	var name, email, password string
+	set := flag.NewFlagSet("admin "+args[0], flag.ContinueOnError)
+	set.StringVar(&name, "name", "", "User name of super admin")
+	set.StringVar(&email, "email", "", "Email of super admin, create need")
+	set.StringVar(&password, "password", "", "Password of super admin, random when empty")
+	if err := set.Parse(args[1:]); err != nil {
+		return err
+	}
+
+	if name == "" || strings.Contains(name, "@") {
+		return errors.New("name empty or contain @")
+	}
+
+	random := false
+	if password == "" {
+		password = util.GetGUID()[0:16]
+		random = true
+	}
+
+	var err error
+	switch args[0] {
+	case "create":
+		err = adminCreate(name, email, password)
+	case "reset-password":
+		err = adminResetPassword(name, password)
+	default:
+		return errors.New(adminUsage)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if random {
+		fmt.Printf("super admin %s password: %s\n", name, password)
+	}
+	return nil
+}
+
+// Create a new super admin, or promote a exist user to super admin
+func adminCreate(name, email, password string) error {
+	u := new(model.User)
+	u.Name = name
+	exist, err := u.GetRaw()
+	if err != nil {
+		return err
+	}
+
+	if exist {
+		u.IsSuperAdmin = 1
+		err = u.UpdateSuperAdmin()
+		if err != nil {
+			return err
+		}
+		return adminResetPassword(name, password)
+	}
+
+	if email == "" {
+		return errors.New("email empty")
+	}
+
+	u.Email = email
+	repeat, err := u.IsEmailRepeat()
+	if err != nil {
+		return err
+	}
+	if repeat {
+		return errors.New("email already use by other")
+	}
+
+	u.NickName = name
+	repeat, err = u.IsNickNameRepeat()
+	if err != nil {
+		return err
+	}
+	if repeat {
+		return errors.New("nickname already use by other")
+	}
+
+	err = u.SetPassword(password)
+	if err != nil {
+		return err
+	}
+
+	u.Status = 1
+	u.Vip = 1
+	u.IsSuperAdmin = 1
+	u.ActivateTime = time.Now().Unix()
+	return u.InsertOne()
+}
+
+// Reset the password of super admin, all the login session will be destroy
+func adminResetPassword(name, password string) error {
+	u := new(model.User)
+	u.Name = name
+	exist, err := u.GetRaw()
+	if err != nil {
+		return err
+	}
+
+	if !exist {
+		return errors.New("user not found")
+	}
+
+	if u.IsSuperAdmin != 1 {
+		return errors.New("user is not super admin")
+	}
+
+	err = u.SetPassword(password)
+	if err != nil {
+		return err
+	}
+
+	err = u.UpdatePassword()
+	if err != nil {
+		return err
+	}
+
+	return session.FafaSessionMgr.DeleteUserToken(u.Id)
+}
@@ -2,7 +2,7 @@ package config
 
 import (
 	"encoding/json"
-	"github.com/hunterhug/fafacms/core/util/kv"
+	"github.com/hunterhug/fafacms/core/session"
 	"github.com/hunterhug/fafacms/core/util/mail"
 	"github.com/hunterhug/fafacms/core/util/oss"
 	"github.com/hunterhug/fafacms/core/util/rdb"
@@ -14,11 +14,12 @@ var (
 )
 
 type Config struct {
-	DefaultConfig MyConfig                   // default config
-	OssConfig     oss.Key                    // oss like aws s3
-	DbConfig      rdb.MyDbConfig             // mysql config
-	SessionConfig kv.MyRedisConf             // redis config for user session
-	MailConfig    mail.Sender `json:"Email"` // email config
+	DefaultConfig MyConfig              // default config
+	OssConfig     oss.Key               // oss like aws s3
+	DbConfig      rdb.MyDbConfig        // mysql config
+	SessionConfig session.MySessionConf // user session config, redis(default), memory or stateless
+	MailConfig    mail.Sender           `json:"Email"` // email config
+	CaptchaConfig MyCaptchaConfig       // which api need captcha
 }
 
 // Some especial my config
@@ -29,6 +30,23 @@ type MyConfig struct {
 	LogDebug      bool
 	StorageOss    bool
 	CloseRegister bool
+	SiteName      string // site title show in feed
+	SiteUrl       string // front end url like https://www.lenggirl.com, empty use the request host
+	Robots        string // robots.txt, empty use the default
+	StaticPath    string // static site output dir, empty is StoragePath_static
+	StaticTplPath string // dir of *.html redefine the static site template, empty use the default
+	ThemePath     string // dir of html themes, every sub dir is one, empty home api only return json
+	Theme         string // theme of the site, user can choose his own
+	Review        bool   // content need review before publish, node and group can change it
+	ReviewGroup   string // name of the group, users in it can review, super admin always can
+}
+
+// Captcha switch of api, default all close
+type MyCaptchaConfig struct {
+	Register           bool
+	ForgetPassword     bool
+	ResendActivateCode bool
+	LoginFailTimes     int // login fail so many times will need captcha, 0 not need
 }
 
 // Let the config struct to json file, just for test
@@ -0,0 +1,255 @@
+package controllers
+
+import (
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/hunterhug/fafacms/core/flog"
+	"github.com/hunterhug/fafacms/core/model"
+	"github.com/hunterhug/fafacms/core/session"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// brute force protection, count the fail by account and client ip, lock when too many
+const (
+	AttemptSceneLogin    = "login"
+	AttemptSceneReset    = "reset" // forget password and change password
+	AttemptSceneActivate = "activate"
+)
+
+var (
+	AttemptScenes = []string{AttemptSceneLogin, AttemptSceneReset, AttemptSceneActivate}
+
+	// fail so many times will lock, ip can be share by many people so bigger
+	AttemptAccountMaxFail = 5
+	AttemptIpMaxFail      = 20
+
+	// first lock seconds, double every time lock again
+	AttemptLockBase int64 = 60
+	AttemptLockMax  int64 = 24 * 3600
+
+	// fail times keep so long after the first fail
+	AttemptKeepTime int64 = 24 * 3600
+)
+
+func attemptKey(scene, kind, value string) string {
+	return fmt.Sprintf("ff_attempt_%s_%s_%s", scene, kind, strings.ToLower(value))
+}
+
+// lock until unix time keep here, fail times keep in the key
+func attemptLockKey(key string) string {
+	return key + "_lock"
+}
+
+type Attempt struct {
+	Scene   string
+	UserId  int64  // login count by user, so user name and email share the same
+	Account string // email, or user name not found
+	Ip      string
+}
+
+func NewAttempt(c *gin.Context, scene string, account string) *Attempt {
+	return &Attempt{Scene: scene, Account: account, Ip: c.ClientIP()}
+}
+
+type attemptTarget struct {
+	key     string
+	maxFail int
+}
+
+func (a *Attempt) accountKey() string {
+	if a.UserId != 0 {
+		return attemptKey(a.Scene, "user", strconv.FormatInt(a.UserId, 10))
+	}
+
+	if a.Account != "" {
+		return attemptKey(a.Scene, "account", a.Account)
+	}
+	return ""
+}
+
+func (a *Attempt) targets() []attemptTarget {
+	t := make([]attemptTarget, 0, 2)
+	if key := a.accountKey(); key != "" {
+		t = append(t, attemptTarget{key: key, maxFail: AttemptAccountMaxFail})
+	}
+	if a.Ip != "" {
+		t = append(t, attemptTarget{key: attemptKey(a.Scene, "ip", a.Ip), maxFail: AttemptIpMaxFail})
+	}
+	return t
+}
+
+func getAttemptInt(key string) int64 {
+	raw, exist, err := session.FafaKV.Get(key)
+	if err != nil {
+		flog.Log.Errorf("attempt get err:%s", err.Error())
+		return 0
+	}
+
+	if !exist {
+		return 0
+	}
+
+	n, _ := strconv.ParseInt(string(raw), 10, 64)
+	return n
+}
+
+// Remain lock seconds, 0 is not lock
+func (a *Attempt) Locked() (remain int64) {
+	now := time.Now().Unix()
+	for _, t := range a.targets() {
+		until := getAttemptInt(attemptLockKey(t.key))
+		if until-now > remain {
+			remain = until - now
+		}
+	}
+	return
+}
+
+// All fail times of account, success will clear it
+func (a *Attempt) FailTimes() int {
+	key := a.accountKey()
+	if key == "" {
+		return 0
+	}
+
+	return int(getAttemptInt(key))
+}
+
+// Record a fail, return remain lock seconds if reach the max fail.
+// Fail times incr atomic so guess at the same time can not pass the max,
+// every max fail lock once, and the lock time double every time.
+func (a *Attempt) Fail() (remain int64) {
+	now := time.Now().Unix()
+	for _, t := range a.targets() {
+		n, err := session.FafaKV.Incr(t.key, AttemptKeepTime)
+		if err != nil {
+			flog.Log.Errorf("attempt incr err:%s", err.Error())
+			continue
+		}
+
+		max := int64(t.maxFail)
+		if n%max != 0 {
+			continue
+		}
+
+		lock := AttemptLockMax
+		if times := n/max - 1; times < 32 {
+			lock = AttemptLockBase << uint(times)
+		}
+		if lock <= 0 || lock > AttemptLockMax {
+			lock = AttemptLockMax
+		}
+
+		err = session.FafaKV.Set(attemptLockKey(t.key), []byte(strconv.FormatInt(now+lock, 10)), lock)
+		if err != nil {
+			flog.Log.Errorf("attempt set err:%s", err.Error())
+		}
+
+		if lock > remain {
+			remain = lock
+		}
+	}
+	return
+}
+
+// Success will clear the account record, ip record not because attacker can use his own account to clear it
+func (a *Attempt) Success() {
+	key := a.accountKey()
+	if key == "" {
+		return
+	}
+
+	session.FafaKV.Delete(key)
+	session.FafaKV.Delete(attemptLockKey(key))
+}
+
+// Too many attempts error, data is the remain lock seconds
+func attemptLockError(resp *Resp, remain int64) {
+	resp.Error = Error(TooManyAttempts, fmt.Sprintf("%d seconds", remain))
+	resp.Data = remain
+}
+
+// Clear all the lock of account, user or ip
+func ClearAttempt(account string, userId int64, ip string) error {
+	for _, scene := range AttemptScenes {
+		keys := make([]string, 0, 3)
+		if account != "" {
+			keys = append(keys, attemptKey(scene, "account", account))
+		}
+
+		if userId != 0 {
+			keys = append(keys, attemptKey(scene, "user", strconv.FormatInt(userId, 10)))
+		}
+
+		if ip != "" {
+			keys = append(keys, attemptKey(scene, "ip", ip))
+		}
+
+		for _, key := range keys {
+			if err := session.FafaKV.Delete(key); err != nil {
+				return err
+			}
+			if err := session.FafaKV.Delete(attemptLockKey(key)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+type ClearAttemptRequest struct {
+	Account string `json:"account"` // user name or email
+	Ip      string `json:"ip"`
+}
+
+// Admin clear the lockout of account or ip
+func ClearAttemptAdmin(c *gin.Context) {
+	resp := new(Resp)
+	req := new(ClearAttemptRequest)
+	defer func() {
+		JSONL(c, 200, req, resp)
+	}()
+
+	if errResp := ParseJSON(c, req); errResp != nil {
+		resp.Error = errResp
+		return
+	}
+
+	if req.Account == "" && req.Ip == "" {
+		flog.Log.Errorf("ClearAttemptAdmin err: %s", "account or ip empty")
+		resp.Error = Error(ParasError, "account or ip empty")
+		return
+	}
+
+	// login count by user id, reset and activate count by email
+	account, userId := req.Account, int64(0)
+	if account != "" {
+		uu := new(model.User)
+		if strings.Contains(account, "@") {
+			uu.Email = account
+		} else {
+			uu.Name = account
+		}
+		ok, err := uu.GetRaw()
+		if err != nil {
+			flog.Log.Errorf("ClearAttemptAdmin err: %s", err.Error())
+			resp.Error = Error(DBError, err.Error())
+			return
+		}
+
+		if ok {
+			account, userId = uu.Email, uu.Id
+		}
+	}
+
+	err := ClearAttempt(account, userId, req.Ip)
+	if err != nil {
+		flog.Log.Errorf("ClearAttemptAdmin err: %s", err.Error())
+		resp.Error = Error(SetUserSessionError, err.Error())
+		return
+	}
+
+	resp.Flag = true
+}
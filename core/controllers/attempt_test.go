@@ -0,0 +1,71 @@
+package controllers
+
+import (
+	"github.com/hunterhug/fafacms/core/session"
+	"sync"
+	"testing"
+)
+
+func TestAttempt(t *testing.T) {
+	store, _ := session.NewMemoryStore("")
+	session.FafaKV = store
+
+	a := &Attempt{Scene: AttemptSceneLogin, Account: "Hunter", Ip: "127.0.0.1"}
+	for i := 1; i < AttemptAccountMaxFail; i++ {
+		if remain := a.Fail(); remain != 0 {
+			t.Fatalf("fail %d should not lock", i)
+		}
+	}
+
+	if remain := a.Fail(); remain != AttemptLockBase {
+		t.Fatalf("first lock should be %d, but %d", AttemptLockBase, remain)
+	}
+
+	// account ignore case
+	b := &Attempt{Scene: AttemptSceneLogin, Account: "hunter"}
+	if b.Locked() == 0 {
+		t.Fatal("account should be lock")
+	}
+
+	for i := 0; i < AttemptAccountMaxFail; i++ {
+		a.Fail()
+	}
+	if remain := b.Locked(); remain <= AttemptLockBase {
+		t.Fatalf("second lock should double, but %d", remain)
+	}
+
+	ClearAttempt("hunter", 0, "127.0.0.1")
+	if a.Locked() != 0 {
+		t.Fatal("lock should be clear")
+	}
+}
+
+func TestAttemptConcurrent(t *testing.T) {
+	store, _ := session.NewMemoryStore("")
+	session.FafaKV = store
+
+	// guess at the same time still count every one
+	var wg sync.WaitGroup
+	for i := 0; i < AttemptAccountMaxFail; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			(&Attempt{Scene: AttemptSceneLogin, UserId: 1}).Fail()
+		}()
+	}
+	wg.Wait()
+
+	a := &Attempt{Scene: AttemptSceneLogin, UserId: 1}
+	if a.Locked() == 0 {
+		t.Fatal("user should be lock")
+	}
+
+	if a.FailTimes() != AttemptAccountMaxFail {
+		t.Fatalf("fail times wrong: %d", a.FailTimes())
+	}
+
+	ClearAttempt("", 1, "")
+	if a.Locked() != 0 || a.FailTimes() != 0 {
+		t.Fatal("lock should be clear")
+	}
+}
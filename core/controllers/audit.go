@@ -0,0 +1,298 @@
+package controllers
+
+import (
+	"encoding/json"
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator"
+	"github.com/hunterhug/fafacms/core/flog"
+	"github.com/hunterhug/fafacms/core/model"
+	"github.com/hunterhug/fafacms/core/util"
+	"math"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+var (
+	// audit log write into db or not
+	AuditOpen = true
+
+	// keep log so many days, 0 keep forever
+	AuditKeepDays int64 = 90
+
+	// channel full will drop the log, not block the api
+	AuditChan = make(chan model.Log, 10000)
+
+	// insert when batch full or every flush time
+	AuditBatchSize     = 200
+	AuditFlushInterval = 5 * time.Second
+	AuditPruneInterval = time.Hour
+
+	// in and out too long will cut
+	AuditMaxBodyLength = 60000
+
+	auditDropped int64
+)
+
+// value of those keys will not write into log
+var (
+	auditSecretKeyContain = []string{"pass", "secret", "token", "challenge", "recovery"}
+	auditSecretKey        = map[string]bool{"code": true, "captcha_code": true}
+)
+
+const auditMask = "***"
+
+// Put into the audit channel, never block
+func SendToAudit(record model.Log) {
+	if !AuditOpen {
+		return
+	}
+
+	select {
+	case AuditChan <- record:
+	default:
+		atomic.AddInt64(&auditDropped, 1)
+	}
+}
+
+// How many log drop because channel full
+func AuditDropped() int64 {
+	return atomic.LoadInt64(&auditDropped)
+}
+
+func auditSecret(key string) bool {
+	key = strings.ToLower(key)
+	if auditSecretKey[key] {
+		return true
+	}
+
+	for _, v := range auditSecretKeyContain {
+		if strings.Contains(key, v) {
+			return true
+		}
+	}
+	return false
+}
+
+func auditHasObject(v interface{}) bool {
+	switch vv := v.(type) {
+	case map[string]interface{}:
+		return true
+	case []interface{}:
+		for _, i := range vv {
+			if auditHasObject(i) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func auditRedactValue(v interface{}) interface{} {
+	switch vv := v.(type) {
+	case map[string]interface{}:
+		for k, kv := range vv {
+			// object such as access tokens still keep, only mask the secret inside
+			if auditSecret(k) && !auditHasObject(kv) {
+				vv[k] = auditMask
+				continue
+			}
+			vv[k] = auditRedactValue(kv)
+		}
+	case []interface{}:
+		for i := range vv {
+			vv[i] = auditRedactValue(vv[i])
+		}
+	}
+	return v
+}
+
+// Mask the sensitive value such as password and code, secretData will mask all the data of response
+func auditRedact(obj interface{}, secretData bool) string {
+	raw, err := json.Marshal(obj)
+	if err != nil || len(raw) == 0 {
+		return ""
+	}
+
+	var v interface{}
+	if err := json.Unmarshal(raw, &v); err != nil {
+		return ""
+	}
+
+	if m, ok := v.(map[string]interface{}); ok && secretData {
+		if _, exist := m["data"]; exist {
+			m["data"] = auditMask
+		}
+	}
+
+	raw, _ = json.Marshal(auditRedactValue(v))
+	return util.CutBytes(string(raw), AuditMaxBodyLength)
+}
+
+func flushAudit(logs []model.Log) []model.Log {
+	if len(logs) == 0 {
+		return logs
+	}
+
+	err := model.InsertLogs(logs)
+	if err != nil {
+		flog.Log.Errorf("Audit insert %d log err: %s", len(logs), err.Error())
+	}
+	return logs[:0]
+}
+
+func pruneAudit() {
+	if AuditKeepDays <= 0 {
+		return
+	}
+
+	num, err := model.PruneLog(time.Now().Unix() - AuditKeepDays*24*3600)
+	if err != nil {
+		flog.Log.Errorf("Audit prune err: %s", err.Error())
+		return
+	}
+
+	if num > 0 {
+		flog.Log.Debugf("Audit prune %d log", num)
+	}
+}
+
+// Write the audit log by batch, and prune the old log
+func LoopAudit() {
+	flog.Log.Debugf("Audit start")
+	logs := make([]model.Log, 0, AuditBatchSize)
+	flush := time.NewTicker(AuditFlushInterval)
+	prune := time.NewTicker(AuditPruneInterval)
+	defer flush.Stop()
+	defer prune.Stop()
+
+	pruneAudit()
+	for {
+		select {
+		case v := <-AuditChan:
+			logs = append(logs, v)
+			if len(logs) >= AuditBatchSize {
+				logs = flushAudit(logs)
+			}
+		case <-flush.C:
+			logs = flushAudit(logs)
+		case <-prune.C:
+			pruneAudit()
+		}
+	}
+}
+
+type ListLogRequest struct {
+	Id           int64    `json:"id"`
+	UserId       int64    `json:"user_id"`
+	Url          string   `json:"url"`
+	Cid          string   `json:"cid"`
+	Ip           string   `json:"ip"`
+	ErrorId      string   `json:"error_id"`
+	Flag         int      `json:"flag" validate:"oneof=0 1 2"` // 1 success, 2 fail
+	LogTimeBegin int64    `json:"log_time_begin"`
+	LogTimeEnd   int64    `json:"log_time_end"`
+	Sort         []string `json:"sort"`
+	PageHelp
+}
+
+type ListLogResponse struct {
+	Logs    []model.Log `json:"logs"`
+	Dropped int64       `json:"dropped"` // log drop because write too slow
+	PageHelp
+}
+
+// Admin search the audit log
+func ListLog(c *gin.Context) {
+	resp := new(Resp)
+
+	respResult := new(ListLogResponse)
+	req := new(ListLogRequest)
+	defer func() {
+		// not log itself, or the log will be very big
+		JSON(c, 200, resp)
+	}()
+
+	if errResp := ParseJSON(c, req); errResp != nil {
+		resp.Error = errResp
+		return
+	}
+
+	var validate = validator.New()
+	err := validate.Struct(req)
+	if err != nil {
+		flog.Log.Errorf("ListLog err: %s", err.Error())
+		resp.Error = Error(ParasError, err.Error())
+		return
+	}
+
+	session := model.FaFaRdb.Client.NewSession()
+	defer session.Close()
+
+	session.Table(new(model.Log)).Where("1=1")
+
+	if req.Id != 0 {
+		session.And("id=?", req.Id)
+	}
+	if req.UserId != 0 {
+		session.And("user_id=?", req.UserId)
+	}
+	if req.Url != "" {
+		session.And("url=?", req.Url)
+	}
+	if req.Cid != "" {
+		session.And("cid=?", req.Cid)
+	}
+	if req.Ip != "" {
+		session.And("ip=?", req.Ip)
+	}
+	if req.ErrorId != "" {
+		session.And("error_id=?", req.ErrorId)
+	}
+
+	if req.Flag == 1 {
+		session.And("flag=?", 1)
+	} else if req.Flag == 2 {
+		session.And("flag=?", 0)
+	}
+
+	if req.LogTimeBegin > 0 {
+		session.And("log_time>=?", req.LogTimeBegin)
+	}
+	if req.LogTimeEnd > 0 {
+		session.And("log_time<?", req.LogTimeEnd)
+	}
+
+	countSession := session.Clone()
+	defer countSession.Close()
+	total, err := countSession.Count()
+	if err != nil {
+		flog.Log.Errorf("ListLog err:%s", err.Error())
+		resp.Error = Error(DBError, err.Error())
+		return
+	}
+
+	logs := make([]model.Log, 0)
+	p := &req.PageHelp
+	if total == 0 {
+		if p.Limit == 0 {
+			p.Limit = 20
+		}
+	} else {
+		p.build(session, req.Sort, model.LogSortName)
+		err = session.Find(&logs)
+		if err != nil {
+			flog.Log.Errorf("ListLog err:%s", err.Error())
+			resp.Error = Error(DBError, err.Error())
+			return
+		}
+	}
+
+	respResult.Logs = logs
+	respResult.Dropped = AuditDropped()
+	p.Pages = int(math.Ceil(float64(total) / float64(p.Limit)))
+	p.Total = int(total)
+	respResult.PageHelp = *p
+	resp.Data = respResult
+	resp.Flag = true
+}
@@ -0,0 +1,70 @@
+package controllers
+
+import (
+	"github.com/hunterhug/fafacms/core/model"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestAuditRedact(t *testing.T) {
+	req := map[string]interface{}{
+		"name":         "hunterhug",
+		"pass_wd":      "123456",
+		"code":         "654321",
+		"captcha_code": "ABCDE",
+		"hash_code":    "keep",
+		"access_tokens": []map[string]interface{}{
+			{"id": 1, "token_prefix": "ff_abc"},
+		},
+	}
+
+	out := auditRedact(req, false)
+	for _, secret := range []string{"123456", "654321", "ABCDE", "ff_abc"} {
+		if strings.Contains(out, secret) {
+			t.Fatalf("secret %s in log: %s", secret, out)
+		}
+	}
+
+	for _, keep := range []string{"hunterhug", "keep", `"id":1`} {
+		if !strings.Contains(out, keep) {
+			t.Fatalf("%s should keep: %s", keep, out)
+		}
+	}
+
+	resp := &Resp{Flag: true, Data: "1_token_value"}
+	out = auditRedact(resp, true)
+	if strings.Contains(out, "token_value") {
+		t.Fatalf("token in log: %s", out)
+	}
+}
+
+func TestAuditRedactCut(t *testing.T) {
+	old := AuditMaxBodyLength
+	defer func() {
+		AuditMaxBodyLength = old
+	}()
+
+	// {"name":"中文"} cut in the middle of a char
+	AuditMaxBodyLength = 12
+	out := auditRedact(map[string]interface{}{"name": "中文"}, false)
+	if !utf8.ValidString(out) || len(out) > AuditMaxBodyLength {
+		t.Fatalf("cut wrong: %q", out)
+	}
+}
+
+func TestSendToAuditDrop(t *testing.T) {
+	old := AuditChan
+	defer func() {
+		AuditChan = old
+	}()
+
+	AuditChan = make(chan model.Log, 1)
+	before := AuditDropped()
+	SendToAudit(model.Log{Url: "/a"})
+	SendToAudit(model.Log{Url: "/b"})
+
+	if AuditDropped()-before != 1 {
+		t.Fatalf("dropped should be 1, now %d", AuditDropped()-before)
+	}
+}
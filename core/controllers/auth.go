@@ -6,6 +6,7 @@ import (
 	"github.com/hunterhug/fafacms/core/flog"
 	"github.com/hunterhug/fafacms/core/model"
 	"github.com/hunterhug/fafacms/core/session"
+	"strings"
 )
 
 // every API which need auth should take a HTTP header `Auth`
@@ -15,8 +16,8 @@ var (
 	// if you want skip auth you can set it true
 	AuthDebug = false
 
-	// those api will be check resource
-	AdminUrl map[string]int64
+	// every api is a resource, will be check by the rules of user groups
+	RouteResources map[string]RouteResource
 
 	// can only single login, one token gen will destroy other tokens
 	SingleLogin bool
@@ -51,14 +52,14 @@ var AuthFilter = func(c *gin.Context) {
 		return
 	}
 
-	// root user can ignore next auth
-	if nowUser.Id == -1 {
-		return
-	}
-
-	// admin user is skr
-	if nowUser.Name == "admin" {
-		return
+	// personal access token can only access the url its scopes allow
+	if v, exist := c.Get("accessTokenScopes"); exist {
+		url := c.Request.URL.Path
+		if !AccessTokenScopeAllow(v.([]string), UrlScope[url]) {
+			flog.Log.Errorf("filter err: access token scope not allow %s", url)
+			resp.Error = Error(AccessTokenScopeNotAllow, UrlScope[url])
+			return
+		}
 	}
 
 	// not active will be refuse
@@ -75,37 +76,22 @@ var AuthFilter = func(c *gin.Context) {
 		return
 	}
 
-	// resource is exist
-	//r := new(model.Resource)
-	url := c.Request.URL.Path
-	//r.Url, _ = util2.Sha256([]byte(url))
-	//r.Admin = true
-	//
-	//// resource not found can skip auth
-	//if err := r.Get(); err != nil {
-	//	flog.Log.Debugf("resource found url:%s, auth err:%s", url, err.Error())
-	//	return
-	//}
-
-	// resource not found can skip auth
-	resourceId, exist := AdminUrl[url]
-	if !exist {
+	// super admin create by command line can ignore next auth
+	if nowUser.IsSuperAdmin == 1 {
 		return
 	}
 
-	// if group has this resource
-	gr := new(model.GroupResource)
-	gr.GroupId = nowUser.GroupId
-	gr.ResourceId = resourceId
-	exist, err = model.FaFaRdb.Client.Exist(gr)
+	// every route is a resource, check the rules of user groups
+	url := c.Request.URL.Path
+	allow, err := CheckPermission(nowUser, url, c.Request.Method)
 	if err != nil {
 		flog.Log.Errorf("filter err:%s", err.Error())
 		resp.Error = Error(DBError, err.Error())
 		return
 	}
 
-	// resource not found in group will be refuse
-	if !exist {
+	// resource not allow in groups will be refuse
+	if !allow {
 		flog.Log.Errorf("filter err:%s", "resource not allow")
 		resp.Error = Error(UserAuthPermit, "resource not allow")
 		return
@@ -121,7 +107,19 @@ func GetUserSession(c *gin.Context) (*model.User, error) {
 
 	// get token from HTTP header and check if it is exist
 	token := c.GetHeader(AuthHeader)
-	user, err := session.FafaSessionMgr.CheckAndSetToken(token, SessionExpireTime)
+
+	var user *model.User
+	var err error
+	if strings.HasPrefix(token, AccessTokenPrefix) {
+		// personal access token for script
+		user, err = GetUserByAccessToken(c, token)
+	} else {
+		user, err = session.FafaSessionMgr.CheckAndSetToken(token, SessionExpireTime)
+		if err == nil {
+			// record where the device last seen, error not important
+			session.FafaSessionMgr.TouchToken(token, c.ClientIP(), c.Request.UserAgent())
+		}
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -131,17 +129,30 @@ func GetUserSession(c *gin.Context) (*model.User, error) {
 	return user, nil
 }
 
-func SetUserSession(user *model.User) (string, error) {
+func SetUserSession(c *gin.Context, user *model.User) (string, error) {
 	if user == nil {
 		return "", errors.New("user nil")
 	}
 
+	info := session.TokenInfo{
+		Ip: c.ClientIP(),
+		Ua: c.Request.UserAgent(),
+	}
+
+	token, err := session.FafaSessionMgr.SetToken(user, SessionExpireTime, info)
+	if err != nil {
+		return "", err
+	}
+
+	// token return in data, audit log must not record it
+	c.Set("auditSecretData", true)
+
 	// single login
-	// we only allow one token exist, other token will be delete.
+	// we only allow one token exist, other device will be logout.
 	if SingleLogin {
-		session.FafaSessionMgr.DeleteUserToken(user.Id)
+		session.FafaSessionMgr.DeleteUserTokenExcept(user.Id, token)
 	}
-	return session.FafaSessionMgr.SetToken(user, SessionExpireTime)
+	return token, nil
 }
 
 func DeleteUserSession(c *gin.Context) error {
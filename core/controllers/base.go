@@ -35,15 +35,33 @@ const (
 	GroupHasResourceHookIn              = 100042
 	GroupHasUserHookIn                  = 100043
 	ResourceCountNumNotRight            = 100050
+	ResourceAlreadyExist                = 100051
+	ResourceNotFound                    = 100052
+	ResourceHasGroupHookIn              = 100053
+	AccessTokenNotFound                 = 100060
+	AccessTokenScopeNotAllow            = 100061
+	TwoFactorRequired                   = 100070
+	TwoFactorCodeWrong                  = 100071
+	TwoFactorChallengeNotFound          = 100072
+	TwoFactorAlreadyEnable              = 100073
+	TwoFactorNotEnable                  = 100074
+	UserSessionNotFound                 = 100080
+	TooManyAttempts                     = 100090
+	CaptchaWrong                        = 100091
 	UploadFileError                     = 100100
 	UploadFileTypeNotPermit             = 100101
 	UploadFileTooMaxLimit               = 100102
+	ThemeNotFound                       = 100110
 	ContentNodeSeoAlreadyBeUsed         = 101000
 	ContentNodeNotFound                 = 101001
 	ContentParentNodeNotFound           = 101002
 	ContentNodeSortConflict             = 101003
 	ContentNodeHasChildren              = 101004
 	ContentNodeHasContentCanNotDelete   = 101005
+	ContentNodeMoveIntoChild            = 101006
+	ContentNodeTooDeep                  = 101007
+	CollaboratorNotFound                = 101010
+	CollaboratorCanNotBeSelf            = 101011
 	ContentNotFound                     = 110000
 	ContentPasswordWrong                = 110001
 	ContentBanPermit                    = 110002
@@ -56,6 +74,19 @@ const (
 	CommentBanPermit                    = 110009
 	CommentClose                        = 110010
 	GlobalMessageNotFound               = 110011
+	TagNotFound                         = 110012
+	TagNameAlreadyBeUsed                = 110013
+	ContentScheduleTimeWrong            = 110014
+	ContentRevisionConflict             = 110015
+	ContentExportDoing                  = 110016
+	ContentExportNotFound               = 110017
+	ContentExportNotDone                = 110018
+	StaticBuildDoing                    = 110019
+	ContentNeedReview                   = 110020
+	ContentReviewWaiting                = 110021
+	ContentReviewNotWaiting             = 110022
+	ContentReviewCanNotBeSelf           = 110023
+	ContentReviewerPermit               = 110024
 	AddUserCacheError                   = 120000
 	DeleteUserCacheError                = 120001
 	RefreshUserCacheError               = 120002
@@ -113,15 +144,33 @@ var ErrorMap = map[int]string{
 	GroupHasResourceHookIn:              "group has resource hook in",
 	GroupHasUserHookIn:                  "group has user hook in",
 	ResourceCountNumNotRight:            "resource count not right",
+	ResourceAlreadyExist:                "resource already exist",
+	ResourceNotFound:                    "resource not found",
+	ResourceHasGroupHookIn:              "resource has group hook in",
+	AccessTokenNotFound:                 "access token not found",
+	AccessTokenScopeNotAllow:            "access token scope not allow",
+	TwoFactorRequired:                   "two factor code required, use the challenge in data",
+	TwoFactorCodeWrong:                  "two factor code wrong",
+	TwoFactorChallengeNotFound:          "two factor challenge not found or expired",
+	TwoFactorAlreadyEnable:              "two factor already enable",
+	TwoFactorNotEnable:                  "two factor not enable",
+	UserSessionNotFound:                 "user session not found",
+	TooManyAttempts:                     "too many attempts, locked, try again after",
+	CaptchaWrong:                        "captcha wrong or expired, get a new one",
 	UploadFileError:                     "upload file err",
 	UploadFileTypeNotPermit:             "upload file type not permit",
 	UploadFileTooMaxLimit:               "upload file too max limit",
+	ThemeNotFound:                       "theme not found",
 	ContentNodeSeoAlreadyBeUsed:         "content node seo already be used",
 	ContentNodeNotFound:                 "content node not found",
 	ContentParentNodeNotFound:           "parent content node not found",
 	ContentNodeSortConflict:             "content node sort conflict",
 	ContentNodeHasChildren:              "content node has children",
 	ContentNodeHasContentCanNotDelete:   "content node has content can not delete",
+	ContentNodeMoveIntoChild:            "content node can not move into its children",
+	ContentNodeTooDeep:                  "content node too deep",
+	CollaboratorNotFound:                "collaborator not found",
+	CollaboratorCanNotBeSelf:            "collaborator can not be yourself",
 	ContentNotFound:                     "content not found",
 	ContentBanPermit:                    "content ban permit",
 	ContentPasswordWrong:                "content password wrong",
@@ -134,6 +183,19 @@ var ErrorMap = map[int]string{
 	CommentBanPermit:                    "comment ban permit",
 	CommentClose:                        "content close comment",
 	GlobalMessageNotFound:               "global message not found",
+	TagNotFound:                         "tag not found",
+	TagNameAlreadyBeUsed:                "tag name already be used, can merge",
+	ContentScheduleTimeWrong:            "content schedule time wrong",
+	ContentRevisionConflict:             "content already change by other, revision not match",
+	ContentExportDoing:                  "content export is doing, wait it finish",
+	ContentExportNotFound:               "content export not found",
+	ContentExportNotDone:                "content export not done",
+	StaticBuildDoing:                    "static site is building, wait it finish",
+	ContentNeedReview:                   "content need review before publish, submit it",
+	ContentReviewWaiting:                "content already waiting review",
+	ContentReviewNotWaiting:             "content not waiting review",
+	ContentReviewCanNotBeSelf:           "can not review your own content",
+	ContentReviewerPermit:               "you are not reviewer",
 	SystemProblem:                       "system problem",
 	DbNotFound:                          "db not found",
 	DbRepeat:                            "db repeat data",
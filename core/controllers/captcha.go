@@ -0,0 +1,120 @@
+package controllers
+
+import (
+	"bytes"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/hunterhug/fafacms/core/flog"
+	"github.com/hunterhug/fafacms/core/session"
+	"github.com/hunterhug/fafacms/core/util"
+	"io/ioutil"
+)
+
+var (
+	// captcha valid in 60 seconds, delete after check whether right or not
+	CaptchaExpireTime int64 = 60
+	CaptchaLength           = 5
+
+	// those api must take a captcha
+	CaptchaUrl map[string]bool
+
+	// login fail so many times will need captcha, 0 not need
+	CaptchaLoginFailTimes int
+)
+
+func captchaKey(id string) string {
+	return fmt.Sprintf("ff_captcha_%s", id)
+}
+
+type Captcha struct {
+	Id     string `json:"id"`
+	Image  string `json:"image"` // png base64, can be direct put in <img src>
+	Expire int64  `json:"expire"`
+}
+
+// Get a new captcha
+func NewCaptcha(c *gin.Context) {
+	resp := new(Resp)
+	defer func() {
+		JSON(c, 200, resp)
+	}()
+
+	code, err := util.GenCaptchaCode(CaptchaLength)
+	if err != nil {
+		flog.Log.Errorf("NewCaptcha err: %s", err.Error())
+		resp.Error = Error(Unknown, err.Error())
+		return
+	}
+
+	raw, err := util.CaptchaPNG(code)
+	if err != nil {
+		flog.Log.Errorf("NewCaptcha err: %s", err.Error())
+		resp.Error = Error(Unknown, err.Error())
+		return
+	}
+
+	id := util.GetGUID()
+	err = session.FafaKV.Set(captchaKey(id), []byte(code), CaptchaExpireTime)
+	if err != nil {
+		flog.Log.Errorf("NewCaptcha err: %s", err.Error())
+		resp.Error = Error(SetUserSessionError, err.Error())
+		return
+	}
+
+	resp.Data = Captcha{
+		Id:     id,
+		Image:  "data:image/png;base64," + base64.StdEncoding.EncodeToString(raw),
+		Expire: CaptchaExpireTime,
+	}
+	resp.Flag = true
+}
+
+// Check the captcha, it can only use once
+func CheckCaptcha(id string, code string) bool {
+	if id == "" || code == "" {
+		return false
+	}
+
+	raw, exist, err := session.FafaKV.Get(captchaKey(id))
+	if err != nil {
+		flog.Log.Errorf("CheckCaptcha err: %s", err.Error())
+		return false
+	}
+
+	if !exist {
+		return false
+	}
+
+	session.FafaKV.Delete(captchaKey(id))
+	return subtle.ConstantTimeCompare(raw, []byte(code)) == 1
+}
+
+// api which need captcha take those field in json body
+type CaptchaRequest struct {
+	CaptchaId   string `json:"captcha_id"`
+	CaptchaCode string `json:"captcha_code"`
+}
+
+// Captcha filter, the url in CaptchaUrl must take a right captcha
+var CaptchaFilter = func(c *gin.Context) {
+	if !CaptchaUrl[c.Request.URL.Path] {
+		return
+	}
+
+	// read the body then put it back, the api will read it again
+	raw, _ := ioutil.ReadAll(c.Request.Body)
+	c.Request.Body = ioutil.NopCloser(bytes.NewBuffer(raw))
+
+	req := new(CaptchaRequest)
+	json.Unmarshal(raw, req)
+	if !CheckCaptcha(req.CaptchaId, req.CaptchaCode) {
+		flog.Log.Errorf("filter err: captcha wrong %s", c.Request.URL.Path)
+		resp := new(Resp)
+		resp.Error = Error(CaptchaWrong, "")
+		c.AbortWithStatusJSON(200, resp)
+		return
+	}
+}
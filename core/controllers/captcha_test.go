@@ -0,0 +1,26 @@
+package controllers
+
+import (
+	"github.com/hunterhug/fafacms/core/session"
+	"testing"
+)
+
+func TestCheckCaptcha(t *testing.T) {
+	store, _ := session.NewMemoryStore("")
+	session.FafaKV = store
+
+	store.Set(captchaKey("a"), []byte("12345"), CaptchaExpireTime)
+	if CheckCaptcha("a", "54321") {
+		t.Fatal("wrong code should not pass")
+	}
+
+	// delete after check whether right or not
+	if CheckCaptcha("a", "12345") {
+		t.Fatal("captcha can only check once")
+	}
+
+	store.Set(captchaKey("b"), []byte("12345"), CaptchaExpireTime)
+	if !CheckCaptcha("b", "12345") {
+		t.Fatal("right code should pass")
+	}
+}
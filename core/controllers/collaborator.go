@@ -0,0 +1,352 @@
+package controllers
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator"
+	"github.com/hunterhug/fafacms/core/flog"
+	"github.com/hunterhug/fafacms/core/model"
+	"math"
+)
+
+// Role of the user on the node share to him, the owner not use it
+func hasCollaboratorRole(userId int64, nodeId int64, role int) (bool, error) {
+	r, ok, err := model.CollaboratorRole(userId, nodeId)
+	if err != nil || !ok {
+		return false, err
+	}
+	return r >= role, nil
+}
+
+// Content is yours or under the node share to you with the role, not enough role same as not found.
+// After the user id of content is the owner, write with it.
+func getContentWithRole(content *model.Content, userId int64, role int) (bool, error) {
+	content.UserId = 0
+	exist, err := content.Get()
+	if err != nil || !exist || content.UserId == userId {
+		return exist, err
+	}
+
+	content.EditUserId = userId
+	return hasCollaboratorRole(userId, content.NodeId, role)
+}
+
+// Same as content, the node share to you or its parent share to you
+func getNodeWithRole(node *model.ContentNode, userId int64, role int) (bool, error) {
+	node.UserId = 0
+	node.Seo = ""
+	exist, err := node.Get()
+	if err != nil || !exist || node.UserId == userId {
+		return exist, err
+	}
+	return hasCollaboratorRole(userId, node.Id, role)
+}
+
+// History of content is yours or the content of it you can see, role on the node now not the old one of history
+func getHistoryWithRole(history *model.ContentHistory, userId int64, role int) (bool, error) {
+	history.UserId = 0
+	exist, err := history.GetRaw()
+	if err != nil || !exist || history.UserId == userId {
+		return exist, err
+	}
+
+	content := new(model.Content)
+	content.Id = history.ContentId
+	return getContentWithRole(content, userId, role)
+}
+
+// The user of content write, the owner of content and tag
+func contentOwner(content *model.Content) *model.User {
+	return &model.User{Id: content.UserId, Name: content.UserName}
+}
+
+type AddCollaboratorRequest struct {
+	NodeId   int64  `json:"node_id" validate:"required"`
+	UserId   int64  `json:"user_id"`
+	UserName string `json:"user_name"`
+	Role     int    `json:"role" validate:"oneof=0 1 2"` // 0 viewer, 1 editor, 2 publisher
+}
+
+// Share the node to other user, add again will change the role
+func AddCollaborator(c *gin.Context) {
+	resp := new(Resp)
+	req := new(AddCollaboratorRequest)
+	defer func() {
+		JSONL(c, 200, req, resp)
+	}()
+
+	if errResp := ParseJSON(c, req); errResp != nil {
+		resp.Error = errResp
+		return
+	}
+
+	var validate = validator.New()
+	err := validate.Struct(req)
+	if err != nil {
+		flog.Log.Errorf("AddCollaborator err: %s", err.Error())
+		resp.Error = Error(ParasError, err.Error())
+		return
+	}
+
+	if req.UserId == 0 && req.UserName == "" {
+		flog.Log.Errorf("AddCollaborator err: %s", "user info empty")
+		resp.Error = Error(ParasError, "user info empty")
+		return
+	}
+
+	uu, err := GetUserSession(c)
+	if err != nil {
+		flog.Log.Errorf("AddCollaborator err: %s", err.Error())
+		resp.Error = Error(GetUserSessionError, err.Error())
+		return
+	}
+
+	n := new(model.ContentNode)
+	n.Id = req.NodeId
+	n.UserId = uu.Id
+	exist, err := n.Get()
+	if err != nil {
+		flog.Log.Errorf("AddCollaborator err: %s", err.Error())
+		resp.Error = Error(DBError, err.Error())
+		return
+	}
+
+	if !exist {
+		flog.Log.Errorf("AddCollaborator err: %s", "content node not found")
+		resp.Error = Error(ContentNodeNotFound, "")
+		return
+	}
+
+	who := new(model.User)
+	who.Id = req.UserId
+	who.Name = req.UserName
+	ok, err := who.GetActivateRaw()
+	if err != nil {
+		flog.Log.Errorf("AddCollaborator err: %s", err.Error())
+		resp.Error = Error(DBError, err.Error())
+		return
+	}
+
+	if !ok {
+		flog.Log.Errorf("AddCollaborator err: %s", "user not found")
+		resp.Error = Error(UserNotFound, "")
+		return
+	}
+
+	if who.Id == uu.Id {
+		flog.Log.Errorf("AddCollaborator err: %s", "can not be self")
+		resp.Error = Error(CollaboratorCanNotBeSelf, "")
+		return
+	}
+
+	nc := new(model.NodeCollaborator)
+	nc.NodeId = n.Id
+	nc.CollaboratorId = who.Id
+	exist, err = nc.Get()
+	if err != nil {
+		flog.Log.Errorf("AddCollaborator err: %s", err.Error())
+		resp.Error = Error(DBError, err.Error())
+		return
+	}
+
+	if exist {
+		if nc.Role != req.Role {
+			nc.Role = req.Role
+			err = nc.UpdateRole()
+		}
+	} else {
+		nc.UserId = uu.Id
+		nc.UserName = uu.Name
+		nc.CollaboratorName = who.Name
+		nc.Role = req.Role
+		err = nc.Insert()
+		if err == nil {
+			go model.CollaboratorAdd(uu.Id, who.Id, n.Id, n.Name)
+		}
+	}
+
+	if err != nil {
+		flog.Log.Errorf("AddCollaborator err: %s", err.Error())
+		resp.Error = Error(DBError, err.Error())
+		return
+	}
+
+	resp.Data = nc
+	resp.Flag = true
+}
+
+type DeleteCollaboratorRequest struct {
+	Id int64 `json:"id" validate:"required"`
+}
+
+// The owner remove the collaborator, or the collaborator leave
+func DeleteCollaborator(c *gin.Context) {
+	resp := new(Resp)
+	req := new(DeleteCollaboratorRequest)
+	defer func() {
+		JSONL(c, 200, req, resp)
+	}()
+
+	if errResp := ParseJSON(c, req); errResp != nil {
+		resp.Error = errResp
+		return
+	}
+
+	var validate = validator.New()
+	err := validate.Struct(req)
+	if err != nil {
+		flog.Log.Errorf("DeleteCollaborator err: %s", err.Error())
+		resp.Error = Error(ParasError, err.Error())
+		return
+	}
+
+	uu, err := GetUserSession(c)
+	if err != nil {
+		flog.Log.Errorf("DeleteCollaborator err: %s", err.Error())
+		resp.Error = Error(GetUserSessionError, err.Error())
+		return
+	}
+
+	nc := new(model.NodeCollaborator)
+	nc.Id = req.Id
+	exist, err := nc.Get()
+	if err != nil {
+		flog.Log.Errorf("DeleteCollaborator err: %s", err.Error())
+		resp.Error = Error(DBError, err.Error())
+		return
+	}
+
+	if !exist || (nc.UserId != uu.Id && nc.CollaboratorId != uu.Id) {
+		flog.Log.Errorf("DeleteCollaborator err: %s", "collaborator not found")
+		resp.Error = Error(CollaboratorNotFound, "")
+		return
+	}
+
+	err = nc.Delete()
+	if err != nil {
+		flog.Log.Errorf("DeleteCollaborator err: %s", err.Error())
+		resp.Error = Error(DBError, err.Error())
+		return
+	}
+
+	resp.Flag = true
+}
+
+type ListCollaboratorRequest struct {
+	NodeId         int64    `json:"node_id"`
+	CollaboratorId int64    `json:"collaborator_id"`
+	Role           int      `json:"role" validate:"oneof=-1 0 1 2"`
+	Shared         bool     `json:"shared"` // true list the node other share to you, false who you share to
+	Sort           []string `json:"sort"`
+	PageHelp
+}
+
+type ListCollaboratorResponse struct {
+	Collaborators []model.NodeCollaborator `json:"collaborators"`
+	Nodes         map[int64]Node           `json:"nodes"`
+	PageHelp
+}
+
+func ListCollaborator(c *gin.Context) {
+	resp := new(Resp)
+
+	respResult := new(ListCollaboratorResponse)
+	req := new(ListCollaboratorRequest)
+	defer func() {
+		JSONL(c, 200, req, resp)
+	}()
+
+	if errResp := ParseJSON(c, req); errResp != nil {
+		resp.Error = errResp
+		return
+	}
+
+	var validate = validator.New()
+	err := validate.Struct(req)
+	if err != nil {
+		flog.Log.Errorf("ListCollaborator err: %s", err.Error())
+		resp.Error = Error(ParasError, err.Error())
+		return
+	}
+
+	uu, err := GetUserSession(c)
+	if err != nil {
+		flog.Log.Errorf("ListCollaborator err: %s", err.Error())
+		resp.Error = Error(GetUserSessionError, err.Error())
+		return
+	}
+
+	session := model.FaFaRdb.Client.NewSession()
+	defer session.Close()
+
+	session.Table(new(model.NodeCollaborator)).Where("1=1")
+
+	if req.Shared {
+		session.And("collaborator_id=?", uu.Id)
+	} else {
+		session.And("user_id=?", uu.Id)
+		if req.CollaboratorId != 0 {
+			session.And("collaborator_id=?", req.CollaboratorId)
+		}
+	}
+
+	if req.NodeId != 0 {
+		session.And("node_id=?", req.NodeId)
+	}
+
+	if req.Role != -1 {
+		session.And("role=?", req.Role)
+	}
+
+	countSession := session.Clone()
+	defer countSession.Close()
+	total, err := countSession.Count()
+	if err != nil {
+		flog.Log.Errorf("ListCollaborator err:%s", err.Error())
+		resp.Error = Error(DBError, err.Error())
+		return
+	}
+
+	cs := make([]model.NodeCollaborator, 0)
+	p := &req.PageHelp
+	if total == 0 {
+		if p.Limit == 0 {
+			p.Limit = 20
+		}
+	} else {
+		p.build(session, req.Sort, model.NodeCollaboratorSortName)
+		err = session.Find(&cs)
+		if err != nil {
+			flog.Log.Errorf("ListCollaborator err:%s", err.Error())
+			resp.Error = Error(DBError, err.Error())
+			return
+		}
+	}
+
+	// the node info, the shared one need it to visit
+	nodeIds := make([]int64, 0, len(cs))
+	for _, v := range cs {
+		nodeIds = append(nodeIds, v.NodeId)
+	}
+
+	respResult.Nodes = make(map[int64]Node, len(nodeIds))
+	if len(nodeIds) > 0 {
+		nodes := make([]model.ContentNode, 0)
+		err = model.FaFaRdb.Client.In("id", nodeIds).Find(&nodes)
+		if err != nil {
+			flog.Log.Errorf("ListCollaborator err:%s", err.Error())
+			resp.Error = Error(DBError, err.Error())
+			return
+		}
+
+		for _, v := range nodes {
+			respResult.Nodes[v.Id] = NodeOf(v)
+		}
+	}
+
+	respResult.Collaborators = cs
+	p.Pages = int(math.Ceil(float64(total) / float64(p.Limit)))
+	p.Total = int(total)
+	respResult.PageHelp = *p
+	resp.Data = respResult
+	resp.Flag = true
+}
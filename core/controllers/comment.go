@@ -277,6 +277,7 @@ func CreateComment(c *gin.Context) {
 		go model.CommentForComment(uu.Id, newComment.CommentUserId, content.Id, content.Title, newComment.Id, newComment.Describe, req.Anonymous)
 	}
 	go model.CommentForComment(uu.Id, content.UserId, content.Id, content.Title, newComment.Id, newComment.Describe, req.Anonymous)
+	SendToSearch(model.SearchComment, newComment.Id)
 	resp.Data = newComment.Id
 	resp.Flag = true
 }
@@ -333,6 +334,7 @@ func DeleteComment(c *gin.Context) {
 		return
 	}
 
+	SendToSearch(model.SearchComment, comment.Id)
 	resp.Flag = true
 }
 
@@ -629,10 +631,12 @@ func ListHomeComment(c *gin.Context) {
 	respResult := new(ListCommentResponse)
 	req := new(ListHomeCommentRequest)
 	defer func() {
-		JSONL(c, 200, req, resp)
+		if !ThemeHTML(c, "comment.html", req, resp) {
+			JSONL(c, 200, req, resp)
+		}
 	}()
 
-	if errResp := ParseJSON(c, req); errResp != nil {
+	if errResp := ParseQuery(c, req); errResp != nil {
 		resp.Error = errResp
 		return
 	}
@@ -643,6 +647,16 @@ func ListHomeComment(c *gin.Context) {
 		return
 	}
 
+	// comment page use the theme of the content owner
+	if ThemeWant(c) {
+		var userId int64
+		_, err := model.FaFaRdb.Client.Table(new(model.Content)).Where("id=?", req.ContentId).Cols("user_id").Get(&userId)
+		if err != nil {
+			flog.Log.Errorf("ListHomeComment err: %s", err.Error())
+		}
+		themeUser(c, userId, "")
+	}
+
 	// new query list session
 	session := model.FaFaRdb.Client.NewSession()
 	defer session.Close()
@@ -964,6 +978,7 @@ func BadComment(c *gin.Context) {
 			if err != nil {
 				flog.Log.Errorf("BadComment ban err: %s", err.Error())
 			}
+			SendToSearch(model.SearchComment, cc.Id)
 		}
 		resp.Data = "+"
 	}
@@ -1022,6 +1037,7 @@ func UpdateComment(c *gin.Context) {
 			resp.Error = Error(DBError, err.Error())
 		}
 
+		SendToSearch(model.SearchComment, comment.Id)
 	}
 	resp.Flag = true
 }
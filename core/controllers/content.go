@@ -1,23 +1,26 @@
 package controllers
 
 import (
+	"fmt"
 	"github.com/gin-gonic/gin"
 	"github.com/go-playground/validator"
 	"github.com/hunterhug/fafacms/core/flog"
 	"github.com/hunterhug/fafacms/core/model"
+	"github.com/hunterhug/fafacms/core/util"
 	"math"
 )
 
 type CreateContentRequest struct {
-	Seo          string `json:"seo" validate:"omitempty,alphanumunicode"` // unique mark in user's content
-	Title        string `json:"title" validate:"required"`                // content's title
-	Status       int    `json:"status" validate:"oneof=0 1"`              // 1 stand for content hide in front end, 0 show.
-	Top          int    `json:"top" validate:"oneof=0 1"`                 // 1 stand for let content on the top
-	Describe     string `json:"describe" validate:"omitempty"`            // content's body
-	ImagePath    string `json:"image_path" validate:"omitempty"`          // picture
-	NodeId       int64  `json:"node_id"`                                  // node
-	Password     string `json:"password"`                                 // if not empty will need a password in front end
-	CloseComment int    `json:"close_comment" validate:"oneof=0 1"`       // 0 stand for open comment, 1 close comment
+	Seo          string   `json:"seo" validate:"omitempty,alphanumunicode"` // unique mark in user's content
+	Title        string   `json:"title" validate:"required"`                // content's title
+	Status       int      `json:"status" validate:"oneof=0 1"`              // 1 stand for content hide in front end, 0 show.
+	Top          int      `json:"top" validate:"oneof=0 1"`                 // 1 stand for let content on the top
+	Describe     string   `json:"describe" validate:"omitempty"`            // content's body
+	ImagePath    string   `json:"image_path" validate:"omitempty"`          // picture
+	NodeId       int64    `json:"node_id"`                                  // node
+	Password     string   `json:"password"`                                 // if not empty will need a password in front end
+	CloseComment int      `json:"close_comment" validate:"oneof=0 1"`       // 0 stand for open comment, 1 close comment
+	Tags         []string `json:"tags"`                                     // tag names, not exist will create
 }
 
 func CreateContent(c *gin.Context) {
@@ -59,37 +62,15 @@ func CreateContent(c *gin.Context) {
 		return
 	}
 
-	content := new(model.Content)
-	content.UserId = uu.Id
-	if req.Seo != "" {
-		content.Seo = req.Seo
-		exist, err := content.CheckSeoValid()
-		if err != nil {
-			flog.Log.Errorf("CreateContent err: %s", err.Error())
-			resp.Error = Error(DBError, err.Error())
-			return
-		}
-		if exist {
-			flog.Log.Errorf("CreateContent err: %s", "seo repeat")
-			resp.Error = Error(ContentSeoAlreadyBeUsed, "")
-			return
-		}
-	} else {
-		resp.Error = Error(ParasError, "seo can not empty")
-		return
-	}
-
 	if req.NodeId == 0 {
 		flog.Log.Errorf("CreateContent err: %s", "node_id can not empty")
 		resp.Error = Error(ParasError, "node_id can not empty")
 		return
 	}
 
-	content.NodeId = req.NodeId
 	contentNode := new(model.ContentNode)
 	contentNode.Id = req.NodeId
-	contentNode.UserId = uu.Id
-	exist, err := contentNode.Get()
+	exist, err := getNodeWithRole(contentNode, uu.Id, model.CollaboratorEditor)
 	if err != nil {
 		flog.Log.Errorf("CreateContent err: %s", err.Error())
 		resp.Error = Error(DBError, "")
@@ -102,6 +83,30 @@ func CreateContent(c *gin.Context) {
 		return
 	}
 
+	// content in the node share to you belong to the owner of node
+	content := new(model.Content)
+	content.UserId = contentNode.UserId
+	content.UserName = contentNode.UserName
+	content.EditUserId = uu.Id
+	content.NodeId = req.NodeId
+	if req.Seo != "" {
+		content.Seo = req.Seo
+		exist, err := content.CheckSeoValid()
+		if err != nil {
+			flog.Log.Errorf("CreateContent err: %s", err.Error())
+			resp.Error = Error(DBError, err.Error())
+			return
+		}
+		if exist {
+			flog.Log.Errorf("CreateContent err: %s", "seo repeat")
+			resp.Error = Error(ContentSeoAlreadyBeUsed, "")
+			return
+		}
+	} else {
+		resp.Error = Error(ParasError, "seo can not empty")
+		return
+	}
+
 	content.NodeSeo = contentNode.Seo
 
 	if req.ImagePath != "" {
@@ -128,8 +133,8 @@ func CreateContent(c *gin.Context) {
 	content.Password = req.Password
 	content.CloseComment = req.CloseComment
 	content.Top = req.Top
-	content.UserName = uu.Name
 	content.SortNum, _ = content.CountNumUnderNode()
+	content.Revision = 1
 	_, err = content.Insert()
 	if err != nil {
 		flog.Log.Errorf("CreateContent err:%s", err.Error())
@@ -137,14 +142,25 @@ func CreateContent(c *gin.Context) {
 		return
 	}
 
+	if len(req.Tags) > 0 {
+		tags, errResp := setContentTags(contentOwner(content), content.Id, req.Tags)
+		if errResp != nil {
+			flog.Log.Errorf("CreateContent err:%s", errResp.Error())
+			resp.Error = errResp
+			return
+		}
+		content.Tags = tags
+	}
+
 	resp.Data = content
 	resp.Flag = true
 }
 
 // update SEO
 type UpdateSeoOfContentRequest struct {
-	Id  int64  `json:"id" validate:"required"`
-	Seo string `json:"seo" validate:"required,alphanumunicode"`
+	Id       int64  `json:"id" validate:"required"`
+	Seo      string `json:"seo" validate:"required,alphanumunicode"`
+	Revision int64  `json:"revision" validate:"required"` // the revision you saw, not match return the revision now
 }
 
 func UpdateSeoOfContent(c *gin.Context) {
@@ -176,8 +192,7 @@ func UpdateSeoOfContent(c *gin.Context) {
 
 	contentBefore := new(model.Content)
 	contentBefore.Id = req.Id
-	contentBefore.UserId = uu.Id
-	exist, err := contentBefore.Get()
+	exist, err := getContentWithRole(contentBefore, uu.Id, model.CollaboratorEditor)
 	if err != nil {
 		flog.Log.Errorf("UpdateSeoOfContent err: %s", err.Error())
 		resp.Error = Error(DBError, err.Error())
@@ -192,7 +207,7 @@ func UpdateSeoOfContent(c *gin.Context) {
 
 	content := new(model.Content)
 	content.Id = req.Id
-	content.UserId = uu.Id
+	content.UserId = contentBefore.UserId
 	if req.Seo != contentBefore.Seo {
 		content.Seo = req.Seo
 		exist, err := content.CheckSeoValid()
@@ -207,12 +222,14 @@ func UpdateSeoOfContent(c *gin.Context) {
 			return
 		}
 
+		content.Revision = req.Revision
 		_, err = content.UpdateSeo()
 		if err != nil {
-			flog.Log.Errorf("UpdateSeoOfContent err:%s", err.Error())
-			resp.Error = Error(DBError, err.Error())
+			contentWriteError(resp, "UpdateSeoOfContent", req.Id, err)
 			return
 		}
+
+		resp.Data = ContentRevisionResponse{Revision: content.Revision}
 	}
 	resp.Flag = true
 }
@@ -221,6 +238,7 @@ func UpdateSeoOfContent(c *gin.Context) {
 type UpdateImageOfContentRequest struct {
 	Id        int64  `json:"id" validate:"required"`
 	ImagePath string `json:"image_path" validate:"required"`
+	Revision  int64  `json:"revision" validate:"required"`
 }
 
 func UpdateImageOfContent(c *gin.Context) {
@@ -252,8 +270,7 @@ func UpdateImageOfContent(c *gin.Context) {
 
 	contentBefore := new(model.Content)
 	contentBefore.Id = req.Id
-	contentBefore.UserId = uu.Id
-	exist, err := contentBefore.Get()
+	exist, err := getContentWithRole(contentBefore, uu.Id, model.CollaboratorEditor)
 	if err != nil {
 		flog.Log.Errorf("UpdateImageOfContent err: %s", err.Error())
 		resp.Error = Error(DBError, err.Error())
@@ -268,7 +285,7 @@ func UpdateImageOfContent(c *gin.Context) {
 
 	content := new(model.Content)
 	content.Id = req.Id
-	content.UserId = uu.Id
+	content.UserId = contentBefore.UserId
 	if req.ImagePath != contentBefore.ImagePath {
 		p := new(model.File)
 		p.Url = req.ImagePath
@@ -286,12 +303,14 @@ func UpdateImageOfContent(c *gin.Context) {
 		}
 
 		content.ImagePath = req.ImagePath
+		content.Revision = req.Revision
 		_, err = content.UpdateImage()
 		if err != nil {
-			flog.Log.Errorf("UpdateImageOfContent err:%s", err.Error())
-			resp.Error = Error(DBError, err.Error())
+			contentWriteError(resp, "UpdateImageOfContent", req.Id, err)
 			return
 		}
+
+		resp.Data = ContentRevisionResponse{Revision: content.Revision}
 	}
 	resp.Flag = true
 }
@@ -355,13 +374,16 @@ func UpdateStatusOfContentAdmin(c *gin.Context) {
 		go SendToLoop(contentBefore.UserId, 0, 3)
 
 	}
+	SendToSearch(model.SearchContent, req.Id)
+	RefreshSitemap()
 	resp.Flag = true
 }
 
 // user update the status of content, 0 normal, 1 hide
 type UpdateStatusOfContentRequest struct {
-	Id     int64 `json:"id" validate:"required"`
-	Status int   `json:"status" validate:"oneof=0 1"`
+	Id       int64 `json:"id" validate:"required"`
+	Status   int   `json:"status" validate:"oneof=0 1"`
+	Revision int64 `json:"revision" validate:"required"`
 }
 
 func UpdateStatusOfContent(c *gin.Context) {
@@ -393,8 +415,7 @@ func UpdateStatusOfContent(c *gin.Context) {
 
 	contentBefore := new(model.Content)
 	contentBefore.Id = req.Id
-	contentBefore.UserId = uu.Id
-	exist, err := contentBefore.Get()
+	exist, err := getContentWithRole(contentBefore, uu.Id, model.CollaboratorPublisher)
 	if err != nil {
 		flog.Log.Errorf("UpdateStatusOfContent err: %s", err.Error())
 		resp.Error = Error(DBError, err.Error())
@@ -421,27 +442,32 @@ func UpdateStatusOfContent(c *gin.Context) {
 
 	content := new(model.Content)
 	content.Id = req.Id
-	content.UserId = uu.Id
+	content.UserId = contentBefore.UserId
 	if req.Status != contentBefore.Status {
 		content.Status = req.Status
+		content.Revision = req.Revision
 		_, err = content.UpdateStatus(true, false)
 		if err != nil {
-			flog.Log.Errorf("UpdateStatusOfContent err:%s", err.Error())
-			resp.Error = Error(DBError, err.Error())
+			contentWriteError(resp, "UpdateStatusOfContent", req.Id, err)
 			return
 		}
 
+		resp.Data = ContentRevisionResponse{Revision: content.Revision}
+
 		go SendToLoop(contentBefore.UserId, 0, 1)
 		go SendToLoop(contentBefore.UserId, contentBefore.NodeId, 2)
 		go SendToLoop(contentBefore.UserId, 0, 3)
 	}
+	SendToSearch(model.SearchContent, req.Id)
+	RefreshSitemap()
 	resp.Flag = true
 }
 
 // update the node of content
 type UpdateNodesOfContentRequest struct {
-	Id     int64 `json:"id" validate:"required"`
-	NodeId int64 `json:"node_id" validate:"required"`
+	Id       int64 `json:"id" validate:"required"`
+	NodeId   int64 `json:"node_id" validate:"required"`
+	Revision int64 `json:"revision" validate:"required"`
 }
 
 func UpdateNodeOfContent(c *gin.Context) {
@@ -473,8 +499,7 @@ func UpdateNodeOfContent(c *gin.Context) {
 
 	contentBefore := new(model.Content)
 	contentBefore.Id = req.Id
-	contentBefore.UserId = uu.Id
-	exist, err := contentBefore.Get()
+	exist, err := getContentWithRole(contentBefore, uu.Id, model.CollaboratorPublisher)
 	if err != nil {
 		flog.Log.Errorf("UpdateNodeOfContent err: %s", err.Error())
 		resp.Error = Error(DBError, err.Error())
@@ -490,14 +515,15 @@ func UpdateNodeOfContent(c *gin.Context) {
 	if req.NodeId != contentBefore.NodeId {
 		contentNode := new(model.ContentNode)
 		contentNode.Id = req.NodeId
-		contentNode.UserId = uu.Id
-		exist, err := contentNode.Get()
+		exist, err := getNodeWithRole(contentNode, uu.Id, model.CollaboratorPublisher)
 		if err != nil {
 			flog.Log.Errorf("UpdateNodeOfContent err: %s", err.Error())
 			resp.Error = Error(DBError, "")
 			return
 		}
-		if !exist {
+
+		// content can only move between the node of its owner
+		if !exist || contentNode.UserId != contentBefore.UserId {
 			flog.Log.Errorf("UpdateNodeOfContent err: %s", "node not found")
 			resp.Error = Error(ContentNodeNotFound, "")
 			return
@@ -505,27 +531,30 @@ func UpdateNodeOfContent(c *gin.Context) {
 
 		content := new(model.Content)
 		content.Id = req.Id
-		content.UserId = uu.Id
+		content.UserId = contentBefore.UserId
 		content.NodeId = req.NodeId
 		content.NodeSeo = contentNode.Seo
 		content.SortNum = contentBefore.SortNum
+		content.Revision = req.Revision
 		err = content.UpdateNode(contentBefore.NodeId)
 		if err != nil {
-			flog.Log.Errorf("UpdateNodeOfContent err:%s", err.Error())
-			resp.Error = Error(DBError, err.Error())
+			contentWriteError(resp, "UpdateNodeOfContent", req.Id, err)
 			return
 		}
 
-		go SendToLoop(uu.Id, contentBefore.NodeId, 2)
-		go SendToLoop(uu.Id, req.NodeId, 2)
+		resp.Data = ContentRevisionResponse{Revision: content.Revision}
+
+		go SendToLoop(contentBefore.UserId, contentBefore.NodeId, 2)
+		go SendToLoop(contentBefore.UserId, req.NodeId, 2)
 	}
 	resp.Flag = true
 }
 
 // update the top of content
 type UpdateTopOfContentRequest struct {
-	Id  int64 `json:"id" validate:"required"`
-	Top int   `json:"top" validate:"oneof=0 1"`
+	Id       int64 `json:"id" validate:"required"`
+	Top      int   `json:"top" validate:"oneof=0 1"`
+	Revision int64 `json:"revision" validate:"required"`
 }
 
 func UpdateTopOfContent(c *gin.Context) {
@@ -557,8 +586,7 @@ func UpdateTopOfContent(c *gin.Context) {
 
 	contentBefore := new(model.Content)
 	contentBefore.Id = req.Id
-	contentBefore.UserId = uu.Id
-	exist, err := contentBefore.Get()
+	exist, err := getContentWithRole(contentBefore, uu.Id, model.CollaboratorEditor)
 	if err != nil {
 		flog.Log.Errorf("UpdateTopOfContent err: %s", err.Error())
 		resp.Error = Error(DBError, err.Error())
@@ -573,15 +601,17 @@ func UpdateTopOfContent(c *gin.Context) {
 
 	content := new(model.Content)
 	content.Id = req.Id
-	content.UserId = uu.Id
+	content.UserId = contentBefore.UserId
 	if req.Top != contentBefore.Top {
 		content.Top = req.Top
+		content.Revision = req.Revision
 		_, err = content.UpdateTop()
 		if err != nil {
-			flog.Log.Errorf("UpdateTopOfContent err:%s", err.Error())
-			resp.Error = Error(DBError, err.Error())
+			contentWriteError(resp, "UpdateTopOfContent", req.Id, err)
 			return
 		}
+
+		resp.Data = ContentRevisionResponse{Revision: content.Revision}
 	}
 	resp.Flag = true
 }
@@ -590,6 +620,7 @@ func UpdateTopOfContent(c *gin.Context) {
 type UpdateTopOfCommentRequest struct {
 	Id           int64 `json:"id" validate:"required"`
 	CloseComment int   `json:"close_comment" validate:"oneof=0 1"`
+	Revision     int64 `json:"revision" validate:"required"`
 }
 
 func UpdateCommentOfContent(c *gin.Context) {
@@ -621,8 +652,7 @@ func UpdateCommentOfContent(c *gin.Context) {
 
 	contentBefore := new(model.Content)
 	contentBefore.Id = req.Id
-	contentBefore.UserId = uu.Id
-	exist, err := contentBefore.Get()
+	exist, err := getContentWithRole(contentBefore, uu.Id, model.CollaboratorEditor)
 	if err != nil {
 		flog.Log.Errorf("UpdateCommentOfContent err: %s", err.Error())
 		resp.Error = Error(DBError, err.Error())
@@ -637,15 +667,17 @@ func UpdateCommentOfContent(c *gin.Context) {
 
 	content := new(model.Content)
 	content.Id = req.Id
-	content.UserId = uu.Id
+	content.UserId = contentBefore.UserId
 	if req.CloseComment != contentBefore.CloseComment {
 		content.CloseComment = req.CloseComment
-		_, err = content.UpdateTop()
+		content.Revision = req.Revision
+		_, err = content.UpdateComment()
 		if err != nil {
-			flog.Log.Errorf("UpdateCommentOfContent err:%s", err.Error())
-			resp.Error = Error(DBError, err.Error())
+			contentWriteError(resp, "UpdateCommentOfContent", req.Id, err)
 			return
 		}
+
+		resp.Data = ContentRevisionResponse{Revision: content.Revision}
 	}
 	resp.Flag = true
 }
@@ -654,6 +686,7 @@ func UpdateCommentOfContent(c *gin.Context) {
 type UpdatePasswordOfContentRequest struct {
 	Id       int64  `json:"id" validate:"required"`
 	Password string `json:"password"`
+	Revision int64  `json:"revision" validate:"required"`
 }
 
 func UpdatePasswordOfContent(c *gin.Context) {
@@ -685,8 +718,7 @@ func UpdatePasswordOfContent(c *gin.Context) {
 
 	contentBefore := new(model.Content)
 	contentBefore.Id = req.Id
-	contentBefore.UserId = uu.Id
-	exist, err := contentBefore.Get()
+	exist, err := getContentWithRole(contentBefore, uu.Id, model.CollaboratorEditor)
 	if err != nil {
 		flog.Log.Errorf("UpdatePasswordOfContent err: %s", err.Error())
 		resp.Error = Error(DBError, err.Error())
@@ -701,25 +733,31 @@ func UpdatePasswordOfContent(c *gin.Context) {
 
 	content := new(model.Content)
 	content.Id = req.Id
-	content.UserId = uu.Id
+	content.UserId = contentBefore.UserId
 	if req.Password != contentBefore.Password {
 		content.Password = req.Password
+		content.Revision = req.Revision
 		_, err = content.UpdatePassword()
 		if err != nil {
-			flog.Log.Errorf("UpdatePasswordOfContent err:%s", err.Error())
-			resp.Error = Error(DBError, err.Error())
+			contentWriteError(resp, "UpdatePasswordOfContent", req.Id, err)
 			return
 		}
+
+		resp.Data = ContentRevisionResponse{Revision: content.Revision}
 	}
+	SendToSearch(model.SearchContent, req.Id)
+	RefreshSitemap()
 	resp.Flag = true
 }
 
 // update the body and title of content
 type UpdateInfoOfContentRequest struct {
-	Id       int64  `json:"id" validate:"required"`
-	Title    string `json:"title" validate:"required"`
-	Describe string `json:"describe" validate:"omitempty"`
-	Save     bool   `json:"save"`
+	Id       int64    `json:"id" validate:"required"`
+	Title    string   `json:"title" validate:"required"`
+	Describe string   `json:"describe" validate:"omitempty"`
+	Save     bool     `json:"save"`
+	Tags     []string `json:"tags"` // null not change, [] clear the tags
+	Revision int64    `json:"revision" validate:"required"`
 }
 
 func UpdateInfoOfContent(c *gin.Context) {
@@ -763,8 +801,7 @@ func UpdateInfoOfContent(c *gin.Context) {
 
 	contentBefore := new(model.Content)
 	contentBefore.Id = req.Id
-	contentBefore.UserId = uu.Id
-	exist, err := contentBefore.Get()
+	exist, err := getContentWithRole(contentBefore, uu.Id, model.CollaboratorEditor)
 	if err != nil {
 		flog.Log.Errorf("UpdateInfoOfContent err: %s", err.Error())
 		resp.Error = Error(DBError, "")
@@ -777,22 +814,39 @@ func UpdateInfoOfContent(c *gin.Context) {
 		return
 	}
 
-	if contentBefore.PreDescribe != req.Describe || contentBefore.PreTitle != req.Title {
+	if contentBefore.PreDescribe != req.Describe || contentBefore.PreTitle != req.Title || req.Tags != nil {
 		content := new(model.Content)
 		content.Id = req.Id
-		content.UserId = uu.Id
+		content.UserId = contentBefore.UserId
+		content.UserName = contentBefore.UserName
 		content.NodeId = contentBefore.NodeId
 		content.PreDescribe = contentBefore.PreDescribe
 		content.PreTitle = contentBefore.PreTitle
 		content.PreFlush = contentBefore.PreFlush
 		content.Describe = req.Describe
 		content.Title = req.Title
+		content.EditUserId = uu.Id
+		content.Revision = req.Revision
+		if req.Tags != nil {
+			content.Tags, err = model.CleanTagNames(req.Tags)
+			if err != nil {
+				flog.Log.Errorf("UpdateInfoOfContent err:%s", err.Error())
+				resp.Error = Error(ParasError, err.Error())
+				return
+			}
+		}
+
+		// draft and tags write together with the revision
 		err = content.UpdateDescribeAndHistory(req.Save)
 		if err != nil {
-			flog.Log.Errorf("UpdateInfoOfContent err:%s", err.Error())
-			resp.Error = Error(DBError, err.Error())
+			contentWriteError(resp, "UpdateInfoOfContent", req.Id, err)
 			return
 		}
+
+		resp.Data = ContentRevisionResponse{Revision: content.Revision}
+		if req.Tags != nil {
+			go SendToLoop(contentBefore.UserId, 0, 4)
+		}
 	}
 	resp.Flag = true
 }
@@ -841,8 +895,7 @@ func SortContent(c *gin.Context) {
 
 	x := new(model.Content)
 	x.Id = req.XID
-	x.UserId = uu.Id
-	exist, err := x.Get()
+	exist, err := getContentWithRole(x, uu.Id, model.CollaboratorEditor)
 	if err != nil {
 		flog.Log.Errorf("SortContent err: %s", err.Error())
 		resp.Error = Error(DBError, err.Error())
@@ -868,7 +921,7 @@ func SortContent(c *gin.Context) {
 		}
 
 		// contents sort_num small than x will be add 1, because x is the top.
-		_, err = session.Exec("update fafacms_content SET sort_num=sort_num+1 where sort_num < ? and user_id = ? and node_id = ?", x.SortNum, uu.Id, x.NodeId)
+		_, err = session.Exec("update fafacms_content SET sort_num=sort_num+1 where sort_num < ? and user_id = ? and node_id = ?", x.SortNum, x.UserId, x.NodeId)
 		if err != nil {
 			session.Rollback()
 			flog.Log.Errorf("SortContent err: %s", err.Error())
@@ -877,7 +930,7 @@ func SortContent(c *gin.Context) {
 		}
 
 		// x now is the top, it's sort_num is 0
-		_, err = session.Exec("update fafacms_content SET sort_num=0 where user_id = ? and node_id = ? and id = ?", uu.Id, x.NodeId, x.Id)
+		_, err = session.Exec("update fafacms_content SET sort_num=0 where user_id = ? and node_id = ? and id = ?", x.UserId, x.NodeId, x.Id)
 		if err != nil {
 			session.Rollback()
 			flog.Log.Errorf("SortContent err: %s", err.Error())
@@ -898,7 +951,7 @@ func SortContent(c *gin.Context) {
 
 	y := new(model.Content)
 	y.Id = req.YID
-	y.UserId = uu.Id
+	y.UserId = x.UserId
 	exist, err = y.Get()
 	if err != nil {
 		flog.Log.Errorf("SortContent err: %s", err.Error())
@@ -928,7 +981,7 @@ func SortContent(c *gin.Context) {
 		return
 	}
 
-	_, err = session.Exec("update fafacms_content SET sort_num=sort_num-1 where sort_num > ? and user_id = ? and node_id = ?", x.SortNum, uu.Id, x.NodeId)
+	_, err = session.Exec("update fafacms_content SET sort_num=sort_num-1 where sort_num > ? and user_id = ? and node_id = ?", x.SortNum, x.UserId, x.NodeId)
 	if err != nil {
 		session.Rollback()
 		flog.Log.Errorf("SortContent err: %s", err.Error())
@@ -936,7 +989,7 @@ func SortContent(c *gin.Context) {
 		return
 	}
 
-	_, err = session.Exec("update fafacms_content SET sort_num=sort_num+1 where sort_num > ? and user_id = ? and node_id = ?", y.SortNum, uu.Id, y.NodeId)
+	_, err = session.Exec("update fafacms_content SET sort_num=sort_num+1 where sort_num > ? and user_id = ? and node_id = ?", y.SortNum, x.UserId, y.NodeId)
 	if err != nil {
 		session.Rollback()
 		flog.Log.Errorf("SortContent err: %s", err.Error())
@@ -945,9 +998,9 @@ func SortContent(c *gin.Context) {
 	}
 
 	if y.SortNum > x.SortNum {
-		_, err = session.Exec("update fafacms_content SET sort_num=? where user_id = ? and id = ?", y.SortNum, uu.Id, x.Id)
+		_, err = session.Exec("update fafacms_content SET sort_num=? where user_id = ? and id = ?", y.SortNum, x.UserId, x.Id)
 	} else {
-		_, err = session.Exec("update fafacms_content SET sort_num=? where user_id = ? and id = ?", y.SortNum+1, uu.Id, x.Id)
+		_, err = session.Exec("update fafacms_content SET sort_num=? where user_id = ? and id = ?", y.SortNum+1, x.UserId, x.Id)
 	}
 	if err != nil {
 		session.Rollback()
@@ -968,7 +1021,8 @@ func SortContent(c *gin.Context) {
 }
 
 type PublishContentRequest struct {
-	Id int64 `json:"id" validate:"required"`
+	Id       int64 `json:"id" validate:"required"`
+	Revision int64 `json:"revision" validate:"required"`
 }
 
 func PublishContent(c *gin.Context) {
@@ -1000,8 +1054,7 @@ func PublishContent(c *gin.Context) {
 
 	content := new(model.Content)
 	content.Id = req.Id
-	content.UserId = uu.Id
-	exist, err := content.Get()
+	exist, err := getContentWithRole(content, uu.Id, model.CollaboratorPublisher)
 	if err != nil {
 		flog.Log.Errorf("PublishContent err: %s", err.Error())
 		resp.Error = Error(DBError, err.Error())
@@ -1019,26 +1072,50 @@ func PublishContent(c *gin.Context) {
 		return
 	}
 
-	err = content.PublishDescribe()
+	// need review submit it, approve will publish
+	need, err := reviewNeeded(uu, content)
 	if err != nil {
 		flog.Log.Errorf("PublishContent err: %s", err.Error())
 		resp.Error = Error(DBError, err.Error())
 		return
 	}
 
+	if need {
+		flog.Log.Errorf("PublishContent err: %s", "need review")
+		resp.Error = Error(ContentNeedReview, "")
+		return
+	}
+
+	content.Revision = req.Revision
+	err = content.PublishDescribe()
+	if err != nil {
+		contentWriteError(resp, "PublishContent", req.Id, err)
+		return
+	}
+
+	resp.Data = ContentRevisionResponse{Revision: content.Revision}
+
+	afterPublishContent(content)
+	resp.Flag = true
+}
+
+// first publish tell the followers and count again, publish again tell the followers update
+func afterPublishContent(content *model.Content) {
 	if content.Version == 1 {
-		go model.PublishContent(uu.Id, 0, content.Id, content.Title, false)
-		go SendToLoop(uu.Id, 0, 1)
-		go SendToLoop(uu.Id, content.NodeId, 2)
+		go model.PublishContent(content.UserId, 0, content.Id, content.Title, false)
+		go SendToLoop(content.UserId, 0, 1)
+		go SendToLoop(content.UserId, content.NodeId, 2)
 	} else {
-		go model.PublishContent(uu.Id, 0, content.Id, content.Title, true)
+		go model.PublishContent(content.UserId, 0, content.Id, content.Title, true)
 	}
-	resp.Flag = true
+	SendToSearch(model.SearchContent, content.Id)
+	RefreshSitemap()
 }
 
 type RestoreContentRequest struct {
 	HistoryId int64 `json:"history_id" validate:"required"`
 	Save      bool  `json:"save"`
+	Revision  int64 `json:"revision" validate:"required"`
 }
 
 func RestoreContent(c *gin.Context) {
@@ -1070,7 +1147,6 @@ func RestoreContent(c *gin.Context) {
 
 	contentH := new(model.ContentHistory)
 	contentH.Id = req.HistoryId
-	contentH.UserId = uu.Id
 	exist, err := contentH.GetRaw()
 	if err != nil {
 		flog.Log.Errorf("RestoreContent err: %s", err.Error())
@@ -1086,8 +1162,7 @@ func RestoreContent(c *gin.Context) {
 
 	content := new(model.Content)
 	content.Id = contentH.ContentId
-	content.UserId = uu.Id
-	exist, err = content.Get()
+	exist, err = getContentWithRole(content, uu.Id, model.CollaboratorEditor)
 	if err != nil {
 		flog.Log.Errorf("RestoreContent err: %s", err.Error())
 		resp.Error = Error(DBError, err.Error())
@@ -1102,12 +1177,14 @@ func RestoreContent(c *gin.Context) {
 
 	content.Title = contentH.Title
 	content.Describe = contentH.Describe
+	content.Revision = req.Revision
 	err = content.ResetDescribe(req.Save)
 	if err != nil {
-		flog.Log.Errorf("RestoreContent err: %s", err.Error())
-		resp.Error = Error(DBError, "")
+		contentWriteError(resp, "RestoreContent", content.Id, err)
 		return
 	}
+
+	resp.Data = ContentRevisionResponse{Revision: content.Revision}
 	resp.Flag = true
 }
 
@@ -1131,6 +1208,7 @@ type ListContentRequest struct {
 	FirstPublishTimeEnd   int64    `json:"first_publish_time_end"`
 	PublishTimeBegin      int64    `json:"publish_time_begin"`
 	PublishTimeEnd        int64    `json:"publish_time_end"`
+	Render                bool     `json:"render"` // true return the html, toc and excerpt, not the raw describe
 	Sort                  []string `json:"sort"`
 	PageHelp
 }
@@ -1180,6 +1258,22 @@ func ListContentHelper(c *gin.Context, userId int64) {
 		return
 	}
 
+	// list the node share to you, the contents belong to the owner of node
+	if userId != 0 && req.NodeId != 0 {
+		n := new(model.ContentNode)
+		n.Id = req.NodeId
+		exist, err := getNodeWithRole(n, userId, model.CollaboratorViewer)
+		if err != nil {
+			flog.Log.Errorf("ListContent err: %s", err.Error())
+			resp.Error = Error(DBError, err.Error())
+			return
+		}
+
+		if exist {
+			userId = n.UserId
+		}
+	}
+
 	// new query list session
 	session := model.FaFaRdb.Client.NewSession()
 	defer session.Close()
@@ -1308,12 +1402,23 @@ func ListContentHelper(c *gin.Context, userId int64) {
 		// sql build
 		p.build(session, req.Sort, model.ContentSortName)
 		// do query
-		err = session.Omit("describe", "pre_describe").Find(&cs)
+		if req.Render {
+			err = session.Omit("pre_describe").Find(&cs)
+		} else {
+			err = session.Omit("describe", "pre_describe", "describe_html", "toc").Find(&cs)
+		}
 		if err != nil {
 			flog.Log.Errorf("ListContent err:%s", err.Error())
 			resp.Error = Error(DBError, err.Error())
 			return
 		}
+
+		if req.Render {
+			for k := range cs {
+				cs[k].RenderIfNeed()
+				cs[k].Describe = ""
+			}
+		}
 	}
 
 	// result
@@ -1328,6 +1433,7 @@ func ListContentHelper(c *gin.Context, userId int64) {
 type ListContentHistoryRequest struct {
 	Id              int64    `json:"content_id"`
 	UserId          int64    `json:"user_id"`
+	EditUserId      int64    `json:"edit_user_id"` // who make the change
 	Types           int      `json:"types" validate:"oneof=-1 0 1 2"`
 	CreateTimeBegin int64    `json:"create_time_begin"`
 	CreateTimeEnd   int64    `json:"create_time_end"`
@@ -1380,6 +1486,22 @@ func ListContentHistoryHelper(c *gin.Context, userId int64) {
 		return
 	}
 
+	// history of content under the node share to you
+	if userId != 0 && req.Id != 0 {
+		content := new(model.Content)
+		content.Id = req.Id
+		exist, err := getContentWithRole(content, userId, model.CollaboratorViewer)
+		if err != nil {
+			flog.Log.Errorf("ListContentHistory err: %s", err.Error())
+			resp.Error = Error(DBError, err.Error())
+			return
+		}
+
+		if exist {
+			userId = content.UserId
+		}
+	}
+
 	// new query list session
 	session := model.FaFaRdb.Client.NewSession()
 	defer session.Close()
@@ -1399,6 +1521,10 @@ func ListContentHistoryHelper(c *gin.Context, userId int64) {
 		}
 	}
 
+	if req.EditUserId != 0 {
+		session.And("edit_user_id=?", req.EditUserId)
+	}
+
 	if req.Types != -1 {
 		session.And("types=?", req.Types)
 	}
@@ -1475,8 +1601,12 @@ func TakeContentHelper(c *gin.Context, userId int64) {
 
 	content := new(model.Content)
 	content.Id = req.Id
-	content.UserId = userId
-	exist, err := content.Get()
+	var exist bool
+	if userId != 0 {
+		exist, err = getContentWithRole(content, userId, model.CollaboratorViewer)
+	} else {
+		exist, err = content.Get()
+	}
 	if err != nil {
 		flog.Log.Errorf("TakeContent err: %s", err.Error())
 		resp.Error = Error(DBError, err.Error())
@@ -1489,6 +1619,13 @@ func TakeContentHelper(c *gin.Context, userId int64) {
 		return
 	}
 
+	content.Tags, err = model.ContentTagNames(content.Id)
+	if err != nil {
+		flog.Log.Errorf("TakeContent err: %s", err.Error())
+		resp.Error = Error(DBError, err.Error())
+		return
+	}
+
 	resp.Data = content
 	resp.Flag = true
 }
@@ -1537,8 +1674,12 @@ func TakeContentHistoryHelper(c *gin.Context, userId int64) {
 
 	content := new(model.ContentHistory)
 	content.Id = req.Id
-	content.UserId = userId
-	exist, err := content.GetRaw()
+	var exist bool
+	if userId != 0 {
+		exist, err = getHistoryWithRole(content, userId, model.CollaboratorViewer)
+	} else {
+		exist, err = content.GetRaw()
+	}
 	if err != nil {
 		flog.Log.Errorf("TakeContentHistory err: %s", err.Error())
 		resp.Error = Error(DBError, err.Error())
@@ -1573,8 +1714,165 @@ func TakeContentHistoryAdmin(c *gin.Context) {
 	TakeContentHistoryHelper(c, 0)
 }
 
+type DiffContentHistoryRequest struct {
+	Id          int64 `json:"id" validate:"required"`              // old side, a history
+	CompareId   int64 `json:"compare_id"`                          // new side history id, compare_type 0 need
+	CompareType int   `json:"compare_type" validate:"oneof=0 1 2"` // 0 another history, 1 the draft of content, 2 the publish of content
+	Word        bool  `json:"word"`                                // change line diff by word too
+}
+
+type DiffContentSide struct {
+	Name       string `json:"name"`
+	Id         int64  `json:"id,omitempty"`
+	ContentId  int64  `json:"content_id"`
+	Title      string `json:"title"`
+	Types      int    `json:"types"`
+	Version    int    `json:"version"`
+	CreateTime int64  `json:"create_time,omitempty"`
+}
+
+type DiffContentHistoryResponse struct {
+	Old         DiffContentSide `json:"old"`
+	New         DiffContentSide `json:"new"`
+	TitleChange bool            `json:"title_change"`
+	util.Diff
+	Unified string `json:"unified"`
+}
+
+func DiffContentHistoryHelper(c *gin.Context, userId int64) {
+	resp := new(Resp)
+	req := new(DiffContentHistoryRequest)
+	defer func() {
+		JSONL(c, 200, req, resp)
+	}()
+
+	if errResp := ParseJSON(c, req); errResp != nil {
+		resp.Error = errResp
+		return
+	}
+
+	var validate = validator.New()
+	err := validate.Struct(req)
+	if err != nil {
+		flog.Log.Errorf("DiffContentHistory err: %s", err.Error())
+		resp.Error = Error(ParasError, err.Error())
+		return
+	}
+
+	if req.CompareType == 0 && req.CompareId == 0 {
+		flog.Log.Errorf("DiffContentHistory err: %s", "compare_id empty")
+		resp.Error = Error(ParasError, "compare_id empty")
+		return
+	}
+
+	oldH := new(model.ContentHistory)
+	oldH.Id = req.Id
+	var exist bool
+	if userId != 0 {
+		exist, err = getHistoryWithRole(oldH, userId, model.CollaboratorViewer)
+	} else {
+		exist, err = oldH.GetRaw()
+	}
+	if err != nil {
+		flog.Log.Errorf("DiffContentHistory err: %s", err.Error())
+		resp.Error = Error(DBError, err.Error())
+		return
+	}
+
+	if !exist {
+		flog.Log.Errorf("DiffContentHistory err: %s", "content history not found")
+		resp.Error = Error(ContentHistoryNotFound, "")
+		return
+	}
+
+	// the other side must be the same owner
+	if userId != 0 {
+		userId = oldH.UserId
+	}
+
+	respResult := new(DiffContentHistoryResponse)
+	respResult.Old = DiffContentSide{Name: fmt.Sprintf("history/%d", oldH.Id), Id: oldH.Id, ContentId: oldH.ContentId, Title: oldH.Title,
+		Types: oldH.Types, Version: oldH.Version, CreateTime: oldH.CreateTime}
+
+	newDescribe := ""
+	if req.CompareType == 0 {
+		newH := new(model.ContentHistory)
+		newH.Id = req.CompareId
+		newH.UserId = userId
+		exist, err = newH.GetRaw()
+		if err != nil {
+			flog.Log.Errorf("DiffContentHistory err: %s", err.Error())
+			resp.Error = Error(DBError, err.Error())
+			return
+		}
+
+		if !exist {
+			flog.Log.Errorf("DiffContentHistory err: %s", "content history not found")
+			resp.Error = Error(ContentHistoryNotFound, "")
+			return
+		}
+
+		respResult.New = DiffContentSide{Name: fmt.Sprintf("history/%d", newH.Id), Id: newH.Id, ContentId: newH.ContentId, Title: newH.Title,
+			Types: newH.Types, Version: newH.Version, CreateTime: newH.CreateTime}
+		newDescribe = newH.Describe
+	} else {
+		content := new(model.Content)
+		content.Id = oldH.ContentId
+		content.UserId = userId
+		exist, err = content.GetByRawAll()
+		if err != nil {
+			flog.Log.Errorf("DiffContentHistory err: %s", err.Error())
+			resp.Error = Error(DBError, err.Error())
+			return
+		}
+
+		if !exist {
+			flog.Log.Errorf("DiffContentHistory err: %s", "content not found")
+			resp.Error = Error(ContentNotFound, "")
+			return
+		}
+
+		respResult.New = DiffContentSide{ContentId: content.Id, Version: content.Version}
+		if req.CompareType == 1 {
+			respResult.New.Name = "draft"
+			respResult.New.Title = content.PreTitle
+			newDescribe = content.PreDescribe
+		} else {
+			respResult.New.Name = "publish"
+			respResult.New.Title = content.Title
+			respResult.New.Types = 1
+			newDescribe = content.Describe
+		}
+	}
+
+	respResult.TitleChange = respResult.Old.Title != respResult.New.Title
+	respResult.Diff = util.DiffText(oldH.Describe, newDescribe, req.Word)
+	respResult.Unified = respResult.Diff.Unified(respResult.Old.Name, respResult.New.Name)
+	resp.Data = respResult
+	resp.Flag = true
+}
+
+func DiffContentHistory(c *gin.Context) {
+	resp := new(Resp)
+	uu, err := GetUserSession(c)
+	if err != nil {
+		flog.Log.Errorf("DiffContentHistory err: %s", err.Error())
+		resp.Error = Error(GetUserSessionError, err.Error())
+		JSONL(c, 200, nil, resp)
+		return
+	}
+
+	uid := uu.Id
+	DiffContentHistoryHelper(c, uid)
+}
+
+func DiffContentHistoryAdmin(c *gin.Context) {
+	DiffContentHistoryHelper(c, 0)
+}
+
 type SentContentToRubbishRequest struct {
-	Id int64 `json:"id" validate:"required"`
+	Id       int64 `json:"id" validate:"required"`
+	Revision int64 `json:"revision" validate:"required"`
 }
 
 func SentContentToRubbish(c *gin.Context) {
@@ -1612,8 +1910,7 @@ func SentContentToRubbish(c *gin.Context) {
 
 	contentBefore := new(model.Content)
 	contentBefore.Id = req.Id
-	contentBefore.UserId = uu.Id
-	exist, err := contentBefore.Get()
+	exist, err := getContentWithRole(contentBefore, uu.Id, model.CollaboratorPublisher)
 	if err != nil {
 		flog.Log.Errorf("SentContentToRubbish err: %s", err.Error())
 		resp.Error = Error(DBError, err.Error())
@@ -1639,23 +1936,28 @@ func SentContentToRubbish(c *gin.Context) {
 
 	content := new(model.Content)
 	content.Id = req.Id
-	content.UserId = uu.Id
+	content.UserId = contentBefore.UserId
 	content.Status = 3
+	content.Revision = req.Revision
 	_, err = content.UpdateStatus(true, false)
 	if err != nil {
-		flog.Log.Errorf("SentContentToRubbish err:%s", err.Error())
-		resp.Error = Error(DBError, err.Error())
+		contentWriteError(resp, "SentContentToRubbish", req.Id, err)
 		return
 	}
 
-	go SendToLoop(uu.Id, 0, 1)
-	go SendToLoop(uu.Id, contentBefore.NodeId, 2)
-	go SendToLoop(uu.Id, 0, 3)
+	resp.Data = ContentRevisionResponse{Revision: content.Revision}
+
+	go SendToLoop(contentBefore.UserId, 0, 1)
+	go SendToLoop(contentBefore.UserId, contentBefore.NodeId, 2)
+	go SendToLoop(contentBefore.UserId, 0, 3)
+	SendToSearch(model.SearchContent, req.Id)
+	RefreshSitemap()
 	resp.Flag = true
 }
 
 type ReCycleOfContentInRubbishRequest struct {
-	Id int64 `json:"id" validate:"required"`
+	Id       int64 `json:"id" validate:"required"`
+	Revision int64 `json:"revision" validate:"required"`
 }
 
 func ReCycleOfContentInRubbish(c *gin.Context) {
@@ -1687,8 +1989,7 @@ func ReCycleOfContentInRubbish(c *gin.Context) {
 
 	contentBefore := new(model.Content)
 	contentBefore.Id = req.Id
-	contentBefore.UserId = uu.Id
-	exist, err := contentBefore.Get()
+	exist, err := getContentWithRole(contentBefore, uu.Id, model.CollaboratorPublisher)
 	if err != nil {
 		flog.Log.Errorf("ReCycleOfContentInRubbish err: %s", err.Error())
 		resp.Error = Error(DBError, err.Error())
@@ -1704,25 +2005,29 @@ func ReCycleOfContentInRubbish(c *gin.Context) {
 	if contentBefore.Status == 3 {
 		content := new(model.Content)
 		content.Id = req.Id
-		content.UserId = uu.Id
+		content.UserId = contentBefore.UserId
 
 		if contentBefore.BanTime == 0 {
 			content.Status = 1
 		} else {
 			content.Status = 2
 		}
+		content.Revision = req.Revision
 		_, err = content.UpdateStatus(true, false)
 		if err != nil {
-			flog.Log.Errorf("ReCycleOfContentInRubbish err:%s", err.Error())
-			resp.Error = Error(DBError, err.Error())
+			contentWriteError(resp, "ReCycleOfContentInRubbish", req.Id, err)
 			return
 		}
 
-		go SendToLoop(uu.Id, 0, 1)
-		go SendToLoop(uu.Id, contentBefore.NodeId, 2)
-		go SendToLoop(uu.Id, 0, 3)
+		resp.Data = ContentRevisionResponse{Revision: content.Revision}
+
+		go SendToLoop(contentBefore.UserId, 0, 1)
+		go SendToLoop(contentBefore.UserId, contentBefore.NodeId, 2)
+		go SendToLoop(contentBefore.UserId, 0, 3)
 	}
 
+	SendToSearch(model.SearchContent, req.Id)
+	RefreshSitemap()
 	resp.Flag = true
 }
 
@@ -1759,8 +2064,7 @@ func ReallyDeleteContent(c *gin.Context) {
 
 	contentBefore := new(model.Content)
 	contentBefore.Id = req.Id
-	contentBefore.UserId = uu.Id
-	exist, err := contentBefore.Get()
+	exist, err := getContentWithRole(contentBefore, uu.Id, model.CollaboratorPublisher)
 	if err != nil {
 		flog.Log.Errorf("ReallyDeleteContent err: %s", err.Error())
 		resp.Error = Error(DBError, err.Error())
@@ -1776,7 +2080,7 @@ func ReallyDeleteContent(c *gin.Context) {
 	if contentBefore.Status == 3 {
 		content := new(model.Content)
 		content.Id = req.Id
-		content.UserId = uu.Id
+		content.UserId = contentBefore.UserId
 		err = content.Delete()
 		if err != nil {
 			flog.Log.Errorf("ReallyDeleteContent err:%s", err.Error())
@@ -1784,15 +2088,17 @@ func ReallyDeleteContent(c *gin.Context) {
 			return
 		}
 
-		go SendToLoop(uu.Id, 0, 1)
-		go SendToLoop(uu.Id, contentBefore.NodeId, 2)
-		go SendToLoop(uu.Id, 0, 3)
+		go SendToLoop(contentBefore.UserId, 0, 1)
+		go SendToLoop(contentBefore.UserId, contentBefore.NodeId, 2)
+		go SendToLoop(contentBefore.UserId, 0, 3)
 	} else {
 		flog.Log.Errorf("ReallyDeleteContent err: %s", "content can not delete")
 		resp.Error = Error(ContentCanNotDelete, "")
 		return
 	}
 
+	SendToSearch(model.SearchContent, req.Id)
+	RefreshSitemap()
 	resp.Flag = true
 }
 
@@ -1829,8 +2135,7 @@ func ReallyDeleteHistoryContent(c *gin.Context) {
 
 	contentBeforeH := new(model.ContentHistory)
 	contentBeforeH.Id = req.Id
-	contentBeforeH.UserId = uu.Id
-	exist, err := contentBeforeH.GetRaw()
+	exist, err := getHistoryWithRole(contentBeforeH, uu.Id, model.CollaboratorPublisher)
 	if err != nil {
 		flog.Log.Errorf("ReallyDeleteHistoryContent err: %s", err.Error())
 		resp.Error = Error(DBError, err.Error())
@@ -1845,7 +2150,7 @@ func ReallyDeleteHistoryContent(c *gin.Context) {
 
 	contentH := new(model.ContentHistory)
 	contentH.Id = req.Id
-	contentH.UserId = uu.Id
+	contentH.UserId = contentBeforeH.UserId
 	_, err = contentH.Delete()
 	if err != nil {
 		flog.Log.Errorf("ReallyDeleteHistoryContent err:%s", err.Error())
@@ -193,6 +193,8 @@ func BadContent(c *gin.Context) {
 			if err != nil {
 				flog.Log.Errorf("BadContent ban err: %s", err.Error())
 			}
+			SendToSearch(model.SearchContent, cc.Id)
+			RefreshSitemap()
 		}
 		resp.Data = "+"
 	}
@@ -0,0 +1,165 @@
+package controllers
+
+import (
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator"
+	"github.com/hunterhug/fafacms/core/flog"
+	"github.com/hunterhug/fafacms/core/model"
+)
+
+// edit lock no heartbeat so many seconds will be free
+var ContentEditLockExpire int64 = 60
+
+type ContentRevisionResponse struct {
+	Revision int64 `json:"revision"`
+}
+
+// Write must take the revision the client saw, other write after that will return the now revision
+func contentWriteError(resp *Resp, name string, id int64, err error) {
+	flog.Log.Errorf("%s err: %s", name, err.Error())
+	if err != model.ErrContentRevisionConflict {
+		resp.Error = Error(DBError, err.Error())
+		return
+	}
+
+	now, err := model.ContentRevision(id)
+	if err != nil {
+		flog.Log.Errorf("%s err: %s", name, err.Error())
+		resp.Error = Error(DBError, err.Error())
+		return
+	}
+
+	resp.Data = ContentRevisionResponse{Revision: now}
+	resp.Error = Error(ContentRevisionConflict, fmt.Sprintf("now revision is %d", now))
+}
+
+type EditLockOfContentRequest struct {
+	Id       int64  `json:"id" validate:"required"`
+	ClientId string `json:"client_id" validate:"required,max=100"` // every browser tab or device a random id
+	Force    bool   `json:"force"`                                 // take the lock from other
+}
+
+type EditLockOfContentResponse struct {
+	Lock         bool   `json:"lock"` // true you have the lock, false other is editing
+	EditLockId   string `json:"edit_lock_id"`
+	EditLockTime int64  `json:"edit_lock_time"`
+	Revision     int64  `json:"revision"`
+}
+
+// Soft edit lock, call it again as heartbeat, not has the lock can still write, only for warning
+func EditLockOfContent(c *gin.Context) {
+	resp := new(Resp)
+	req := new(EditLockOfContentRequest)
+	defer func() {
+		JSONL(c, 200, req, resp)
+	}()
+
+	if errResp := ParseJSON(c, req); errResp != nil {
+		resp.Error = errResp
+		return
+	}
+
+	var validate = validator.New()
+	err := validate.Struct(req)
+	if err != nil {
+		flog.Log.Errorf("EditLockOfContent err: %s", err.Error())
+		resp.Error = Error(ParasError, err.Error())
+		return
+	}
+
+	uu, err := GetUserSession(c)
+	if err != nil {
+		flog.Log.Errorf("EditLockOfContent err: %s", err.Error())
+		resp.Error = Error(GetUserSessionError, err.Error())
+		return
+	}
+
+	content := new(model.Content)
+	content.Id = req.Id
+	exist, err := getContentWithRole(content, uu.Id, model.CollaboratorEditor)
+	if err != nil {
+		flog.Log.Errorf("EditLockOfContent err: %s", err.Error())
+		resp.Error = Error(DBError, err.Error())
+		return
+	}
+
+	if !exist {
+		flog.Log.Errorf("EditLockOfContent err: %s", "content not found")
+		resp.Error = Error(ContentNotFound, "")
+		return
+	}
+
+	err = content.EditLock(req.ClientId, req.Force, ContentEditLockExpire)
+	if err != nil {
+		flog.Log.Errorf("EditLockOfContent err: %s", err.Error())
+		resp.Error = Error(DBError, err.Error())
+		return
+	}
+
+	resp.Data = EditLockOfContentResponse{
+		Lock:         content.EditLockId == req.ClientId,
+		EditLockId:   content.EditLockId,
+		EditLockTime: content.EditLockTime,
+		Revision:     content.Revision,
+	}
+	resp.Flag = true
+}
+
+type EditUnlockOfContentRequest struct {
+	Id       int64  `json:"id" validate:"required"`
+	ClientId string `json:"client_id" validate:"required,max=100"`
+}
+
+// Leave the edit page should release the lock, or wait it expire
+func EditUnlockOfContent(c *gin.Context) {
+	resp := new(Resp)
+	req := new(EditUnlockOfContentRequest)
+	defer func() {
+		JSONL(c, 200, req, resp)
+	}()
+
+	if errResp := ParseJSON(c, req); errResp != nil {
+		resp.Error = errResp
+		return
+	}
+
+	var validate = validator.New()
+	err := validate.Struct(req)
+	if err != nil {
+		flog.Log.Errorf("EditUnlockOfContent err: %s", err.Error())
+		resp.Error = Error(ParasError, err.Error())
+		return
+	}
+
+	uu, err := GetUserSession(c)
+	if err != nil {
+		flog.Log.Errorf("EditUnlockOfContent err: %s", err.Error())
+		resp.Error = Error(GetUserSessionError, err.Error())
+		return
+	}
+
+	content := new(model.Content)
+	content.Id = req.Id
+	exist, err := getContentWithRole(content, uu.Id, model.CollaboratorEditor)
+	if err != nil {
+		flog.Log.Errorf("EditUnlockOfContent err: %s", err.Error())
+		resp.Error = Error(DBError, err.Error())
+		return
+	}
+
+	if !exist {
+		flog.Log.Errorf("EditUnlockOfContent err: %s", "content not found")
+		resp.Error = Error(ContentNotFound, "")
+		return
+	}
+
+	err = content.EditUnlock(req.ClientId)
+	if err != nil {
+		flog.Log.Errorf("EditUnlockOfContent err: %s", err.Error())
+		resp.Error = Error(DBError, err.Error())
+		return
+	}
+
+	resp.Flag = true
+}
@@ -0,0 +1,734 @@
+package controllers
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator"
+	"github.com/hunterhug/fafacms/core/config"
+	"github.com/hunterhug/fafacms/core/flog"
+	"github.com/hunterhug/fafacms/core/model"
+	"github.com/hunterhug/fafacms/core/util"
+	"github.com/hunterhug/fafacms/core/util/oss"
+	"io"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+var (
+	// export doing more than the seconds take as fail, can export again
+	ExportTimeout int64 = 3600
+
+	// read so many content from db once
+	ExportBatchSize = 100
+
+	// at most so many export doing at the same time
+	exportSem = make(chan struct{}, 2)
+)
+
+type ExportContentRequest struct {
+	History bool `json:"history"` // include all the history
+	File    bool `json:"file"`    // include all the upload file
+}
+
+type ExportContentAdminRequest struct {
+	UserId  int64 `json:"user_id" validate:"required"`
+	History bool  `json:"history"`
+	File    bool  `json:"file"`
+}
+
+type ExportContentResponse struct {
+	model.ContentExport
+	DownloadUrl string `json:"download_url"`
+}
+
+func exportContentResponse(e model.ContentExport, admin bool) ExportContentResponse {
+	url := "/v1/content/export/download?id=%d"
+	if admin {
+		url = "/v1/content/export/admin/download?id=%d"
+	}
+
+	r := ExportContentResponse{ContentExport: e}
+	if e.Status == 1 {
+		r.DownloadUrl = fmt.Sprintf(url, e.Id)
+	}
+	return r
+}
+
+// Export all the content of self into a zip, make in background, list to see when done
+func ExportContent(c *gin.Context) {
+	resp := new(Resp)
+	req := new(ExportContentRequest)
+	defer func() {
+		JSONL(c, 200, req, resp)
+	}()
+
+	if errResp := ParseJSON(c, req); errResp != nil {
+		resp.Error = errResp
+		return
+	}
+
+	uu, err := GetUserSession(c)
+	if err != nil {
+		flog.Log.Errorf("ExportContent err: %s", err.Error())
+		resp.Error = Error(GetUserSessionError, err.Error())
+		return
+	}
+
+	exportContent(resp, uu, uu.Id, req.History, req.File, false)
+}
+
+// Admin export any user
+func ExportContentAdmin(c *gin.Context) {
+	resp := new(Resp)
+	req := new(ExportContentAdminRequest)
+	defer func() {
+		JSONL(c, 200, req, resp)
+	}()
+
+	if errResp := ParseJSON(c, req); errResp != nil {
+		resp.Error = errResp
+		return
+	}
+
+	var validate = validator.New()
+	err := validate.Struct(req)
+	if err != nil {
+		flog.Log.Errorf("ExportContentAdmin err: %s", err.Error())
+		resp.Error = Error(ParasError, err.Error())
+		return
+	}
+
+	uu, err := GetUserSession(c)
+	if err != nil {
+		flog.Log.Errorf("ExportContentAdmin err: %s", err.Error())
+		resp.Error = Error(GetUserSessionError, err.Error())
+		return
+	}
+
+	u := new(model.User)
+	u.Id = req.UserId
+	exist, err := u.GetRaw()
+	if err != nil {
+		flog.Log.Errorf("ExportContentAdmin err: %s", err.Error())
+		resp.Error = Error(DBError, err.Error())
+		return
+	}
+
+	if !exist {
+		flog.Log.Errorf("ExportContentAdmin err: %s", "user not found")
+		resp.Error = Error(UserNotFound, "")
+		return
+	}
+
+	exportContent(resp, u, uu.Id, req.History, req.File, true)
+}
+
+func exportContent(resp *Resp, u *model.User, createUserId int64, history, file bool, admin bool) {
+	e := new(model.ContentExport)
+	e.UserId = u.Id
+	doing, err := e.Doing(time.Now().Unix() - ExportTimeout)
+	if err != nil {
+		flog.Log.Errorf("ExportContent err: %s", err.Error())
+		resp.Error = Error(DBError, err.Error())
+		return
+	}
+
+	if doing {
+		flog.Log.Errorf("ExportContent err: %s", "export doing")
+		resp.Error = Error(ContentExportDoing, "")
+		return
+	}
+
+	e.UserName = u.Name
+	e.CreateUserId = createUserId
+	if history {
+		e.History = 1
+	}
+	if file {
+		e.File = 1
+	}
+
+	err = e.Insert()
+	if err != nil {
+		flog.Log.Errorf("ExportContent err: %s", err.Error())
+		resp.Error = Error(DBError, err.Error())
+		return
+	}
+
+	go runExport(*e)
+
+	resp.Data = exportContentResponse(*e, admin)
+	resp.Flag = true
+}
+
+type ListExportContentRequest struct {
+	Id       int64    `json:"id"`
+	UserId   int64    `json:"user_id"`
+	UserName string   `json:"user_name"`
+	Status   int      `json:"status" validate:"oneof=-1 0 1 2"`
+	Sort     []string `json:"sort"`
+	PageHelp
+}
+
+type ListExportContentResponse struct {
+	Exports []ExportContentResponse `json:"exports"`
+	PageHelp
+}
+
+func ListExportContent(c *gin.Context) {
+	resp := new(Resp)
+	uu, err := GetUserSession(c)
+	if err != nil {
+		flog.Log.Errorf("ListExportContent err: %s", err.Error())
+		resp.Error = Error(GetUserSessionError, err.Error())
+		JSONL(c, 200, nil, resp)
+		return
+	}
+
+	ListExportContentHelper(c, uu.Id)
+}
+
+func ListExportContentAdmin(c *gin.Context) {
+	ListExportContentHelper(c, 0)
+}
+
+func ListExportContentHelper(c *gin.Context, userId int64) {
+	resp := new(Resp)
+
+	respResult := new(ListExportContentResponse)
+	req := new(ListExportContentRequest)
+	defer func() {
+		JSONL(c, 200, req, resp)
+	}()
+
+	if errResp := ParseJSON(c, req); errResp != nil {
+		resp.Error = errResp
+		return
+	}
+
+	var validate = validator.New()
+	err := validate.Struct(req)
+	if err != nil {
+		flog.Log.Errorf("ListExportContent err: %s", err.Error())
+		resp.Error = Error(ParasError, err.Error())
+		return
+	}
+
+	session := model.FaFaRdb.Client.NewSession()
+	defer session.Close()
+
+	session.Table(new(model.ContentExport)).Where("1=1")
+
+	// not admin only see self
+	if userId != 0 {
+		session.And("user_id=?", userId)
+	} else {
+		if req.UserId != 0 {
+			session.And("user_id=?", req.UserId)
+		}
+		if req.UserName != "" {
+			session.And("user_name=?", req.UserName)
+		}
+	}
+
+	if req.Id != 0 {
+		session.And("id=?", req.Id)
+	}
+
+	if req.Status != -1 {
+		session.And("status=?", req.Status)
+	}
+
+	countSession := session.Clone()
+	defer countSession.Close()
+	total, err := countSession.Count()
+	if err != nil {
+		flog.Log.Errorf("ListExportContent err:%s", err.Error())
+		resp.Error = Error(DBError, err.Error())
+		return
+	}
+
+	es := make([]model.ContentExport, 0)
+	p := &req.PageHelp
+	if total == 0 {
+		if p.Limit == 0 {
+			p.Limit = 20
+		}
+	} else {
+		p.build(session, req.Sort, model.ContentExportSortName)
+		err = session.Find(&es)
+		if err != nil {
+			flog.Log.Errorf("ListExportContent err:%s", err.Error())
+			resp.Error = Error(DBError, err.Error())
+			return
+		}
+	}
+
+	respResult.Exports = make([]ExportContentResponse, 0, len(es))
+	for _, v := range es {
+		respResult.Exports = append(respResult.Exports, exportContentResponse(v, userId == 0))
+	}
+	p.Pages = int(math.Ceil(float64(total) / float64(p.Limit)))
+	p.Total = int(total)
+	respResult.PageHelp = *p
+	resp.Data = respResult
+	resp.Flag = true
+}
+
+func DownloadExportContent(c *gin.Context) {
+	resp := new(Resp)
+	uu, err := GetUserSession(c)
+	if err != nil {
+		flog.Log.Errorf("DownloadExportContent err: %s", err.Error())
+		resp.Error = Error(GetUserSessionError, err.Error())
+		JSONL(c, 200, nil, resp)
+		return
+	}
+
+	DownloadExportContentHelper(c, uu.Id)
+}
+
+func DownloadExportContentAdmin(c *gin.Context) {
+	DownloadExportContentHelper(c, 0)
+}
+
+// Download the zip: /v1/content/export/download?id=1, error still return json
+func DownloadExportContentHelper(c *gin.Context, userId int64) {
+	resp := new(Resp)
+	defer func() {
+		if resp.Error != nil {
+			JSONL(c, 200, nil, resp)
+		}
+	}()
+
+	id, err := util.SI(c.Query("id"))
+	if err != nil || id <= 0 {
+		flog.Log.Errorf("DownloadExportContent err: %s", "id wrong")
+		resp.Error = Error(ParasError, "id wrong")
+		return
+	}
+
+	e := new(model.ContentExport)
+	e.Id = int64(id)
+	e.UserId = userId
+	exist, err := e.Get()
+	if err != nil {
+		flog.Log.Errorf("DownloadExportContent err: %s", err.Error())
+		resp.Error = Error(DBError, err.Error())
+		return
+	}
+
+	if !exist {
+		flog.Log.Errorf("DownloadExportContent err: %s", "export not found")
+		resp.Error = Error(ContentExportNotFound, "")
+		return
+	}
+
+	if e.Status != 1 {
+		flog.Log.Errorf("DownloadExportContent err: %s", "export not done")
+		resp.Error = Error(ContentExportNotDone, "")
+		return
+	}
+
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="fafacms_%s_%d.zip"`, e.UserName, e.Id))
+	if e.StoreType == 0 {
+		if _, err := os.Stat(e.Path); err != nil {
+			flog.Log.Errorf("DownloadExportContent err: %s", err.Error())
+			resp.Error = Error(ContentExportNotFound, err.Error())
+			return
+		}
+
+		c.File(e.Path)
+		return
+	}
+
+	raw, err := oss.GetFile(config.FaFaConfig.OssConfig, e.Path)
+	if err != nil {
+		flog.Log.Errorf("DownloadExportContent err: %s", err.Error())
+		resp.Error = Error(ContentExportNotFound, err.Error())
+		return
+	}
+	c.Data(200, "application/zip", raw)
+}
+
+func runExport(e model.ContentExport) {
+	exportSem <- struct{}{}
+	defer func() {
+		<-exportSem
+	}()
+
+	flog.Log.Debugf("Export content %d of user %s start", e.Id, e.UserName)
+	err := exportZip(&e)
+	if err != nil {
+		flog.Log.Errorf("Export content %d err: %s", e.Id, err.Error())
+		e.Status = 2
+		e.Error = err.Error()
+	} else {
+		flog.Log.Debugf("Export content %d of user %s done", e.Id, e.UserName)
+		e.Status = 1
+	}
+
+	err = e.Finish()
+	if err != nil {
+		flog.Log.Errorf("Export content %d err: %s", e.Id, err.Error())
+	}
+}
+
+// Local disk not in the storage dir, can not visit without login
+func exportZip(e *model.ContentExport) (err error) {
+	name := fmt.Sprintf("%d_%s.zip", e.Id, util.GetGUID())
+	buf := new(bytes.Buffer)
+	var w io.Writer = buf
+	if config.FaFaConfig.DefaultConfig.StorageOss {
+		e.StoreType = 1
+		e.Path = fmt.Sprintf("storage_export/%s/%s", e.UserName, name)
+	} else {
+		dir := filepath.Join(config.FaFaConfig.DefaultConfig.StoragePath+"_export", e.UserName)
+		err = util.MakeDir(dir)
+		if err != nil {
+			return err
+		}
+
+		e.Path = filepath.Join(dir, name)
+		var f *os.File
+		f, err = os.Create(e.Path)
+		if err != nil {
+			return err
+		}
+
+		defer func() {
+			f.Close()
+			if err != nil {
+				os.Remove(e.Path)
+			}
+		}()
+		w = f
+	}
+
+	zw := zip.NewWriter(w)
+	ex := &exporter{zip: zw, export: e, loc: time.FixedZone("", int(3600*TimeZone))}
+	err = ex.nodes()
+	if err != nil {
+		return err
+	}
+
+	err = ex.contents()
+	if err != nil {
+		return err
+	}
+
+	if e.File == 1 {
+		err = ex.files()
+		if err != nil {
+			return err
+		}
+	}
+
+	err = zw.Close()
+	if err != nil {
+		return err
+	}
+
+	if e.StoreType == 1 {
+		e.Size = int64(buf.Len())
+		return oss.SaveFile(config.FaFaConfig.OssConfig, e.Path, buf.Bytes())
+	}
+
+	info, err := os.Stat(e.Path)
+	if err != nil {
+		return err
+	}
+	e.Size = info.Size()
+	return nil
+}
+
+type exporter struct {
+	zip       *zip.Writer
+	export    *model.ContentExport
+	loc       *time.Location
+	nodeMap   map[int64]*exportNode
+	nodePaths map[int64]string
+}
+
+type exportNode struct {
+	Id         int64         `json:"id"`
+	Seo        string        `json:"seo"`
+	Name       string        `json:"name"`
+	Describe   string        `json:"describe"`
+	ImagePath  string        `json:"image_path"`
+	Status     int           `json:"status"`
+	SortNum    int64         `json:"sort_num"`
+	CreateTime int64         `json:"create_time"`
+	Path       string        `json:"path"`
+	Children   []*exportNode `json:"children"`
+	parentId   int64
+}
+
+type exportFile struct {
+	Path           string `json:"path"` // path in the zip, empty is miss
+	Url            string `json:"url"`
+	Type           string `json:"type"`
+	Tag            string `json:"tag"`
+	ReallyFileName string `json:"really_file_name"`
+	Describe       string `json:"describe"`
+	Size           int64  `json:"size"`
+	CreateTime     int64  `json:"create_time"`
+	Error          string `json:"error,omitempty"`
+}
+
+func (ex *exporter) write(name string, raw []byte) error {
+	w, err := ex.zip.Create(name)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(raw)
+	return err
+}
+
+func (ex *exporter) writeJson(name string, v interface{}) error {
+	raw, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ex.write(name, raw)
+}
+
+func (ex *exporter) time(t int64) string {
+	if t <= 0 {
+		return ""
+	}
+	return time.Unix(t, 0).In(ex.loc).Format(time.RFC3339)
+}
+
+// Node tree in nodes.json, path is seo from the top
+func (ex *exporter) nodes() error {
+	nodes := make([]model.ContentNode, 0)
+	err := model.FaFaRdb.Client.Where("user_id=?", ex.export.UserId).Asc("sort_num", "id").Find(&nodes)
+	if err != nil {
+		return err
+	}
+
+	ex.nodeMap = make(map[int64]*exportNode, len(nodes))
+	ex.nodePaths = make(map[int64]string, len(nodes))
+	for _, v := range nodes {
+		ex.nodeMap[v.Id] = &exportNode{
+			Id:         v.Id,
+			Seo:        v.Seo,
+			Name:       v.Name,
+			Describe:   v.Describe,
+			ImagePath:  v.ImagePath,
+			Status:     v.Status,
+			SortNum:    v.SortNum,
+			CreateTime: v.CreateTime,
+			Children:   make([]*exportNode, 0),
+			parentId:   v.ParentNodeId,
+		}
+	}
+
+	tree := make([]*exportNode, 0)
+	for _, v := range nodes {
+		n := ex.nodeMap[v.Id]
+		n.Path = ex.nodePath(v.Id)
+		if parent, ok := ex.nodeMap[n.parentId]; ok && n.parentId != n.Id {
+			parent.Children = append(parent.Children, n)
+		} else {
+			tree = append(tree, n)
+		}
+	}
+	return ex.writeJson("nodes.json", tree)
+}
+
+func (ex *exporter) nodePath(id int64) string {
+	if p, ok := ex.nodePaths[id]; ok {
+		return p
+	}
+
+	seos := make([]string, 0)
+	seen := make(map[int64]bool)
+	for n, ok := ex.nodeMap[id]; ok && !seen[n.Id]; n, ok = ex.nodeMap[n.parentId] {
+		seen[n.Id] = true
+		seos = append([]string{n.Seo}, seos...)
+	}
+
+	p := filepath.ToSlash(filepath.Join(seos...))
+	ex.nodePaths[id] = p
+	return p
+}
+
+// One markdown a content, the draft not publish in another one, can import again
+func (ex *exporter) contents() error {
+	keys := []string{"id", "title", "seo", "node", "categories", "tags", "date", "lastmod", "created", "updated", "draft",
+		"status", "top", "close_comment", "password", "image_path", "version", "revision"}
+	historyKeys := []string{"id", "title", "content_id", "content_seo", "version", "types", "date"}
+
+	for offset := 0; ; offset = offset + ExportBatchSize {
+		cs := make([]model.Content, 0)
+		err := model.FaFaRdb.Client.Where("user_id=?", ex.export.UserId).Asc("id").Limit(ExportBatchSize, offset).
+			Omit("describe_html", "toc", "excerpt").Find(&cs)
+		if err != nil {
+			return err
+		}
+
+		for _, v := range cs {
+			tags, err := model.ContentTagNames(v.Id)
+			if err != nil {
+				return err
+			}
+
+			seo := v.Seo
+			if seo == "" {
+				seo = util.IS(int(v.Id))
+			}
+
+			dir := "content"
+			categories := make([]string, 0)
+			if n, ok := ex.nodeMap[v.NodeId]; ok {
+				dir = "content/" + ex.nodePath(v.NodeId)
+				categories = append(categories, n.Name)
+			}
+
+			meta := map[string]interface{}{
+				"id":            v.Id,
+				"seo":           v.Seo,
+				"node":          ex.nodePath(v.NodeId),
+				"categories":    categories,
+				"tags":          tags,
+				"date":          ex.time(v.FirstPublishTime),
+				"lastmod":       ex.time(v.PublishTime),
+				"created":       ex.time(v.CreateTime),
+				"updated":       ex.time(v.UpdateTime),
+				"draft":         v.Version == 0,
+				"status":        v.Status,
+				"top":           v.Top,
+				"close_comment": v.CloseComment,
+				"password":      v.Password,
+				"image_path":    v.ImagePath,
+				"version":       v.Version,
+				"revision":      v.Revision,
+			}
+
+			meta["title"] = v.Title
+			body := v.Describe
+			if v.Version == 0 {
+				meta["title"] = v.PreTitle
+				body = v.PreDescribe
+			}
+
+			raw, err := util.ExportMarkdown(keys, meta, body)
+			if err != nil {
+				return err
+			}
+
+			err = ex.write(fmt.Sprintf("%s/%s.md", dir, seo), raw)
+			if err != nil {
+				return err
+			}
+
+			// publish before but the draft change after
+			if v.Version > 0 && v.PreFlush == 0 {
+				meta["title"] = v.PreTitle
+				meta["draft"] = true
+				raw, err := util.ExportMarkdown(keys, meta, v.PreDescribe)
+				if err != nil {
+					return err
+				}
+
+				err = ex.write(fmt.Sprintf("%s/%s.draft.md", dir, seo), raw)
+				if err != nil {
+					return err
+				}
+			}
+
+			if ex.export.History == 1 {
+				hs := make([]model.ContentHistory, 0)
+				err = model.FaFaRdb.Client.Where("content_id=?", v.Id).And("user_id=?", v.UserId).Asc("id").Find(&hs)
+				if err != nil {
+					return err
+				}
+
+				for _, h := range hs {
+					raw, err := util.ExportMarkdown(historyKeys, map[string]interface{}{
+						"id":          h.Id,
+						"title":       h.Title,
+						"content_id":  h.ContentId,
+						"content_seo": v.Seo,
+						"version":     h.Version,
+						"types":       h.Types,
+						"date":        ex.time(h.CreateTime),
+					}, h.Describe)
+					if err != nil {
+						return err
+					}
+
+					err = ex.write(fmt.Sprintf("history/%s/%d.md", seo, h.Id), raw)
+					if err != nil {
+						return err
+					}
+				}
+			}
+			ex.export.ContentNum++
+		}
+
+		if len(cs) < ExportBatchSize {
+			return nil
+		}
+	}
+}
+
+// Upload file keep the path of the url, so the link in content can find it, list in files.json
+func (ex *exporter) files() error {
+	fs := make([]model.File, 0)
+	err := model.FaFaRdb.Client.Where("user_id=?", ex.export.UserId).Asc("id").Find(&fs)
+	if err != nil {
+		return err
+	}
+
+	list := make([]exportFile, 0, len(fs))
+	for _, v := range fs {
+		helpPath := fmt.Sprintf("storage/%s/%s/%s", v.UserName, v.Type, v.FileName)
+		f := exportFile{
+			Path:           helpPath,
+			Url:            v.Url,
+			Type:           v.Type,
+			Tag:            v.Tag,
+			ReallyFileName: v.ReallyFileName,
+			Describe:       v.Describe,
+			Size:           v.Size,
+			CreateTime:     v.CreateTime,
+		}
+
+		var raw []byte
+		if v.StoreType == 1 {
+			raw, err = oss.GetFile(config.FaFaConfig.OssConfig, helpPath)
+		} else {
+			raw, err = ioutil.ReadFile(filepath.Join(config.FaFaConfig.DefaultConfig.StoragePath, v.UserName, v.Type, v.FileName))
+		}
+
+		// file miss not stop the export
+		if err != nil {
+			f.Path = ""
+			f.Error = err.Error()
+			list = append(list, f)
+			continue
+		}
+
+		err = ex.write(helpPath, raw)
+		if err != nil {
+			return err
+		}
+		list = append(list, f)
+		ex.export.FileNum++
+	}
+
+	sort.SliceStable(list, func(i, j int) bool {
+		return list[i].Path != "" && list[j].Path == ""
+	})
+	return ex.writeJson("files.json", list)
+}
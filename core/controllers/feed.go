@@ -0,0 +1,276 @@
+package controllers
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/hunterhug/fafacms/core/flog"
+	"github.com/hunterhug/fafacms/core/model"
+	"github.com/hunterhug/fafacms/core/util"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// site info, set by config
+var (
+	SiteName = "FaFa CMS"
+	SiteUrl  = ""
+)
+
+// front end page of user, node and content
+var (
+	UserUrlFormat    = "/u/%s"
+	NodeUrlFormat    = "/u/%s/n/%s"
+	ContentUrlFormat = "/u/%s/c/%d"
+)
+
+// one feed at most so many content
+var FeedItemNum = 20
+
+// config site url first, or the host of request
+func siteUrl(c *gin.Context) string {
+	if SiteUrl != "" {
+		return strings.TrimSuffix(SiteUrl, "/")
+	}
+
+	scheme := "http"
+	if c.Request.TLS != nil || c.GetHeader("X-Forwarded-Proto") == "https" {
+		scheme = "https"
+	}
+	return scheme + "://" + c.Request.Host
+}
+
+// Site feed: /feed.xml, /feed.atom, /feed.json
+func FeedSite(c *gin.Context) {
+	_, format, ok := util.FeedFormat(c.Request.URL.Path)
+	if !ok {
+		c.AbortWithStatus(404)
+		return
+	}
+
+	site := siteUrl(c)
+	f := &util.Feed{
+		Title:       SiteName,
+		Description: SiteName,
+		Link:        site,
+		FeedLink:    site + c.Request.URL.Path,
+	}
+
+	feedHelper(c, f, format, 0, nil)
+}
+
+// User feed: /feed/hunterhug.xml
+func FeedUser(c *gin.Context) {
+	name, format, ok := util.FeedFormat(c.Param("name"))
+	if !ok {
+		c.AbortWithStatus(404)
+		return
+	}
+
+	u, ok := feedUser(c, name)
+	if !ok {
+		return
+	}
+
+	site := siteUrl(c)
+	f := &util.Feed{
+		Title:       u.NickName,
+		Description: u.ShortDescribe,
+		Link:        site + fmt.Sprintf(UserUrlFormat, u.Name),
+		FeedLink:    site + c.Request.URL.Path,
+		Author:      u.NickName,
+	}
+
+	feedHelper(c, f, format, u.Id, nil)
+}
+
+// Node feed: /feed/hunterhug/golang.xml, content of child node include
+func FeedNode(c *gin.Context) {
+	seo, format, ok := util.FeedFormat(c.Param("node"))
+	if !ok {
+		c.AbortWithStatus(404)
+		return
+	}
+
+	u, ok := feedUser(c, c.Param("name"))
+	if !ok {
+		return
+	}
+
+	node := new(model.ContentNode)
+	node.UserId = u.Id
+	node.Seo = seo
+	exist, err := node.Get()
+	if err != nil {
+		flog.Log.Errorf("Feed err: %s", err.Error())
+		c.AbortWithStatus(500)
+		return
+	}
+
+	if !exist || node.Status != 0 {
+		c.AbortWithStatus(404)
+		return
+	}
+
+	children, err := node.Children(true)
+	if err != nil {
+		flog.Log.Errorf("Feed err: %s", err.Error())
+		c.AbortWithStatus(500)
+		return
+	}
+
+	nodeIds := make([]int64, 0, len(children)+1)
+	for _, v := range children {
+		nodeIds = append(nodeIds, v.Id)
+	}
+	nodeIds = append(nodeIds, node.Id)
+
+	site := siteUrl(c)
+	f := &util.Feed{
+		Title:       fmt.Sprintf("%s - %s", node.Name, u.NickName),
+		Description: node.Describe,
+		Link:        site + fmt.Sprintf(NodeUrlFormat, u.Name, node.Seo),
+		FeedLink:    site + c.Request.URL.Path,
+		Author:      u.NickName,
+	}
+
+	feedHelper(c, f, format, u.Id, nodeIds)
+}
+
+// user in black or not active has no feed
+func feedUser(c *gin.Context, name string) (*model.User, bool) {
+	u := new(model.User)
+	u.Name = name
+	exist, err := u.GetRaw()
+	if err != nil {
+		flog.Log.Errorf("Feed err: %s", err.Error())
+		c.AbortWithStatus(500)
+		return nil, false
+	}
+
+	if !exist || u.Status != 1 {
+		c.AbortWithStatus(404)
+		return nil, false
+	}
+	return u, true
+}
+
+// Only publish, not hide, not ban, not rubbish and no password content in feed
+func feedHelper(c *gin.Context, f *util.Feed, format string, userId int64, nodeIds []int64) {
+	session := model.FaFaRdb.Client.NewSession()
+	defer session.Close()
+
+	session.Where("status=?", 0).And("version>?", 0).And("password=?", "")
+	if userId != 0 {
+		session.And("user_id=?", userId)
+	}
+
+	if len(nodeIds) > 0 {
+		session.In("node_id", nodeIds)
+	}
+
+	cs := make([]model.Content, 0)
+	err := session.Omit("pre_describe", "pre_title").Desc("first_publish_time", "id").Limit(FeedItemNum).Find(&cs)
+	if err != nil {
+		flog.Log.Errorf("Feed err: %s", err.Error())
+		c.AbortWithStatus(500)
+		return
+	}
+
+	// full text or excerpt decide by the author
+	userIds := make([]int64, 0, len(cs))
+	for _, v := range cs {
+		userIds = append(userIds, v.UserId)
+	}
+
+	users := make(map[int64]model.User)
+	if len(userIds) > 0 {
+		us := make([]model.User, 0)
+		err = model.FaFaRdb.Client.In("id", userIds).Cols("id", "name", "nick_name", "feed_full_text").Find(&us)
+		if err != nil {
+			flog.Log.Errorf("Feed err: %s", err.Error())
+			c.AbortWithStatus(500)
+			return
+		}
+
+		for _, v := range us {
+			users[v.Id] = v
+		}
+	}
+
+	site := siteUrl(c)
+	f.Location = time.FixedZone("", int(3600*TimeZone))
+	f.Items = make([]util.FeedItem, 0, len(cs))
+	for _, v := range cs {
+		v.RenderIfNeed()
+		u := users[v.UserId]
+		link := site + fmt.Sprintf(ContentUrlFormat, v.UserName, v.Id)
+		item := util.FeedItem{
+			Id:        link,
+			Title:     v.Title,
+			Link:      link,
+			Author:    u.NickName,
+			Summary:   v.Excerpt,
+			Published: v.FirstPublishTime,
+			Updated:   v.PublishTime,
+		}
+
+		if u.FeedFullText == 1 {
+			item.Content = v.DescribeHtml
+		}
+
+		item.Tags, err = model.ContentTagNames(v.Id)
+		if err != nil {
+			flog.Log.Errorf("Feed err: %s", err.Error())
+			c.AbortWithStatus(500)
+			return
+		}
+
+		if v.PublishTime > f.Updated {
+			f.Updated = v.PublishTime
+		}
+		f.Items = append(f.Items, item)
+	}
+
+	raw, contentType, err := f.Render(format)
+	if err != nil {
+		flog.Log.Errorf("Feed err: %s", err.Error())
+		c.AbortWithStatus(500)
+		return
+	}
+
+	etag := fmt.Sprintf(`"%s"`, util.Md5FS(bytes.NewReader(raw)))
+	c.Header("ETag", etag)
+	if f.Updated > 0 {
+		c.Header("Last-Modified", time.Unix(f.Updated, 0).UTC().Format(http.TimeFormat))
+	}
+
+	if feedNotModified(c, etag, f.Updated) {
+		c.Status(http.StatusNotModified)
+		return
+	}
+
+	c.Data(200, contentType, raw)
+}
+
+// If-None-Match first, then If-Modified-Since
+func feedNotModified(c *gin.Context, etag string, lastModified int64) bool {
+	if match := c.GetHeader("If-None-Match"); match != "" {
+		for _, v := range strings.Split(match, ",") {
+			v = strings.TrimPrefix(strings.TrimSpace(v), "W/")
+			if v == etag || v == "*" {
+				return true
+			}
+		}
+		return false
+	}
+
+	if since := c.GetHeader("If-Modified-Since"); since != "" && lastModified > 0 {
+		t, err := http.ParseTime(since)
+		if err == nil && lastModified <= t.Unix() {
+			return true
+		}
+	}
+	return false
+}
@@ -68,8 +68,6 @@ func UploadFile(c *gin.Context) {
 		return
 	}
 
-	uName := uu.Name
-
 	fileType := c.DefaultPostForm("type", "other")
 	if fileType == "" {
 		fileType = "other"
@@ -136,12 +134,43 @@ func UploadFile(c *gin.Context) {
 		return
 	}
 
+	p, exist, errResp := saveFile(uu, fileType, tag, describe, h.Filename, raw)
+	if errResp != nil {
+		resp.Error = errResp
+		return
+	}
+
+	if exist {
+		data.Addon = "file the same in server"
+	}
+
+	// Return all basic info
+	data.FileName = p.FileName
+	data.ReallyFileName = p.ReallyFileName
+	data.IsPicture = p.IsPicture == 1
+	data.Size = p.Size
+	data.Url = p.Url
+	data.Oss = p.StoreType == 1
+	if data.IsPicture && CanScale {
+		data.UrlX = strings.Replace(p.Url, "/storage", "/storage_x", -1)
+	}
+
+	resp.Data = data
+	resp.Flag = true
+	return
+}
+
+// Save the file into disk or oss, the same file of one user only save once
+func saveFile(uu *model.User, fileType, tag, describe, reallyFileName string, raw []byte) (*model.File, bool, *ErrorResp) {
+	uName := uu.Name
+	fileSuffix := myutil.GetFileSuffix(reallyFileName)
+	fileSize := len(raw)
+
 	// HashCode the raw bytes
 	fileHashCode, err := myutil.Sha256(raw)
 	if err != nil {
 		Log.Errorf("upload err:%s", err.Error())
-		resp.Error = Error(UploadFileError, err.Error())
-		return
+		return nil, false, Error(UploadFileError, err.Error())
 	}
 
 	// HashCode add a prefix of userName, so diff user can upload the same file but the same user will still keep one file
@@ -153,8 +182,7 @@ func UploadFile(c *gin.Context) {
 	p.HashCode = fileHashCode
 	exist, err := p.Get()
 	if err != nil {
-		resp.Error = Error(DBError, err.Error())
-		return
+		return nil, false, Error(DBError, err.Error())
 	}
 
 	helpPath := fmt.Sprintf("storage/%s/%s", uName, fileType)
@@ -169,15 +197,13 @@ func UploadFile(c *gin.Context) {
 			err := myutil.MakeDir(fileDir)
 			if err != nil {
 				Log.Errorf("upload err:%s", err.Error())
-				resp.Error = Error(UploadFileError, err.Error())
-				return
+				return nil, false, Error(UploadFileError, err.Error())
 			}
 
 			err = myutil.SaveToFile(fileAbName, raw)
 			if err != nil {
 				Log.Errorf("upload err:%s", err.Error())
-				resp.Error = Error(UploadFileError, err.Error())
-				return
+				return nil, false, Error(UploadFileError, err.Error())
 			}
 
 			p.Url = fmt.Sprintf("/%s/%s", helpPath, fileName)
@@ -188,8 +214,7 @@ func UploadFile(c *gin.Context) {
 			err = oss.SaveFile(config.FaFaConfig.OssConfig, helpPath+"/"+fileName, raw)
 			if err != nil {
 				Log.Errorf("upload err:%s", err.Error())
-				resp.Error = Error(UploadFileError, err.Error())
-				return
+				return nil, false, Error(UploadFileError, err.Error())
 			}
 		}
 
@@ -209,29 +234,25 @@ func UploadFile(c *gin.Context) {
 					err = myutil.MakeDir(fileScaleDir)
 					if err != nil {
 						Log.Errorf("upload err:%s", err.Error())
-						resp.Error = Error(UploadFileError, err.Error())
-						return
+						return nil, false, Error(UploadFileError, err.Error())
 					}
 					err := go_image.ScaleF2F(fileAbName, fileScaleAbName, ScaleWidth)
 					if err != nil {
 						Log.Errorf("upload err:%s", err.Error())
-						resp.Error = Error(UploadFileError, err.Error())
-						return
+						return nil, false, Error(UploadFileError, err.Error())
 					}
 				} else {
 					// OSS again
 					outRaw, err := go_image.ScaleB2B(raw, ScaleWidth)
 					if err != nil {
 						Log.Errorf("upload err:%s", err.Error())
-						resp.Error = Error(UploadFileError, err.Error())
-						return
+						return nil, false, Error(UploadFileError, err.Error())
 					}
 
 					err = oss.SaveFile(config.FaFaConfig.OssConfig, strings.Replace(helpPath, "storage/", "storage_x/", -1)+"/"+fileName, outRaw)
 					if err != nil {
 						Log.Errorf("upload err:%s", err.Error())
-						resp.Error = Error(UploadFileError, err.Error())
-						return
+						return nil, false, Error(UploadFileError, err.Error())
 					}
 				}
 			}
@@ -239,7 +260,7 @@ func UploadFile(c *gin.Context) {
 
 		p.Type = fileType
 		p.FileName = fileName
-		p.ReallyFileName = h.Filename
+		p.ReallyFileName = reallyFileName
 		p.CreateTime = time.Now().Unix()
 		p.Describe = describe
 		p.UserId = uu.Id
@@ -249,33 +270,17 @@ func UploadFile(c *gin.Context) {
 		_, err = model.FaFaRdb.InsertOne(p)
 		if err != nil {
 			Log.Errorf("upload err:%s", err.Error())
-			resp.Error = Error(DBError, err.Error())
-			return
+			return nil, false, Error(DBError, err.Error())
 		}
 	} else {
 		// File exist
-		data.Addon = "file the same in server"
 		if p.Status != 0 {
 			// If file is hide must change back
 			p.Status = 0
 			p.UpdateStatus()
 		}
 	}
-
-	// Return all basic info
-	data.FileName = p.FileName
-	data.ReallyFileName = p.ReallyFileName
-	data.IsPicture = p.IsPicture == 1
-	data.Size = p.Size
-	data.Url = p.Url
-	data.Oss = p.StoreType == 1
-	if data.IsPicture && CanScale {
-		data.UrlX = strings.Replace(p.Url, "/storage", "/storage_x", -1)
-	}
-
-	resp.Data = data
-	resp.Flag = true
-	return
+	return p, exist, nil
 }
 
 type ListFileAdminRequest struct {
@@ -184,6 +184,59 @@ func UpdateGroup(c *gin.Context) {
 	resp.Data = g
 }
 
+type UpdateReviewOfGroupRequest struct {
+	Id     int64 `json:"id" validate:"required"`
+	Review int   `json:"review" validate:"oneof=0 1 2"` // 0 follow the site, 1 need review, 2 not need
+}
+
+// Content of users in the group need review before publish or not
+func UpdateReviewOfGroup(c *gin.Context) {
+	resp := new(Resp)
+	req := new(UpdateReviewOfGroupRequest)
+	defer func() {
+		JSONL(c, 200, req, resp)
+	}()
+
+	if errResp := ParseJSON(c, req); errResp != nil {
+		resp.Error = errResp
+		return
+	}
+
+	var validate = validator.New()
+	err := validate.Struct(req)
+	if err != nil {
+		flog.Log.Errorf("UpdateReviewOfGroup err: %s", err.Error())
+		resp.Error = Error(ParasError, err.Error())
+		return
+	}
+
+	g := new(model.Group)
+	g.Id = req.Id
+	ok, err := g.GetById()
+	if err != nil {
+		flog.Log.Errorf("UpdateReviewOfGroup err:%s", err.Error())
+		resp.Error = Error(DBError, err.Error())
+		return
+	}
+
+	if !ok {
+		flog.Log.Errorf("UpdateReviewOfGroup err: group not exist")
+		resp.Error = Error(GroupNotFound, "")
+		return
+	}
+
+	g.Review = req.Review
+	err = g.UpdateReview()
+	if err != nil {
+		flog.Log.Errorf("UpdateReviewOfGroup err:%s", err.Error())
+		resp.Error = Error(DBError, err.Error())
+		return
+	}
+
+	resp.Flag = true
+	resp.Data = g
+}
+
 type DeleteGroupRequest struct {
 	Id   int64  `json:"id"`
 	Name string `json:"name"`
@@ -242,7 +295,7 @@ func DeleteGroup(c *gin.Context) {
 	}
 
 	// user exist under group
-	u := new(model.User)
+	u := new(model.UserGroup)
 	u.GroupId = temp.Id
 	ok, err = u.Exist()
 	if err != nil {
@@ -267,6 +320,7 @@ func DeleteGroup(c *gin.Context) {
 		return
 	}
 
+	ClearPermission()
 	resp.Flag = true
 }
 
@@ -427,7 +481,8 @@ type ListGroupResourceRequest struct {
 }
 
 type ListGroupResourceResponse struct {
-	Resources []int64 `json:"resources"`
+	Resources []int64               `json:"resources"`
+	Rules     []model.GroupResource `json:"rules"` // which methods allow or deny
 }
 
 func ListGroupResource(c *gin.Context) {
@@ -472,6 +527,7 @@ func ListGroupResource(c *gin.Context) {
 	}
 
 	respResult.Resources = rs
+	respResult.Rules = grs
 	resp.Data = respResult
 	resp.Flag = true
 }
@@ -27,7 +27,9 @@ func Home(c *gin.Context) {
 	resp.Flag = true
 	resp.Data = "FaFa CMS: https://github.com/hunterhug/fafacms Version:" + config.Version
 	defer func() {
-		c.JSON(200, resp)
+		if !ThemeHTML(c, "home.html", nil, resp) {
+			c.JSON(200, resp)
+		}
 	}()
 }
 
@@ -57,6 +59,9 @@ type People struct {
 	NickNameUpdateTime    string `json:"nick_name_update_time,omitempty"`
 	IsInBlack             bool   `json:"is_in_black"`
 	IsVip                 bool   `json:"is_vip"`
+	TwoFactorEnable       bool   `json:"two_factor_enable,omitempty"` // only show to oneself
+	FeedFullText          bool   `json:"feed_full_text,omitempty"`    // only show to oneself
+	Theme                 string `json:"theme,omitempty"`             // only show to oneself
 	FollowedNum           int64  `json:"followed_num"`
 	FollowingNum          int64  `json:"following_num"`
 	ContentNum            int64  `json:"content_num"`      // normal publish content num
@@ -77,13 +82,15 @@ type PeoplesResponse struct {
 func Peoples(c *gin.Context) {
 	resp := new(Resp)
 
+	respResult := new(PeoplesResponse)
+	req := new(PeoplesRequest)
 	defer func() {
-		JSON(c, 200, resp)
+		if !ThemeHTML(c, "home.html", req, resp) {
+			JSON(c, 200, resp)
+		}
 	}()
 
-	respResult := new(PeoplesResponse)
-	req := new(PeoplesRequest)
-	if errResp := ParseJSON(c, req); errResp != nil {
+	if errResp := ParseQuery(c, req); errResp != nil {
 		resp.Error = errResp
 		return
 	}
@@ -99,7 +106,7 @@ func Peoples(c *gin.Context) {
 	session := model.FaFaRdb.Client.NewSession()
 	defer session.Close()
 
-	session.Table(new(model.User)).Where("1=1").And("status!=?", 0).And("name!=?", "admin")
+	session.Table(new(model.User)).Where("1=1").And("status!=?", 0).And("is_super_admin=?", 0)
 
 	if req.Vip != -1 {
 		if req.Vip == 0 {
@@ -201,8 +208,64 @@ type Node struct {
 	Level         int    `json:"level"`
 	Status        int    `json:"status"`
 	ParentNodeId  int64  `json:"parent_node_id"`
+	Path          string `json:"path"`
 	Son           []Node `json:"son,omitempty"`
-	ContentNum    int64  `json:"content_num"` // normal publish content num
+	ContentNum    int64  `json:"content_num"` // normal publish content num, include its children
+}
+
+func NodeOf(v model.ContentNode) Node {
+	f := Node{}
+	f.Id = v.Id
+	f.Seo = v.Seo
+	f.Describe = v.Describe
+	f.ImagePath = v.ImagePath
+	f.Name = v.Name
+	if v.UpdateTime > 0 {
+		f.UpdateTime = GetSecond2DateTimes(v.UpdateTime)
+		f.UpdateTimeInt = v.UpdateTime
+	}
+	f.CreateTime = GetSecond2DateTimes(v.CreateTime)
+	f.CreateTimeInt = v.CreateTime
+	f.SortNum = v.SortNum
+	f.UserName = v.UserName
+	f.UserId = v.UserId
+	f.Level = v.Level
+	f.ParentNodeId = v.ParentNodeId
+	f.Path = v.Path
+	f.Status = v.Status
+	f.ContentNum = v.ContentNum
+	return f
+}
+
+// Tree of the nodes under the parent, son keep the order of nodes, node can not reach from parent not in the tree
+func NodeTree(nodes []model.ContentNode, parentNodeId int64) []Node {
+	children := make(map[int64][]model.ContentNode)
+	for _, v := range nodes {
+		if v.Id != v.ParentNodeId {
+			children[v.ParentNodeId] = append(children[v.ParentNodeId], v)
+		}
+	}
+
+	n := nodeTree(children, parentNodeId, make(map[int64]bool))
+	if n == nil {
+		n = make([]Node, 0)
+	}
+	return n
+}
+
+func nodeTree(children map[int64][]model.ContentNode, parentNodeId int64, seen map[int64]bool) []Node {
+	var n []Node
+	for _, v := range children[parentNodeId] {
+		if seen[v.Id] {
+			continue
+		}
+		seen[v.Id] = true
+
+		f := NodeOf(v)
+		f.Son = nodeTree(children, v.Id, seen)
+		n = append(n, f)
+	}
+	return n
 }
 
 type NodesInfoRequest struct {
@@ -218,17 +281,21 @@ type NodesResponse struct {
 func NodesInfo(c *gin.Context) {
 	resp := new(Resp)
 
+	respResult := new(NodesResponse)
+	req := new(NodesInfoRequest)
 	defer func() {
-		JSON(c, 200, resp)
+		if !ThemeHTML(c, "user.html", req, resp) {
+			JSON(c, 200, resp)
+		}
 	}()
 
-	respResult := new(NodesResponse)
-	req := new(NodesInfoRequest)
-	if errResp := ParseJSON(c, req); errResp != nil {
+	if errResp := ParseQuery(c, req); errResp != nil {
 		resp.Error = errResp
 		return
 	}
 
+	themeUser(c, req.UserId, req.UserName)
+
 	if req.UserId == 0 && req.UserName == "" {
 		flog.Log.Errorf("NodesInfo err:%s", "")
 		resp.Error = Error(ParasError, "user info empty")
@@ -257,62 +324,7 @@ func NodesInfo(c *gin.Context) {
 		return
 	}
 
-	father := make([]model.ContentNode, 0)
-	son := make([]model.ContentNode, 0)
-	for _, v := range nodes {
-		if v.Level == 0 {
-			father = append(father, v)
-		} else {
-			son = append(son, v)
-		}
-	}
-
-	n := make([]Node, 0)
-	for _, v := range father {
-		f := Node{}
-		f.Id = v.Id
-		f.Seo = v.Seo
-		f.Describe = v.Describe
-		f.ImagePath = v.ImagePath
-		f.Name = v.Name
-		if v.UpdateTime > 0 {
-			f.UpdateTime = GetSecond2DateTimes(v.UpdateTime)
-			f.UpdateTimeInt = v.UpdateTime
-		}
-		f.CreateTime = GetSecond2DateTimes(v.CreateTime)
-		f.CreateTimeInt = v.CreateTime
-		f.SortNum = v.SortNum
-		f.UserName = v.UserName
-		f.UserId = v.UserId
-		f.Level = v.Level
-		f.ParentNodeId = v.ParentNodeId
-		f.ContentNum = v.ContentNum
-		for _, vv := range son {
-			if vv.ParentNodeId == f.Id {
-				s := Node{}
-				s.Id = vv.Id
-				s.Seo = vv.Seo
-				s.Describe = vv.Describe
-				s.ImagePath = vv.ImagePath
-				s.Name = vv.Name
-				if vv.UpdateTime > 0 {
-					s.UpdateTimeInt = vv.UpdateTime
-					s.UpdateTime = GetSecond2DateTimes(vv.UpdateTime)
-				}
-				s.CreateTime = GetSecond2DateTimes(vv.CreateTime)
-				s.CreateTimeInt = vv.CreateTime
-				s.SortNum = vv.SortNum
-				s.UserId = vv.UserId
-				s.UserName = vv.UserName
-				s.Level = vv.Level
-				s.ContentNum = vv.ContentNum
-				s.ParentNodeId = vv.ParentNodeId
-				f.Son = append(f.Son, s)
-			}
-		}
-
-		n = append(n, f)
-	}
+	n := NodeTree(nodes, 0)
 
 	respResult.Nodes = n
 	resp.Flag = true
@@ -330,12 +342,14 @@ type NodeInfoRequest struct {
 func NodeInfo(c *gin.Context) {
 	resp := new(Resp)
 
+	req := new(NodeInfoRequest)
 	defer func() {
-		JSON(c, 200, resp)
+		if !ThemeHTML(c, "node.html", req, resp) {
+			JSON(c, 200, resp)
+		}
 	}()
 
-	req := new(NodeInfoRequest)
-	if errResp := ParseJSON(c, req); errResp != nil {
+	if errResp := ParseQuery(c, req); errResp != nil {
 		resp.Error = errResp
 		return
 	}
@@ -389,56 +403,20 @@ func NodeInfo(c *gin.Context) {
 		return
 	}
 
-	f := Node{}
-	f.Id = v.Id
-	f.Seo = v.Seo
-	f.Describe = v.Describe
-	f.ImagePath = v.ImagePath
-	f.Name = v.Name
-	if v.UpdateTime > 0 {
-		f.UpdateTime = GetSecond2DateTimes(v.UpdateTime)
-		f.UpdateTimeInt = v.UpdateTime
-	}
-	f.CreateTime = GetSecond2DateTimes(v.CreateTime)
-	f.CreateTimeInt = v.CreateTime
-	f.SortNum = v.SortNum
-	f.UserName = v.UserName
-	f.UserId = v.UserId
-	f.Level = v.Level
-	f.ParentNodeId = v.ParentNodeId
-	f.ContentNum = v.ContentNum
+	themeUser(c, v.UserId, v.UserName)
+
+	f := NodeOf(*v)
 
-	// 是顶层且需要列出儿子
-	if f.Level == 0 && req.ListSon {
-		ns := make([]model.ContentNode, 0)
-		err = model.FaFaRdb.Client.Where("parent_node_id=?", f.Id).And("status=?", 0).Find(&ns)
+	// list all the children, hidden one and its children not
+	if req.ListSon {
+		ns, err := v.Children(true)
 		if err != nil {
 			flog.Log.Errorf("NodeInfo err:%s", err.Error())
 			resp.Error = Error(DBError, err.Error())
 			return
 		}
 
-		for _, vv := range ns {
-			ff := Node{}
-			ff.Id = vv.Id
-			ff.Seo = vv.Seo
-			ff.Describe = vv.Describe
-			ff.ImagePath = vv.ImagePath
-			ff.Name = vv.Name
-			if vv.UpdateTime > 0 {
-				ff.UpdateTime = GetSecond2DateTimes(vv.UpdateTime)
-				ff.UpdateTimeInt = vv.UpdateTime
-			}
-			ff.CreateTime = GetSecond2DateTimes(vv.CreateTime)
-			ff.CreateTimeInt = vv.CreateTime
-			ff.SortNum = vv.SortNum
-			ff.UserName = vv.UserName
-			ff.UserId = vv.UserId
-			ff.Level = vv.Level
-			ff.ParentNodeId = vv.ParentNodeId
-			ff.ContentNum = vv.ContentNum
-			f.Son = append(f.Son, ff)
-		}
+		f.Son = NodeTree(ns, f.Id)
 	}
 	resp.Flag = true
 	resp.Data = f
@@ -452,12 +430,14 @@ type UserInfoRequest struct {
 func UserInfo(c *gin.Context) {
 	resp := new(Resp)
 
+	req := new(UserInfoRequest)
 	defer func() {
-		JSON(c, 200, resp)
+		if !ThemeHTML(c, "user.html", req, resp) {
+			JSON(c, 200, resp)
+		}
 	}()
 
-	req := new(UserInfoRequest)
-	if errResp := ParseJSON(c, req); errResp != nil {
+	if errResp := ParseQuery(c, req); errResp != nil {
 		resp.Error = errResp
 		return
 	}
@@ -483,6 +463,8 @@ func UserInfo(c *gin.Context) {
 		return
 	}
 
+	themeUser(c, user.Id, user.Name)
+
 	v := user
 	p := People{}
 	p.Id = v.Id
@@ -619,34 +601,40 @@ type ContentsRequest struct {
 	FirstPublishTimeEnd   int64    `json:"first_publish_time_end"`
 	PublishTimeBegin      int64    `json:"publish_time_begin"`
 	PublishTimeEnd        int64    `json:"publish_time_end"`
+	Render                bool     `json:"render"`
 	Sort                  []string `json:"sort"`
 	PageHelp
 }
 
 type ContentsX struct {
-	Id                  int64      `json:"id"`
-	Seo                 string     `json:"seo"`
-	Title               string     `json:"title"`
-	UserId              int64      `json:"user_id"`
-	UserName            string     `json:"user_name"`
-	NodeId              int64      `json:"node_id"`
-	NodeSeo             string     `json:"node_seo"`
-	Top                 int        `json:"top"`
-	FirstPublishTime    string     `json:"first_publish_time"`
-	PublishTime         string     `json:"publish_time,omitempty"`
-	FirstPublishTimeInt int64      `json:"first_publish_time_int"`
-	PublishTimeInt      int64      `json:"publish_time_int"`
-	ImagePath           string     `json:"image_path"`
-	Views               int64      `json:"views"`
-	IsLock              bool       `json:"is_lock"`
-	Describe            string     `json:"describe"`
-	Next                *ContentsX `json:"next,omitempty"`
-	Pre                 *ContentsX `json:"pre,omitempty"`
-	SortNum             int64      `json:"sort_num"`
-	Bad                 int64      `json:"bad"`
-	Cool                int64      `json:"cool"`
-	CommentNum          int64      `json:"comment_num"`
-	IsBan               bool       `json:"is_ban"`
+	Id                  int64          `json:"id"`
+	Seo                 string         `json:"seo"`
+	Title               string         `json:"title"`
+	UserId              int64          `json:"user_id"`
+	UserName            string         `json:"user_name"`
+	NodeId              int64          `json:"node_id"`
+	NodeSeo             string         `json:"node_seo"`
+	Top                 int            `json:"top"`
+	FirstPublishTime    string         `json:"first_publish_time"`
+	PublishTime         string         `json:"publish_time,omitempty"`
+	FirstPublishTimeInt int64          `json:"first_publish_time_int"`
+	PublishTimeInt      int64          `json:"publish_time_int"`
+	ImagePath           string         `json:"image_path"`
+	Views               int64          `json:"views"`
+	IsLock              bool           `json:"is_lock"`
+	Describe            string         `json:"describe"`
+	Next                *ContentsX     `json:"next,omitempty"`
+	Pre                 *ContentsX     `json:"pre,omitempty"`
+	SortNum             int64          `json:"sort_num"`
+	Bad                 int64          `json:"bad"`
+	Cool                int64          `json:"cool"`
+	CommentNum          int64          `json:"comment_num"`
+	IsBan               bool           `json:"is_ban"`
+	Tags                []string       `json:"tags,omitempty"`
+	DescribeHtml        string         `json:"describe_html,omitempty"` // render is true will have, describe will be empty
+	Toc                 []util.TocItem `json:"toc,omitempty"`
+	WordNum             int64          `json:"word_num"`
+	ReadMinute          int64          `json:"read_minute"`
 }
 
 type ContentsResponse struct {
@@ -654,20 +642,81 @@ type ContentsResponse struct {
 	PageHelp
 }
 
+// Content in list, describe only show a little, render will use the plain text excerpt
+func contentsX(c model.Content, render bool) ContentsX {
+	temp := ContentsX{}
+	temp.UserId = c.UserId
+	temp.Seo = c.Seo
+	temp.SortNum = c.SortNum
+	temp.NodeSeo = c.NodeSeo
+	temp.UserName = c.UserName
+	temp.Id = c.Id
+	temp.Top = c.Top
+	temp.Title = c.Title
+	temp.NodeId = c.NodeId
+	temp.Views = c.Views
+	temp.ImagePath = c.ImagePath
+	temp.FirstPublishTime = GetSecond2DateTimes(c.FirstPublishTime)
+	temp.PublishTime = GetSecond2DateTimes(c.PublishTime)
+	temp.FirstPublishTimeInt = c.FirstPublishTime
+	temp.PublishTimeInt = c.PublishTime
+	temp.CommentNum = c.CommentNum
+	temp.Bad = c.Bad
+	temp.Cool = c.Cool
+	if c.Status == 2 {
+		temp.IsBan = true
+	}
+
+	if c.Password != "" {
+		temp.IsLock = true
+	}
+
+	if render {
+		c.RenderIfNeed()
+		temp.WordNum = c.WordNum
+		temp.ReadMinute = c.ReadMinute
+		if !temp.IsLock {
+			temp.Describe = c.Excerpt
+		}
+		return temp
+	}
+
+	if len(c.Describe) > 50 {
+		temp.Describe = c.Describe[:50]
+	} else {
+		temp.Describe = c.Describe
+	}
+	return temp
+}
+
 func Contents(c *gin.Context) {
 	resp := new(Resp)
 
 	respResult := new(ContentsResponse)
 	req := new(ContentsRequest)
+	page := "home.html"
 	defer func() {
-		JSONL(c, 200, req, resp)
+		if !ThemeHTML(c, page, req, resp) {
+			JSONL(c, 200, req, resp)
+		}
 	}()
 
-	if errResp := ParseJSON(c, req); errResp != nil {
+	if errResp := ParseQuery(c, req); errResp != nil {
 		resp.Error = errResp
 		return
 	}
 
+	// content of node or user in the page of them, html page show the excerpt
+	if ThemeWant(c) {
+		req.Render = true
+		if req.NodeId != 0 || req.NodeSeo != "" {
+			page = "node.html"
+		} else if req.UserId != 0 || req.UserName != "" {
+			page = "user.html"
+		}
+		themeUser(c, req.UserId, req.UserName)
+	}
+
 	var validate = validator.New()
 	err := validate.Struct(req)
 	if err != nil {
@@ -738,7 +787,7 @@ func Contents(c *gin.Context) {
 		// sql build
 		p.build(session, req.Sort, model.ContentSortName2)
 		// do query
-		err = session.Omit("pre_describe", "pre_title").Find(&cs)
+		err = session.Omit("pre_describe", "pre_title", "describe_html", "toc").Find(&cs)
 		if err != nil {
 			flog.Log.Errorf("Contents err:%s", err.Error())
 			resp.Error = Error(DBError, err.Error())
@@ -749,39 +798,7 @@ func Contents(c *gin.Context) {
 	// result
 	bcs := make([]ContentsX, 0, len(cs))
 	for _, c := range cs {
-		temp := ContentsX{}
-		temp.UserId = c.UserId
-		temp.Seo = c.Seo
-		temp.SortNum = c.SortNum
-		temp.NodeSeo = c.NodeSeo
-		temp.UserName = c.UserName
-		temp.Id = c.Id
-		temp.Top = c.Top
-		temp.Title = c.Title
-		temp.NodeId = c.NodeId
-		temp.Views = c.Views
-		temp.ImagePath = c.ImagePath
-		temp.FirstPublishTime = GetSecond2DateTimes(c.FirstPublishTime)
-		temp.PublishTime = GetSecond2DateTimes(c.PublishTime)
-		temp.FirstPublishTimeInt = c.FirstPublishTime
-		temp.PublishTimeInt = c.PublishTime
-		temp.CommentNum = c.CommentNum
-		temp.Bad = c.Bad
-		temp.Cool = c.Cool
-		if c.Status == 2 {
-			temp.IsBan = true
-		}
-
-		if c.Password != "" {
-			temp.IsLock = true
-		}
-
-		if len(c.Describe) > 50 {
-			temp.Describe = c.Describe[:50]
-		} else {
-			temp.Describe = c.Describe
-		}
-		bcs = append(bcs, temp)
+		bcs = append(bcs, contentsX(c, req.Render))
 	}
 
 	respResult.Contents = bcs
@@ -799,20 +816,28 @@ type ContentRequest struct {
 	Seo      string `json:"seo"`
 	Password string `json:"password"`
 	More     bool   `json:"more"`
+	Render   bool   `json:"render"` // true return the html render from markdown, not the raw describe
 }
 
 func Content(c *gin.Context) {
 	resp := new(Resp)
 	req := new(ContentRequest)
 	defer func() {
-		JSONL(c, 200, req, resp)
+		if !ThemeHTML(c, "content.html", req, resp) {
+			JSONL(c, 200, req, resp)
+		}
 	}()
 
-	if errResp := ParseJSON(c, req); errResp != nil {
+	if errResp := ParseQuery(c, req); errResp != nil {
 		resp.Error = errResp
 		return
 	}
 
+	// html page show the render one
+	if ThemeWant(c) {
+		req.Render = true
+	}
+
 	var validate = validator.New()
 	err := validate.Struct(req)
 	if err != nil {
@@ -853,6 +878,8 @@ func Content(c *gin.Context) {
 		return
 	}
 
+	themeUser(c, content.UserId, content.UserName)
+
 	if content.Status == 0 {
 
 	} else if content.Status == 2 {
@@ -901,7 +928,21 @@ func Content(c *gin.Context) {
 		temp.IsLock = true
 	}
 
-	temp.Describe = cx.Describe
+	if req.Render {
+		cx.RenderIfNeed()
+		temp.DescribeHtml = cx.DescribeHtml
+		temp.Toc = cx.Toc
+	} else {
+		temp.Describe = cx.Describe
+	}
+	temp.WordNum = cx.WordNum
+	temp.ReadMinute = cx.ReadMinute
+	temp.Tags, err = model.ContentTagNames(cx.Id)
+	if err != nil {
+		flog.Log.Errorf("Content err:%s", err.Error())
+		resp.Error = Error(DBError, err.Error())
+		return
+	}
 
 	cx.UpdateView()
 
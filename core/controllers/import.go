@@ -0,0 +1,497 @@
+package controllers
+
+import (
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/hunterhug/fafacms/core/flog"
+	"github.com/hunterhug/fafacms/core/model"
+	"github.com/hunterhug/fafacms/core/util"
+	"io/ioutil"
+	"path"
+	"strings"
+	"time"
+)
+
+var (
+	// the zip or xml upload at most so big
+	ImportFileBytes = 1 << 27
+
+	// all the file in the zip at most so big
+	ImportUnzipBytes int64 = 1 << 29
+
+	// post without category put in this node when not set
+	ImportNodeSeo  = "import"
+	ImportNodeName = "Import"
+)
+
+type ImportContentOption struct {
+	NodeId  int64 // post without category put here, 0 use the import node
+	Publish bool  // publish the post not draft
+	DryRun  bool  // only check, nothing will write
+}
+
+type ImportContentItem struct {
+	util.ImportPost
+	Status     string   `json:"status"` // ok, fail, dry
+	ContentId  int64    `json:"content_id"`
+	NodeId     int64    `json:"node_id"`
+	NodeName   string   `json:"node_name"`
+	NodeCreate bool     `json:"node_create"`
+	Publish    bool     `json:"publish"`
+	Review     bool     `json:"review"`      // publish need review, import as draft and submit it
+	Images     int      `json:"images"`      // local image upload num
+	ImagesMiss []string `json:"images_miss"` // local image not found in the files
+}
+
+type ImportContentResponse struct {
+	DryRun  bool                `json:"dry_run"`
+	Total   int                 `json:"total"`
+	Success int                 `json:"success"`
+	Fail    int                 `json:"fail"`
+	Items   []ImportContentItem `json:"items"`
+}
+
+/*
+file: zip of markdown with hugo or jekyll front matter, or wordpress export xml, or one markdown
+node_id: post without category put in this node, empty will create a node called import
+publish: 1 publish the post not draft, 0 all be draft, publish need review will be draft and submit for review
+dry_run: 1 only check and return the result, nothing write
+*/
+func ImportContent(c *gin.Context) {
+	resp := new(Resp)
+	defer func() {
+		JSONL(c, 200, nil, resp)
+	}()
+
+	uu, err := GetUserSession(c)
+	if err != nil {
+		flog.Log.Errorf("ImportContent err: %s", err.Error())
+		resp.Error = Error(GetUserSessionError, err.Error())
+		return
+	}
+
+	if uu.Vip == 0 {
+		flog.Log.Errorf("ImportContent err: %s", "not vip")
+		resp.Error = Error(VipError, "")
+		return
+	}
+
+	opt := ImportContentOption{}
+	if v := c.DefaultPostForm("node_id", ""); v != "" {
+		id, err := util.SI(v)
+		if err != nil {
+			flog.Log.Errorf("ImportContent err: %s", err.Error())
+			resp.Error = Error(ParasError, "node_id wrong")
+			return
+		}
+		opt.NodeId = int64(id)
+	}
+	opt.Publish = c.DefaultPostForm("publish", "") == "1"
+	opt.DryRun = c.DefaultPostForm("dry_run", "") == "1"
+
+	h, err := c.FormFile("file")
+	if err != nil {
+		flog.Log.Errorf("ImportContent err: %s", err.Error())
+		resp.Error = Error(UploadFileError, err.Error())
+		return
+	}
+
+	if h.Size > int64(ImportFileBytes) {
+		flog.Log.Errorf("ImportContent err: file size too big: %d", h.Size)
+		resp.Error = Error(UploadFileTooMaxLimit, fmt.Sprintf("file size too big: %d", h.Size))
+		return
+	}
+
+	f, err := h.Open()
+	if err != nil {
+		flog.Log.Errorf("ImportContent err: %s", err.Error())
+		resp.Error = Error(UploadFileError, err.Error())
+		return
+	}
+	defer f.Close()
+
+	raw, err := ioutil.ReadAll(f)
+	if err != nil {
+		flog.Log.Errorf("ImportContent err: %s", err.Error())
+		resp.Error = Error(UploadFileError, err.Error())
+		return
+	}
+
+	files := make(map[string][]byte)
+	switch strings.ToLower(util.GetFileSuffix(h.Filename)) {
+	case "zip":
+		files, err = util.ImportUnzip(raw, ImportUnzipBytes)
+		if err != nil {
+			flog.Log.Errorf("ImportContent err: %s", err.Error())
+			resp.Error = Error(UploadFileError, err.Error())
+			return
+		}
+	case "xml", "md", "markdown":
+		files[path.Base(h.Filename)] = raw
+	default:
+		flog.Log.Errorf("ImportContent err: file suffix: %s not permit", util.GetFileSuffix(h.Filename))
+		resp.Error = Error(UploadFileTypeNotPermit, "only zip, xml or markdown")
+		return
+	}
+
+	result, errResp := ImportContents(uu, files, opt)
+	if errResp != nil {
+		resp.Error = errResp
+		return
+	}
+
+	resp.Data = result
+	resp.Flag = true
+}
+
+// Import the posts in the files, one fail the others still go on, the command line use it too
+func ImportContents(uu *model.User, files map[string][]byte, opt ImportContentOption) (*ImportContentResponse, *ErrorResp) {
+	im := &importer{
+		user:  uu,
+		files: files,
+		opt:   opt,
+		nodes: make(map[string]*model.ContentNode),
+		seo:   make(map[string]bool),
+		count: make(map[int64]bool),
+	}
+
+	errResp := im.loadNodes()
+	if errResp != nil {
+		return nil, errResp
+	}
+
+	loc := time.FixedZone("", int(3600*TimeZone))
+	result := &ImportContentResponse{DryRun: opt.DryRun, Items: make([]ImportContentItem, 0)}
+	for _, post := range util.ImportParse(files, loc) {
+		item := im.importOne(post)
+		if item.Status == "fail" {
+			result.Fail++
+		} else {
+			result.Success++
+		}
+		result.Items = append(result.Items, item)
+	}
+	result.Total = len(result.Items)
+
+	if !opt.DryRun && len(im.count) > 0 {
+		im.afterImport()
+	}
+	return result, nil
+}
+
+type importer struct {
+	user    *model.User
+	files   map[string][]byte
+	opt     ImportContentOption
+	nodes   map[string]*model.ContentNode // lower name or seo to node
+	def     *model.ContentNode            // node of post without category
+	seo     map[string]bool               // seo take by this import
+	count   map[int64]bool                // node need count again
+	publish []int64                       // content publish need index
+}
+
+func (im *importer) loadNodes() *ErrorResp {
+	nodes := make([]model.ContentNode, 0)
+	err := model.FaFaRdb.Client.Where("user_id=?", im.user.Id).Asc("level", "id").Find(&nodes)
+	if err != nil {
+		flog.Log.Errorf("ImportContent err: %s", err.Error())
+		return Error(DBError, err.Error())
+	}
+
+	for k := range nodes {
+		n := &nodes[k]
+		for _, key := range []string{strings.ToLower(n.Name), strings.ToLower(n.Seo)} {
+			if _, ok := im.nodes[key]; !ok {
+				im.nodes[key] = n
+			}
+		}
+
+		if im.opt.NodeId != 0 && n.Id == im.opt.NodeId {
+			im.def = n
+		}
+	}
+
+	if im.opt.NodeId != 0 && im.def == nil {
+		flog.Log.Errorf("ImportContent err: %s", "node not found")
+		return Error(ContentNodeNotFound, "")
+	}
+	return nil
+}
+
+// Find the node by category name, not exist create one in the top level
+func (im *importer) node(name string) (*model.ContentNode, bool, error) {
+	key := strings.ToLower(name)
+	if name == "" {
+		if im.def != nil {
+			return im.def, false, nil
+		}
+		name = ImportNodeName
+		key = ImportNodeSeo
+	}
+
+	if n, ok := im.nodes[key]; ok {
+		return n, false, nil
+	}
+
+	n := new(model.ContentNode)
+	n.UserId = im.user.Id
+	n.UserName = im.user.Name
+	n.Name = util.Substr(name, 0, 100)
+	n.Seo = util.ImportSeo(key)
+	if n.Seo == "" {
+		n.Seo = "node"
+	}
+
+	for i, seo := 1, n.Seo; ; i++ {
+		exist := false
+		for _, v := range im.nodes {
+			if v.Seo == n.Seo {
+				exist = true
+				break
+			}
+		}
+
+		if !exist {
+			break
+		}
+		n.Seo = fmt.Sprintf("%s%d", seo, i)
+	}
+
+	if !im.opt.DryRun {
+		var err error
+		n.SortNum, err = n.CountNodeNum()
+		if err != nil {
+			return nil, false, err
+		}
+
+		err = n.InsertOne()
+		if err != nil {
+			return nil, false, err
+		}
+	}
+
+	im.nodes[key] = n
+	if name == ImportNodeName && key == ImportNodeSeo {
+		im.def = n
+	}
+	return n, true, nil
+}
+
+// Seo repeat add number at the end
+func (im *importer) contentSeo(seo string) (string, error) {
+	if seo == "" {
+		seo = "content"
+	}
+
+	content := new(model.Content)
+	content.UserId = im.user.Id
+	for i, base := 1, seo; ; i++ {
+		content.Seo = seo
+		exist, err := content.CheckSeoValid()
+		if err != nil {
+			return "", err
+		}
+
+		if !exist && !im.seo[seo] {
+			im.seo[seo] = true
+			return seo, nil
+		}
+		seo = fmt.Sprintf("%s%d", base, i)
+	}
+}
+
+// Upload the local image and change the url in the markdown
+func (im *importer) images(item *ImportContentItem) error {
+	item.ImagesMiss = make([]string, 0)
+	for _, ref := range util.ImportImages(item.Describe) {
+		name, ok := util.ImportFindFile(im.files, item.Path, ref)
+		if !ok {
+			// remote image not in the zip keep it
+			if !strings.Contains(ref, "//") {
+				item.ImagesMiss = append(item.ImagesMiss, ref)
+			}
+			continue
+		}
+
+		if !util.InArray(FileAllow["image"], strings.ToLower(util.GetFileSuffix(name))) || len(im.files[name]) == 0 || len(im.files[name]) > FileBytes {
+			item.ImagesMiss = append(item.ImagesMiss, ref)
+			continue
+		}
+
+		item.Images++
+		if im.opt.DryRun {
+			continue
+		}
+
+		p, _, errResp := saveFile(im.user, "image", "import", item.Path, path.Base(name), im.files[name])
+		if errResp != nil {
+			return errResp
+		}
+		item.Describe = strings.Replace(item.Describe, "("+ref, "("+p.Url, -1)
+	}
+	return nil
+}
+
+func (im *importer) importOne(post util.ImportPost) (item ImportContentItem) {
+	item.ImportPost = post
+	item.Status = "fail"
+	if item.Error != "" {
+		return
+	}
+
+	fail := func(err error) {
+		flog.Log.Errorf("ImportContent %s err: %s", item.Path, err.Error())
+		item.Error = err.Error()
+	}
+
+	category := ""
+	if len(post.Categories) > 0 {
+		category = post.Categories[0]
+	}
+
+	node, create, err := im.node(category)
+	if err != nil {
+		fail(err)
+		return
+	}
+	item.NodeId = node.Id
+	item.NodeName = node.Name
+	item.NodeCreate = create
+
+	item.Seo, err = im.contentSeo(post.Seo)
+	if err != nil {
+		fail(err)
+		return
+	}
+
+	err = im.images(&item)
+	if err != nil {
+		fail(err)
+		return
+	}
+
+	item.Publish = im.opt.Publish && !post.Draft
+	if item.Publish {
+		need, err := reviewNeeded(im.user, &model.Content{NodeId: node.Id})
+		if err != nil {
+			fail(err)
+			return
+		}
+
+		if need {
+			item.Publish = false
+			item.Review = true
+		}
+	}
+
+	if im.opt.DryRun {
+		item.Status = "dry"
+		return
+	}
+
+	content := new(model.Content)
+	content.UserId = im.user.Id
+	content.UserName = im.user.Name
+	content.Seo = item.Seo
+	content.NodeId = node.Id
+	content.NodeSeo = node.Seo
+	content.PreTitle = util.Substr(post.Title, 0, 200)
+	content.PreDescribe = item.Describe
+	content.Password = post.Password
+	content.Revision = 1
+	if post.Hide {
+		content.Status = 1
+	}
+	content.SortNum, _ = content.CountNumUnderNode()
+	_, err = content.Insert()
+	if err != nil {
+		fail(err)
+		return
+	}
+	item.ContentId = content.Id
+	im.count[node.Id] = true
+
+	if len(post.Tags) > 0 {
+		tags, errResp := setContentTags(im.user, content.Id, post.Tags)
+		if errResp != nil {
+			fail(errResp)
+			return
+		}
+		item.Tags = tags
+	}
+
+	// the reviewer publish it later
+	if item.Review {
+		r := new(model.ContentReview)
+		r.ContentId = content.Id
+		r.ContentTitle = content.PreTitle
+		r.UserId = content.UserId
+		r.NodeId = content.NodeId
+		r.SubmitUserId = im.user.Id
+		r.Status = model.ContentReviewWaiting
+		r.Revision = content.Revision
+		err = r.Insert()
+		if err != nil {
+			fail(err)
+			return
+		}
+
+		go reviewSubmitTell(r)
+	}
+
+	if item.Publish {
+		err = content.PublishDescribe()
+		if err != nil {
+			fail(err)
+			return
+		}
+
+		// keep the time of the old blog
+		if post.Date > 0 {
+			content.CreateTime = post.Date
+			content.FirstPublishTime = post.Date
+			content.PublishTime = post.Date
+			if post.Updated > post.Date {
+				content.PublishTime = post.Updated
+			}
+
+			_, err = content.UpdatePublishTime()
+			if err != nil {
+				fail(err)
+				return
+			}
+		}
+		im.publish = append(im.publish, content.Id)
+	}
+
+	item.Status = "ok"
+	return
+}
+
+// Count and index at once, not tell the followers
+func (im *importer) afterImport() {
+	for nodeId := range im.count {
+		err := model.CountContentOneNode(im.user.Id, nodeId)
+		if err != nil {
+			flog.Log.Errorf("ImportContent count node err: %s", err.Error())
+		}
+	}
+
+	err := model.CountContentAll(im.user.Id)
+	if err != nil {
+		flog.Log.Errorf("ImportContent count all content err: %s", err.Error())
+	}
+
+	err = model.CountTagContent(im.user.Id)
+	if err != nil {
+		flog.Log.Errorf("ImportContent count tag content err: %s", err.Error())
+	}
+
+	for _, id := range im.publish {
+		err = model.SearchIndexContent(id)
+		if err != nil {
+			flog.Log.Errorf("ImportContent search index %d err: %s", id, err.Error())
+		}
+	}
+	RefreshSitemap()
+}
@@ -12,6 +12,7 @@ import (
 type LoginRequest struct {
 	UserName string `json:"user_name"`
 	PassWd   string `json:"pass_wd"`
+	CaptchaRequest
 }
 
 func Login(c *gin.Context) {
@@ -42,25 +43,6 @@ func Login(c *gin.Context) {
 		return
 	}
 
-	// super root user login
-	if req.UserName == "hunterhug" && req.PassWd == "hunterhug" {
-		u := new(model.User)
-		u.Id = -1
-		u.Name = "hunterhug"
-		u.Status = 1
-		token, err := SetUserSession(u)
-		if err != nil {
-			flog.Log.Errorf("login set root err:%s", err.Error())
-			resp.Error = Error(SetUserSessionError, err.Error())
-			return
-		}
-
-		c.Set("uid", u.Id)
-		resp.Data = token
-		resp.Flag = true
-		return
-	}
-
 	// common people login
 	uu := new(model.User)
 	if strings.Contains(req.UserName, "@") {
@@ -68,7 +50,6 @@ func Login(c *gin.Context) {
 	} else {
 		uu.Name = req.UserName
 	}
-	uu.Password = req.PassWd
 	ok, err := uu.GetRaw()
 	if err != nil {
 		flog.Log.Errorf("login err:%s", err.Error())
@@ -76,12 +57,93 @@ func Login(c *gin.Context) {
 		return
 	}
 
+	// too many fail will be lock a while, count by user so login by name or email is the same
+	attempt := NewAttempt(c, AttemptSceneLogin, req.UserName)
+	if ok {
+		attempt.UserId = uu.Id
+	}
+
+	if remain := attempt.Locked(); remain > 0 {
+		flog.Log.Errorf("login err:%s", "too many attempts")
+		attemptLockError(resp, remain)
+		return
+	}
+
+	// fail many times need captcha
+	if CaptchaLoginFailTimes > 0 && attempt.FailTimes() >= CaptchaLoginFailTimes {
+		if !CheckCaptcha(req.CaptchaId, req.CaptchaCode) {
+			flog.Log.Errorf("login err:%s", "captcha wrong")
+			resp.Error = Error(CaptchaWrong, "")
+			return
+		}
+	}
+
 	if !ok {
 		flog.Log.Errorf("login err:%s", "user or password wrong")
 		resp.Error = Error(LoginWrong, "user or password wrong")
+		if remain := attempt.Fail(); remain > 0 {
+			attemptLockError(resp, remain)
+		}
 		return
 	}
 
+	// password save as hash, check it here
+	right, rehash := uu.CheckPassword(req.PassWd)
+	if !right {
+		flog.Log.Errorf("login err:%s", "user or password wrong")
+		resp.Error = Error(LoginWrong, "user or password wrong")
+		uu.RecordFailAttempt(c.ClientIP())
+		if remain := attempt.Fail(); remain > 0 {
+			attemptLockError(resp, remain)
+		}
+		return
+	}
+
+	// legacy plain text password will hash when login success
+	if rehash {
+		err = uu.UpdatePasswordHash(req.PassWd)
+		if err != nil {
+			flog.Log.Errorf("login rehash password err:%s", err.Error())
+		}
+	}
+
+	// two factor open, return a challenge, then use it and the totp code to get token,
+	// the login fail record not clear until the second factor right
+	if uu.TwoFactorEnable == 1 {
+		if remain := twoFactorLocked(uu.Id); remain > 0 {
+			flog.Log.Errorf("login err:%s", "two factor too many attempts")
+			attemptLockError(resp, remain)
+			return
+		}
+
+		challenge, err := SetTwoFactorChallenge(uu.Id)
+		if err != nil {
+			flog.Log.Errorf("login err:%s", err.Error())
+			resp.Error = Error(SetUserSessionError, err.Error())
+			return
+		}
+
+		c.Set("uid", uu.Id)
+		c.Set("auditSecretData", true)
+		resp.Error = Error(TwoFactorRequired, "")
+		resp.Data = challenge
+		return
+	}
+
+	attempt.Success()
+
+	token, errResp := loginUser(c, uu)
+	if errResp != nil {
+		resp.Error = errResp
+		return
+	}
+
+	resp.Data = token
+	resp.Flag = true
+}
+
+// Password and two factor all right, now set the session
+func loginUser(c *gin.Context, uu *model.User) (string, *ErrorResp) {
 	c.Set("uid", uu.Id)
 
 	u := new(model.User)
@@ -96,15 +158,13 @@ func Login(c *gin.Context) {
 	session.FafaSessionMgr.RefreshUser([]int64{u.Id}, SessionExpireTime)
 
 	// Activate or black user can login, but those auth api can not use
-	token, err := SetUserSession(uu)
+	token, err := SetUserSession(c, uu)
 	if err != nil {
 		flog.Log.Errorf("login err:%s", err.Error())
-		resp.Error = Error(SetUserSessionError, err.Error())
-		return
+		return "", Error(SetUserSessionError, err.Error())
 	}
 
-	resp.Data = token
-	resp.Flag = true
+	return token, nil
 }
 
 func Logout(c *gin.Context) {
@@ -151,3 +211,119 @@ func Refresh(c *gin.Context) {
 	}
 	resp.Flag = true
 }
+
+type UserSession struct {
+	session.TokenInfo
+	Current bool `json:"current"`
+}
+
+// List the login devices of myself
+func ListUserSession(c *gin.Context) {
+	resp := new(Resp)
+	defer func() {
+		JSON(c, 200, resp)
+	}()
+
+	uu, err := GetUserSession(c)
+	if err != nil {
+		flog.Log.Errorf("ListUserSession err: %s", err.Error())
+		resp.Error = Error(GetUserSessionError, err.Error())
+		return
+	}
+
+	infos, err := session.FafaSessionMgr.ListUserToken(uu.Id)
+	if err != nil {
+		flog.Log.Errorf("ListUserSession err: %s", err.Error())
+		resp.Error = Error(GetUserSessionError, err.Error())
+		return
+	}
+
+	token := c.GetHeader(AuthHeader)
+	result := make([]UserSession, 0, len(infos))
+	for _, v := range infos {
+		s := UserSession{TokenInfo: v}
+		s.Current = v.Token == token
+
+		// token can not show
+		s.Token = ""
+		result = append(result, s)
+	}
+
+	resp.Data = result
+	resp.Flag = true
+}
+
+type RevokeUserSessionRequest struct {
+	Id    string `json:"id"`
+	Other bool   `json:"other"` // log out everywhere else
+}
+
+func RevokeUserSession(c *gin.Context) {
+	resp := new(Resp)
+	req := new(RevokeUserSessionRequest)
+	defer func() {
+		JSONL(c, 200, req, resp)
+	}()
+
+	if errResp := ParseJSON(c, req); errResp != nil {
+		resp.Error = errResp
+		return
+	}
+
+	if req.Id == "" && !req.Other {
+		flog.Log.Errorf("RevokeUserSession err: %s", "id or other empty")
+		resp.Error = Error(ParasError, "id or other empty")
+		return
+	}
+
+	uu, err := GetUserSession(c)
+	if err != nil {
+		flog.Log.Errorf("RevokeUserSession err: %s", err.Error())
+		resp.Error = Error(GetUserSessionError, err.Error())
+		return
+	}
+
+	token := c.GetHeader(AuthHeader)
+	if req.Other {
+		err = session.FafaSessionMgr.DeleteUserTokenExcept(uu.Id, token)
+		if err != nil {
+			flog.Log.Errorf("RevokeUserSession err: %s", err.Error())
+			resp.Error = Error(DeleteUserSessionError, err.Error())
+			return
+		}
+
+		resp.Flag = true
+		return
+	}
+
+	// find first, not found should tell
+	infos, err := session.FafaSessionMgr.ListUserToken(uu.Id)
+	if err != nil {
+		flog.Log.Errorf("RevokeUserSession err: %s", err.Error())
+		resp.Error = Error(GetUserSessionError, err.Error())
+		return
+	}
+
+	exist := false
+	for _, v := range infos {
+		if v.Id == req.Id {
+			exist = true
+			break
+		}
+	}
+
+	if !exist {
+		flog.Log.Errorf("RevokeUserSession err: %s", "session not found")
+		resp.Error = Error(UserSessionNotFound, "")
+		return
+	}
+
+	err = session.FafaSessionMgr.DeleteUserTokenById(uu.Id, req.Id)
+	if err != nil {
+		flog.Log.Errorf("RevokeUserSession err: %s", err.Error())
+		resp.Error = Error(DeleteUserSessionError, err.Error())
+		return
+	}
+
+	resp.Flag = true
+}
@@ -11,7 +11,7 @@ import (
 
 type ListMessageRequest struct {
 	MessageId       int64    `json:"message_id"`
-	MessageType     int      `json:"message_type" validate:"oneof=-1 0 1 2 3 4 5 6 7 8 9 10 11"`
+	MessageType     int      `json:"message_type" validate:"oneof=-1 0 1 2 3 4 5 6 7 8 9 10 11 12 13 14 15 16"`
 	ReceiveUserId   int64    `json:"receive_user_id"`
 	ChanelUserId    int64    `json:"chanel_user_id"`
 	ReceiveStatus   int      `json:"receive_status" validate:"oneof=-1 0 1 2"`
@@ -50,6 +50,26 @@ func CreateNode(c *gin.Context) {
 
 	n := new(model.ContentNode)
 	n.UserId = uu.Id
+	n.UserName = uu.Name
+
+	// under the node share to you, the new node belong to the owner
+	if req.ParentNodeId != 0 {
+		parent := new(model.ContentNode)
+		parent.Id = req.ParentNodeId
+		exist, err := getNodeWithRole(parent, uu.Id, model.CollaboratorPublisher)
+		if err != nil {
+			flog.Log.Errorf("CreateNode err: %s", err.Error())
+			resp.Error = Error(DBError, err.Error())
+			return
+		}
+		if !exist {
+			flog.Log.Errorf("CreateNode err: %s", "parent content node not found")
+			resp.Error = Error(ContentParentNodeNotFound, "")
+			return
+		}
+		n.UserId = parent.UserId
+		n.UserName = parent.UserName
+	}
 
 	// If seo not empty, check valid
 	if req.Seo != "" {
@@ -85,8 +105,6 @@ func CreateNode(c *gin.Context) {
 			resp.Error = Error(ContentParentNodeNotFound, "")
 			return
 		}
-
-		n.Level = 1
 	}
 
 	// if image not empty
@@ -110,9 +128,13 @@ func CreateNode(c *gin.Context) {
 	n.Name = req.Name
 	n.Describe = req.Describe
 	n.ParentNodeId = req.ParentNodeId
-	n.UserName = uu.Name
 	n.SortNum, _ = n.CountNodeNum()
 	err = n.InsertOne()
+	if err == model.ErrContentNodeTooDeep {
+		flog.Log.Errorf("CreateNode err:%s", err.Error())
+		resp.Error = Error(ContentNodeTooDeep, "")
+		return
+	}
 	if err != nil {
 		flog.Log.Errorf("CreateNode err:%s", err.Error())
 		resp.Error = Error(DBError, err.Error())
@@ -143,6 +165,11 @@ type UpdateSeoOfNodeRequest struct {
 	Seo string `json:"seo" validate:"required,alphanumunicode"`
 }
 
+type UpdateReviewOfNodeRequest struct {
+	Id     int64 `json:"id" validate:"required"`
+	Review int   `json:"review" validate:"oneof=0 1 2"` // 0 follow the parent, 1 need review, 2 cancel the need of parent node, group or site need still work
+}
+
 type UpdateParentOfNodeRequest struct {
 	Id           int64 `json:"id" validate:"required"`
 	ToBeRoot     bool  `json:"to_be_root"` // let the node to be root node, in the first level
@@ -177,10 +204,9 @@ func UpdateSeoOfNode(c *gin.Context) {
 	}
 	n := new(model.ContentNode)
 	n.Id = req.Id
-	n.UserId = uu.Id
 
 	// Get info of node
-	exist, err := n.Get()
+	exist, err := getNodeWithRole(n, uu.Id, model.CollaboratorPublisher)
 	if err != nil {
 		flog.Log.Errorf("UpdateSeoOfNode err: %s", err.Error())
 		resp.Error = Error(DBError, err.Error())
@@ -226,6 +252,7 @@ func UpdateSeoOfNode(c *gin.Context) {
 			return
 		}
 	}
+	RefreshSitemap()
 	resp.Flag = true
 }
 
@@ -257,9 +284,7 @@ func UpdateInfoOfNode(c *gin.Context) {
 	}
 	n := new(model.ContentNode)
 	n.Id = req.Id
-	n.UserId = uu.Id
-
-	exist, err := n.Get()
+	exist, err := getNodeWithRole(n, uu.Id, model.CollaboratorEditor)
 	if err != nil {
 		flog.Log.Errorf("UpdateInfoOfNode err: %s", err.Error())
 		resp.Error = Error(DBError, err.Error())
@@ -321,9 +346,7 @@ func UpdateImageOfNode(c *gin.Context) {
 	}
 	n := new(model.ContentNode)
 	n.Id = req.Id
-	n.UserId = uu.Id
-
-	exist, err := n.Get()
+	exist, err := getNodeWithRole(n, uu.Id, model.CollaboratorEditor)
 	if err != nil {
 		flog.Log.Errorf("UpdateInfoOfNode err: %s", err.Error())
 		resp.Error = Error(DBError, err.Error())
@@ -395,9 +418,7 @@ func UpdateStatusOfNode(c *gin.Context) {
 	}
 	n := new(model.ContentNode)
 	n.Id = req.Id
-	n.UserId = uu.Id
-
-	exist, err := n.Get()
+	exist, err := getNodeWithRole(n, uu.Id, model.CollaboratorPublisher)
 	if err != nil {
 		flog.Log.Errorf("UpdateStatusOfNode err: %s", err.Error())
 		resp.Error = Error(DBError, err.Error())
@@ -423,6 +444,60 @@ func UpdateStatusOfNode(c *gin.Context) {
 
 	go SendToLoop(n.UserId, 0, 1)
 	go SendToLoop(n.UserId, 0, 3)
+	RefreshSitemap()
+	resp.Flag = true
+}
+
+// Content under the node and its children need review before publish or not
+func UpdateReviewOfNode(c *gin.Context) {
+	resp := new(Resp)
+	req := new(UpdateReviewOfNodeRequest)
+	defer func() {
+		JSONL(c, 200, req, resp)
+	}()
+
+	if errResp := ParseJSON(c, req); errResp != nil {
+		resp.Error = errResp
+		return
+	}
+
+	var validate = validator.New()
+	err := validate.Struct(req)
+	if err != nil {
+		flog.Log.Errorf("UpdateReviewOfNode err: %s", err.Error())
+		resp.Error = Error(ParasError, err.Error())
+		return
+	}
+
+	uu, err := GetUserSession(c)
+	if err != nil {
+		flog.Log.Errorf("UpdateReviewOfNode err: %s", err.Error())
+		resp.Error = Error(GetUserSessionError, err.Error())
+		return
+	}
+	n := new(model.ContentNode)
+	n.Id = req.Id
+	n.UserId = uu.Id
+
+	exist, err := n.Get()
+	if err != nil {
+		flog.Log.Errorf("UpdateReviewOfNode err: %s", err.Error())
+		resp.Error = Error(DBError, err.Error())
+		return
+	}
+	if !exist {
+		flog.Log.Errorf("UpdateReviewOfNode err: %s", "content node not found")
+		resp.Error = Error(ContentNodeNotFound, "")
+		return
+	}
+
+	n.Review = req.Review
+	err = n.UpdateReview()
+	if err != nil {
+		flog.Log.Errorf("UpdateReviewOfNode err:%s", err.Error())
+		resp.Error = Error(DBError, err.Error())
+		return
+	}
 	resp.Flag = true
 }
 
@@ -452,6 +527,12 @@ func UpdateParentOfNode(c *gin.Context) {
 		return
 	}
 
+	if !req.ToBeRoot && req.ParentNodeId == 0 {
+		flog.Log.Errorf("UpdateParentOfNode err: %s", "parent empty")
+		resp.Error = Error(ParasError, "parent_node_id empty")
+		return
+	}
+
 	uu, err := GetUserSession(c)
 	if err != nil {
 		flog.Log.Errorf("UpdateParentOfNode err: %s", err.Error())
@@ -475,48 +556,26 @@ func UpdateParentOfNode(c *gin.Context) {
 		return
 	}
 
-	// Who has children can not be child due to we only design 2 level
-	childNum, err := n.CheckChildrenNum()
-	if err != nil {
-		flog.Log.Errorf("UpdateParentOfNode err: %s", err.Error())
-		resp.Error = Error(DBError, err.Error())
-		return
-	}
-
-	if childNum > 0 {
-		flog.Log.Errorf("UpdateParentOfNode err: %s", "has child")
-		resp.Error = Error(ContentNodeHasChildren, "has child")
-		return
-	}
-
 	beforeParentNode := n.ParentNodeId
-
-	after := new(model.ContentNode)
-	after.UserId = n.UserId
-	after.Id = n.Id
-	after.SortNum = n.SortNum
+	parentNodeId := req.ParentNodeId
 
 	// Let the node to be the first level
 	if req.ToBeRoot {
-		// has been
-		if n.ParentNodeId == 0 {
-			resp.Flag = true
-			return
-		}
-		// level first and parent zero
-		after.Level = 0
-		after.ParentNodeId = 0
-	} else {
-		// not change at all
-		if n.ParentNodeId == req.ParentNodeId {
-			resp.Flag = true
-			return
-		}
+		parentNodeId = 0
+	}
 
-		after.ParentNodeId = req.ParentNodeId
+	// not change at all
+	if n.ParentNodeId == parentNodeId {
+		resp.Flag = true
+		return
+	}
 
+	if parentNodeId != 0 {
 		// parent is exit?
-		exist, err := after.CheckParentValid()
+		parent := new(model.ContentNode)
+		parent.Id = parentNodeId
+		parent.UserId = uu.Id
+		exist, err := parent.Get()
 		if err != nil {
 			flog.Log.Errorf("UpdateParentOfNode err: %s", err.Error())
 			resp.Error = Error(DBError, err.Error())
@@ -528,19 +587,44 @@ func UpdateParentOfNode(c *gin.Context) {
 			return
 		}
 
-		// set in to 1
-		after.Level = 1
+		// children of the node can not be its parent
+		if n.IsAncestorOf(parent.Path) {
+			flog.Log.Errorf("UpdateParentOfNode err: %s", "parent is child")
+			resp.Error = Error(ContentNodeMoveIntoChild, "")
+			return
+		}
 	}
 
-	err = after.UpdateParent(beforeParentNode)
+	// the node and its children move together, at the end of the new level
+	err = n.Move(parentNodeId, -1)
 	if err != nil {
 		flog.Log.Errorf("UpdateParentOfNode err:%s", err.Error())
-		resp.Error = Error(DBError, err.Error())
+		resp.Error = nodeMoveError(err)
 		return
 	}
+
+	nodeMoveCount(n.UserId, n.Id, beforeParentNode)
 	resp.Flag = true
 }
 
+func nodeMoveError(err error) *ErrorResp {
+	switch err {
+	case model.ErrContentNodeMoveIntoChild:
+		return Error(ContentNodeMoveIntoChild, "")
+	case model.ErrContentNodeTooDeep:
+		return Error(ContentNodeTooDeep, "")
+	}
+	return Error(DBError, err.Error())
+}
+
+// content num of the ancestors change when node move
+func nodeMoveCount(userId int64, nodeId int64, beforeParentNode int64) {
+	if beforeParentNode != 0 {
+		go SendToLoop(userId, beforeParentNode, 2)
+	}
+	go SendToLoop(userId, nodeId, 2)
+}
+
 type DeleteNodeRequest struct {
 	Id int64 `json:"id" validate:"required"`
 }
@@ -654,6 +738,7 @@ func DeleteNode(c *gin.Context) {
 		resp.Error = Error(DBError, err.Error())
 		return
 	}
+	RefreshSitemap()
 	resp.Flag = true
 }
 
@@ -712,56 +797,18 @@ func TakeNode(c *gin.Context) {
 		return
 	}
 
-	f := Node{}
-	f.Id = v.Id
-	f.Seo = v.Seo
-	f.Describe = v.Describe
-	f.ImagePath = v.ImagePath
-	f.Name = v.Name
-	if v.UpdateTime > 0 {
-		f.UpdateTime = GetSecond2DateTimes(v.UpdateTime)
-		f.UpdateTimeInt = v.UpdateTime
-	}
-	f.CreateTime = GetSecond2DateTimes(v.CreateTime)
-	f.CreateTimeInt = v.CreateTime
-	f.SortNum = v.SortNum
-	f.UserName = v.UserName
-	f.UserId = v.UserId
-	f.Level = v.Level
-	f.ParentNodeId = v.ParentNodeId
-	f.Status = v.Status
-
-	// is the root level and want list son
-	if f.Level == 0 && req.ListSon {
-		ns := make([]model.ContentNode, 0)
-		err = model.FaFaRdb.Client.Where("parent_node_id=?", f.Id).Find(&ns)
+	f := NodeOf(*v)
+
+	// list all the children
+	if req.ListSon {
+		ns, err := v.Children(false)
 		if err != nil {
 			flog.Log.Errorf("Node err:%s", err.Error())
 			resp.Error = Error(DBError, err.Error())
 			return
 		}
 
-		for _, vv := range ns {
-			ff := Node{}
-			ff.Id = vv.Id
-			ff.Seo = vv.Seo
-			ff.Describe = vv.Describe
-			ff.ImagePath = vv.ImagePath
-			ff.Name = vv.Name
-			if vv.UpdateTime > 0 {
-				ff.UpdateTime = GetSecond2DateTimes(vv.UpdateTime)
-				ff.UpdateTimeInt = vv.UpdateTime
-			}
-			ff.CreateTime = GetSecond2DateTimes(vv.CreateTime)
-			ff.CreateTimeInt = vv.CreateTime
-			ff.SortNum = vv.SortNum
-			ff.UserName = vv.UserName
-			ff.UserId = vv.UserId
-			ff.Level = vv.Level
-			ff.ParentNodeId = vv.ParentNodeId
-			ff.Status = vv.Status
-			f.Son = append(f.Son, ff)
-		}
+		f.Son = NodeTree(ns, f.Id)
 	}
 	resp.Flag = true
 	resp.Data = f
@@ -833,73 +880,19 @@ func ListNodeHelper(c *gin.Context, userId int64) {
 		return
 	}
 
-	father := make([]model.ContentNode, 0)
-	son := make([]model.ContentNode, 0)
-	for _, v := range nodes {
-		if v.Level == 0 {
-			father = append(father, v)
-		} else {
-			son = append(son, v)
-		}
-	}
-
-	n := make([]Node, 0)
-	for _, v := range father {
-		f := Node{}
-		f.Id = v.Id
-		f.Seo = v.Seo
-		f.Describe = v.Describe
-		f.ImagePath = v.ImagePath
-		f.Name = v.Name
-		if v.UpdateTime > 0 {
-			f.UpdateTime = GetSecond2DateTimes(v.UpdateTime)
-			f.UpdateTimeInt = v.UpdateTime
-		}
-		f.CreateTime = GetSecond2DateTimes(v.CreateTime)
-		f.CreateTimeInt = v.CreateTime
-		f.SortNum = v.SortNum
-		f.UserName = v.UserName
-		f.UserId = v.UserId
-		f.Level = v.Level
-		f.ParentNodeId = v.ParentNodeId
-		f.Status = v.Status
-		for _, vv := range son {
-			if vv.ParentNodeId == f.Id {
-				s := Node{}
-				s.Id = vv.Id
-				s.Seo = vv.Seo
-				s.Describe = vv.Describe
-				s.ImagePath = vv.ImagePath
-				s.Name = vv.Name
-				if vv.UpdateTime > 0 {
-					s.UpdateTimeInt = vv.UpdateTime
-					s.UpdateTime = GetSecond2DateTimes(vv.UpdateTime)
-				}
-				s.CreateTime = GetSecond2DateTimes(vv.CreateTime)
-				s.CreateTimeInt = vv.CreateTime
-				s.SortNum = vv.SortNum
-				s.UserId = vv.UserId
-				s.UserName = vv.UserName
-				s.Level = vv.Level
-				s.ParentNodeId = vv.ParentNodeId
-				s.Status = vv.Status
-				f.Son = append(f.Son, s)
-			}
-		}
-
-		n = append(n, f)
-	}
+	n := NodeTree(nodes, 0)
 
 	respResult.Nodes = n
 	resp.Flag = true
 	resp.Data = respResult
 }
 
-// put x behind y
-// when y is zero, x will be the top one.
+// put x behind y, x can be in any level of y, its children move together
+// when y is zero, x will be the top one, in the level of parent_node_id if not zero.
 type SortNodeRequest struct {
-	XID int64 `json:"xid" validate:"required"`
-	YID int64 `json:"yid"`
+	XID          int64 `json:"xid" validate:"required"`
+	YID          int64 `json:"yid"`
+	ParentNodeId int64 `json:"parent_node_id"`
 }
 
 //  Sort the node
@@ -924,7 +917,7 @@ func SortNode(c *gin.Context) {
 		return
 	}
 
-	if req.XID == req.YID {
+	if req.XID == req.YID || req.XID == req.ParentNodeId {
 		flog.Log.Errorf("SortNode err: %s", "xid=yid not right")
 		resp.Error = Error(ParasError, "xid=yid not right")
 		return
@@ -953,165 +946,113 @@ func SortNode(c *gin.Context) {
 		return
 	}
 
-	// x will be put in the top in it's level
+	beforeParentNode := x.ParentNodeId
+
+	// x will be put in the top in it's level, or the level of parent
+	//  --- a  0		---
+	//  --- x  1   ==》	--- a x 1
+	//  --- b  2		--- b 2
+	parentNodeId := x.ParentNodeId
+	var sortNum int64 = 0
+
 	if req.YID == 0 {
-		session := model.FaFaRdb.Client.NewSession()
-		defer session.Close()
+		if req.ParentNodeId != 0 {
+			parent := new(model.ContentNode)
+			parent.Id = req.ParentNodeId
+			parent.UserId = uu.Id
+			exist, err = parent.GetSortOneNode()
+			if err != nil {
+				flog.Log.Errorf("SortNode err: %s", err.Error())
+				resp.Error = Error(DBError, err.Error())
+				return
+			}
 
-		err = session.Begin()
-		if err != nil {
-			flog.Log.Errorf("SortNode err: %s", err.Error())
-			resp.Error = Error(DBError, err.Error())
-			return
-		}
+			if !exist {
+				flog.Log.Errorf("SortNode err: %s", "parent node not found")
+				resp.Error = Error(ContentParentNodeNotFound, "")
+				return
+			}
+
+			// x can not be the child of his child
+			if x.IsAncestorOf(parent.Path) {
+				flog.Log.Errorf("SortNode err: %s", "can not move node to be his child's child")
+				resp.Error = Error(ContentNodeMoveIntoChild, "")
+				return
+			}
 
-		// all nodes which small than x, will +1 for x want to be top
-		//  --- a  0		---
-		//  --- x  1   ==》	--- a x 1
-		//  --- b  2		--- b 2
-		_, err = session.Exec("update fafacms_content_node SET sort_num=sort_num+1 where sort_num < ? and user_id = ? and parent_node_id = ?", x.SortNum, uu.Id, x.ParentNodeId)
+			parentNodeId = parent.Id
+		}
+	} else {
+		y := new(model.ContentNode)
+		y.Id = req.YID
+		y.UserId = uu.Id
+		exist, err = y.GetSortOneNode()
 		if err != nil {
-			session.Rollback()
 			flog.Log.Errorf("SortNode err: %s", err.Error())
 			resp.Error = Error(DBError, err.Error())
 			return
 		}
 
-		// x now is top!
-		_, err = session.Exec("update fafacms_content_node SET sort_num=0 where user_id = ? and parent_node_id = ? and id = ?", uu.Id, x.ParentNodeId, x.Id)
-		if err != nil {
-			session.Rollback()
-			flog.Log.Errorf("SortNode err: %s", err.Error())
-			resp.Error = Error(DBError, err.Error())
+		if !exist {
+			flog.Log.Errorf("SortNode err: %s", "y node not found")
+			resp.Error = Error(ContentNodeNotFound, "y node not found")
 			return
 		}
 
-		err = session.Commit()
-		if err != nil {
-			session.Rollback()
-			flog.Log.Errorf("SortNode err: %s", err.Error())
-			resp.Error = Error(DBError, err.Error())
+		// x is ancestor of y, can not to be brother of y
+		if x.IsAncestorOf(y.Path) {
+			flog.Log.Errorf("SortNode err: %s", "can not move node to be his child's brother")
+			resp.Error = Error(ContentNodeSortConflict, "can not move node to be his child's brother")
 			return
 		}
 
-		resp.Flag = true
-		return
-	}
-
-	y := new(model.ContentNode)
-	y.Id = req.YID
-	y.UserId = uu.Id
-	exist, err = y.GetSortOneNode()
-	if err != nil {
-		flog.Log.Errorf("SortNode err: %s", err.Error())
-		resp.Error = Error(DBError, err.Error())
-		return
-	}
-
-	if !exist {
-		flog.Log.Errorf("SortNode err: %s", "y node not found")
-		resp.Error = Error(ContentNodeNotFound, "y node not found")
-		return
-	}
-
-	// x is father of y, can not to be brother of y
-	if y.ParentNodeId == x.Id {
-		flog.Log.Errorf("SortNode err: %s", "can not move node to be his child's brother")
-		resp.Error = Error(ContentNodeSortConflict, "can not move node to be his child's brother")
-		return
-	}
-
-	children, err := x.CheckChildrenNum()
-	if err != nil {
-		flog.Log.Errorf("SortNode err: %s", err.Error())
-		resp.Error = Error(DBError, err.Error())
-		return
-	}
-
-	// y is son, but x want to be y's brother, however, x has children
-	if y.Level == 1 && children > 0 {
-		flog.Log.Errorf("SortNode err: %s", "x has child can not move to be other's child's brother")
-		resp.Error = Error(ContentNodeSortConflict, "x has child can not move to be other's child's brother")
-		return
-	}
-
-	session := model.FaFaRdb.Client.NewSession()
-	defer session.Close()
-
-	err = session.Begin()
-	if err != nil {
-		flog.Log.Errorf("SortNode err: %s", err.Error())
-		resp.Error = Error(DBError, err.Error())
-		return
-	}
-
-	// all nodes behind x sort_num-1, one by one to replace x's position, pretend x is delete
-	_, err = session.Exec("update fafacms_content_node SET sort_num=sort_num-1 where sort_num > ? and user_id = ? and parent_node_id = ?", x.SortNum, uu.Id, x.ParentNodeId)
-	if err != nil {
-		session.Rollback()
-		flog.Log.Errorf("SortNode err: %s", err.Error())
-		resp.Error = Error(DBError, err.Error())
-		return
-	}
-
-	// all nodes behind y sort_num+1, make a empty position to x
-	_, err = session.Exec("update fafacms_content_node SET sort_num=sort_num+1 where sort_num > ? and user_id = ? and parent_node_id = ?", y.SortNum, uu.Id, y.ParentNodeId)
-	if err != nil {
-		session.Rollback()
-		flog.Log.Errorf("SortNode err: %s", err.Error())
-		resp.Error = Error(DBError, err.Error())
-		return
+		// all nodes behind x sort_num-1, one by one to replace x's position, pretend x is delete
+		// all nodes behind y sort_num+1, make a empty position to x
+		// same level
+		// y>x  y=5 x=2
+		//   --- a	0		--- a 0			--- a 0
+		//   --- b  1 ==>	--- b 1 	==>	--- b 1
+		//   --- x	2		--- xc 2		--- xc 2
+		//   --- c	3		--- d 3			--- d 3
+		//   --- d  4		--- y 4			--- y 4
+		//   --- y	5		--- e 5			---			==> x=5
+		//   --- e	6		---  			--- e 6
+
+		// y<x  y=2 x=5
+		//   --- a	0		--- a 0			--- a 0
+		//   --- b  1 ==>	--- b 1 	==>	--- b 1
+		//   --- y	2		--- y 2			--- y 2		==> x=3
+		//   --- c	3		--- c 3			--- c 4
+		//   --- d  4		--- d 4			--- d 5
+		//   --- x	5		--- xe 5		---	xe 6
+		//   --- e	6		---
+
+		// diff level, x and his children go into the level of y
+		// y=1
+		//   --- a	0		--- a 0				--- a 0
+		//   --- b  1 	==>	--- b 1 		==>	--- b 1
+		//   	--- c 0			--- c 0				--- c 0
+		//   	--- y 1			--- y 1				--- y 1		==> x=2
+		//   	--- d 2			--- d 2				--- d 3
+		//   --- x	2		--- xe 2			---	xe 2
+		//   --- e	3
+		parentNodeId = y.ParentNodeId
+		if x.ParentNodeId == y.ParentNodeId && y.SortNum > x.SortNum {
+			sortNum = y.SortNum
+		} else {
+			sortNum = y.SortNum + 1
+		}
 	}
 
-	// all nodes behind x sort_num-1, one by one to replace x's position, pretend x is delete
-	// all nodes behind y sort_num+1, make a empty position to x
-	// same level
-	// y>x  y=5 x=2
-	//   --- a	0		--- a 0			--- a 0
-	//   --- b  1 ==>	--- b 1 	==>	--- b 1
-	//   --- x	2		--- xc 2		--- xc 2
-	//   --- c	3		--- d 3			--- d 3
-	//   --- d  4		--- y 4			--- y 4
-	//   --- y	5		--- e 5			---			==> x=5
-	//   --- e	6		---  			--- e 6
-
-	// y<x  y=2 x=5
-	//   --- a	0		--- a 0			--- a 0
-	//   --- b  1 ==>	--- b 1 	==>	--- b 1
-	//   --- y	2		--- y 2			--- y 2		==> x=3
-	//   --- c	3		--- c 3			--- c 4
-	//   --- d  4		--- d 4			--- d 5
-	//   --- x	5		--- xe 5		---	xe 6
-	//   --- e	6		---
-
-	// diff level
-	// y=1
-	//   --- a	0		--- a 0				--- a 0
-	//   --- b  1 	==>	--- b 1 		==>	--- b 1
-	//   	--- c 0			--- c 0				--- c 0
-	//   	--- y 1			--- y 1				--- y 1		==> x=2
-	//   	--- d 2			--- d 2				--- d 3
-	//   --- x	2		--- xe 2			---	xe 2
-	//   --- e	3
-	if x.ParentNodeId == y.ParentNodeId && y.SortNum > x.SortNum {
-		_, err = session.Exec("update fafacms_content_node SET sort_num=?,level=?,parent_node_id=? where user_id = ? and id = ?", y.SortNum, y.Level, y.ParentNodeId, uu.Id, x.Id)
-	} else {
-		_, err = session.Exec("update fafacms_content_node SET sort_num=?,level=?,parent_node_id=? where user_id = ? and id = ?", y.SortNum+1, y.Level, y.ParentNodeId, uu.Id, x.Id)
-	}
+	err = x.Move(parentNodeId, sortNum)
 	if err != nil {
-		session.Rollback()
 		flog.Log.Errorf("SortNode err: %s", err.Error())
-		resp.Error = Error(DBError, err.Error())
+		resp.Error = nodeMoveError(err)
 		return
 	}
 
-	err = session.Commit()
-	if err != nil {
-		session.Rollback()
-		flog.Log.Errorf("SortNode err: %s", err.Error())
-		resp.Error = Error(DBError, err.Error())
-		return
+	if beforeParentNode != parentNodeId {
+		nodeMoveCount(uu.Id, x.Id, beforeParentNode)
 	}
 	resp.Flag = true
-	return
 }
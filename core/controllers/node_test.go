@@ -0,0 +1,39 @@
+package controllers
+
+import (
+	"github.com/hunterhug/fafacms/core/model"
+	"testing"
+)
+
+func TestNodeTree(t *testing.T) {
+	nodes := []model.ContentNode{
+		{Id: 1, Path: "/1/"},
+		{Id: 2, ParentNodeId: 1, Level: 1, Path: "/1/2/"},
+		{Id: 3, ParentNodeId: 2, Level: 2, Path: "/1/2/3/"},
+		{Id: 4, ParentNodeId: 1, Level: 1, Path: "/1/4/"},
+		{Id: 5, ParentNodeId: 9, Level: 1, Path: "/9/5/"}, // parent hidden
+		{Id: 6, Path: "/6/"},
+	}
+
+	n := NodeTree(nodes, 0)
+	if len(n) != 2 || n[0].Id != 1 || n[1].Id != 6 || len(n[1].Son) != 0 {
+		t.Fatalf("root wrong: %#v", n)
+	}
+
+	if len(n[0].Son) != 2 || n[0].Son[0].Id != 2 || n[0].Son[1].Id != 4 {
+		t.Fatalf("son wrong: %#v", n[0].Son)
+	}
+
+	if len(n[0].Son[0].Son) != 1 || n[0].Son[0].Son[0].Id != 3 || n[0].Son[0].Son[0].Path != "/1/2/3/" {
+		t.Fatalf("son of son wrong: %#v", n[0].Son[0].Son)
+	}
+
+	n = NodeTree(nodes, 2)
+	if len(n) != 1 || n[0].Id != 3 {
+		t.Fatalf("sub tree wrong: %#v", n)
+	}
+
+	if n = NodeTree(nil, 0); n == nil || len(n) != 0 {
+		t.Fatalf("empty tree wrong: %#v", n)
+	}
+}
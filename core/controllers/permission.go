@@ -0,0 +1,187 @@
+package controllers
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/hunterhug/fafacms/core/flog"
+	"github.com/hunterhug/fafacms/core/model"
+	"github.com/hunterhug/fafacms/core/session"
+	"sort"
+)
+
+// groups and rules of user cache so long, change of group or resource clear it at once,
+// other server can not be told so not too long
+var PermissionCacheTime int64 = 600
+
+// all cache key has the version, incr it will make all the cache old
+const permissionVersionKey = "ff_perm_version"
+
+type permissionCache struct {
+	Groups []int64           `json:"groups"`
+	Rules  []model.GroupRule `json:"rules"`
+}
+
+func permissionKey(userId int64) string {
+	version, _, err := session.FafaKV.Get(permissionVersionKey)
+	if err != nil {
+		flog.Log.Errorf("permission version get err:%s", err.Error())
+	}
+	return fmt.Sprintf("ff_perm_%s_%d", version, userId)
+}
+
+// Groups and rules of user, cache first, not in cache take from db and keep
+func userPermission(userId int64) ([]int64, []model.GroupRule, error) {
+	key := permissionKey(userId)
+	raw, exist, err := session.FafaKV.Get(key)
+	if err == nil && exist {
+		p := permissionCache{}
+		if json.Unmarshal(raw, &p) == nil {
+			return p.Groups, p.Rules, nil
+		}
+	}
+
+	groupIds, err := model.UserGroupIds(userId)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	rules, err := model.GroupRules(groupIds)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	raw, _ = json.Marshal(permissionCache{Groups: groupIds, Rules: rules})
+	err = session.FafaKV.Set(key, raw, PermissionCacheTime)
+	if err != nil {
+		flog.Log.Errorf("permission cache set err:%s", err.Error())
+	}
+	return groupIds, rules, nil
+}
+
+// Clear the permission cache of those users, empty will clear all when group or resource change
+func ClearPermission(userIds ...int64) {
+	if len(userIds) == 0 {
+		_, err := session.FafaKV.Incr(permissionVersionKey, 0)
+		if err != nil {
+			flog.Log.Errorf("permission clear err:%s", err.Error())
+		}
+		return
+	}
+
+	for _, id := range userIds {
+		err := session.FafaKV.Delete(permissionKey(id))
+		if err != nil {
+			flog.Log.Errorf("permission clear err:%s", err.Error())
+		}
+	}
+}
+
+// Every route of v1 is a resource
+type RouteResource struct {
+	Id      int64
+	Name    string
+	Admin   bool // admin resource default deny, others default allow
+	Methods []string
+}
+
+// Decide by rules of groups, deny first, then allow, no rule match use the default
+func decidePermission(rules []model.GroupRule, url string, method string, defaultAllow bool) bool {
+	allow := false
+	for _, r := range rules {
+		if !model.MatchResourceUrl(r.Url, url) || !r.MatchMethod(method) {
+			continue
+		}
+
+		if r.Deny == 1 {
+			return false
+		}
+		allow = true
+	}
+
+	if allow {
+		return true
+	}
+	return defaultAllow
+}
+
+func routeDefaultAllow(url string) bool {
+	r, exist := RouteResources[url]
+	return !(exist && r.Admin)
+}
+
+// Whether user can access the url with the method
+func CheckPermission(user *model.User, url string, method string) (bool, error) {
+	if user.IsSuperAdmin == 1 {
+		return true, nil
+	}
+
+	_, rules, err := userPermission(user.Id)
+	if err != nil {
+		return false, err
+	}
+
+	return decidePermission(rules, url, method, routeDefaultAllow(url)), nil
+}
+
+type Permission struct {
+	Url     string   `json:"url"`
+	Name    string   `json:"name"`
+	Methods []string `json:"methods"`
+}
+
+type UserPermissionsResponse struct {
+	SuperAdmin  bool         `json:"super_admin"`
+	Groups      []int64      `json:"groups"`
+	Permissions []Permission `json:"permissions"`
+}
+
+// Tell front end which api current user can use
+func UserPermissions(c *gin.Context) {
+	resp := new(Resp)
+	defer func() {
+		JSON(c, 200, resp)
+	}()
+
+	uu, err := GetUserSession(c)
+	if err != nil {
+		flog.Log.Errorf("UserPermissions err: %s", err.Error())
+		resp.Error = Error(GetUserSessionError, err.Error())
+		return
+	}
+
+	groupIds, rules, err := userPermission(uu.Id)
+	if err != nil {
+		flog.Log.Errorf("UserPermissions err: %s", err.Error())
+		resp.Error = Error(DBError, err.Error())
+		return
+	}
+
+	urls := make([]string, 0, len(RouteResources))
+	for url := range RouteResources {
+		urls = append(urls, url)
+	}
+	sort.Strings(urls)
+
+	ps := make([]Permission, 0)
+	for _, url := range urls {
+		r := RouteResources[url]
+		methods := make([]string, 0)
+		for _, m := range r.Methods {
+			if uu.IsSuperAdmin == 1 || decidePermission(rules, url, m, !r.Admin) {
+				methods = append(methods, m)
+			}
+		}
+
+		if len(methods) > 0 {
+			ps = append(ps, Permission{Url: url, Name: r.Name, Methods: methods})
+		}
+	}
+
+	resp.Data = UserPermissionsResponse{
+		SuperAdmin:  uu.IsSuperAdmin == 1,
+		Groups:      groupIds,
+		Permissions: ps,
+	}
+	resp.Flag = true
+}
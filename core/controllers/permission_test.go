@@ -0,0 +1,68 @@
+package controllers
+
+import (
+	"encoding/json"
+	"github.com/hunterhug/fafacms/core/model"
+	"github.com/hunterhug/fafacms/core/session"
+	"testing"
+)
+
+func rule(url string, methods string, deny int) model.GroupRule {
+	r := model.GroupRule{Url: url}
+	r.Methods = methods
+	r.Deny = deny
+	return r
+}
+
+func TestDecidePermission(t *testing.T) {
+	rules := []model.GroupRule{
+		rule("/v1/content/*", "", 0),
+		rule("/v1/content/delete", "POST", 1),
+		rule("/v1/group/list", "GET", 0),
+	}
+
+	cases := []struct {
+		url          string
+		method       string
+		defaultAllow bool
+		want         bool
+	}{
+		{"/v1/content/create", "POST", false, true},   // wildcard allow
+		{"/v1/content/delete", "POST", true, false},   // deny first
+		{"/v1/content/delete", "GET", false, true},    // deny only post
+		{"/v1/group/list", "GET", false, true},        // method allow
+		{"/v1/group/list", "POST", false, false},      // method not allow, use default
+		{"/v1/node/create", "POST", true, true},       // no rule use default
+		{"/v1/contentx/create", "POST", false, false}, // prefix must with slash
+	}
+
+	for _, c := range cases {
+		if got := decidePermission(rules, c.url, c.method, c.defaultAllow); got != c.want {
+			t.Fatalf("%s %s want %v but %v", c.method, c.url, c.want, got)
+		}
+	}
+}
+
+func TestPermissionCache(t *testing.T) {
+	store, _ := session.NewMemoryStore("")
+	session.FafaKV = store
+
+	// in cache not hit the db
+	raw, _ := json.Marshal(permissionCache{Groups: []int64{1}, Rules: []model.GroupRule{rule("/v1/group/list", "GET", 0)}})
+	session.FafaKV.Set(permissionKey(2), raw, PermissionCacheTime)
+	ok, err := CheckPermission(&model.User{Id: 2}, "/v1/group/list", "GET")
+	if err != nil || !ok {
+		t.Fatalf("cache rule should allow: %v", err)
+	}
+
+	ClearPermission()
+	if _, exist, _ := session.FafaKV.Get(permissionKey(2)); exist {
+		t.Fatal("clear all should make the cache old")
+	}
+
+	session.FafaKV.Set(permissionKey(2), raw, PermissionCacheTime)
+	ClearPermission(2)
+	if _, exist, _ := session.FafaKV.Get(permissionKey(2)); exist {
+		t.Fatal("clear user should delete the cache")
+	}
+}
@@ -8,13 +8,17 @@ import (
 	"github.com/hunterhug/fafacms/core/model"
 	"github.com/hunterhug/fafacms/core/util"
 	"math"
+	"strings"
+	"time"
 )
 
 type ListResourceRequest struct {
-	Id   int64    `json:"id"`
-	Name string   `json:"name"`
-	Url  string   `json:"url"`
-	Sort []string `json:"sort"`
+	Id      int64    `json:"id"`
+	Name    string   `json:"name"`
+	Url     string   `json:"url"`
+	Admin   int      `json:"admin" validate:"oneof=0 1 2"`   // 1 admin resource, 2 not admin
+	Pattern int      `json:"pattern" validate:"oneof=0 1 2"` // 1 wildcard resource, 2 route resource
+	Sort    []string `json:"sort"`
 	PageHelp
 }
 
@@ -60,7 +64,17 @@ func ListResource(c *gin.Context) {
 		session.And("name=?", req.Name)
 	}
 
-	session.And("admin=?", 1)
+	if req.Admin == 1 {
+		session.And("admin=?", 1)
+	} else if req.Admin == 2 {
+		session.And("admin=?", 0)
+	}
+
+	if req.Pattern == 1 {
+		session.And("pattern=?", 1)
+	} else if req.Pattern == 2 {
+		session.And("pattern=?", 0)
+	}
 
 	if req.Url != "" {
 		urlHash, _ := util.Sha256([]byte(req.Url))
@@ -99,18 +113,147 @@ func ListResource(c *gin.Context) {
 	// result
 	respResult.Resources = r
 	p.Pages = int(math.Ceil(float64(total) / float64(p.Limit)))
-p.Total = int(total)
+	p.Total = int(total)
 	respResult.PageHelp = *p
 	resp.Data = respResult
 	resp.Flag = true
 }
 
+type CreateResourceRequest struct {
+	Name     string `json:"name" validate:"required"`
+	Url      string `json:"url" validate:"required,startswith=/,endswith=*"` // such as /v1/content/*
+	Describe string `json:"describe"`
+	Admin    bool   `json:"admin"`
+}
+
+// Create a wildcard resource, route resource create when server start
+func CreateResource(c *gin.Context) {
+	resp := new(Resp)
+	req := new(CreateResourceRequest)
+	defer func() {
+		JSONL(c, 200, req, resp)
+	}()
+
+	if errResp := ParseJSON(c, req); errResp != nil {
+		resp.Error = errResp
+		return
+	}
+
+	var validate = validator.New()
+	err := validate.Struct(req)
+	if err != nil {
+		flog.Log.Errorf("CreateResource err: %s", err.Error())
+		resp.Error = Error(ParasError, err.Error())
+		return
+	}
+
+	r := new(model.Resource)
+	r.UrlHash, _ = util.Sha256([]byte(req.Url))
+	exist, err := r.GetByHash()
+	if err != nil {
+		flog.Log.Errorf("CreateResource err:%s", err.Error())
+		resp.Error = Error(DBError, err.Error())
+		return
+	}
+
+	if exist {
+		flog.Log.Errorf("CreateResource err:%s", "resource exist")
+		resp.Error = Error(ResourceAlreadyExist, "")
+		return
+	}
+
+	r.Url = req.Url
+	r.Name = req.Name
+	r.Describe = req.Describe
+	r.Admin = req.Admin
+	r.Pattern = true
+	r.CreateTime = time.Now().Unix()
+	err = r.InsertOne()
+	if err != nil {
+		flog.Log.Errorf("CreateResource err:%s", err.Error())
+		resp.Error = Error(DBError, err.Error())
+		return
+	}
+
+	resp.Data = r
+	resp.Flag = true
+}
+
+type DeleteResourceRequest struct {
+	Id int64 `json:"id" validate:"required"`
+}
+
+// Only wildcard resource can delete, and no group use it
+func DeleteResource(c *gin.Context) {
+	resp := new(Resp)
+	req := new(DeleteResourceRequest)
+	defer func() {
+		JSONL(c, 200, req, resp)
+	}()
+
+	if errResp := ParseJSON(c, req); errResp != nil {
+		resp.Error = errResp
+		return
+	}
+
+	var validate = validator.New()
+	err := validate.Struct(req)
+	if err != nil {
+		flog.Log.Errorf("DeleteResource err: %s", err.Error())
+		resp.Error = Error(ParasError, err.Error())
+		return
+	}
+
+	r := new(model.Resource)
+	exist, err := model.FaFaRdb.Client.Where("id=?", req.Id).And("pattern=?", 1).Get(r)
+	if err != nil {
+		flog.Log.Errorf("DeleteResource err:%s", err.Error())
+		resp.Error = Error(DBError, err.Error())
+		return
+	}
+
+	if !exist {
+		flog.Log.Errorf("DeleteResource err:%s", "resource not found")
+		resp.Error = Error(ResourceNotFound, "")
+		return
+	}
+
+	gr := new(model.GroupResource)
+	gr.ResourceId = r.Id
+	exist, err = gr.Exist()
+	if err != nil {
+		flog.Log.Errorf("DeleteResource err:%s", err.Error())
+		resp.Error = Error(DBError, err.Error())
+		return
+	}
+
+	if exist {
+		flog.Log.Errorf("DeleteResource err:%s", "group use it")
+		resp.Error = Error(ResourceHasGroupHookIn, "")
+		return
+	}
+
+	_, err = model.FaFaRdb.Client.Where("id=?", r.Id).Delete(new(model.Resource))
+	if err != nil {
+		flog.Log.Errorf("DeleteResource err:%s", err.Error())
+		resp.Error = Error(DBError, err.Error())
+		return
+	}
+
+	ClearPermission()
+	resp.Flag = true
+}
+
 type AssignResourceToGroupRequest struct {
-	GroupId         int64   `json:"group_id"`
-	ResourceRelease int     `json:"resource_release"`
-	Resources       []int64 `json:"resources"`
+	GroupId         int64    `json:"group_id"`
+	GroupIds        []int64  `json:"group_ids"`        // many groups at once
+	ResourceRelease int      `json:"resource_release"` // 1 revoke the rule of resources, resources empty will revoke all
+	Resources       []int64  `json:"resources"`
+	Methods         []string `json:"methods" validate:"dive,oneof=GET POST PUT DELETE PATCH"` // empty is all method
+	Deny            int      `json:"deny" validate:"oneof=0 1"`                               // 1 is a deny rule
 }
 
+// Grant or revoke the rules of resources to groups
 func AssignResourceToGroup(c *gin.Context) {
 	resp := new(Resp)
 	req := new(AssignResourceToGroupRequest)
@@ -123,6 +266,14 @@ func AssignResourceToGroup(c *gin.Context) {
 		return
 	}
 
+	var validate = validator.New()
+	err := validate.Struct(req)
+	if err != nil {
+		flog.Log.Errorf("AssignGroupAndResource err: %s", err.Error())
+		resp.Error = Error(ParasError, err.Error())
+		return
+	}
+
 	resourceNums := len(req.Resources)
 	if resourceNums == 0 && req.ResourceRelease != 1 {
 		flog.Log.Errorf("AssignGroupAndResource err:%s", "resources empty")
@@ -130,22 +281,25 @@ func AssignResourceToGroup(c *gin.Context) {
 		return
 	}
 
-	if req.GroupId == 0 {
+	groupIds := req.GroupIds
+	if req.GroupId != 0 {
+		groupIds = append(groupIds, req.GroupId)
+	}
+
+	if len(groupIds) == 0 {
 		flog.Log.Errorf("AssignGroupAndResource err:%s", "group id empty")
 		resp.Error = Error(ParasError, "group_id")
 		return
 	}
 
-	g := new(model.Group)
-	g.Id = req.GroupId
-	exist, err := g.GetById()
+	num, err := model.FaFaRdb.Client.Table(new(model.Group)).In("id", groupIds).Count()
 	if err != nil {
 		flog.Log.Errorf("AssignGroupAndResource err:%s", err.Error())
 		resp.Error = Error(DBError, err.Error())
 		return
 	}
 
-	if !exist {
+	if int(num) != len(groupIds) {
 		flog.Log.Errorf("AssignGroupAndResource err:%s", "group not found")
 		resp.Error = Error(GroupNotFound, "")
 		return
@@ -176,11 +330,12 @@ func AssignResourceToGroup(c *gin.Context) {
 		return
 	}
 
+	// one group one resource only one rule, old will be replace
 	if len(req.Resources) > 0 {
 		session.In("resource_id", req.Resources)
 	}
 
-	_, err = session.Where("group_id=?", req.GroupId).Delete(new(model.GroupResource))
+	_, err = session.In("group_id", groupIds).Delete(new(model.GroupResource))
 	if err != nil {
 		session.Rollback()
 		flog.Log.Errorf("AssignGroupAndResource err:%s", err.Error())
@@ -189,9 +344,12 @@ func AssignResourceToGroup(c *gin.Context) {
 	}
 
 	if req.ResourceRelease != 1 {
-		rs := make([]model.GroupResource, 0, resourceNums)
-		for _, r := range req.Resources {
-			rs = append(rs, model.GroupResource{GroupId: req.GroupId, ResourceId: r})
+		methods := strings.Join(req.Methods, ",")
+		rs := make([]model.GroupResource, 0, resourceNums*len(groupIds))
+		for _, g := range groupIds {
+			for _, r := range req.Resources {
+				rs = append(rs, model.GroupResource{GroupId: g, ResourceId: r, Methods: methods, Deny: req.Deny})
+			}
 		}
 		_, err = session.Insert(rs)
 		if err != nil {
@@ -209,5 +367,7 @@ func AssignResourceToGroup(c *gin.Context) {
 		resp.Error = Error(DBError, err.Error())
 		return
 	}
+
+	ClearPermission()
 	resp.Flag = true
 }
@@ -31,6 +31,26 @@ func ParseJSON(c *gin.Context, req interface{}) *ErrorResp {
 	return nil
 }
 
+// Page render by theme open in browser has no body, parse the query into request struct,
+// not want the theme still parse the json
+func ParseQuery(c *gin.Context, req interface{}) *ErrorResp {
+	if !ThemeWant(c) {
+		return ParseJSON(c, req)
+	}
+
+	raw, err := util.QueryToJson(c.Request.URL.Query(), req)
+	if err == nil {
+		err = json.Unmarshal(raw, req)
+	}
+
+	if err != nil {
+		Log.Debugf("%s ParseQueryErr [%s]:%s", c.ClientIP(), c.Request.URL.Path, err.Error())
+		c.Set("skipLog", true)
+		return Error(ParseJsonError, err.Error())
+	}
+	return nil
+}
+
 // Log the json output
 func JSONL(c *gin.Context, code int, req interface{}, obj *Resp) {
 	if c.GetBool("skipLog") {
@@ -44,7 +64,8 @@ func JSONL(c *gin.Context, code int, req interface{}, obj *Resp) {
 	record.Url = c.Request.URL.Path
 	record.LogTime = time.Now().Unix()
 	record.Ua = c.Request.UserAgent()
-	record.UserId = c.GetInt("uid")
+	record.Method = c.Request.Method
+	record.UserId = c.GetInt64("uid")
 	flag := obj.Flag
 	if !flag && obj.Error != nil {
 		errStr := obj.Error.Error()
@@ -56,31 +77,22 @@ func JSONL(c *gin.Context, code int, req interface{}, obj *Resp) {
 	}
 	record.Flag = flag
 
+	// password, code and token will mask
 	if req != nil {
-		in, _ := json.Marshal(req)
-		if len(in) > 0 {
-			record.In = string(in)
-		}
+		record.In = auditRedact(req, false)
 	}
 
-	if obj != nil {
-		out, _ := json.Marshal(obj)
-		if len(out) > 0 {
-			record.Out = string(out)
-		}
-	}
 	cid := util.GetGUID()
 	record.Cid = cid
+	obj.Cid = cid
+
+	record.Out = auditRedact(obj, c.GetBool("auditSecretData"))
 
 	Log.Debugf("FaFa Monitor:%#v", record)
 
-	// log table not read fot it will slow the service
-	//_, err := model.FaFaRdb.InsertOne(record)
-	//if err != nil {
-	//	Log.Errorf("insert log record:%s", err.Error())
-	//}
+	// write into db by batch in background, not slow the service
+	SendToAudit(*record)
 
-	obj.Cid = cid
 	c.Render(code, render.JSON{Data: obj})
 }
 
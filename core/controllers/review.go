@@ -0,0 +1,485 @@
+package controllers
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator"
+	"github.com/hunterhug/fafacms/core/flog"
+	"github.com/hunterhug/fafacms/core/model"
+	"math"
+)
+
+var (
+	// content need review before publish, node and group can change it
+	Review = false
+
+	// users in the group can review, super admin always can
+	ReviewGroup = ""
+)
+
+// message tell the author after review
+var reviewMessageType = map[int]int{
+	model.ContentReviewApprove: model.MessageTypeReviewApprove,
+	model.ContentReviewChange:  model.MessageTypeReviewChange,
+	model.ContentReviewReject:  model.MessageTypeReviewReject,
+}
+
+// Publish by the user need review or not, reviewer publish directly
+func reviewNeeded(uu *model.User, content *model.Content) (bool, error) {
+	ok, err := model.IsReviewer(uu, ReviewGroup)
+	if err != nil || ok {
+		return false, err
+	}
+	return model.ReviewNeeded(uu.Id, content.NodeId, Review)
+}
+
+// Tell all the reviewer except who submit
+func reviewSubmitTell(r *model.ContentReview) {
+	ids, err := model.ReviewerIds(ReviewGroup)
+	if err != nil {
+		flog.Log.Errorf("ReviewSubmitTell err: %s", err.Error())
+		return
+	}
+
+	for _, id := range ids {
+		if id == r.SubmitUserId {
+			continue
+		}
+
+		err = model.ReviewAbout(r.SubmitUserId, id, r.ContentId, r.ContentTitle, r.Id, r.Comment, model.MessageTypeReviewSubmit)
+		if err != nil {
+			flog.Log.Errorf("ReviewSubmitTell err: %s", err.Error())
+		}
+	}
+}
+
+// Tell who submit and the owner of content
+func reviewTell(r *model.ContentReview) {
+	ids := []int64{r.SubmitUserId}
+	if r.UserId != r.SubmitUserId {
+		ids = append(ids, r.UserId)
+	}
+
+	for _, id := range ids {
+		err := model.ReviewAbout(r.ReviewUserId, id, r.ContentId, r.ContentTitle, r.Id, r.Comment, reviewMessageType[r.Status])
+		if err != nil {
+			flog.Log.Errorf("ReviewTell err: %s", err.Error())
+		}
+	}
+}
+
+type SubmitReviewOfContentRequest struct {
+	Id      int64  `json:"id" validate:"required"`
+	Comment string `json:"comment" validate:"max=1000"` // tell the reviewer something
+}
+
+// Author or the editor of the node share to him submit the draft, wait reviewer publish it
+func SubmitReviewOfContent(c *gin.Context) {
+	resp := new(Resp)
+	req := new(SubmitReviewOfContentRequest)
+	defer func() {
+		JSONL(c, 200, req, resp)
+	}()
+
+	if errResp := ParseJSON(c, req); errResp != nil {
+		resp.Error = errResp
+		return
+	}
+
+	var validate = validator.New()
+	err := validate.Struct(req)
+	if err != nil {
+		flog.Log.Errorf("SubmitReviewOfContent err: %s", err.Error())
+		resp.Error = Error(ParasError, err.Error())
+		return
+	}
+
+	uu, err := GetUserSession(c)
+	if err != nil {
+		flog.Log.Errorf("SubmitReviewOfContent err: %s", err.Error())
+		resp.Error = Error(GetUserSessionError, err.Error())
+		return
+	}
+
+	content := new(model.Content)
+	content.Id = req.Id
+	exist, err := getContentWithRole(content, uu.Id, model.CollaboratorEditor)
+	if err != nil {
+		flog.Log.Errorf("SubmitReviewOfContent err: %s", err.Error())
+		resp.Error = Error(DBError, err.Error())
+		return
+	}
+
+	if !exist {
+		flog.Log.Errorf("SubmitReviewOfContent err: %s", "content not found")
+		resp.Error = Error(ContentNotFound, "")
+		return
+	}
+
+	if content.Status == 2 {
+		flog.Log.Errorf("SubmitReviewOfContent err: %s", "content ban")
+		resp.Error = Error(ContentBanPermit, "")
+		return
+	}
+
+	if content.Status == 3 {
+		flog.Log.Errorf("SubmitReviewOfContent err: %s", "content rubbish")
+		resp.Error = Error(ContentInRubbish, "")
+		return
+	}
+
+	if content.ReviewStatus == model.ContentReviewWaiting {
+		flog.Log.Errorf("SubmitReviewOfContent err: %s", "already waiting")
+		resp.Error = Error(ContentReviewWaiting, "")
+		return
+	}
+
+	// the draft publish already, nothing to review
+	if content.PreFlush == 1 {
+		resp.Flag = true
+		return
+	}
+
+	r := new(model.ContentReview)
+	r.ContentId = content.Id
+	r.ContentTitle = content.PreTitle
+	r.UserId = content.UserId
+	r.NodeId = content.NodeId
+	r.SubmitUserId = uu.Id
+	r.Status = model.ContentReviewWaiting
+	r.Comment = req.Comment
+	r.Revision = content.Revision
+	err = r.Insert()
+	if err != nil {
+		flog.Log.Errorf("SubmitReviewOfContent err: %s", err.Error())
+		resp.Error = Error(DBError, err.Error())
+		return
+	}
+
+	go reviewSubmitTell(r)
+	resp.Data = r
+	resp.Flag = true
+}
+
+type ReviewContentRequest struct {
+	Id      int64  `json:"id" validate:"required"`
+	Status  int    `json:"status" validate:"oneof=2 3 4"` // 2 approve and publish, 3 need change, 4 reject
+	Comment string `json:"comment" validate:"max=1000"`
+}
+
+// Reviewer approve the content waiting review and publish it, or let the author change it, or reject
+func ReviewContent(c *gin.Context) {
+	resp := new(Resp)
+	req := new(ReviewContentRequest)
+	defer func() {
+		JSONL(c, 200, req, resp)
+	}()
+
+	if errResp := ParseJSON(c, req); errResp != nil {
+		resp.Error = errResp
+		return
+	}
+
+	var validate = validator.New()
+	err := validate.Struct(req)
+	if err != nil {
+		flog.Log.Errorf("ReviewContent err: %s", err.Error())
+		resp.Error = Error(ParasError, err.Error())
+		return
+	}
+
+	uu, err := GetUserSession(c)
+	if err != nil {
+		flog.Log.Errorf("ReviewContent err: %s", err.Error())
+		resp.Error = Error(GetUserSessionError, err.Error())
+		return
+	}
+
+	ok, err := model.IsReviewer(uu, ReviewGroup)
+	if err != nil {
+		flog.Log.Errorf("ReviewContent err: %s", err.Error())
+		resp.Error = Error(DBError, err.Error())
+		return
+	}
+
+	if !ok {
+		flog.Log.Errorf("ReviewContent err: %s", "not reviewer")
+		resp.Error = Error(ContentReviewerPermit, "")
+		return
+	}
+
+	content := new(model.Content)
+	content.Id = req.Id
+	exist, err := content.Get()
+	if err != nil {
+		flog.Log.Errorf("ReviewContent err: %s", err.Error())
+		resp.Error = Error(DBError, err.Error())
+		return
+	}
+
+	if !exist {
+		flog.Log.Errorf("ReviewContent err: %s", "content not found")
+		resp.Error = Error(ContentNotFound, "")
+		return
+	}
+
+	submit, exist, err := model.ContentReviewLastSubmit(content.Id)
+	if err != nil {
+		flog.Log.Errorf("ReviewContent err: %s", err.Error())
+		resp.Error = Error(DBError, err.Error())
+		return
+	}
+
+	if !exist || content.ReviewStatus != model.ContentReviewWaiting {
+		flog.Log.Errorf("ReviewContent err: %s", "content not waiting review")
+		resp.Error = Error(ContentReviewNotWaiting, "")
+		return
+	}
+
+	if uu.Id == content.UserId || uu.Id == submit.SubmitUserId {
+		flog.Log.Errorf("ReviewContent err: %s", "can not review self")
+		resp.Error = Error(ContentReviewCanNotBeSelf, "")
+		return
+	}
+
+	if req.Status == model.ContentReviewApprove {
+		if content.Status == 2 {
+			flog.Log.Errorf("ReviewContent err: %s", "content ban")
+			resp.Error = Error(ContentBanPermit, "")
+			return
+		}
+
+		if content.Status == 3 {
+			flog.Log.Errorf("ReviewContent err: %s", "content rubbish")
+			resp.Error = Error(ContentInRubbish, "")
+			return
+		}
+
+		// the draft change after submit, the reviewer not see it
+		content.EditUserId = uu.Id
+		content.Revision = submit.Revision
+		err = content.PublishDescribe()
+		if err != nil {
+			contentWriteError(resp, "ReviewContent", content.Id, err)
+			return
+		}
+
+		afterPublishContent(content)
+	}
+
+	r := new(model.ContentReview)
+	r.ContentId = content.Id
+	r.ContentTitle = submit.ContentTitle
+	r.UserId = content.UserId
+	r.NodeId = content.NodeId
+	r.SubmitUserId = submit.SubmitUserId
+	r.ReviewUserId = uu.Id
+	r.Status = req.Status
+	r.Comment = req.Comment
+	r.Revision = submit.Revision
+	err = r.Insert()
+	if err != nil {
+		flog.Log.Errorf("ReviewContent err: %s", err.Error())
+		resp.Error = Error(DBError, err.Error())
+		return
+	}
+
+	go reviewTell(r)
+	resp.Data = r
+	resp.Flag = true
+}
+
+type ListReviewQueueRequest struct {
+	UserId int64    `json:"user_id"`
+	NodeId int64    `json:"node_id"`
+	Sort   []string `json:"sort"`
+	PageHelp
+}
+
+// Contents waiting review, only reviewer can see
+func ListReviewQueue(c *gin.Context) {
+	resp := new(Resp)
+
+	respResult := new(ListContentResponse)
+	req := new(ListReviewQueueRequest)
+	defer func() {
+		JSONL(c, 200, req, resp)
+	}()
+
+	if errResp := ParseJSON(c, req); errResp != nil {
+		resp.Error = errResp
+		return
+	}
+
+	uu, err := GetUserSession(c)
+	if err != nil {
+		flog.Log.Errorf("ListReviewQueue err: %s", err.Error())
+		resp.Error = Error(GetUserSessionError, err.Error())
+		return
+	}
+
+	ok, err := model.IsReviewer(uu, ReviewGroup)
+	if err != nil {
+		flog.Log.Errorf("ListReviewQueue err: %s", err.Error())
+		resp.Error = Error(DBError, err.Error())
+		return
+	}
+
+	if !ok {
+		flog.Log.Errorf("ListReviewQueue err: %s", "not reviewer")
+		resp.Error = Error(ContentReviewerPermit, "")
+		return
+	}
+
+	session := model.FaFaRdb.Client.NewSession()
+	defer session.Close()
+
+	session.Table(new(model.Content)).Where("review_status=?", model.ContentReviewWaiting).And("status!=?", 3)
+
+	if req.UserId != 0 {
+		session.And("user_id=?", req.UserId)
+	}
+
+	if req.NodeId != 0 {
+		session.And("node_id=?", req.NodeId)
+	}
+
+	countSession := session.Clone()
+	defer countSession.Close()
+	total, err := countSession.Count()
+	if err != nil {
+		flog.Log.Errorf("ListReviewQueue err:%s", err.Error())
+		resp.Error = Error(DBError, err.Error())
+		return
+	}
+
+	cs := make([]model.Content, 0)
+	p := &req.PageHelp
+	if total == 0 {
+		if p.Limit == 0 {
+			p.Limit = 20
+		}
+	} else {
+		p.build(session, req.Sort, model.ContentReviewQueueSortName)
+		err = session.Omit("describe", "pre_describe", "describe_html", "toc").Find(&cs)
+		if err != nil {
+			flog.Log.Errorf("ListReviewQueue err:%s", err.Error())
+			resp.Error = Error(DBError, err.Error())
+			return
+		}
+	}
+
+	respResult.Contents = cs
+	p.Pages = int(math.Ceil(float64(total) / float64(p.Limit)))
+	p.Total = int(total)
+	respResult.PageHelp = *p
+	resp.Data = respResult
+	resp.Flag = true
+}
+
+type ListReviewOfContentRequest struct {
+	ContentId int64    `json:"content_id" validate:"required"`
+	Status    int      `json:"status" validate:"oneof=-1 1 2 3 4"`
+	Sort      []string `json:"sort"`
+	PageHelp
+}
+
+type ListReviewOfContentResponse struct {
+	Reviews []model.ContentReview `json:"reviews"`
+	PageHelp
+}
+
+// Every step of review of the content, the reviewer or who can see the content
+func ListReviewOfContent(c *gin.Context) {
+	resp := new(Resp)
+
+	respResult := new(ListReviewOfContentResponse)
+	req := new(ListReviewOfContentRequest)
+	defer func() {
+		JSONL(c, 200, req, resp)
+	}()
+
+	if errResp := ParseJSON(c, req); errResp != nil {
+		resp.Error = errResp
+		return
+	}
+
+	var validate = validator.New()
+	err := validate.Struct(req)
+	if err != nil {
+		flog.Log.Errorf("ListReviewOfContent err: %s", err.Error())
+		resp.Error = Error(ParasError, err.Error())
+		return
+	}
+
+	uu, err := GetUserSession(c)
+	if err != nil {
+		flog.Log.Errorf("ListReviewOfContent err: %s", err.Error())
+		resp.Error = Error(GetUserSessionError, err.Error())
+		return
+	}
+
+	ok, err := model.IsReviewer(uu, ReviewGroup)
+	if err != nil {
+		flog.Log.Errorf("ListReviewOfContent err: %s", err.Error())
+		resp.Error = Error(DBError, err.Error())
+		return
+	}
+
+	if !ok {
+		content := new(model.Content)
+		content.Id = req.ContentId
+		ok, err = getContentWithRole(content, uu.Id, model.CollaboratorViewer)
+		if err != nil {
+			flog.Log.Errorf("ListReviewOfContent err: %s", err.Error())
+			resp.Error = Error(DBError, err.Error())
+			return
+		}
+	}
+
+	if !ok {
+		flog.Log.Errorf("ListReviewOfContent err: %s", "content not found")
+		resp.Error = Error(ContentNotFound, "")
+		return
+	}
+
+	session := model.FaFaRdb.Client.NewSession()
+	defer session.Close()
+
+	session.Table(new(model.ContentReview)).Where("content_id=?", req.ContentId)
+
+	if req.Status != -1 {
+		session.And("status=?", req.Status)
+	}
+
+	countSession := session.Clone()
+	defer countSession.Close()
+	total, err := countSession.Count()
+	if err != nil {
+		flog.Log.Errorf("ListReviewOfContent err:%s", err.Error())
+		resp.Error = Error(DBError, err.Error())
+		return
+	}
+
+	rs := make([]model.ContentReview, 0)
+	p := &req.PageHelp
+	if total == 0 {
+		if p.Limit == 0 {
+			p.Limit = 20
+		}
+	} else {
+		p.build(session, req.Sort, model.ContentReviewSortName)
+		err = session.Find(&rs)
+		if err != nil {
+			flog.Log.Errorf("ListReviewOfContent err:%s", err.Error())
+			resp.Error = Error(DBError, err.Error())
+			return
+		}
+	}
+
+	respResult.Reviews = rs
+	p.Pages = int(math.Ceil(float64(total) / float64(p.Limit)))
+	p.Total = int(total)
+	respResult.PageHelp = *p
+	resp.Data = respResult
+	resp.Flag = true
+}
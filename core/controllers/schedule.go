@@ -0,0 +1,359 @@
+package controllers
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator"
+	"github.com/hunterhug/fafacms/core/flog"
+	"github.com/hunterhug/fafacms/core/model"
+	"math"
+	"time"
+)
+
+// how long check the schedule once
+var ScheduleInterval = 10 * time.Second
+
+// one check at most do so many
+var ScheduleBatchSize = 100
+
+type ScheduleContentRequest struct {
+	Id          int64 `json:"id" validate:"required"`
+	PublishAt   int64 `json:"publish_at"`   // publish the draft at, 0 not publish
+	UnpublishAt int64 `json:"unpublish_at"` // hide the content at, 0 not hide
+}
+
+// Set the time to publish or hide the content, old schedule will be replace
+func ScheduleContent(c *gin.Context) {
+	resp := new(Resp)
+	req := new(ScheduleContentRequest)
+	defer func() {
+		JSONL(c, 200, req, resp)
+	}()
+
+	if errResp := ParseJSON(c, req); errResp != nil {
+		resp.Error = errResp
+		return
+	}
+
+	var validate = validator.New()
+	err := validate.Struct(req)
+	if err != nil {
+		flog.Log.Errorf("ScheduleContent err: %s", err.Error())
+		resp.Error = Error(ParasError, err.Error())
+		return
+	}
+
+	now := time.Now().Unix()
+	if req.PublishAt == 0 && req.UnpublishAt == 0 {
+		flog.Log.Errorf("ScheduleContent err: %s", "publish_at and unpublish_at empty")
+		resp.Error = Error(ContentScheduleTimeWrong, "publish_at and unpublish_at empty")
+		return
+	}
+
+	if req.PublishAt < 0 || req.UnpublishAt < 0 || (req.PublishAt != 0 && req.PublishAt <= now) || (req.UnpublishAt != 0 && req.UnpublishAt <= now) {
+		flog.Log.Errorf("ScheduleContent err: %s", "time must after now")
+		resp.Error = Error(ContentScheduleTimeWrong, "time must after now")
+		return
+	}
+
+	if req.PublishAt != 0 && req.UnpublishAt != 0 && req.UnpublishAt <= req.PublishAt {
+		flog.Log.Errorf("ScheduleContent err: %s", "unpublish_at must after publish_at")
+		resp.Error = Error(ContentScheduleTimeWrong, "unpublish_at must after publish_at")
+		return
+	}
+
+	uu, err := GetUserSession(c)
+	if err != nil {
+		flog.Log.Errorf("ScheduleContent err: %s", err.Error())
+		resp.Error = Error(GetUserSessionError, err.Error())
+		return
+	}
+
+	content := new(model.Content)
+	content.Id = req.Id
+	exist, err := getContentWithRole(content, uu.Id, model.CollaboratorPublisher)
+	if err != nil {
+		flog.Log.Errorf("ScheduleContent err: %s", err.Error())
+		resp.Error = Error(DBError, err.Error())
+		return
+	}
+
+	if !exist {
+		flog.Log.Errorf("ScheduleContent err: %s", "content not found")
+		resp.Error = Error(ContentNotFound, "")
+		return
+	}
+
+	if content.Status == 2 {
+		flog.Log.Errorf("ScheduleContent err: %s", "content ban")
+		resp.Error = Error(ContentBanPermit, "")
+		return
+	}
+
+	if content.Status == 3 {
+		flog.Log.Errorf("ScheduleContent err: %s", "content rubbish")
+		resp.Error = Error(ContentInRubbish, "")
+		return
+	}
+
+	if req.PublishAt != 0 {
+		need, err := reviewNeeded(uu, content)
+		if err != nil {
+			flog.Log.Errorf("ScheduleContent err: %s", err.Error())
+			resp.Error = Error(DBError, err.Error())
+			return
+		}
+
+		if need {
+			flog.Log.Errorf("ScheduleContent err: %s", "need review")
+			resp.Error = Error(ContentNeedReview, "")
+			return
+		}
+	}
+
+	content.PublishAt = req.PublishAt
+	content.UnpublishAt = req.UnpublishAt
+	err = content.UpdateSchedule()
+	if err != nil {
+		flog.Log.Errorf("ScheduleContent err: %s", err.Error())
+		resp.Error = Error(DBError, err.Error())
+		return
+	}
+
+	resp.Flag = true
+}
+
+type CancelScheduleContentRequest struct {
+	Id    int64 `json:"id" validate:"required"`
+	Types int   `json:"types" validate:"oneof=0 1 2"` // 0 cancel all, 1 cancel publish, 2 cancel unpublish
+}
+
+func CancelScheduleContent(c *gin.Context) {
+	resp := new(Resp)
+	req := new(CancelScheduleContentRequest)
+	defer func() {
+		JSONL(c, 200, req, resp)
+	}()
+
+	if errResp := ParseJSON(c, req); errResp != nil {
+		resp.Error = errResp
+		return
+	}
+
+	var validate = validator.New()
+	err := validate.Struct(req)
+	if err != nil {
+		flog.Log.Errorf("CancelScheduleContent err: %s", err.Error())
+		resp.Error = Error(ParasError, err.Error())
+		return
+	}
+
+	uu, err := GetUserSession(c)
+	if err != nil {
+		flog.Log.Errorf("CancelScheduleContent err: %s", err.Error())
+		resp.Error = Error(GetUserSessionError, err.Error())
+		return
+	}
+
+	content := new(model.Content)
+	content.Id = req.Id
+	exist, err := getContentWithRole(content, uu.Id, model.CollaboratorPublisher)
+	if err != nil {
+		flog.Log.Errorf("CancelScheduleContent err: %s", err.Error())
+		resp.Error = Error(DBError, err.Error())
+		return
+	}
+
+	if !exist {
+		flog.Log.Errorf("CancelScheduleContent err: %s", "content not found")
+		resp.Error = Error(ContentNotFound, "")
+		return
+	}
+
+	if req.Types != 2 {
+		content.PublishAt = 0
+	}
+
+	if req.Types != 1 {
+		content.UnpublishAt = 0
+	}
+
+	err = content.UpdateSchedule()
+	if err != nil {
+		flog.Log.Errorf("CancelScheduleContent err: %s", err.Error())
+		resp.Error = Error(DBError, err.Error())
+		return
+	}
+
+	resp.Flag = true
+}
+
+type ListScheduleContentRequest struct {
+	Id       int64    `json:"id"`
+	NodeId   int64    `json:"node_id"`
+	UserId   int64    `json:"user_id"`
+	UserName string   `json:"user_name"`
+	Types    int      `json:"types" validate:"oneof=0 1 2"` // 0 all, 1 wait to publish, 2 wait to unpublish
+	Sort     []string `json:"sort"`
+	PageHelp
+}
+
+type ListScheduleContentResponse struct {
+	Contents []model.Content `json:"contents"`
+	PageHelp
+}
+
+func ListScheduleContent(c *gin.Context) {
+	resp := new(Resp)
+	uu, err := GetUserSession(c)
+	if err != nil {
+		flog.Log.Errorf("ListScheduleContent err: %s", err.Error())
+		resp.Error = Error(GetUserSessionError, err.Error())
+		JSONL(c, 200, nil, resp)
+		return
+	}
+
+	ListScheduleContentHelper(c, uu.Id)
+}
+
+func ListScheduleContentAdmin(c *gin.Context) {
+	ListScheduleContentHelper(c, 0)
+}
+
+func ListScheduleContentHelper(c *gin.Context, userId int64) {
+	resp := new(Resp)
+
+	respResult := new(ListScheduleContentResponse)
+	req := new(ListScheduleContentRequest)
+	defer func() {
+		JSONL(c, 200, req, resp)
+	}()
+
+	if errResp := ParseJSON(c, req); errResp != nil {
+		resp.Error = errResp
+		return
+	}
+
+	var validate = validator.New()
+	err := validate.Struct(req)
+	if err != nil {
+		flog.Log.Errorf("ListScheduleContent err: %s", err.Error())
+		resp.Error = Error(ParasError, err.Error())
+		return
+	}
+
+	session := model.FaFaRdb.Client.NewSession()
+	defer session.Close()
+
+	session.Table(new(model.Content)).Where("1=1")
+
+	// not admin only see self
+	if userId != 0 {
+		session.And("user_id=?", userId)
+	} else {
+		if req.UserId != 0 {
+			session.And("user_id=?", req.UserId)
+		}
+		if req.UserName != "" {
+			session.And("user_name=?", req.UserName)
+		}
+	}
+
+	if req.Id != 0 {
+		session.And("id=?", req.Id)
+	}
+
+	if req.NodeId != 0 {
+		session.And("node_id=?", req.NodeId)
+	}
+
+	if req.Types == 1 {
+		session.And("publish_at>?", 0)
+	} else if req.Types == 2 {
+		session.And("unpublish_at>?", 0)
+	} else {
+		session.And("(publish_at>? or unpublish_at>?)", 0, 0)
+	}
+
+	countSession := session.Clone()
+	defer countSession.Close()
+	total, err := countSession.Count()
+	if err != nil {
+		flog.Log.Errorf("ListScheduleContent err:%s", err.Error())
+		resp.Error = Error(DBError, err.Error())
+		return
+	}
+
+	cs := make([]model.Content, 0)
+	p := &req.PageHelp
+	if total == 0 {
+		if p.Limit == 0 {
+			p.Limit = 20
+		}
+	} else {
+		p.build(session, req.Sort, model.ContentScheduleSortName)
+		err = session.Omit("describe", "pre_describe", "describe_html", "toc").Find(&cs)
+		if err != nil {
+			flog.Log.Errorf("ListScheduleContent err:%s", err.Error())
+			resp.Error = Error(DBError, err.Error())
+			return
+		}
+	}
+
+	respResult.Contents = cs
+	p.Pages = int(math.Ceil(float64(total) / float64(p.Limit)))
+	p.Total = int(total)
+	respResult.PageHelp = *p
+	resp.Data = respResult
+	resp.Flag = true
+}
+
+// Publish or hide the content on time, every server can run it, the db make sure only one do the job
+func LoopSchedule() {
+	flog.Log.Debugf("Schedule start")
+	for {
+		now := time.Now().Unix()
+		cs, err := model.ContentScheduleDue(now, true, ScheduleBatchSize)
+		if err != nil {
+			flog.Log.Errorf("Schedule publish err: %s", err.Error())
+		}
+
+		for _, v := range cs {
+			content := v
+			ok, err := content.SchedulePublish()
+			if err != nil {
+				flog.Log.Errorf("Schedule publish content %d err: %s", v.Id, err.Error())
+				continue
+			}
+
+			if ok {
+				flog.Log.Debugf("Schedule publish content %d", v.Id)
+				afterPublishContent(&content)
+			}
+		}
+
+		cs, err = model.ContentScheduleDue(now, false, ScheduleBatchSize)
+		if err != nil {
+			flog.Log.Errorf("Schedule unpublish err: %s", err.Error())
+		}
+
+		for _, v := range cs {
+			content := v
+			ok, err := content.ScheduleUnpublish()
+			if err != nil {
+				flog.Log.Errorf("Schedule unpublish content %d err: %s", v.Id, err.Error())
+				continue
+			}
+
+			if ok {
+				flog.Log.Debugf("Schedule unpublish content %d", v.Id)
+				go SendToLoop(content.UserId, 0, 1)
+				go SendToLoop(content.UserId, content.NodeId, 2)
+				go SendToLoop(content.UserId, 0, 3)
+				SendToSearch(model.SearchContent, content.Id)
+				RefreshSitemap()
+			}
+		}
+
+		time.Sleep(ScheduleInterval)
+	}
+}
@@ -0,0 +1,383 @@
+package controllers
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator"
+	"github.com/hunterhug/fafacms/core/flog"
+	"github.com/hunterhug/fafacms/core/model"
+	"github.com/hunterhug/fafacms/core/util"
+	"math"
+)
+
+var (
+	// doc change put in, then index it in background
+	SearchChan = make(chan SearchType, 1000)
+
+	// snippet rune length
+	SearchSnippetLength = 120
+
+	// too many words in keyword only use the front
+	SearchMaxWords = 20
+)
+
+type SearchType struct {
+	Types int // model.SearchContent, model.SearchUser, model.SearchComment
+	DocId int64
+}
+
+// Index the doc again, never block, the rebuild command can fix the miss
+func SendToSearch(types int, docId int64) {
+	select {
+	case SearchChan <- SearchType{Types: types, DocId: docId}:
+	default:
+		flog.Log.Errorf("Search chan full, miss %d:%d", types, docId)
+	}
+}
+
+func searchIndex(types int, docId int64) error {
+	switch types {
+	case model.SearchContent:
+		err := model.SearchIndexContent(docId)
+		if err != nil {
+			return err
+		}
+		return model.SearchIndexCommentsOfContent(docId)
+	case model.SearchUser:
+		return model.SearchIndexUser(docId)
+	case model.SearchComment:
+		return model.SearchIndexComment(docId)
+	}
+	return nil
+}
+
+func LoopSearch() {
+	flog.Log.Debugf("Search index start")
+	for v := range SearchChan {
+		err := searchIndex(v.Types, v.DocId)
+		if err != nil {
+			flog.Log.Errorf("Search index %d:%d err: %s", v.Types, v.DocId, err.Error())
+		}
+	}
+}
+
+// Query words, same word only once
+func searchWords(keyword string) []string {
+	words := make([]string, 0)
+	exist := make(map[string]bool)
+	for _, w := range util.SearchTokens(keyword) {
+		if exist[w] {
+			continue
+		}
+		exist[w] = true
+		words = append(words, w)
+		if len(words) >= SearchMaxWords {
+			break
+		}
+	}
+	return words
+}
+
+type SearchRequest struct {
+	Keyword string `json:"keyword" validate:"required"`
+	Types   int    `json:"types" validate:"oneof=0 1 2 3"` // 0 or 1 content, 2 user, 3 comment
+	PageHelp
+}
+
+type SearchContentX struct {
+	Id               int64  `json:"id"`
+	Seo              string `json:"seo"`
+	Title            string `json:"title"`
+	TitleHighlight   string `json:"title_highlight"`
+	Snippet          string `json:"snippet"`
+	UserId           int64  `json:"user_id"`
+	UserName         string `json:"user_name"`
+	NodeId           int64  `json:"node_id"`
+	NodeSeo          string `json:"node_seo"`
+	ImagePath        string `json:"image_path"`
+	Views            int64  `json:"views"`
+	FirstPublishTime string `json:"first_publish_time"`
+	PublishTime      string `json:"publish_time"`
+}
+
+type SearchUserX struct {
+	Id            int64  `json:"id"`
+	Name          string `json:"name"`
+	NickName      string `json:"nick_name"`
+	NameHighlight string `json:"name_highlight"` // nick name highlight
+	HeadPhoto     string `json:"head_photo"`
+	Snippet       string `json:"snippet"`
+	IsVip         bool   `json:"is_vip"`
+	ContentNum    int64  `json:"content_num"`
+}
+
+type SearchCommentX struct {
+	Id           int64  `json:"id"`
+	ContentId    int64  `json:"content_id"`
+	ContentTitle string `json:"content_title"`
+	UserId       int64  `json:"user_id,omitempty"` // anonymous comment not show
+	UserName     string `json:"user_name,omitempty"`
+	Snippet      string `json:"snippet"`
+	CreateTime   string `json:"create_time"`
+}
+
+type SearchResponse struct {
+	Types    int              `json:"types"`
+	Words    []string         `json:"words"`
+	Contents []SearchContentX `json:"contents,omitempty"`
+	Users    []SearchUserX    `json:"users,omitempty"`
+	Comments []SearchCommentX `json:"comments,omitempty"`
+	PageHelp
+}
+
+// Full text search of content, user and comment, order by relevance
+func Search(c *gin.Context) {
+	resp := new(Resp)
+
+	respResult := new(SearchResponse)
+	req := new(SearchRequest)
+	defer func() {
+		JSONL(c, 200, req, resp)
+	}()
+
+	if errResp := ParseJSON(c, req); errResp != nil {
+		resp.Error = errResp
+		return
+	}
+
+	var validate = validator.New()
+	err := validate.Struct(req)
+	if err != nil {
+		flog.Log.Errorf("Search err: %s", err.Error())
+		resp.Error = Error(ParasError, err.Error())
+		return
+	}
+
+	if req.Types == 0 {
+		req.Types = model.SearchContent
+	}
+
+	p := &req.PageHelp
+	if p.Page <= 0 {
+		p.Page = 1
+	}
+	if p.Limit <= 0 {
+		p.Limit = 20
+	}
+	if p.Limit > 100 {
+		p.Limit = 100
+	}
+
+	words := searchWords(req.Keyword)
+	ids, total, err := model.SearchQuery(req.Types, words, (p.Page-1)*p.Limit, p.Limit)
+	if err != nil {
+		flog.Log.Errorf("Search err:%s", err.Error())
+		resp.Error = Error(DBError, err.Error())
+		return
+	}
+
+	switch req.Types {
+	case model.SearchContent:
+		respResult.Contents, err = searchContents(ids, words)
+	case model.SearchUser:
+		respResult.Users, err = searchUsers(ids, words)
+	case model.SearchComment:
+		respResult.Comments, err = searchComments(ids, words)
+	}
+	if err != nil {
+		flog.Log.Errorf("Search err:%s", err.Error())
+		resp.Error = Error(DBError, err.Error())
+		return
+	}
+
+	respResult.Types = req.Types
+	respResult.Words = words
+	p.Pages = int(math.Ceil(float64(total) / float64(p.Limit)))
+	p.Total = int(total)
+	respResult.PageHelp = *p
+	resp.Data = respResult
+	resp.Flag = true
+}
+
+func searchContents(ids []int64, words []string) ([]SearchContentX, error) {
+	result := make([]SearchContentX, 0, len(ids))
+	if len(ids) == 0 {
+		return result, nil
+	}
+
+	cs := make([]model.Content, 0)
+	err := model.FaFaRdb.Client.In("id", ids).Omit("pre_describe", "pre_title", "describe_html", "toc").Find(&cs)
+	if err != nil {
+		return nil, err
+	}
+
+	m := make(map[int64]model.Content, len(cs))
+	for _, v := range cs {
+		m[v.Id] = v
+	}
+
+	// keep the order of relevance, index may be old so check again
+	for _, id := range ids {
+		v, ok := m[id]
+		if !ok || !v.Searchable() {
+			continue
+		}
+
+		result = append(result, SearchContentX{
+			Id:               v.Id,
+			Seo:              v.Seo,
+			Title:            v.Title,
+			TitleHighlight:   util.SearchHighlight(v.Title, words, SearchSnippetLength),
+			Snippet:          util.SearchHighlight(v.Describe, words, SearchSnippetLength),
+			UserId:           v.UserId,
+			UserName:         v.UserName,
+			NodeId:           v.NodeId,
+			NodeSeo:          v.NodeSeo,
+			ImagePath:        v.ImagePath,
+			Views:            v.Views,
+			FirstPublishTime: GetSecond2DateTimes(v.FirstPublishTime),
+			PublishTime:      GetSecond2DateTimes(v.PublishTime),
+		})
+	}
+	return result, nil
+}
+
+func searchUsers(ids []int64, words []string) ([]SearchUserX, error) {
+	result := make([]SearchUserX, 0, len(ids))
+	if len(ids) == 0 {
+		return result, nil
+	}
+
+	us := make([]model.User, 0)
+	err := model.FaFaRdb.Client.In("id", ids).Find(&us)
+	if err != nil {
+		return nil, err
+	}
+
+	m := make(map[int64]model.User, len(us))
+	for _, v := range us {
+		m[v.Id] = v
+	}
+
+	for _, id := range ids {
+		v, ok := m[id]
+		if !ok || v.Status != 1 {
+			continue
+		}
+
+		describe := v.ShortDescribe
+		if describe == "" {
+			describe = v.Describe
+		}
+
+		result = append(result, SearchUserX{
+			Id:            v.Id,
+			Name:          v.Name,
+			NickName:      v.NickName,
+			NameHighlight: util.SearchHighlight(v.NickName, words, SearchSnippetLength),
+			HeadPhoto:     v.HeadPhoto,
+			Snippet:       util.SearchHighlight(describe, words, SearchSnippetLength),
+			IsVip:         v.Vip == 1,
+			ContentNum:    v.ContentNum,
+		})
+	}
+	return result, nil
+}
+
+func searchComments(ids []int64, words []string) ([]SearchCommentX, error) {
+	result := make([]SearchCommentX, 0, len(ids))
+	if len(ids) == 0 {
+		return result, nil
+	}
+
+	cs := make([]model.Comment, 0)
+	err := model.FaFaRdb.Client.In("id", ids).Find(&cs)
+	if err != nil {
+		return nil, err
+	}
+
+	m := make(map[int64]model.Comment, len(cs))
+	contentIds := make([]int64, 0, len(cs))
+	for _, v := range cs {
+		m[v.Id] = v
+		contentIds = append(contentIds, v.ContentId)
+	}
+
+	// the content of comment also can be search
+	contents := make([]model.Content, 0)
+	err = model.FaFaRdb.Client.In("id", contentIds).Cols("id", "status", "version", "password").Find(&contents)
+	if err != nil {
+		return nil, err
+	}
+
+	searchable := make(map[int64]bool, len(contents))
+	for _, v := range contents {
+		searchable[v.Id] = v.Searchable()
+	}
+
+	for _, id := range ids {
+		v, ok := m[id]
+		if !ok || v.Status != 0 || v.IsDelete == 1 || !searchable[v.ContentId] {
+			continue
+		}
+
+		x := SearchCommentX{
+			Id:           v.Id,
+			ContentId:    v.ContentId,
+			ContentTitle: v.ContentTitle,
+			Snippet:      util.SearchHighlight(v.Describe, words, SearchSnippetLength),
+			CreateTime:   GetSecond2DateTimes(v.CreateTime),
+		}
+
+		if v.CommentAnonymous == 0 {
+			x.UserId = v.UserId
+			x.UserName = v.UserName
+		}
+		result = append(result, x)
+	}
+	return result, nil
+}
+
+type RebuildSearchRequest struct {
+	Types int `json:"types" validate:"oneof=0 1 2 3"` // 0 all
+}
+
+// Admin clear the index and build again, may be slow
+func RebuildSearch(c *gin.Context) {
+	resp := new(Resp)
+	req := new(RebuildSearchRequest)
+	defer func() {
+		JSONL(c, 200, req, resp)
+	}()
+
+	if errResp := ParseJSON(c, req); errResp != nil {
+		resp.Error = errResp
+		return
+	}
+
+	var validate = validator.New()
+	err := validate.Struct(req)
+	if err != nil {
+		flog.Log.Errorf("RebuildSearch err: %s", err.Error())
+		resp.Error = Error(ParasError, err.Error())
+		return
+	}
+
+	types := []int{model.SearchContent, model.SearchUser, model.SearchComment}
+	if req.Types != 0 {
+		types = []int{req.Types}
+	}
+
+	result := make(map[int]int64)
+	for _, t := range types {
+		num, err := model.SearchRebuild(t)
+		if err != nil {
+			flog.Log.Errorf("RebuildSearch err: %s", err.Error())
+			resp.Error = Error(DBError, err.Error())
+			return
+		}
+		result[t] = num
+	}
+
+	resp.Data = result
+	resp.Flag = true
+}
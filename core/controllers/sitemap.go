@@ -0,0 +1,283 @@
+package controllers
+
+import (
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/hunterhug/fafacms/core/flog"
+	"github.com/hunterhug/fafacms/core/model"
+	"github.com/hunterhug/fafacms/core/util"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var (
+	// robots.txt, empty use the default
+	Robots = ""
+
+	// content change in other server can not tell this one, so build again after some seconds
+	SitemapExpire int64 = 3600
+
+	// content change too often, not build more than once in the seconds
+	SitemapMinInterval int64 = 60
+)
+
+type sitemapFile struct {
+	pages     [][]util.SitemapUrl // every page at most util.SitemapMaxUrl urls, loc is path, host add when output
+	lastMods  []int64             // last modify of every page
+	buildTime int64
+}
+
+var (
+	// at most so many sitemap keep, the early build one drop when full
+	SitemapCacheMax = 1000
+
+	// key is the user id, 0 is the site, not the host so request with other host not build again
+	sitemapLock       sync.Mutex
+	sitemapCache      = make(map[int64]*sitemapFile)
+	sitemapChangeTime int64
+)
+
+// Content or node or user can see or not change, sitemap should build again when next request
+func RefreshSitemap() {
+	atomic.StoreInt64(&sitemapChangeTime, time.Now().UnixNano())
+}
+
+// Site sitemap: /sitemap.xml, too many url will be index, page in /sitemap.xml?page=1
+func Sitemap(c *gin.Context) {
+	f, err := sitemapGet(nil)
+	if err != nil {
+		flog.Log.Errorf("Sitemap err: %s", err.Error())
+		c.AbortWithStatus(500)
+		return
+	}
+
+	sitemapOutput(c, f, "/sitemap.xml")
+}
+
+// User sitemap: /sitemap/hunterhug.xml
+func SitemapUser(c *gin.Context) {
+	name := c.Param("name")
+	if !strings.HasSuffix(name, ".xml") {
+		c.AbortWithStatus(404)
+		return
+	}
+
+	u, ok := feedUser(c, strings.TrimSuffix(name, ".xml"))
+	if !ok {
+		return
+	}
+
+	f, err := sitemapGet(u)
+	if err != nil {
+		flog.Log.Errorf("Sitemap err: %s", err.Error())
+		c.AbortWithStatus(500)
+		return
+	}
+
+	sitemapOutput(c, f, fmt.Sprintf("/sitemap/%s.xml", u.Name))
+}
+
+// Add the host to the path and render, more than one page output the index when no page
+func sitemapOutput(c *gin.Context, f *sitemapFile, self string) {
+	site := siteUrl(c)
+	loc := time.FixedZone("", int(3600*TimeZone))
+
+	var raw []byte
+	var err error
+	page := c.Query("page")
+	if page == "" && len(f.pages) > 1 {
+		index := make([]util.SitemapUrl, 0, len(f.pages))
+		for i := range f.pages {
+			index = append(index, util.SitemapUrl{Loc: fmt.Sprintf("%s%s?page=%d", site, self, i+1), LastMod: f.lastMods[i]})
+		}
+		raw, err = util.SitemapIndex(index, loc)
+	} else {
+		i := 1
+		if page != "" {
+			i, err = util.SI(page)
+			if err != nil || i < 1 || i > len(f.pages) {
+				c.AbortWithStatus(404)
+				return
+			}
+		}
+
+		urls := make([]util.SitemapUrl, 0, len(f.pages[i-1]))
+		for _, v := range f.pages[i-1] {
+			urls = append(urls, util.SitemapUrl{Loc: site + v.Loc, LastMod: v.LastMod})
+		}
+		raw, err = util.Sitemap(urls, loc)
+	}
+
+	if err != nil {
+		flog.Log.Errorf("Sitemap err: %s", err.Error())
+		c.AbortWithStatus(500)
+		return
+	}
+
+	c.Data(200, "application/xml; charset=utf-8", raw)
+}
+
+// Get from cache, build again when content change or expire
+func sitemapGet(u *model.User) (*sitemapFile, error) {
+	var userId int64
+	if u != nil {
+		userId = u.Id
+	}
+
+	sitemapLock.Lock()
+	defer sitemapLock.Unlock()
+
+	now := time.Now().UnixNano()
+	if f, ok := sitemapCache[userId]; ok {
+		if now-f.buildTime < SitemapMinInterval*int64(time.Second) {
+			return f, nil
+		}
+
+		if f.buildTime > atomic.LoadInt64(&sitemapChangeTime) && now-f.buildTime < SitemapExpire*int64(time.Second) {
+			return f, nil
+		}
+	}
+
+	urls, err := sitemapUrls(userId)
+	if err != nil {
+		return nil, err
+	}
+
+	f := &sitemapFile{buildTime: now}
+	for begin := 0; begin == 0 || begin < len(urls); begin = begin + util.SitemapMaxUrl {
+		end := begin + util.SitemapMaxUrl
+		if end > len(urls) {
+			end = len(urls)
+		}
+
+		var lastMod int64
+		for _, v := range urls[begin:end] {
+			if v.LastMod > lastMod {
+				lastMod = v.LastMod
+			}
+		}
+		f.pages = append(f.pages, urls[begin:end])
+		f.lastMods = append(f.lastMods, lastMod)
+	}
+
+	// full drop the early build one
+	if _, ok := sitemapCache[userId]; !ok && len(sitemapCache) >= SitemapCacheMax {
+		var early int64
+		earlyTime := now
+		for k, v := range sitemapCache {
+			if v.buildTime < earlyTime {
+				early, earlyTime = k, v.buildTime
+			}
+		}
+		delete(sitemapCache, early)
+	}
+
+	sitemapCache[userId] = f
+	return f, nil
+}
+
+// Path of home, normal user, not hide node, and publish, not hide, not ban, not rubbish, no password content
+func sitemapUrls(userId int64) ([]util.SitemapUrl, error) {
+	urls := make([]util.SitemapUrl, 0)
+	if userId == 0 {
+		urls = append(urls, util.SitemapUrl{Loc: "/"})
+	}
+
+	normalUser := "user_id in (SELECT id FROM `fafacms_user` WHERE status=1)"
+
+	users := make([]model.User, 0)
+	session := model.FaFaRdb.Client.Where("status=?", 1)
+	if userId != 0 {
+		session.And("id=?", userId)
+	}
+	err := session.Asc("id").Cols("id", "name", "update_time", "create_time").Find(&users)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, v := range users {
+		lastMod := v.UpdateTime
+		if lastMod == 0 {
+			lastMod = v.CreateTime
+		}
+		urls = append(urls, util.SitemapUrl{Loc: fmt.Sprintf(UserUrlFormat, v.Name), LastMod: lastMod})
+	}
+
+	nodes := make([]model.ContentNode, 0)
+	session = model.FaFaRdb.Client.Where(normalUser)
+	if userId != 0 {
+		session.And("user_id=?", userId)
+	}
+	err = session.Asc("id").Cols("id", "user_name", "seo", "status", "path", "update_time", "create_time").Find(&nodes)
+	if err != nil {
+		return nil, err
+	}
+
+	// node hide, the children and content in them can not see
+	hideNodes := make(map[int64]bool)
+	for _, v := range nodes {
+		if v.Status != 0 {
+			hideNodes[v.Id] = true
+		}
+	}
+
+	for _, v := range nodes {
+		for _, id := range model.NodePathIds(v.Path) {
+			if hideNodes[id] {
+				hideNodes[v.Id] = true
+				break
+			}
+		}
+	}
+
+	for _, v := range nodes {
+		if hideNodes[v.Id] {
+			continue
+		}
+
+		lastMod := v.UpdateTime
+		if lastMod == 0 {
+			lastMod = v.CreateTime
+		}
+		urls = append(urls, util.SitemapUrl{Loc: fmt.Sprintf(NodeUrlFormat, v.UserName, v.Seo), LastMod: lastMod})
+	}
+
+	cs := make([]model.Content, 0)
+	session = model.FaFaRdb.Client.Where("status=?", 0).And("version>?", 0).And("password=?", "").And(normalUser)
+	if userId != 0 {
+		session.And("user_id=?", userId)
+	}
+	err = session.Asc("id").Cols("id", "user_name", "node_id", "publish_time").Find(&cs)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, v := range cs {
+		if hideNodes[v.NodeId] {
+			continue
+		}
+
+		urls = append(urls, util.SitemapUrl{Loc: fmt.Sprintf(ContentUrlFormat, v.UserName, v.Id), LastMod: v.PublishTime})
+	}
+	return urls, nil
+}
+
+// robots.txt, sitemap line will add when not have
+func RobotsTxt(c *gin.Context) {
+	robots := Robots
+	if robots == "" {
+		robots = "User-agent: *\nDisallow: /v1/\nDisallow: /storage_x/\n"
+	}
+
+	if !strings.HasSuffix(robots, "\n") {
+		robots = robots + "\n"
+	}
+
+	if !strings.Contains(strings.ToLower(robots), "sitemap:") {
+		robots = robots + fmt.Sprintf("\nSitemap: %s/sitemap.xml\n", siteUrl(c))
+	}
+
+	c.Data(200, "text/plain; charset=utf-8", []byte(robots))
+}
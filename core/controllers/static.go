@@ -0,0 +1,596 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/hunterhug/fafacms/core/config"
+	"github.com/hunterhug/fafacms/core/flog"
+	"github.com/hunterhug/fafacms/core/model"
+	"github.com/hunterhug/fafacms/core/util"
+	"html/template"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var (
+	// content num in one index page
+	StaticPageSize = 20
+
+	// keep in the output dir, record what build before
+	StaticManifestName = ".fafacms_static.json"
+
+	staticDoing int32
+	staticLock  sync.Mutex
+	staticLast  *StaticResult
+)
+
+type StaticOption struct {
+	UserId   int64  // 0 all user and the site index
+	Output   string // dir to write
+	Template string // dir of *.html to redefine the default template, empty use the default
+	Full     bool   // render all content again, otherwise only the publish time change
+}
+
+type StaticResult struct {
+	Output     string `json:"output"`
+	UserId     int64  `json:"user_id"`
+	Full       bool   `json:"full"`
+	Users      int    `json:"users"`
+	Nodes      int    `json:"nodes"`
+	Contents   int    `json:"contents"`
+	Render     int    `json:"render"`      // content page render this time
+	Skip       int    `json:"skip"`        // content publish time not change
+	Delete     int    `json:"delete"`      // content can not see any more
+	Pages      int    `json:"pages"`       // index, user and node page
+	Files      int    `json:"files"`       // upload file copy this time
+	FilesMiss  int    `json:"files_miss"`  // refer but not found in the storage
+	BeginTime  int64  `json:"begin_time"`  // unix
+	FinishTime int64  `json:"finish_time"` // unix
+	Error      string `json:"error,omitempty"`
+}
+
+// Render the publish content of normal user into html, can serve by any web server
+func StaticBuild(opt StaticOption) (*StaticResult, error) {
+	if opt.Output == "" {
+		return nil, errors.New("output dir empty")
+	}
+
+	loc := time.FixedZone("", int(3600*TimeZone))
+	t, hash, err := util.StaticTemplate(opt.Template, loc)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, name := range util.StaticTemplateNames {
+		if t.Lookup(name) == nil {
+			return nil, fmt.Errorf("template %s not define", name)
+		}
+	}
+
+	manifestName := filepath.Join(opt.Output, StaticManifestName)
+	manifest, err := util.StaticManifestLoad(manifestName)
+	if err != nil {
+		return nil, err
+	}
+
+	b := &staticBuilder{
+		opt:      opt,
+		tpl:      t,
+		manifest: manifest,
+		seen:     make(map[int64]bool),
+		files:    make(map[string]bool),
+		result:   &StaticResult{Output: opt.Output, UserId: opt.UserId, BeginTime: time.Now().Unix()},
+		site:     util.StaticSite{Name: SiteName, Url: SiteUrl, BuildTime: time.Now().Unix()},
+	}
+
+	// template change all content should render again
+	b.result.Full = opt.Full || manifest.Template != hash
+	manifest.Template = hash
+
+	err = b.build()
+
+	// what done still record, next time not need to do again
+	if e := manifest.Save(manifestName); e != nil && err == nil {
+		err = e
+	}
+
+	b.result.FinishTime = time.Now().Unix()
+	if err != nil {
+		b.result.Error = err.Error()
+	}
+	return b.result, err
+}
+
+type staticBuilder struct {
+	opt      StaticOption
+	tpl      *template.Template
+	manifest *util.StaticManifest
+	seen     map[int64]bool
+	files    map[string]bool
+	result   *StaticResult
+	site     util.StaticSite
+}
+
+func (b *staticBuilder) build() error {
+	users := make([]model.User, 0)
+	session := model.FaFaRdb.Client.Where("status=?", 1)
+	if b.opt.UserId != 0 {
+		session.And("id=?", b.opt.UserId)
+	}
+	err := session.Asc("id").Cols("id", "name", "nick_name", "short_describe", "head_photo").Find(&users)
+	if err != nil {
+		return err
+	}
+
+	all := make([]*util.StaticContent, 0)
+	staticUsers := make([]*util.StaticUser, 0, len(users))
+	for _, u := range users {
+		if !util.StaticSafeName(u.Name) {
+			continue
+		}
+
+		su, cs, err := b.user(u)
+		if err != nil {
+			return err
+		}
+
+		staticUsers = append(staticUsers, su)
+		all = append(all, cs...)
+	}
+
+	// the site index only build when all user
+	if b.opt.UserId == 0 {
+		keep := make(map[string]bool)
+		for _, v := range staticUsers {
+			keep[v.Name] = true
+		}
+
+		// user not normal any more, remove all the page
+		err = b.clean(staticParentDir(b.opt.Output, fmt.Sprintf(UserUrlFormat, "x")), keep)
+		if err != nil {
+			return err
+		}
+
+		sort.SliceStable(all, func(i, j int) bool {
+			if all[i].FirstPublishTime != all[j].FirstPublishTime {
+				return all[i].FirstPublishTime > all[j].FirstPublishTime
+			}
+			return all[i].Id > all[j].Id
+		})
+
+		err = b.pages("index.html", "/", &util.StaticPage{Users: staticUsers}, all)
+		if err != nil {
+			return err
+		}
+	}
+
+	// content hide or delete, remove the page
+	for id, v := range b.manifest.Contents {
+		if b.seen[id] || (b.opt.UserId != 0 && v.UserId != b.opt.UserId) {
+			continue
+		}
+
+		err = os.RemoveAll(filepath.Dir(util.StaticUrlFile(b.opt.Output, v.Url)))
+		if err != nil {
+			return err
+		}
+		delete(b.manifest.Contents, id)
+		b.result.Delete++
+	}
+	return nil
+}
+
+// User page, node page and content page of one user
+func (b *staticBuilder) user(u model.User) (*util.StaticUser, []*util.StaticContent, error) {
+	b.result.Users++
+	su := &util.StaticUser{
+		Name:          u.Name,
+		NickName:      u.NickName,
+		ShortDescribe: u.ShortDescribe,
+		HeadPhoto:     u.HeadPhoto,
+		Url:           fmt.Sprintf(UserUrlFormat, u.Name) + "/",
+	}
+	b.copyFiles(u.HeadPhoto)
+
+	nodes := make([]model.ContentNode, 0)
+	err := model.FaFaRdb.Client.Where("user_id=?", u.Id).Asc("sort_num", "id").Find(&nodes)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// node hide, the child and content in it can not see
+	nodeMap := make(map[int64]*model.ContentNode, len(nodes))
+	for i := range nodes {
+		nodeMap[nodes[i].Id] = &nodes[i]
+	}
+
+	hidden := func(id int64) bool {
+		seen := make(map[int64]bool)
+		for n, ok := nodeMap[id]; ok && !seen[n.Id]; n, ok = nodeMap[n.ParentNodeId] {
+			if n.Status != 0 || !util.StaticSafeName(n.Seo) {
+				return true
+			}
+			seen[n.Id] = true
+		}
+		return false
+	}
+
+	staticNodes := make(map[int64]*util.StaticNode)
+	for _, v := range nodes {
+		if hidden(v.Id) {
+			continue
+		}
+
+		staticNodes[v.Id] = &util.StaticNode{
+			Id:        v.Id,
+			Seo:       v.Seo,
+			Name:      v.Name,
+			Describe:  v.Describe,
+			ImagePath: v.ImagePath,
+			Url:       fmt.Sprintf(NodeUrlFormat, u.Name, v.Seo) + "/",
+			Children:  make([]*util.StaticNode, 0),
+		}
+		b.copyFiles(v.ImagePath)
+	}
+
+	tree := make([]*util.StaticNode, 0)
+	for _, v := range nodes {
+		n, ok := staticNodes[v.Id]
+		if !ok {
+			continue
+		}
+
+		if parent, ok := staticNodes[v.ParentNodeId]; ok && v.ParentNodeId != v.Id {
+			parent.Children = append(parent.Children, n)
+		} else {
+			tree = append(tree, n)
+		}
+	}
+
+	cs := make([]model.Content, 0)
+	err = model.FaFaRdb.Client.Where("user_id=?", u.Id).And("status=?", 0).And("version>?", 0).And("password=?", "").
+		Omit("pre_describe", "pre_title", "describe_html", "toc").Desc("top", "first_publish_time", "id").Find(&cs)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	list := make([]*util.StaticContent, 0, len(cs))
+	nodeContents := make(map[int64][]*util.StaticContent)
+	for _, v := range cs {
+		n, ok := staticNodes[v.NodeId]
+		if !ok {
+			continue
+		}
+
+		v.RenderIfNeed()
+		sc := &util.StaticContent{
+			Id:               v.Id,
+			Title:            v.Title,
+			Url:              fmt.Sprintf(ContentUrlFormat, u.Name, v.Id) + "/",
+			User:             su,
+			Node:             n,
+			Top:              v.Top == 1,
+			ImagePath:        v.ImagePath,
+			Excerpt:          v.Excerpt,
+			WordNum:          v.WordNum,
+			ReadMinute:       v.ReadMinute,
+			FirstPublishTime: v.FirstPublishTime,
+			PublishTime:      v.PublishTime,
+		}
+
+		err = b.content(u, sc)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		list = append(list, sc)
+		nodeContents[v.NodeId] = append(nodeContents[v.NodeId], sc)
+	}
+
+	err = b.pages("user.html", su.Url, &util.StaticPage{Title: su.NickName, User: su, Nodes: tree}, list)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	keep := make(map[string]bool)
+	for _, n := range staticNodes {
+		keep[n.Seo] = true
+	}
+
+	// node hide or delete, remove the page
+	err = b.clean(staticParentDir(b.opt.Output, fmt.Sprintf(NodeUrlFormat, u.Name, "x")), keep)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// content of the child node also in the node page
+	var under func(n *util.StaticNode) []*util.StaticContent
+	under = func(n *util.StaticNode) []*util.StaticContent {
+		r := append([]*util.StaticContent{}, nodeContents[n.Id]...)
+		for _, child := range n.Children {
+			r = append(r, under(child)...)
+		}
+		return r
+	}
+
+	for _, n := range staticNodes {
+		ncs := under(n)
+		sort.SliceStable(ncs, func(i, j int) bool {
+			if ncs[i].Top != ncs[j].Top {
+				return ncs[i].Top
+			}
+			if ncs[i].FirstPublishTime != ncs[j].FirstPublishTime {
+				return ncs[i].FirstPublishTime > ncs[j].FirstPublishTime
+			}
+			return ncs[i].Id > ncs[j].Id
+		})
+
+		n.ContentNum = len(ncs)
+		err = b.pages("node.html", n.Url, &util.StaticPage{Title: n.Name, User: su, Nodes: tree, Node: n}, ncs)
+		if err != nil {
+			return nil, nil, err
+		}
+		b.result.Nodes++
+	}
+
+	return su, list, nil
+}
+
+// Render the content page when publish time change
+func (b *staticBuilder) content(u model.User, sc *util.StaticContent) error {
+	b.seen[sc.Id] = true
+	b.result.Contents++
+
+	item, ok := b.manifest.Contents[sc.Id]
+	if !b.result.Full && ok && item.PublishTime == sc.PublishTime && item.Url == sc.Url && util.FileExist(util.StaticUrlFile(b.opt.Output, sc.Url)) {
+		b.result.Skip++
+		return nil
+	}
+
+	c := new(model.Content)
+	exist, err := model.FaFaRdb.Client.Where("id=?", sc.Id).Omit("pre_describe", "pre_title").Get(c)
+	if err != nil {
+		return err
+	}
+
+	if !exist {
+		return nil
+	}
+
+	c.RenderIfNeed()
+	sc.Html = template.HTML(c.DescribeHtml)
+	sc.Toc = c.Toc
+	sc.Tags, err = model.ContentTagNames(c.Id)
+	if err != nil {
+		return err
+	}
+
+	raw, err := util.StaticRender(b.tpl, "content.html", &util.StaticPage{Site: b.site, Title: sc.Title, User: sc.User, Node: sc.Node, Content: sc})
+	if err != nil {
+		return err
+	}
+
+	_, err = util.StaticWrite(util.StaticUrlFile(b.opt.Output, sc.Url), raw)
+	if err != nil {
+		return err
+	}
+
+	// the html not keep in memory, too big
+	sc.Html = ""
+	b.copyFiles(c.DescribeHtml + " " + c.ImagePath)
+	b.manifest.Contents[sc.Id] = util.StaticManifestItem{UserId: u.Id, Url: sc.Url, PublishTime: sc.PublishTime}
+	b.result.Render++
+	return nil
+}
+
+// Split into many page, the old page more than now will be remove
+func (b *staticBuilder) pages(name string, url string, page *util.StaticPage, list []*util.StaticContent) error {
+	pages := (len(list) + StaticPageSize - 1) / StaticPageSize
+	if pages == 0 {
+		pages = 1
+	}
+
+	for i := 1; i <= pages; i++ {
+		end := i * StaticPageSize
+		if end > len(list) {
+			end = len(list)
+		}
+
+		p := *page
+		p.Site = b.site
+		p.Contents = list[(i-1)*StaticPageSize : end]
+		p.Page = i
+		p.Pages = pages
+		if i > 1 {
+			p.PrevUrl = util.StaticPageUrl(url, i-1)
+		}
+		if i < pages {
+			p.NextUrl = util.StaticPageUrl(url, i+1)
+		}
+
+		raw, err := util.StaticRender(b.tpl, name, &p)
+		if err != nil {
+			return err
+		}
+
+		_, err = util.StaticWrite(util.StaticUrlFile(b.opt.Output, util.StaticPageUrl(url, i)), raw)
+		if err != nil {
+			return err
+		}
+		b.result.Pages++
+	}
+
+	for i := pages + 1; ; i++ {
+		dir := filepath.Dir(util.StaticUrlFile(b.opt.Output, util.StaticPageUrl(url, i)))
+		if !util.FileExist(dir) {
+			return nil
+		}
+
+		err := os.RemoveAll(dir)
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// Dir of the url without the last name: /u/x is u
+func staticParentDir(output string, url string) string {
+	return filepath.Dir(filepath.Dir(util.StaticUrlFile(output, url)))
+}
+
+// Remove the sub dir not keep
+func (b *staticBuilder) clean(dir string, keep map[string]bool) error {
+	fs, err := ioutil.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	for _, f := range fs {
+		if f.IsDir() && !keep[f.Name()] {
+			err = os.RemoveAll(filepath.Join(dir, f.Name()))
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Copy the upload file refer, oss file not need
+func (b *staticBuilder) copyFiles(s string) {
+	for _, v := range util.StaticFiles(s) {
+		if b.files[v] {
+			continue
+		}
+		b.files[v] = true
+
+		root := config.FaFaConfig.DefaultConfig.StoragePath
+		rel := strings.TrimPrefix(v, "storage/")
+		if strings.HasPrefix(v, "storage_x/") {
+			root = root + "_x"
+			rel = strings.TrimPrefix(v, "storage_x/")
+		}
+
+		ok, err := util.StaticCopy(filepath.Join(root, filepath.FromSlash(rel)), filepath.Join(b.opt.Output, filepath.FromSlash(v)))
+		if err != nil {
+			flog.Log.Errorf("StaticBuild copy %s err: %s", v, err.Error())
+			b.result.FilesMiss++
+			continue
+		}
+
+		if ok {
+			b.result.Files++
+		}
+	}
+}
+
+// Run in background, only one at the same time
+func staticBuildBackground(opt StaticOption) bool {
+	if !atomic.CompareAndSwapInt32(&staticDoing, 0, 1) {
+		return false
+	}
+
+	go func() {
+		defer atomic.StoreInt32(&staticDoing, 0)
+		result, err := StaticBuild(opt)
+		if err != nil {
+			flog.Log.Errorf("StaticBuild err: %s", err.Error())
+			if result == nil {
+				result = &StaticResult{Output: opt.Output, UserId: opt.UserId, Full: opt.Full, FinishTime: time.Now().Unix(), Error: err.Error()}
+			}
+		}
+
+		staticLock.Lock()
+		staticLast = result
+		staticLock.Unlock()
+	}()
+	return true
+}
+
+// Config not set, put beside the storage
+func StaticOutput() string {
+	if config.FaFaConfig.DefaultConfig.StaticPath != "" {
+		return config.FaFaConfig.DefaultConfig.StaticPath
+	}
+	return config.FaFaConfig.DefaultConfig.StoragePath + "_static"
+}
+
+type StaticBuildAdminRequest struct {
+	UserId int64 `json:"user_id"` // 0 all user
+	Full   bool  `json:"full"`
+}
+
+// Admin build the static site, list the status to see the result
+func StaticBuildAdmin(c *gin.Context) {
+	resp := new(Resp)
+	req := new(StaticBuildAdminRequest)
+	defer func() {
+		JSONL(c, 200, req, resp)
+	}()
+
+	if errResp := ParseJSON(c, req); errResp != nil {
+		resp.Error = errResp
+		return
+	}
+
+	if req.UserId != 0 {
+		u := new(model.User)
+		u.Id = req.UserId
+		exist, err := u.GetRaw()
+		if err != nil {
+			flog.Log.Errorf("StaticBuildAdmin err: %s", err.Error())
+			resp.Error = Error(DBError, err.Error())
+			return
+		}
+
+		if !exist {
+			flog.Log.Errorf("StaticBuildAdmin err: %s", "user not found")
+			resp.Error = Error(UserNotFound, "")
+			return
+		}
+	}
+
+	opt := StaticOption{
+		UserId:   req.UserId,
+		Output:   StaticOutput(),
+		Template: config.FaFaConfig.DefaultConfig.StaticTplPath,
+		Full:     req.Full,
+	}
+
+	if !staticBuildBackground(opt) {
+		flog.Log.Errorf("StaticBuildAdmin err: %s", "static build doing")
+		resp.Error = Error(StaticBuildDoing, "")
+		return
+	}
+
+	resp.Flag = true
+}
+
+type StaticStatusAdminResponse struct {
+	Doing bool          `json:"doing"`
+	Last  *StaticResult `json:"last"` // last build result of this server
+}
+
+func StaticStatusAdmin(c *gin.Context) {
+	resp := new(Resp)
+	defer func() {
+		JSONL(c, 200, nil, resp)
+	}()
+
+	staticLock.Lock()
+	last := staticLast
+	staticLock.Unlock()
+
+	resp.Data = StaticStatusAdminResponse{Doing: atomic.LoadInt32(&staticDoing) == 1, Last: last}
+	resp.Flag = true
+}
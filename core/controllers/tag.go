@@ -0,0 +1,603 @@
+package controllers
+
+import (
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator"
+	"github.com/hunterhug/fafacms/core/flog"
+	"github.com/hunterhug/fafacms/core/model"
+	"math"
+	"strings"
+)
+
+// Set the tags of content, then count it in ticker
+func setContentTags(uu *model.User, contentId int64, tags []string) ([]string, *ErrorResp) {
+	names, err := model.CleanTagNames(tags)
+	if err != nil {
+		return nil, Error(ParasError, err.Error())
+	}
+
+	err = model.SetContentTags(uu.Id, uu.Name, contentId, names)
+	if err != nil {
+		return nil, Error(DBError, err.Error())
+	}
+
+	go SendToLoop(uu.Id, 0, 4)
+	return names, nil
+}
+
+type ListTagRequest struct {
+	Id       int64    `json:"id"`
+	Name     string   `json:"name"`
+	UserId   int64    `json:"user_id"`
+	UserName string   `json:"user_name"`
+	Status   int      `json:"status" validate:"oneof=-1 0 1"`
+	Sort     []string `json:"sort"`
+	PageHelp
+}
+
+type ListTagResponse struct {
+	Tags []model.Tag `json:"tags"`
+	PageHelp
+}
+
+func ListTag(c *gin.Context) {
+	uu, err := GetUserSession(c)
+	if err != nil {
+		flog.Log.Errorf("ListTag err: %s", err.Error())
+		resp := new(Resp)
+		resp.Error = Error(GetUserSessionError, err.Error())
+		JSONL(c, 200, nil, resp)
+		return
+	}
+
+	ListTagHelper(c, uu.Id)
+}
+
+func ListTagAdmin(c *gin.Context) {
+	ListTagHelper(c, 0)
+}
+
+func ListTagHelper(c *gin.Context, userId int64) {
+	resp := new(Resp)
+
+	respResult := new(ListTagResponse)
+	req := new(ListTagRequest)
+	defer func() {
+		JSONL(c, 200, req, resp)
+	}()
+
+	if errResp := ParseJSON(c, req); errResp != nil {
+		resp.Error = errResp
+		return
+	}
+
+	var validate = validator.New()
+	err := validate.Struct(req)
+	if err != nil {
+		flog.Log.Errorf("ListTag err: %s", err.Error())
+		resp.Error = Error(ParasError, err.Error())
+		return
+	}
+
+	session := model.FaFaRdb.Client.NewSession()
+	defer session.Close()
+
+	session.Table(new(model.Tag)).Where("1=1")
+
+	// not admin only see self
+	if userId != 0 {
+		session.And("user_id=?", userId)
+	} else {
+		if req.UserId != 0 {
+			session.And("user_id=?", req.UserId)
+		}
+		if req.UserName != "" {
+			session.And("user_name=?", req.UserName)
+		}
+	}
+
+	if req.Id != 0 {
+		session.And("id=?", req.Id)
+	}
+
+	if req.Name != "" {
+		session.And("name=?", req.Name)
+	}
+
+	if req.Status != -1 {
+		session.And("status=?", req.Status)
+	}
+
+	countSession := session.Clone()
+	defer countSession.Close()
+	total, err := countSession.Count()
+	if err != nil {
+		flog.Log.Errorf("ListTag err:%s", err.Error())
+		resp.Error = Error(DBError, err.Error())
+		return
+	}
+
+	tags := make([]model.Tag, 0)
+	p := &req.PageHelp
+	if total == 0 {
+		if p.Limit == 0 {
+			p.Limit = 20
+		}
+	} else {
+		p.build(session, req.Sort, model.TagSortName)
+		err = session.Find(&tags)
+		if err != nil {
+			flog.Log.Errorf("ListTag err:%s", err.Error())
+			resp.Error = Error(DBError, err.Error())
+			return
+		}
+	}
+
+	respResult.Tags = tags
+	p.Pages = int(math.Ceil(float64(total) / float64(p.Limit)))
+	p.Total = int(total)
+	respResult.PageHelp = *p
+	resp.Data = respResult
+	resp.Flag = true
+}
+
+type RenameTagRequest struct {
+	Id   int64  `json:"id" validate:"required"`
+	Name string `json:"name" validate:"required,max=30"`
+}
+
+// Rename the tag, name used by other tag should merge them
+func RenameTag(c *gin.Context) {
+	resp := new(Resp)
+	req := new(RenameTagRequest)
+	defer func() {
+		JSONL(c, 200, req, resp)
+	}()
+
+	if errResp := ParseJSON(c, req); errResp != nil {
+		resp.Error = errResp
+		return
+	}
+
+	var validate = validator.New()
+	err := validate.Struct(req)
+	if err != nil {
+		flog.Log.Errorf("RenameTag err: %s", err.Error())
+		resp.Error = Error(ParasError, err.Error())
+		return
+	}
+
+	uu, err := GetUserSession(c)
+	if err != nil {
+		flog.Log.Errorf("RenameTag err: %s", err.Error())
+		resp.Error = Error(GetUserSessionError, err.Error())
+		return
+	}
+
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		flog.Log.Errorf("RenameTag err: %s", "name empty")
+		resp.Error = Error(ParasError, "name empty")
+		return
+	}
+
+	t := new(model.Tag)
+	t.Id = req.Id
+	t.UserId = uu.Id
+	exist, err := t.Get()
+	if err != nil {
+		flog.Log.Errorf("RenameTag err: %s", err.Error())
+		resp.Error = Error(DBError, err.Error())
+		return
+	}
+
+	if !exist {
+		flog.Log.Errorf("RenameTag err: %s", "tag not found")
+		resp.Error = Error(TagNotFound, "")
+		return
+	}
+
+	if t.Name == name {
+		resp.Flag = true
+		return
+	}
+
+	other := new(model.Tag)
+	other.UserId = uu.Id
+	other.Name = name
+	exist, err = other.Get()
+	if err != nil {
+		flog.Log.Errorf("RenameTag err: %s", err.Error())
+		resp.Error = Error(DBError, err.Error())
+		return
+	}
+
+	if exist {
+		flog.Log.Errorf("RenameTag err: %s", "tag name exist")
+		resp.Error = Error(TagNameAlreadyBeUsed, fmt.Sprintf("%d", other.Id))
+		return
+	}
+
+	err = t.Rename(name)
+	if err != nil {
+		flog.Log.Errorf("RenameTag err: %s", err.Error())
+		resp.Error = Error(DBError, err.Error())
+		return
+	}
+
+	resp.Flag = true
+}
+
+type MergeTagRequest struct {
+	FromIds []int64 `json:"from_ids" validate:"required"`
+	ToId    int64   `json:"to_id" validate:"required"`
+}
+
+// Merge some tags into one, content of those tags will be the target tag
+func MergeTag(c *gin.Context) {
+	resp := new(Resp)
+	req := new(MergeTagRequest)
+	defer func() {
+		JSONL(c, 200, req, resp)
+	}()
+
+	if errResp := ParseJSON(c, req); errResp != nil {
+		resp.Error = errResp
+		return
+	}
+
+	var validate = validator.New()
+	err := validate.Struct(req)
+	if err != nil {
+		flog.Log.Errorf("MergeTag err: %s", err.Error())
+		resp.Error = Error(ParasError, err.Error())
+		return
+	}
+
+	uu, err := GetUserSession(c)
+	if err != nil {
+		flog.Log.Errorf("MergeTag err: %s", err.Error())
+		resp.Error = Error(GetUserSessionError, err.Error())
+		return
+	}
+
+	fromIds := make([]int64, 0, len(req.FromIds))
+	exist := make(map[int64]bool)
+	for _, v := range req.FromIds {
+		if v == req.ToId || exist[v] {
+			continue
+		}
+		exist[v] = true
+		fromIds = append(fromIds, v)
+	}
+
+	if len(fromIds) == 0 {
+		flog.Log.Errorf("MergeTag err: %s", "from_ids empty")
+		resp.Error = Error(ParasError, "from_ids empty")
+		return
+	}
+
+	// all tags must be self
+	allIds := append([]int64{req.ToId}, fromIds...)
+	num, err := model.FaFaRdb.Client.Table(new(model.Tag)).Where("user_id=?", uu.Id).In("id", allIds).Count()
+	if err != nil {
+		flog.Log.Errorf("MergeTag err: %s", err.Error())
+		resp.Error = Error(DBError, err.Error())
+		return
+	}
+
+	if int(num) != len(allIds) {
+		flog.Log.Errorf("MergeTag err: %s", "tag not found")
+		resp.Error = Error(TagNotFound, "")
+		return
+	}
+
+	err = model.MergeTags(uu.Id, fromIds, req.ToId)
+	if err != nil {
+		flog.Log.Errorf("MergeTag err: %s", err.Error())
+		resp.Error = Error(DBError, err.Error())
+		return
+	}
+
+	go SendToLoop(uu.Id, 0, 4)
+	resp.Flag = true
+}
+
+type DeleteTagRequest struct {
+	Id int64 `json:"id" validate:"required"`
+}
+
+func DeleteTag(c *gin.Context) {
+	uu, err := GetUserSession(c)
+	if err != nil {
+		flog.Log.Errorf("DeleteTag err: %s", err.Error())
+		resp := new(Resp)
+		resp.Error = Error(GetUserSessionError, err.Error())
+		JSONL(c, 200, nil, resp)
+		return
+	}
+
+	DeleteTagHelper(c, uu.Id)
+}
+
+func DeleteTagAdmin(c *gin.Context) {
+	DeleteTagHelper(c, 0)
+}
+
+// Delete the tag, content will not be delete
+func DeleteTagHelper(c *gin.Context, userId int64) {
+	resp := new(Resp)
+	req := new(DeleteTagRequest)
+	defer func() {
+		JSONL(c, 200, req, resp)
+	}()
+
+	if errResp := ParseJSON(c, req); errResp != nil {
+		resp.Error = errResp
+		return
+	}
+
+	var validate = validator.New()
+	err := validate.Struct(req)
+	if err != nil {
+		flog.Log.Errorf("DeleteTag err: %s", err.Error())
+		resp.Error = Error(ParasError, err.Error())
+		return
+	}
+
+	t := new(model.Tag)
+	t.Id = req.Id
+	t.UserId = userId
+	exist, err := t.Get()
+	if err != nil {
+		flog.Log.Errorf("DeleteTag err: %s", err.Error())
+		resp.Error = Error(DBError, err.Error())
+		return
+	}
+
+	if !exist {
+		flog.Log.Errorf("DeleteTag err: %s", "tag not found")
+		resp.Error = Error(TagNotFound, "")
+		return
+	}
+
+	err = model.DeleteTag(t.Id)
+	if err != nil {
+		flog.Log.Errorf("DeleteTag err: %s", err.Error())
+		resp.Error = Error(DBError, err.Error())
+		return
+	}
+
+	resp.Flag = true
+}
+
+type UpdateStatusOfTagAdminRequest struct {
+	Id     int64 `json:"id" validate:"required"`
+	Status int   `json:"status" validate:"oneof=0 1"` // 1 ban, ban tag will not show in front end
+}
+
+func UpdateStatusOfTagAdmin(c *gin.Context) {
+	resp := new(Resp)
+	req := new(UpdateStatusOfTagAdminRequest)
+	defer func() {
+		JSONL(c, 200, req, resp)
+	}()
+
+	if errResp := ParseJSON(c, req); errResp != nil {
+		resp.Error = errResp
+		return
+	}
+
+	var validate = validator.New()
+	err := validate.Struct(req)
+	if err != nil {
+		flog.Log.Errorf("UpdateStatusOfTagAdmin err: %s", err.Error())
+		resp.Error = Error(ParasError, err.Error())
+		return
+	}
+
+	t := new(model.Tag)
+	t.Id = req.Id
+	exist, err := t.Get()
+	if err != nil {
+		flog.Log.Errorf("UpdateStatusOfTagAdmin err: %s", err.Error())
+		resp.Error = Error(DBError, err.Error())
+		return
+	}
+
+	if !exist {
+		flog.Log.Errorf("UpdateStatusOfTagAdmin err: %s", "tag not found")
+		resp.Error = Error(TagNotFound, "")
+		return
+	}
+
+	if t.Status != req.Status {
+		t.Status = req.Status
+		err = t.UpdateStatus()
+		if err != nil {
+			flog.Log.Errorf("UpdateStatusOfTagAdmin err: %s", err.Error())
+			resp.Error = Error(DBError, err.Error())
+			return
+		}
+	}
+
+	resp.Flag = true
+}
+
+type TagsRequest struct {
+	UserId   int64  `json:"user_id"`
+	UserName string `json:"user_name"`
+	Limit    int    `json:"limit"`
+}
+
+type TagsResponse struct {
+	Tags []model.TagCloud `json:"tags"`
+}
+
+// Tag cloud of user, or site wide when user empty
+func Tags(c *gin.Context) {
+	resp := new(Resp)
+	req := new(TagsRequest)
+	defer func() {
+		JSONL(c, 200, req, resp)
+	}()
+
+	if errResp := ParseJSON(c, req); errResp != nil {
+		resp.Error = errResp
+		return
+	}
+
+	if req.Limit <= 0 || req.Limit > 200 {
+		req.Limit = 50
+	}
+
+	clouds := make([]model.TagCloud, 0)
+	if req.UserId == 0 && req.UserName == "" {
+		var err error
+		clouds, err = model.TagClouds(req.Limit)
+		if err != nil {
+			flog.Log.Errorf("Tags err:%s", err.Error())
+			resp.Error = Error(DBError, err.Error())
+			return
+		}
+	} else {
+		session := model.FaFaRdb.Client.Where("status=?", 0).And("content_num>?", 0)
+		if req.UserId != 0 {
+			session.And("user_id=?", req.UserId)
+		}
+		if req.UserName != "" {
+			session.And("user_name=?", req.UserName)
+		}
+
+		tags := make([]model.Tag, 0)
+		err := session.Desc("content_num").Asc("name").Limit(req.Limit).Find(&tags)
+		if err != nil {
+			flog.Log.Errorf("Tags err:%s", err.Error())
+			resp.Error = Error(DBError, err.Error())
+			return
+		}
+
+		for _, t := range tags {
+			clouds = append(clouds, model.TagCloud{Name: t.Name, ContentNum: t.ContentNum})
+		}
+	}
+
+	resp.Data = TagsResponse{Tags: clouds}
+	resp.Flag = true
+}
+
+type TagContentsRequest struct {
+	Name     string   `json:"name" validate:"required"`
+	UserId   int64    `json:"user_id"`
+	UserName string   `json:"user_name"`
+	Render   bool     `json:"render"`
+	Sort     []string `json:"sort"`
+	PageHelp
+}
+
+type TagContentsResponse struct {
+	Name     string      `json:"name"`
+	Contents []ContentsX `json:"contents"`
+	PageHelp
+}
+
+// Publish content of tag, of user or site wide
+func TagContents(c *gin.Context) {
+	resp := new(Resp)
+
+	respResult := new(TagContentsResponse)
+	req := new(TagContentsRequest)
+	defer func() {
+		JSONL(c, 200, req, resp)
+	}()
+
+	if errResp := ParseJSON(c, req); errResp != nil {
+		resp.Error = errResp
+		return
+	}
+
+	var validate = validator.New()
+	err := validate.Struct(req)
+	if err != nil {
+		flog.Log.Errorf("TagContents err: %s", err.Error())
+		resp.Error = Error(ParasError, err.Error())
+		return
+	}
+
+	tagSession := model.FaFaRdb.Client.Table(new(model.Tag)).Where("name=?", req.Name).And("status=?", 0)
+	if req.UserId != 0 {
+		tagSession.And("user_id=?", req.UserId)
+	}
+	if req.UserName != "" {
+		tagSession.And("user_name=?", req.UserName)
+	}
+
+	tagIds := make([]int64, 0)
+	err = tagSession.Cols("id").Find(&tagIds)
+	if err != nil {
+		flog.Log.Errorf("TagContents err:%s", err.Error())
+		resp.Error = Error(DBError, err.Error())
+		return
+	}
+
+	p := &req.PageHelp
+	respResult.Name = req.Name
+	respResult.Contents = make([]ContentsX, 0)
+	if len(tagIds) == 0 {
+		if p.Limit == 0 {
+			p.Limit = 20
+		}
+		respResult.PageHelp = *p
+		resp.Data = respResult
+		resp.Flag = true
+		return
+	}
+
+	ids := make([]string, 0, len(tagIds))
+	for _, v := range tagIds {
+		ids = append(ids, fmt.Sprintf("%d", v))
+	}
+
+	session := model.FaFaRdb.Client.NewSession()
+	defer session.Close()
+
+	// same as the home content list
+	session.Table(new(model.Content)).Where("1=1")
+	session.And(fmt.Sprintf("id in (SELECT content_id FROM `fafacms_content_tag` WHERE tag_id in (%s))", strings.Join(ids, ",")))
+	session.And("status!=?", 1).And("status!=?", 3).And("version>?", 0)
+
+	countSession := session.Clone()
+	defer countSession.Close()
+	total, err := countSession.Count()
+	if err != nil {
+		flog.Log.Errorf("TagContents err:%s", err.Error())
+		resp.Error = Error(DBError, err.Error())
+		return
+	}
+
+	cs := make([]model.Content, 0)
+	if total == 0 {
+		if p.Limit == 0 {
+			p.Limit = 20
+		}
+	} else {
+		p.build(session, req.Sort, model.ContentSortName2)
+		err = session.Omit("pre_describe", "pre_title", "describe_html", "toc").Find(&cs)
+		if err != nil {
+			flog.Log.Errorf("TagContents err:%s", err.Error())
+			resp.Error = Error(DBError, err.Error())
+			return
+		}
+	}
+
+	for _, v := range cs {
+		respResult.Contents = append(respResult.Contents, contentsX(v, req.Render))
+	}
+
+	p.Pages = int(math.Ceil(float64(total) / float64(p.Limit)))
+	p.Total = int(total)
+	respResult.PageHelp = *p
+	resp.Data = respResult
+	resp.Flag = true
+}
@@ -0,0 +1,197 @@
+package controllers
+
+import (
+	"bytes"
+	"github.com/gin-gonic/gin"
+	"github.com/hunterhug/fafacms/core/flog"
+	"github.com/hunterhug/fafacms/core/model"
+	"github.com/hunterhug/fafacms/core/util"
+	"net/url"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	// dir of themes, every sub dir is a theme, empty only return json
+	ThemePath = ""
+
+	// theme of the site, user can choose his own
+	Theme = "default"
+
+	// load the template every request, change can see at once
+	ThemeDebug = false
+
+	themeLock  sync.Mutex
+	themeCache = make(map[string]*util.Theme)
+)
+
+// Data of the page, what the json return all inside
+type ThemeData struct {
+	Site    string
+	SiteUrl string
+	Theme   string
+	Handler string // one page may render by many handler, like Contents and Peoples both in home.html
+	Path    string
+	Query   url.Values
+	Request interface{}
+	Flag    bool
+	Error   *ErrorResp
+	Data    interface{}
+}
+
+// the page with those error will be 404
+var themeNotFound = map[int]bool{
+	UserNotFound:        true,
+	ContentNotFound:     true,
+	ContentNodeNotFound: true,
+	CommentNotFound:     true,
+}
+
+func themeGet(name string) (*util.Theme, error) {
+	themeLock.Lock()
+	defer themeLock.Unlock()
+
+	if t, ok := themeCache[name]; ok && !ThemeDebug {
+		return t, nil
+	}
+
+	t, err := util.ThemeLoad(filepath.Join(ThemePath, name), util.ThemeFuncs(time.FixedZone("", int(3600*TimeZone))))
+	if err != nil {
+		return nil, err
+	}
+
+	themeCache[name] = t
+	return t, nil
+}
+
+// Theme open, and the browser want html, ask for application/json still json
+func ThemeWant(c *gin.Context) bool {
+	if ThemePath == "" || c.Request.Method != "GET" || !strings.Contains(c.GetHeader("Accept"), gin.MIMEHTML) {
+		return false
+	}
+	return c.NegotiateFormat(gin.MIMEJSON, gin.MIMEHTML) == gin.MIMEHTML
+}
+
+// The page belong to the user, will use the theme the user choose
+func themeUser(c *gin.Context, userId int64, userName string) {
+	if userId != 0 {
+		c.Set("themeUserId", userId)
+	}
+	if userName != "" {
+		c.Set("themeUserName", userName)
+	}
+}
+
+// Theme of the user first, not set or load fail use the site theme
+func themeOfUser(c *gin.Context) string {
+	userId := c.GetInt64("themeUserId")
+	userName := c.GetString("themeUserName")
+	if userId == 0 && userName == "" {
+		return ""
+	}
+
+	u := new(model.User)
+	u.Id = userId
+	u.Name = userName
+	exist, err := model.FaFaRdb.Client.Cols("id", "theme").Get(u)
+	if err != nil {
+		flog.Log.Errorf("Theme err: %s", err.Error())
+		return ""
+	}
+
+	if !exist {
+		return ""
+	}
+	return u.Theme
+}
+
+// Render the html of page when want, return false the caller should return json
+func ThemeHTML(c *gin.Context, page string, req interface{}, resp *Resp) bool {
+	if !ThemeWant(c) {
+		return false
+	}
+
+	name := Theme
+	t, err := themeGet(name)
+	if userTheme := themeOfUser(c); userTheme != "" && userTheme != name {
+		ut, e := themeGet(userTheme)
+		if e == nil {
+			name, t, err = userTheme, ut, nil
+		} else {
+			flog.Log.Errorf("Theme %s err: %s", userTheme, e.Error())
+		}
+	}
+
+	if err != nil {
+		flog.Log.Errorf("Theme %s err: %s", name, err.Error())
+		return false
+	}
+
+	// handler is the caller, like controllers.Content.func1
+	handler := ""
+	if pc, _, _, ok := runtime.Caller(1); ok {
+		fn := runtime.FuncForPC(pc).Name()
+		parts := strings.Split(fn[strings.LastIndex(fn, "/")+1:], ".")
+		if len(parts) > 1 {
+			handler = parts[1]
+		}
+	}
+
+	data := ThemeData{
+		Site:    SiteName,
+		SiteUrl: SiteUrl,
+		Theme:   name,
+		Handler: handler,
+		Path:    c.Request.URL.Path,
+		Query:   c.Request.URL.Query(),
+		Request: req,
+		Flag:    resp.Flag,
+		Error:   resp.Error,
+		Data:    resp.Data,
+	}
+
+	buf := new(bytes.Buffer)
+	err = t.Render(buf, page, data)
+	if err != nil {
+		flog.Log.Errorf("Theme %s err: %s", name, err.Error())
+		c.AbortWithStatus(500)
+		return true
+	}
+
+	code := 200
+	if resp.Error != nil && themeNotFound[resp.Error.ErrorID] {
+		code = 404
+	}
+	c.Data(code, "text/html; charset=utf-8", buf.Bytes())
+	return true
+}
+
+type ThemesResponse struct {
+	Theme  string   `json:"theme"` // theme of the site
+	Themes []string `json:"themes"`
+}
+
+// All theme can choose
+func Themes(c *gin.Context) {
+	resp := new(Resp)
+	defer func() {
+		JSON(c, 200, resp)
+	}()
+
+	themes := make([]string, 0)
+	if ThemePath != "" {
+		var err error
+		themes, err = util.ThemeList(ThemePath)
+		if err != nil {
+			flog.Log.Errorf("Themes err: %s", err.Error())
+			resp.Error = Error(SystemProblem, err.Error())
+			return
+		}
+	}
+
+	resp.Data = ThemesResponse{Theme: Theme, Themes: themes}
+	resp.Flag = true
+}
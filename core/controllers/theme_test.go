@@ -0,0 +1,42 @@
+package controllers
+
+import (
+	"github.com/gin-gonic/gin"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestThemeHTML(t *testing.T) {
+	ThemePath = "../../theme"
+	defer func() {
+		ThemePath = ""
+	}()
+
+	resp := &Resp{Flag: true, Data: ContentsX{Id: 1, Title: "Hi", DescribeHtml: "<p>body</p>"}}
+	for accept, html := range map[string]bool{
+		"text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8": true,
+		"application/json, text/plain, */*":                               false,
+		"*/*":                                                             false,
+	} {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest("GET", "/content?id=1", nil)
+		c.Request.Header.Set("Accept", accept)
+		if ThemeHTML(c, "content.html", nil, resp) != html {
+			t.Fatalf("accept %s want html %v", accept, html)
+		}
+
+		if html && (w.Code != 200 || !strings.Contains(w.Body.String(), "<p>body</p>")) {
+			t.Fatalf("html wrong: %d %s", w.Code, w.Body.String())
+		}
+	}
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest("GET", "/content?id=2", nil)
+	c.Request.Header.Set("Accept", "text/html")
+	if !ThemeHTML(c, "content.html", nil, &Resp{Error: Error(ContentNotFound, "")}) || w.Code != 404 {
+		t.Fatalf("not found should be 404: %d", w.Code)
+	}
+}
@@ -11,7 +11,7 @@ var LoopChan = make(chan CountType, 1000)
 type CountType struct {
 	UserId int64
 	NodeId int64
-	T      int // 1,2,3
+	T      int // 1,2,3,4
 }
 
 func SendToLoop(userId, nodeId int64, t int) {
@@ -34,6 +34,12 @@ func LoopCount() {
 					if err != nil {
 						flog.Log.Errorf("Ticker Count all content err: %s", err.Error())
 					}
+
+					// content status change will change the num of tag too
+					err = model.CountTagContent(v.UserId)
+					if err != nil {
+						flog.Log.Errorf("Ticker Count tag content err: %s", err.Error())
+					}
 				}
 			} else if v.T == 2 {
 				if v.UserId != 0 && v.NodeId != 0 {
@@ -49,6 +55,13 @@ func LoopCount() {
 						flog.Log.Errorf("Ticker Count all content cool err: %s", err.Error())
 					}
 				}
+			} else if v.T == 4 {
+				if v.UserId != 0 {
+					err := model.CountTagContent(v.UserId)
+					if err != nil {
+						flog.Log.Errorf("Ticker Count tag content err: %s", err.Error())
+					}
+				}
 			}
 
 		case <-time.After(5 * time.Second):
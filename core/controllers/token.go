@@ -0,0 +1,300 @@
+package controllers
+
+import (
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator"
+	"github.com/hunterhug/fafacms/core/flog"
+	"github.com/hunterhug/fafacms/core/model"
+	"github.com/hunterhug/fafacms/core/util"
+	"math"
+	"strings"
+	"time"
+)
+
+// personal access token start with it, so we know it is not a session token
+const AccessTokenPrefix = "fft_"
+
+var (
+	// those scopes can be grant to personal access token, write scope include read
+	AccessTokenScopes = []string{
+		"user:read", "user:write",
+		"file:read", "file:write", "file:upload",
+		"node:read", "node:write",
+		"content:read", "content:write",
+		"comment:read", "comment:write",
+		"relation:read", "relation:write",
+		"message:read", "message:write",
+		"admin",
+	}
+
+	// the scope of every v1 url, empty scope mean access token can not use
+	UrlScope map[string]string
+)
+
+// Check the scope of url can be access by token scopes
+func AccessTokenScopeAllow(scopes []string, need string) bool {
+	if need == "" {
+		return false
+	}
+
+	for _, s := range scopes {
+		if s == need {
+			return true
+		}
+
+		// write include read
+		if strings.HasSuffix(need, ":read") && s == strings.TrimSuffix(need, ":read")+":write" {
+			return true
+		}
+	}
+
+	return false
+}
+
+// get user info by personal access token
+func GetUserByAccessToken(c *gin.Context, token string) (*model.User, error) {
+	t := new(model.AccessToken)
+	t.TokenHash, _ = util.Sha256([]byte(token))
+	exist, err := t.GetByHash()
+	if err != nil {
+		return nil, err
+	}
+
+	if !exist || !t.IsValid() {
+		return nil, errors.New("access token invalid")
+	}
+
+	user := new(model.User)
+	user.Id = t.UserId
+	exist, err = user.GetRaw()
+	if err != nil {
+		return nil, err
+	}
+
+	if !exist {
+		return nil, errors.New("user not exist in db")
+	}
+
+	user.Password = ""
+	user.ActivateCode = ""
+	user.ActivateCodeExpired = 0
+	user.ResetCode = ""
+	user.ResetCodeExpired = 0
+
+	err = t.UpdateLastUsed(c.ClientIP())
+	if err != nil {
+		flog.Log.Errorf("access token update last used err:%s", err.Error())
+	}
+
+	c.Set("accessTokenScopes", t.ScopeList())
+	return user, nil
+}
+
+type CreateAccessTokenRequest struct {
+	Name       string   `json:"name" validate:"required,lt=100"`
+	Scopes     []string `json:"scopes" validate:"required"`
+	ExpireDays int64    `json:"expire_days" validate:"gte=0"` // 0 never expire
+}
+
+type CreateAccessTokenResponse struct {
+	Token       string            `json:"token"` // only show once
+	AccessToken model.AccessToken `json:"access_token"`
+}
+
+func CreateAccessToken(c *gin.Context) {
+	resp := new(Resp)
+	req := new(CreateAccessTokenRequest)
+	defer func() {
+		JSONL(c, 200, req, resp)
+	}()
+
+	if errResp := ParseJSON(c, req); errResp != nil {
+		resp.Error = errResp
+		return
+	}
+
+	var validate = validator.New()
+	err := validate.Struct(req)
+	if err != nil {
+		flog.Log.Errorf("CreateAccessToken err: %s", err.Error())
+		resp.Error = Error(ParasError, err.Error())
+		return
+	}
+
+	uu, err := GetUserSession(c)
+	if err != nil {
+		flog.Log.Errorf("CreateAccessToken err: %s", err.Error())
+		resp.Error = Error(GetUserSessionError, err.Error())
+		return
+	}
+
+	scopes := make([]string, 0, len(req.Scopes))
+	for _, s := range req.Scopes {
+		if !util.InArray(AccessTokenScopes, s) {
+			flog.Log.Errorf("CreateAccessToken err: scope %s not found", s)
+			resp.Error = Error(ParasError, "scope "+s+" not found")
+			return
+		}
+
+		if !util.InArray(scopes, s) {
+			scopes = append(scopes, s)
+		}
+	}
+
+	token := AccessTokenPrefix + util.GetGUID() + util.GetGUID()
+
+	t := new(model.AccessToken)
+	t.UserId = uu.Id
+	t.Name = req.Name
+	t.Scopes = strings.Join(scopes, ",")
+	t.TokenPrefix = token[0:12]
+	t.TokenHash, _ = util.Sha256([]byte(token))
+	if req.ExpireDays > 0 {
+		t.ExpireTime = time.Now().Unix() + req.ExpireDays*24*3600
+	}
+
+	err = t.Insert()
+	if err != nil {
+		flog.Log.Errorf("CreateAccessToken err:%s", err.Error())
+		resp.Error = Error(DBError, err.Error())
+		return
+	}
+
+	// token will not be log
+	c.Set("skipLog", true)
+	resp.Data = CreateAccessTokenResponse{Token: token, AccessToken: *t}
+	resp.Flag = true
+}
+
+type ListAccessTokenRequest struct {
+	Status int      `json:"status" validate:"oneof=-1 0 1"`
+	Sort   []string `json:"sort"`
+	PageHelp
+}
+
+type ListAccessTokenResponse struct {
+	AccessTokens []model.AccessToken `json:"access_tokens"`
+	PageHelp
+}
+
+func ListAccessToken(c *gin.Context) {
+	resp := new(Resp)
+
+	respResult := new(ListAccessTokenResponse)
+	req := new(ListAccessTokenRequest)
+	defer func() {
+		JSONL(c, 200, req, resp)
+	}()
+
+	if errResp := ParseJSON(c, req); errResp != nil {
+		resp.Error = errResp
+		return
+	}
+
+	var validate = validator.New()
+	err := validate.Struct(req)
+	if err != nil {
+		flog.Log.Errorf("ListAccessToken err: %s", err.Error())
+		resp.Error = Error(ParasError, err.Error())
+		return
+	}
+
+	uu, err := GetUserSession(c)
+	if err != nil {
+		flog.Log.Errorf("ListAccessToken err: %s", err.Error())
+		resp.Error = Error(GetUserSessionError, err.Error())
+		return
+	}
+
+	session := model.FaFaRdb.Client.NewSession()
+	defer session.Close()
+
+	session.Table(new(model.AccessToken)).Where("user_id=?", uu.Id)
+
+	if req.Status != -1 {
+		session.And("status=?", req.Status)
+	}
+
+	countSession := session.Clone()
+	defer countSession.Close()
+	total, err := countSession.Count()
+	if err != nil {
+		flog.Log.Errorf("ListAccessToken err:%s", err.Error())
+		resp.Error = Error(DBError, err.Error())
+		return
+	}
+
+	ts := make([]model.AccessToken, 0)
+	p := &req.PageHelp
+	if total == 0 {
+		if p.Limit == 0 {
+			p.Limit = 20
+		}
+	} else {
+		p.build(session, req.Sort, model.AccessTokenSortName)
+		err = session.Find(&ts)
+		if err != nil {
+			flog.Log.Errorf("ListAccessToken err:%s", err.Error())
+			resp.Error = Error(DBError, err.Error())
+			return
+		}
+	}
+
+	respResult.AccessTokens = ts
+	p.Pages = int(math.Ceil(float64(total) / float64(p.Limit)))
+	p.Total = int(total)
+	respResult.PageHelp = *p
+	resp.Data = respResult
+	resp.Flag = true
+}
+
+type RevokeAccessTokenRequest struct {
+	Id int64 `json:"id" validate:"required"`
+}
+
+func RevokeAccessToken(c *gin.Context) {
+	resp := new(Resp)
+	req := new(RevokeAccessTokenRequest)
+	defer func() {
+		JSONL(c, 200, req, resp)
+	}()
+
+	if errResp := ParseJSON(c, req); errResp != nil {
+		resp.Error = errResp
+		return
+	}
+
+	var validate = validator.New()
+	err := validate.Struct(req)
+	if err != nil {
+		flog.Log.Errorf("RevokeAccessToken err: %s", err.Error())
+		resp.Error = Error(ParasError, err.Error())
+		return
+	}
+
+	uu, err := GetUserSession(c)
+	if err != nil {
+		flog.Log.Errorf("RevokeAccessToken err: %s", err.Error())
+		resp.Error = Error(GetUserSessionError, err.Error())
+		return
+	}
+
+	t := new(model.AccessToken)
+	t.Id = req.Id
+	t.UserId = uu.Id
+	num, err := t.Revoke()
+	if err != nil {
+		flog.Log.Errorf("RevokeAccessToken err:%s", err.Error())
+		resp.Error = Error(DBError, err.Error())
+		return
+	}
+
+	if num == 0 {
+		flog.Log.Errorf("RevokeAccessToken err:%s", "access token not found")
+		resp.Error = Error(AccessTokenNotFound, "")
+		return
+	}
+
+	resp.Flag = true
+}
@@ -0,0 +1,23 @@
+package controllers
+
+import "testing"
+
+func TestAccessTokenScopeAllow(t *testing.T) {
+	scopes := []string{"content:write", "file:upload"}
+
+	if !AccessTokenScopeAllow(scopes, "content:write") {
+		t.Fatal("content:write should allow")
+	}
+
+	if !AccessTokenScopeAllow(scopes, "content:read") {
+		t.Fatal("write should include read")
+	}
+
+	if AccessTokenScopeAllow(scopes, "file:read") {
+		t.Fatal("file:upload should not include file:read")
+	}
+
+	if AccessTokenScopeAllow(scopes, "") {
+		t.Fatal("empty scope url can not use token")
+	}
+}
@@ -0,0 +1,512 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator"
+	"github.com/hunterhug/fafacms/core/flog"
+	"github.com/hunterhug/fafacms/core/model"
+	"github.com/hunterhug/fafacms/core/session"
+	"github.com/hunterhug/fafacms/core/util"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// totp issuer show in authenticator app
+	TwoFactorIssuer = "FaFaCMS"
+
+	// challenge valid in 5 min, wrong 5 times will destroy
+	TwoFactorChallengeExpireTime int64 = 300
+	TwoFactorChallengeMaxFail          = 5
+
+	// wrong so many times of one user in lock time, whatever the challenge, will lock the two factor of user
+	TwoFactorUserMaxFail        = 10
+	TwoFactorUserLockTime int64 = 3600
+
+	// recovery code num gen when confirm
+	TwoFactorRecoveryNum = 10
+)
+
+type twoFactorChallenge struct {
+	UserId int64 `json:"user_id"`
+}
+
+func twoFactorChallengeKey(challenge string) string {
+	return "ff_2fa_" + challenge
+}
+
+func twoFactorChallengeFailKey(challenge string) string {
+	return "ff_2fa_fail_" + challenge
+}
+
+func twoFactorUserFailKey(userId int64) string {
+	return fmt.Sprintf("ff_2fa_user_fail_%d", userId)
+}
+
+func twoFactorUserLockKey(userId int64) string {
+	return fmt.Sprintf("ff_2fa_user_lock_%d", userId)
+}
+
+// Password right but two factor open, keep a challenge in session redis
+func SetTwoFactorChallenge(userId int64) (string, error) {
+	challenge := util.GetGUID()
+	raw, _ := json.Marshal(twoFactorChallenge{UserId: userId})
+	err := session.FafaKV.Set(twoFactorChallengeKey(challenge), raw, TwoFactorChallengeExpireTime)
+	if err != nil {
+		return "", err
+	}
+	return challenge, nil
+}
+
+func getTwoFactorChallenge(challenge string) (*twoFactorChallenge, error) {
+	raw, exist, err := session.FafaKV.Get(twoFactorChallengeKey(challenge))
+	if err != nil {
+		return nil, err
+	}
+
+	if !exist {
+		return nil, nil
+	}
+
+	ch := new(twoFactorChallenge)
+	err = json.Unmarshal(raw, ch)
+	if err != nil {
+		return nil, err
+	}
+	return ch, nil
+}
+
+// Remain lock seconds of the user two factor, 0 is not lock
+func twoFactorLocked(userId int64) (remain int64) {
+	raw, exist, err := session.FafaKV.Get(twoFactorUserLockKey(userId))
+	if err != nil {
+		flog.Log.Errorf("two factor lock get err:%s", err.Error())
+		return
+	}
+
+	if !exist {
+		return
+	}
+
+	until, _ := strconv.ParseInt(string(raw), 10, 64)
+	if until-time.Now().Unix() > 0 {
+		remain = until - time.Now().Unix()
+	}
+	return
+}
+
+// Record a wrong code of user, count by user so login again for a new challenge not help,
+// return remain lock seconds if reach the max fail
+func twoFactorFail(userId int64) (remain int64) {
+	n, err := session.FafaKV.Incr(twoFactorUserFailKey(userId), TwoFactorUserLockTime)
+	if err != nil {
+		flog.Log.Errorf("two factor fail incr err:%s", err.Error())
+		return
+	}
+
+	if n < TwoFactorUserMaxFail {
+		return
+	}
+
+	until := time.Now().Unix() + TwoFactorUserLockTime
+	err = session.FafaKV.Set(twoFactorUserLockKey(userId), []byte(strconv.FormatInt(until, 10)), TwoFactorUserLockTime)
+	if err != nil {
+		flog.Log.Errorf("two factor lock set err:%s", err.Error())
+	}
+
+	session.FafaKV.Delete(twoFactorUserFailKey(userId))
+	return TwoFactorUserLockTime
+}
+
+// One totp code can only be accepted once, or it can be replay in its valid time
+func useTwoFactorCode(u *model.User, code string) (bool, error) {
+	counter, ok := util.TOTPCounter(u.TwoFactorSecret, code, time.Now())
+	if !ok {
+		return false, nil
+	}
+
+	n, err := session.FafaKV.Incr(fmt.Sprintf("ff_2fa_used_%d_%d", u.Id, counter), 3*util.TOTPPeriod)
+	if err != nil {
+		return false, err
+	}
+	return n == 1, nil
+}
+
+// Check totp code or recovery code
+func checkTwoFactorCode(u *model.User, code string, recoveryCode string) (bool, error) {
+	if code != "" {
+		return useTwoFactorCode(u, code)
+	}
+
+	if recoveryCode != "" {
+		return u.UseTwoFactorRecovery(recoveryCode)
+	}
+
+	return false, errors.New("code empty")
+}
+
+type LoginTwoFactorRequest struct {
+	Challenge    string `json:"challenge" validate:"required"`
+	Code         string `json:"code"`          // totp code
+	RecoveryCode string `json:"recovery_code"` // use when lost the authenticator
+}
+
+// Second step of login when two factor open
+func LoginTwoFactor(c *gin.Context) {
+	resp := new(Resp)
+	req := new(LoginTwoFactorRequest)
+	defer func() {
+		JSONL(c, 200, req, resp)
+	}()
+
+	if errResp := ParseJSON(c, req); errResp != nil {
+		resp.Error = errResp
+		return
+	}
+
+	var validate = validator.New()
+	err := validate.Struct(req)
+	if err != nil {
+		flog.Log.Errorf("LoginTwoFactor err: %s", err.Error())
+		resp.Error = Error(ParasError, err.Error())
+		return
+	}
+
+	if req.Code == "" && req.RecoveryCode == "" {
+		flog.Log.Errorf("LoginTwoFactor err: %s", "code empty")
+		resp.Error = Error(ParasError, "code or recovery_code")
+		return
+	}
+
+	ch, err := getTwoFactorChallenge(req.Challenge)
+	if err != nil {
+		flog.Log.Errorf("LoginTwoFactor err: %s", err.Error())
+		resp.Error = Error(GetUserSessionError, err.Error())
+		return
+	}
+
+	if ch == nil {
+		flog.Log.Errorf("LoginTwoFactor err: %s", "challenge not found")
+		resp.Error = Error(TwoFactorChallengeNotFound, "")
+		return
+	}
+
+	c.Set("uid", ch.UserId)
+
+	if remain := twoFactorLocked(ch.UserId); remain > 0 {
+		flog.Log.Errorf("LoginTwoFactor err: %s", "too many attempts")
+		attemptLockError(resp, remain)
+		return
+	}
+
+	uu := new(model.User)
+	uu.Id = ch.UserId
+	ok, err := uu.GetRaw()
+	if err != nil {
+		flog.Log.Errorf("LoginTwoFactor err: %s", err.Error())
+		resp.Error = Error(DBError, err.Error())
+		return
+	}
+
+	if !ok || uu.TwoFactorEnable != 1 {
+		flog.Log.Errorf("LoginTwoFactor err: %s", "user not found or two factor close")
+		session.FafaKV.Delete(twoFactorChallengeKey(req.Challenge))
+		resp.Error = Error(TwoFactorChallengeNotFound, "")
+		return
+	}
+
+	right, err := checkTwoFactorCode(uu, req.Code, req.RecoveryCode)
+	if err != nil {
+		flog.Log.Errorf("LoginTwoFactor err: %s", err.Error())
+		resp.Error = Error(DBError, err.Error())
+		return
+	}
+
+	if !right {
+		// too many wrong will destroy the challenge, must login again
+		n, err := session.FafaKV.Incr(twoFactorChallengeFailKey(req.Challenge), TwoFactorChallengeExpireTime)
+		if err != nil || n >= TwoFactorChallengeMaxFail {
+			session.FafaKV.Delete(twoFactorChallengeKey(req.Challenge))
+		}
+
+		flog.Log.Errorf("LoginTwoFactor err: %s", "code wrong")
+		resp.Error = Error(TwoFactorCodeWrong, "")
+		if remain := twoFactorFail(uu.Id); remain > 0 {
+			attemptLockError(resp, remain)
+		}
+		return
+	}
+
+	// challenge can only use once
+	err = session.FafaKV.Delete(twoFactorChallengeKey(req.Challenge))
+	if err != nil {
+		flog.Log.Errorf("LoginTwoFactor err: %s", err.Error())
+		resp.Error = Error(SetUserSessionError, err.Error())
+		return
+	}
+
+	// all factor right, now clear the fail record
+	session.FafaKV.Delete(twoFactorUserFailKey(uu.Id))
+	(&Attempt{Scene: AttemptSceneLogin, UserId: uu.Id}).Success()
+
+	token, errResp := loginUser(c, uu)
+	if errResp != nil {
+		resp.Error = errResp
+		return
+	}
+
+	resp.Data = token
+	resp.Flag = true
+}
+
+type EnrollTwoFactorResponse struct {
+	Secret string `json:"secret"`
+	Uri    string `json:"uri"` // otpauth uri, front end can turn it to QR code
+}
+
+// Gen a new totp secret, will not enable until confirm
+func EnrollTwoFactor(c *gin.Context) {
+	resp := new(Resp)
+	defer func() {
+		JSON(c, 200, resp)
+	}()
+
+	uu, err := GetUserSession(c)
+	if err != nil {
+		flog.Log.Errorf("EnrollTwoFactor err: %s", err.Error())
+		resp.Error = Error(GetUserSessionError, err.Error())
+		return
+	}
+
+	u := new(model.User)
+	u.Id = uu.Id
+	ok, err := u.GetRaw()
+	if err != nil {
+		flog.Log.Errorf("EnrollTwoFactor err: %s", err.Error())
+		resp.Error = Error(DBError, err.Error())
+		return
+	}
+
+	if !ok {
+		flog.Log.Errorf("EnrollTwoFactor err: %s", "user not found")
+		resp.Error = Error(UserNotFound, "")
+		return
+	}
+
+	if u.TwoFactorEnable == 1 {
+		flog.Log.Errorf("EnrollTwoFactor err: %s", "two factor already enable")
+		resp.Error = Error(TwoFactorAlreadyEnable, "")
+		return
+	}
+
+	secret, err := util.GenTOTPSecret()
+	if err != nil {
+		flog.Log.Errorf("EnrollTwoFactor err: %s", err.Error())
+		resp.Error = Error(SystemProblem, err.Error())
+		return
+	}
+
+	u.TwoFactorEnable = 0
+	u.TwoFactorSecret = secret
+	u.TwoFactorRecovery = ""
+	err = u.UpdateTwoFactor()
+	if err != nil {
+		flog.Log.Errorf("EnrollTwoFactor err: %s", err.Error())
+		resp.Error = Error(DBError, err.Error())
+		return
+	}
+
+	resp.Data = EnrollTwoFactorResponse{Secret: secret, Uri: util.TOTPUri(TwoFactorIssuer, u.Name, secret)}
+	resp.Flag = true
+}
+
+type ConfirmTwoFactorRequest struct {
+	Code string `json:"code" validate:"required"`
+}
+
+type ConfirmTwoFactorResponse struct {
+	RecoveryCodes []string `json:"recovery_codes"` // only show once
+}
+
+// Confirm the totp code and enable two factor, recovery code gen at the same time
+func ConfirmTwoFactor(c *gin.Context) {
+	resp := new(Resp)
+	req := new(ConfirmTwoFactorRequest)
+	defer func() {
+		JSON(c, 200, resp)
+	}()
+
+	if errResp := ParseJSON(c, req); errResp != nil {
+		resp.Error = errResp
+		return
+	}
+
+	var validate = validator.New()
+	err := validate.Struct(req)
+	if err != nil {
+		flog.Log.Errorf("ConfirmTwoFactor err: %s", err.Error())
+		resp.Error = Error(ParasError, err.Error())
+		return
+	}
+
+	uu, err := GetUserSession(c)
+	if err != nil {
+		flog.Log.Errorf("ConfirmTwoFactor err: %s", err.Error())
+		resp.Error = Error(GetUserSessionError, err.Error())
+		return
+	}
+
+	u := new(model.User)
+	u.Id = uu.Id
+	ok, err := u.GetRaw()
+	if err != nil {
+		flog.Log.Errorf("ConfirmTwoFactor err: %s", err.Error())
+		resp.Error = Error(DBError, err.Error())
+		return
+	}
+
+	if !ok {
+		flog.Log.Errorf("ConfirmTwoFactor err: %s", "user not found")
+		resp.Error = Error(UserNotFound, "")
+		return
+	}
+
+	if u.TwoFactorEnable == 1 {
+		flog.Log.Errorf("ConfirmTwoFactor err: %s", "two factor already enable")
+		resp.Error = Error(TwoFactorAlreadyEnable, "")
+		return
+	}
+
+	if u.TwoFactorSecret == "" {
+		flog.Log.Errorf("ConfirmTwoFactor err: %s", "two factor not enroll")
+		resp.Error = Error(TwoFactorNotEnable, "enroll first")
+		return
+	}
+
+	right, err := useTwoFactorCode(u, req.Code)
+	if err != nil {
+		flog.Log.Errorf("ConfirmTwoFactor err: %s", err.Error())
+		resp.Error = Error(SetUserSessionError, err.Error())
+		return
+	}
+
+	if !right {
+		flog.Log.Errorf("ConfirmTwoFactor err: %s", "code wrong")
+		resp.Error = Error(TwoFactorCodeWrong, "")
+		return
+	}
+
+	codes := make([]string, 0, TwoFactorRecoveryNum)
+	hashes := make([]string, 0, TwoFactorRecoveryNum)
+	for i := 0; i < TwoFactorRecoveryNum; i++ {
+		code := util.GetGUID()[0:10]
+		h, _ := util.Sha256([]byte(code))
+		codes = append(codes, code)
+		hashes = append(hashes, h)
+	}
+
+	u.TwoFactorEnable = 1
+	u.TwoFactorRecovery = strings.Join(hashes, ",")
+	err = u.UpdateTwoFactor()
+	if err != nil {
+		flog.Log.Errorf("ConfirmTwoFactor err: %s", err.Error())
+		resp.Error = Error(DBError, err.Error())
+		return
+	}
+
+	session.FafaSessionMgr.RefreshUser([]int64{u.Id}, SessionExpireTime)
+	resp.Data = ConfirmTwoFactorResponse{RecoveryCodes: codes}
+	resp.Flag = true
+}
+
+type DisableTwoFactorRequest struct {
+	Code         string `json:"code"`
+	RecoveryCode string `json:"recovery_code"`
+}
+
+// Close two factor, need a code to make sure it is yourself
+func DisableTwoFactor(c *gin.Context) {
+	resp := new(Resp)
+	req := new(DisableTwoFactorRequest)
+	defer func() {
+		JSON(c, 200, resp)
+	}()
+
+	if errResp := ParseJSON(c, req); errResp != nil {
+		resp.Error = errResp
+		return
+	}
+
+	if req.Code == "" && req.RecoveryCode == "" {
+		flog.Log.Errorf("DisableTwoFactor err: %s", "code empty")
+		resp.Error = Error(ParasError, "code or recovery_code")
+		return
+	}
+
+	uu, err := GetUserSession(c)
+	if err != nil {
+		flog.Log.Errorf("DisableTwoFactor err: %s", err.Error())
+		resp.Error = Error(GetUserSessionError, err.Error())
+		return
+	}
+
+	u := new(model.User)
+	u.Id = uu.Id
+	ok, err := u.GetRaw()
+	if err != nil {
+		flog.Log.Errorf("DisableTwoFactor err: %s", err.Error())
+		resp.Error = Error(DBError, err.Error())
+		return
+	}
+
+	if !ok {
+		flog.Log.Errorf("DisableTwoFactor err: %s", "user not found")
+		resp.Error = Error(UserNotFound, "")
+		return
+	}
+
+	if u.TwoFactorEnable != 1 {
+		flog.Log.Errorf("DisableTwoFactor err: %s", "two factor not enable")
+		resp.Error = Error(TwoFactorNotEnable, "")
+		return
+	}
+
+	if remain := twoFactorLocked(u.Id); remain > 0 {
+		flog.Log.Errorf("DisableTwoFactor err: %s", "too many attempts")
+		attemptLockError(resp, remain)
+		return
+	}
+
+	right, err := checkTwoFactorCode(u, req.Code, req.RecoveryCode)
+	if err != nil {
+		flog.Log.Errorf("DisableTwoFactor err: %s", err.Error())
+		resp.Error = Error(DBError, err.Error())
+		return
+	}
+
+	if !right {
+		flog.Log.Errorf("DisableTwoFactor err: %s", "code wrong")
+		resp.Error = Error(TwoFactorCodeWrong, "")
+		if remain := twoFactorFail(u.Id); remain > 0 {
+			attemptLockError(resp, remain)
+		}
+		return
+	}
+
+	u.TwoFactorEnable = 0
+	u.TwoFactorSecret = ""
+	u.TwoFactorRecovery = ""
+	err = u.UpdateTwoFactor()
+	if err != nil {
+		flog.Log.Errorf("DisableTwoFactor err: %s", err.Error())
+		resp.Error = Error(DBError, err.Error())
+		return
+	}
+
+	session.FafaSessionMgr.RefreshUser([]int64{u.Id}, SessionExpireTime)
+	resp.Flag = true
+}
@@ -0,0 +1,48 @@
+package controllers
+
+import (
+	"github.com/hunterhug/fafacms/core/model"
+	"github.com/hunterhug/fafacms/core/session"
+	"github.com/hunterhug/fafacms/core/util"
+	"testing"
+	"time"
+)
+
+func TestTwoFactorFail(t *testing.T) {
+	store, _ := session.NewMemoryStore("")
+	session.FafaKV = store
+
+	for i := 1; i < TwoFactorUserMaxFail; i++ {
+		if remain := twoFactorFail(1); remain != 0 {
+			t.Fatalf("fail %d should not lock", i)
+		}
+	}
+
+	if remain := twoFactorFail(1); remain != TwoFactorUserLockTime {
+		t.Fatalf("should lock %d, but %d", TwoFactorUserLockTime, remain)
+	}
+
+	if twoFactorLocked(1) == 0 {
+		t.Fatal("user should be lock")
+	}
+
+	if twoFactorLocked(2) != 0 {
+		t.Fatal("other user should not be lock")
+	}
+}
+
+func TestUseTwoFactorCode(t *testing.T) {
+	store, _ := session.NewMemoryStore("")
+	session.FafaKV = store
+
+	secret, _ := util.GenTOTPSecret()
+	code, _ := util.TOTPCode(secret, time.Now())
+	u := &model.User{Id: 1, TwoFactorSecret: secret}
+	if ok, _ := useTwoFactorCode(u, code); !ok {
+		t.Fatal("code should pass")
+	}
+
+	if ok, _ := useTwoFactorCode(u, code); ok {
+		t.Fatal("code use again should not pass")
+	}
+}
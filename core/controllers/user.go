@@ -118,13 +118,20 @@ func RegisterUser(c *gin.Context) {
 	u.ActivateCodeExpired = time.Now().Add(5 * time.Minute).Unix()
 	u.ShortDescribe = req.ShortDescribe
 	u.Describe = req.Describe
-	u.Password = req.Password
 	u.Gender = req.Gender
 	u.WeChat = req.WeChat
 	u.QQ = req.QQ
 	u.Github = req.Github
 	u.WeiBo = req.WeiBo
 
+	// password save as hash
+	err = u.SetPassword(req.Password)
+	if err != nil {
+		flog.Log.Errorf("RegisterUser err: %s", err.Error())
+		resp.Error = Error(ParasError, err.Error())
+		return
+	}
+
 	// send email
 	mm := new(mail.Message)
 	mm.Sender = config.FaFaConfig.MailConfig
@@ -249,13 +256,20 @@ func CreateUser(c *gin.Context) {
 	u.Describe = req.Describe
 	u.ShortDescribe = req.ShortDescribe
 	u.NickName = req.NickName
-	u.Password = req.Password
 	u.Gender = req.Gender
 	u.WeChat = req.WeChat
 	u.QQ = req.QQ
 	u.Github = req.Github
 	u.WeiBo = req.WeiBo
 
+	// password save as hash
+	err = u.SetPassword(req.Password)
+	if err != nil {
+		flog.Log.Errorf("CreateUser err: %s", err.Error())
+		resp.Error = Error(ParasError, err.Error())
+		return
+	}
+
 	// default is activate
 	u.Status = 1
 	u.ActivateTime = time.Now().Unix()
@@ -265,6 +279,12 @@ func CreateUser(c *gin.Context) {
 		resp.Error = Error(DBError, err.Error())
 		return
 	}
+
+	SendToSearch(model.SearchUser, u.Id)
+	RefreshSitemap()
+
+	// hash not return
+	u.Password = ""
 	resp.Flag = true
 	resp.Data = u
 }
@@ -295,6 +315,14 @@ func ActivateUser(c *gin.Context) {
 		return
 	}
 
+	// code can not be guess
+	attempt := NewAttempt(c, AttemptSceneActivate, req.Email)
+	if remain := attempt.Locked(); remain > 0 {
+		flog.Log.Errorf("ActivateUser err:%s", "too many attempts")
+		attemptLockError(resp, remain)
+		return
+	}
+
 	// email and activate code must together
 	u := new(model.User)
 	u.ActivateCode = req.Code
@@ -311,9 +339,14 @@ func ActivateUser(c *gin.Context) {
 	if !exist {
 		flog.Log.Errorf("ActivateUser err:%s", "not exist code")
 		resp.Error = Error(ActivateCodeWrong, "not exist code")
+		if remain := attempt.Fail(); remain > 0 {
+			attemptLockError(resp, remain)
+		}
 		return
 	}
 
+	attempt.Success()
+
 	// has been activate direct return
 	if u.Status != 0 {
 		resp.Flag = true
@@ -334,8 +367,11 @@ func ActivateUser(c *gin.Context) {
 			return
 		}
 
+		SendToSearch(model.SearchUser, u.Id)
+		RefreshSitemap()
+
 		// activate success will soon set session
-		token, err := SetUserSession(u)
+		token, err := SetUserSession(c, u)
 		if err != nil {
 			flog.Log.Errorf("ActivateUser err:%s", err.Error())
 			resp.Error = Error(SetUserSessionError, err.Error())
@@ -447,6 +483,14 @@ func ForgetPasswordOfUser(c *gin.Context) {
 		return
 	}
 
+	// email can not be guess
+	attempt := NewAttempt(c, AttemptSceneReset, req.Email)
+	if remain := attempt.Locked(); remain > 0 {
+		flog.Log.Errorf("ForgetPassword err:%s", "too many attempts")
+		attemptLockError(resp, remain)
+		return
+	}
+
 	u := new(model.User)
 	u.Email = req.Email
 	ok, err := u.GetUserByEmail()
@@ -458,6 +502,9 @@ func ForgetPasswordOfUser(c *gin.Context) {
 	if !ok {
 		flog.Log.Errorf("ForgetPassword err:%s", "email not found")
 		resp.Error = Error(EmailNotFound, "")
+		if remain := attempt.Fail(); remain > 0 {
+			attemptLockError(resp, remain)
+		}
 		return
 	}
 
@@ -521,6 +568,14 @@ func ChangePasswordOfUser(c *gin.Context) {
 		return
 	}
 
+	// reset code can not be guess
+	attempt := NewAttempt(c, AttemptSceneReset, req.Email)
+	if remain := attempt.Locked(); remain > 0 {
+		flog.Log.Errorf("ChangePassword err:%s", "too many attempts")
+		attemptLockError(resp, remain)
+		return
+	}
+
 	u := new(model.User)
 	u.Email = req.Email
 	ok, err := u.GetUserByEmail()
@@ -532,12 +587,20 @@ func ChangePasswordOfUser(c *gin.Context) {
 	if !ok {
 		flog.Log.Errorf("ChangePassword err:%s", "email not found")
 		resp.Error = Error(EmailNotFound, "")
+		if remain := attempt.Fail(); remain > 0 {
+			attemptLockError(resp, remain)
+		}
 		return
 	}
 
 	// rest code is the same can change
-	if u.ResetCode == req.Code {
-		u.Password = req.Password
+	if u.ResetCode != "" && u.ResetCode == req.Code {
+		err = u.SetPassword(req.Password)
+		if err != nil {
+			flog.Log.Errorf("ChangePassword err:%s", err.Error())
+			resp.Error = Error(ParasError, err.Error())
+			return
+		}
 		err = u.UpdatePassword()
 		if err != nil {
 			flog.Log.Errorf("ChangePassword err:%s", err.Error())
@@ -547,9 +610,15 @@ func ChangePasswordOfUser(c *gin.Context) {
 	} else {
 		flog.Log.Errorf("ChangePassword err:%s", "reset code wrong")
 		resp.Error = Error(RestCodeWrong, "")
+		u.RecordFailAttempt(c.ClientIP())
+		if remain := attempt.Fail(); remain > 0 {
+			attemptLockError(resp, remain)
+		}
 		return
 	}
 
+	attempt.Success()
+
 	// after change password, session will delete all
 	DeleteUserAllSession(u.Id)
 	resp.Flag = true
@@ -565,6 +634,8 @@ type UpdateUserRequest struct {
 	Describe      string `json:"describe"`
 	ShortDescribe string `json:"short_describe"`
 	ImagePath     string `json:"image_path"`
+	FeedFullText  int    `json:"feed_full_text" validate:"oneof=0 1"` // 0 feed only has excerpt, 1 full text
+	Theme         string `json:"theme"`                               // theme of the html page, empty use the site theme
 }
 
 func UpdateUser(c *gin.Context) {
@@ -610,6 +681,12 @@ func UpdateUser(c *gin.Context) {
 		return
 	}
 
+	if req.Theme != "" && req.Theme != uuu.Theme && !util.ThemeExist(ThemePath, req.Theme) {
+		flog.Log.Errorf("UpdateUser err: %s", "theme not found")
+		resp.Error = Error(ThemeNotFound, "")
+		return
+	}
+
 	u := new(model.User)
 	u.Id = uu.Id
 	if req.ImagePath != "" && req.ImagePath != uuu.HeadPhoto {
@@ -675,6 +752,27 @@ func UpdateUser(c *gin.Context) {
 		return
 	}
 
+	// zero value not update by above, so update alone
+	if req.FeedFullText != uuu.FeedFullText {
+		u.FeedFullText = req.FeedFullText
+		err = u.UpdateFeedFullText()
+		if err != nil {
+			flog.Log.Errorf("UpdateUser err:%s", err.Error())
+			resp.Error = Error(DBError, err.Error())
+			return
+		}
+	}
+
+	if req.Theme != uuu.Theme {
+		u.Theme = req.Theme
+		err = u.UpdateTheme()
+		if err != nil {
+			flog.Log.Errorf("UpdateUser err:%s", err.Error())
+			resp.Error = Error(DBError, err.Error())
+			return
+		}
+	}
+
 	err = session.FafaSessionMgr.RefreshUser([]int64{u.Id}, SessionExpireTime)
 	if err != nil {
 		flog.Log.Errorf("UpdateUser err:%s", err.Error())
@@ -682,6 +780,7 @@ func UpdateUser(c *gin.Context) {
 		return
 	}
 
+	SendToSearch(model.SearchUser, u.Id)
 	resp.Flag = true
 	resp.Data = u
 }
@@ -763,6 +862,9 @@ func TakeUser(c *gin.Context) {
 	p.ContentNum = v.ContentNum
 	p.ContentCoolNum = v.ContentCoolNum
 	p.IsVip = v.Vip == 1
+	p.TwoFactorEnable = v.TwoFactorEnable == 1
+	p.FeedFullText = v.FeedFullText == 1
+	p.Theme = v.Theme
 	resp.Flag = true
 	resp.Data = p
 }
@@ -951,8 +1053,9 @@ func ListGroupUser(c *gin.Context) {
 
 	users := make([]model.User, 0)
 
-	// group list where prepare
-	err = session.Table(new(model.User)).Where("group_id=?", req.GroupId).Find(&users)
+	// user can belong to many groups
+	err = session.Table(new(model.User)).Alias("u").Join("INNER", []string{"fafacms_user_group", "ug"}, "ug.user_id = u.id").
+		Where("ug.group_id=?", req.GroupId).Select("u.*").Find(&users)
 	if err != nil {
 		flog.Log.Errorf("ListUser err:%s", err.Error())
 		resp.Error = Error(DBError, err.Error())
@@ -966,11 +1069,11 @@ func ListGroupUser(c *gin.Context) {
 
 type AssignGroupRequest struct {
 	GroupId      int64   `json:"group_id"`
-	GroupRelease int     `json:"group_release"`
+	GroupRelease int     `json:"group_release"` // 1 remove users from group, group_id empty will remove from all groups
 	Users        []int64 `json:"users"`
 }
 
-// Assign user to a group, every user can only have less than one group
+// Assign users to a group, every user can belong to many groups
 func AssignGroupToUser(c *gin.Context) {
 	resp := new(Resp)
 	req := new(AssignGroupRequest)
@@ -989,72 +1092,95 @@ func AssignGroupToUser(c *gin.Context) {
 		return
 	}
 
-	// release the user of group, user will not belong to any group
+	// release the user of group
 	if req.GroupRelease == 1 {
-		u := new(model.User)
-		num, err := model.FaFaRdb.Client.Cols("group_id").In("id", req.Users).Update(u)
+		s := model.FaFaRdb.Client.In("user_id", req.Users)
+		if req.GroupId != 0 {
+			s.And("group_id=?", req.GroupId)
+		}
+		num, err := s.Delete(new(model.UserGroup))
 		if err != nil {
 			flog.Log.Errorf("AssignGroupToUser err:%s", err.Error())
 			resp.Error = Error(DBError, err.Error())
 			return
 		}
 
-		err = session.FafaSessionMgr.RefreshUser(req.Users, SessionExpireTime)
-		if err != nil {
-			flog.Log.Errorf("AssignGroupToUser err:%s", err.Error())
-			resp.Error = Error(RefreshUserCacheError, err.Error())
-			return
-		}
+		ClearPermission(req.Users...)
 		resp.Data = num
-	} else {
-		if req.GroupId == 0 {
-			flog.Log.Errorf("AssignGroupToUser err:%s", "group id empty")
-			resp.Error = Error(ParasError, "group_id empty")
-			return
-		}
+		resp.Flag = true
+		return
+	}
+
+	if req.GroupId == 0 {
+		flog.Log.Errorf("AssignGroupToUser err:%s", "group id empty")
+		resp.Error = Error(ParasError, "group_id empty")
+		return
+	}
+
+	g := new(model.Group)
+	g.Id = req.GroupId
+	exist, err := g.GetById()
+	if err != nil {
+		flog.Log.Errorf("AssignGroupToUser err:%s", err.Error())
+		resp.Error = Error(DBError, err.Error())
+		return
+	}
+
+	if !exist {
+		flog.Log.Errorf("AssignGroupToUser err:%s", "group not found")
+		resp.Error = Error(GroupNotFound, "")
+		return
+	}
+
+	num, err := model.FaFaRdb.Client.Table(new(model.User)).In("id", req.Users).Count()
+	if err != nil {
+		flog.Log.Errorf("AssignGroupToUser err:%s", err.Error())
+		resp.Error = Error(DBError, err.Error())
+		return
+	}
 
-		g := new(model.Group)
-		g.Id = req.GroupId
-		exist, err := g.GetById()
+	if int(num) != len(req.Users) {
+		flog.Log.Errorf("AssignGroupToUser err:%s", "user not found")
+		resp.Error = Error(UserNotFound, "")
+		return
+	}
+
+	// user already in group skip
+	var add int64
+	for _, id := range req.Users {
+		ug := model.UserGroup{UserId: id, GroupId: req.GroupId}
+		exist, err := ug.Exist()
 		if err != nil {
 			flog.Log.Errorf("AssignGroupToUser err:%s", err.Error())
 			resp.Error = Error(DBError, err.Error())
 			return
 		}
 
-		if !exist {
-			flog.Log.Errorf("AssignGroupToUser err:%s", "group not found")
-			resp.Error = Error(GroupNotFound, "")
-			return
+		if exist {
+			continue
 		}
 
-		u := new(model.User)
-		u.GroupId = req.GroupId
-		num, err := model.FaFaRdb.Client.Cols("group_id").In("id", req.Users).Update(u)
+		_, err = model.FaFaRdb.Client.InsertOne(&ug)
 		if err != nil {
 			flog.Log.Errorf("AssignGroupToUser err:%s", err.Error())
 			resp.Error = Error(DBError, err.Error())
 			return
 		}
-
-		err = session.FafaSessionMgr.RefreshUser(req.Users, SessionExpireTime)
-		if err != nil {
-			flog.Log.Errorf("AssignGroupToUser err:%s", err.Error())
-			resp.Error = Error(RefreshUserCacheError, err.Error())
-			return
-		}
-		resp.Data = num
+		ClearPermission(id)
+		add++
 	}
 
+	resp.Data = add
 	resp.Flag = true
 }
 
 type UpdateUserAdminRequest struct {
-	Id       int64  `json:"id" validate:"required"`
-	NickName string `json:"nick_name" validate:"omitempty"`
-	Password string `json:"password,omitempty"`
-	Status   int    `json:"status" validate:"oneof=0 1 2"` // o nothing 1 activate 2 ban
-	Vip      int    `json:"vip" validate:"oneof=0 1 2"`    // 1 become vip, 2 no vip
+	Id             int64  `json:"id" validate:"required"`
+	NickName       string `json:"nick_name" validate:"omitempty"`
+	Password       string `json:"password,omitempty"`
+	Status         int    `json:"status" validate:"oneof=0 1 2"`         // o nothing 1 activate 2 ban
+	Vip            int    `json:"vip" validate:"oneof=0 1 2"`            // 1 become vip, 2 no vip
+	ResetTwoFactor int    `json:"reset_two_factor" validate:"oneof=0 1"` // 1 close two factor of user who lost authenticator
 }
 
 // Update user info, admin url. Can change user password, black one user, change nickname etc.
@@ -1093,6 +1219,22 @@ func UpdateUserAdmin(c *gin.Context) {
 		return
 	}
 
+	// admin from group can not change the super admin
+	if uu.IsSuperAdmin == 1 {
+		me, err := GetUserSession(c)
+		if err != nil {
+			flog.Log.Errorf("UpdateUserAdmin err: %s", err.Error())
+			resp.Error = Error(GetUserSessionError, err.Error())
+			return
+		}
+
+		if me.IsSuperAdmin != 1 {
+			flog.Log.Errorf("UpdateUserAdmin err: %s", "super admin can not change by other")
+			resp.Error = Error(UserAuthPermit, "super admin")
+			return
+		}
+	}
+
 	u := new(model.User)
 	if req.NickName == model.AnonymousUser {
 		flog.Log.Errorf("UpdateUserAdmin err: %s", "can not be anonymous name")
@@ -1116,7 +1258,16 @@ func UpdateUserAdmin(c *gin.Context) {
 		}
 	}
 	u.Id = req.Id
-	u.Password = req.Password
+
+	// password change will save as hash
+	if req.Password != "" {
+		err = u.SetPassword(req.Password)
+		if err != nil {
+			flog.Log.Errorf("UpdateUserAdmin err: %s", err.Error())
+			resp.Error = Error(ParasError, err.Error())
+			return
+		}
+	}
 
 	// change user status, 1->2, 2->1
 	u.Status = req.Status
@@ -1135,12 +1286,39 @@ func UpdateUserAdmin(c *gin.Context) {
 		return
 	}
 
+	// user locked out by two factor
+	if req.ResetTwoFactor == 1 {
+		u.TwoFactorEnable = 0
+		u.TwoFactorSecret = ""
+		u.TwoFactorRecovery = ""
+		err = u.UpdateTwoFactor()
+		if err != nil {
+			flog.Log.Errorf("UpdateUserAdmin err:%s", err.Error())
+			resp.Error = Error(DBError, err.Error())
+			return
+		}
+	}
+
 	err = session.FafaSessionMgr.RefreshUser([]int64{u.Id}, SessionExpireTime)
 	if err != nil {
 		flog.Log.Errorf("UpdateUserAdmin err:%s", err.Error())
 		resp.Error = Error(RefreshUserCacheError, err.Error())
 		return
 	}
+
+	// password or two factor reset, the old login can not use any more
+	if req.Password != "" || req.ResetTwoFactor == 1 {
+		err = DeleteUserAllSession(u.Id)
+		if err != nil {
+			flog.Log.Errorf("UpdateUserAdmin err:%s", err.Error())
+			resp.Error = Error(DeleteUserAllSessionError, err.Error())
+			return
+		}
+	}
+
+	SendToSearch(model.SearchUser, u.Id)
+	RefreshSitemap()
+	u.Password = ""
 	resp.Data = u
 	resp.Flag = true
 }
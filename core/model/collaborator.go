@@ -0,0 +1,91 @@
+package model
+
+import (
+	"errors"
+	"time"
+)
+
+const (
+	CollaboratorViewer    = 0 // see the draft and history of contents under the node
+	CollaboratorEditor    = 1 // create content, edit the draft, node info
+	CollaboratorPublisher = 2 // publish, hide, move and delete the content
+)
+
+// Node of user share to other as collaborator, grant on the node work for all its children
+type NodeCollaborator struct {
+	Id               int64  `json:"id" xorm:"bigint pk autoincr"`
+	UserId           int64  `json:"user_id" xorm:"bigint index"` // owner of the node
+	UserName         string `json:"user_name" xorm:"index"`
+	NodeId           int64  `json:"node_id" xorm:"bigint index"`
+	CollaboratorId   int64  `json:"collaborator_id" xorm:"bigint index"`
+	CollaboratorName string `json:"collaborator_name" xorm:"index"`
+	Role             int    `json:"role" xorm:"notnull default(0) comment('0 viewer, 1 editor, 2 publisher') TINYINT(1)"`
+	CreateTime       int64  `json:"create_time"`
+	UpdateTime       int64  `json:"update_time,omitempty"`
+}
+
+var NodeCollaboratorSortName = []string{"=id", "-create_time", "-update_time", "=node_id", "=collaborator_id", "=role"}
+
+func (n *NodeCollaborator) Get() (bool, error) {
+	if n.Id == 0 && (n.NodeId == 0 || n.CollaboratorId == 0) {
+		return false, errors.New("where is empty")
+	}
+	return FaFaRdb.Client.Get(n)
+}
+
+func (n *NodeCollaborator) Insert() error {
+	n.CreateTime = time.Now().Unix()
+	_, err := FaFaRdb.Client.InsertOne(n)
+	return err
+}
+
+func (n *NodeCollaborator) UpdateRole() error {
+	if n.Id == 0 || n.UserId == 0 {
+		return errors.New("where is empty")
+	}
+
+	n.UpdateTime = time.Now().Unix()
+	_, err := FaFaRdb.Client.Where("id=?", n.Id).And("user_id=?", n.UserId).Cols("role", "update_time").Update(n)
+	return err
+}
+
+func (n *NodeCollaborator) Delete() error {
+	if n.Id == 0 || n.UserId == 0 {
+		return errors.New("where is empty")
+	}
+
+	_, err := FaFaRdb.Client.Where("id=?", n.Id).And("user_id=?", n.UserId).Delete(new(NodeCollaborator))
+	return err
+}
+
+// Role of the collaborator on the node, grant on the ancestors count too, the biggest one
+func CollaboratorRole(collaboratorId int64, nodeId int64) (int, bool, error) {
+	if collaboratorId == 0 || nodeId == 0 {
+		return 0, false, errors.New("where is empty")
+	}
+
+	node := new(ContentNode)
+	exist, err := FaFaRdb.Client.Where("id=?", nodeId).Cols("id", "path").Get(node)
+	if err != nil || !exist {
+		return 0, false, err
+	}
+
+	ids := NodePathIds(node.Path)
+	if len(ids) == 0 {
+		ids = append(ids, node.Id)
+	}
+
+	grants := make([]NodeCollaborator, 0)
+	err = FaFaRdb.Client.Where("collaborator_id=?", collaboratorId).In("node_id", ids).Cols("role").Find(&grants)
+	if err != nil || len(grants) == 0 {
+		return 0, false, err
+	}
+
+	role := grants[0].Role
+	for _, v := range grants {
+		if v.Role > role {
+			role = v.Role
+		}
+	}
+	return role, true, nil
+}
@@ -2,39 +2,54 @@ package model
 
 import (
 	"errors"
+	"github.com/hunterhug/fafacms/core/util"
 	"time"
 )
 
 var HistoryRecord = true
 
 type Content struct {
-	Id               int64  `json:"id" xorm:"bigint pk autoincr"`
-	Seo              string `json:"seo" xorm:"index"`
-	Title            string `json:"title" xorm:"varchar(200)"`
-	PreTitle         string `json:"pre_title" xorm:"varchar(200)"`
-	UserId           int64  `json:"user_id" xorm:"bigint index"`
-	UserName         string `json:"user_name" xorm:"index"`
-	NodeId           int64  `json:"node_id" xorm:"bigint index"`
-	NodeSeo          string `json:"node_seo" xorm:"index"`
-	Status           int    `json:"status" xorm:"notnull default(0) comment('0 normal, 1 hide，2 ban, 3 rubbish') TINYINT(1) index"`
-	Top              int    `json:"top" xorm:"notnull default(0) comment('0 normal, 1 top') TINYINT(1) index"`
-	Describe         string `json:"describe" xorm:"TEXT"`
-	PreDescribe      string `json:"pre_describe" xorm:"TEXT"`
-	PreFlush         int    `json:"pre_flush" xorm:"notnull default(0) comment('1 flush') TINYINT(1)"`
-	CloseComment     int    `json:"close_comment" xorm:"notnull default(0) comment('0 open, 1 close') TINYINT(1)"`
-	Version          int    `json:"version" xorm:"notnull default(0)"`
-	CreateTime       int64  `json:"create_time"`
-	UpdateTime       int64  `json:"update_time,omitempty"`
-	BanTime          int64  `json:"ban_time,omitempty"`
-	FirstPublishTime int64  `json:"first_publish_time,omitempty"`
-	PublishTime      int64  `json:"publish_time,omitempty"`
-	ImagePath        string `json:"image_path" xorm:"varchar(700)"`
-	Views            int64  `json:"views"`
-	Password         string `json:"password,omitempty"`
-	SortNum          int64  `json:"sort_num" xorm:"notnull default(0)"`
-	Bad              int64  `json:"bad" xorm:"notnull default(0)"`
-	Cool             int64  `json:"cool" xorm:"notnull default(0)"`
-	CommentNum       int64  `json:"comment_num" xorm:"notnull default(0)"`
+	Id               int64          `json:"id" xorm:"bigint pk autoincr"`
+	Seo              string         `json:"seo" xorm:"index"`
+	Title            string         `json:"title" xorm:"varchar(200)"`
+	PreTitle         string         `json:"pre_title" xorm:"varchar(200)"`
+	UserId           int64          `json:"user_id" xorm:"bigint index"`
+	UserName         string         `json:"user_name" xorm:"index"`
+	NodeId           int64          `json:"node_id" xorm:"bigint index"`
+	NodeSeo          string         `json:"node_seo" xorm:"index"`
+	Status           int            `json:"status" xorm:"notnull default(0) comment('0 normal, 1 hide，2 ban, 3 rubbish') TINYINT(1) index"`
+	Top              int            `json:"top" xorm:"notnull default(0) comment('0 normal, 1 top') TINYINT(1) index"`
+	Describe         string         `json:"describe" xorm:"TEXT"`
+	PreDescribe      string         `json:"pre_describe" xorm:"TEXT"`
+	PreFlush         int            `json:"pre_flush" xorm:"notnull default(0) comment('1 flush') TINYINT(1)"`
+	CloseComment     int            `json:"close_comment" xorm:"notnull default(0) comment('0 open, 1 close') TINYINT(1)"`
+	Version          int            `json:"version" xorm:"notnull default(0)"`
+	CreateTime       int64          `json:"create_time"`
+	UpdateTime       int64          `json:"update_time,omitempty"`
+	BanTime          int64          `json:"ban_time,omitempty"`
+	FirstPublishTime int64          `json:"first_publish_time,omitempty"`
+	PublishTime      int64          `json:"publish_time,omitempty"`
+	ImagePath        string         `json:"image_path" xorm:"varchar(700)"`
+	Views            int64          `json:"views"`
+	Password         string         `json:"password,omitempty"`
+	SortNum          int64          `json:"sort_num" xorm:"notnull default(0)"`
+	Bad              int64          `json:"bad" xorm:"notnull default(0)"`
+	Cool             int64          `json:"cool" xorm:"notnull default(0)"`
+	CommentNum       int64          `json:"comment_num" xorm:"notnull default(0)"`
+	Tags             []string       `json:"tags,omitempty" xorm:"-"`
+	DescribeHtml     string         `json:"describe_html,omitempty" xorm:"TEXT"` // render from describe when publish
+	Toc              []util.TocItem `json:"toc,omitempty" xorm:"TEXT"`
+	Excerpt          string         `json:"excerpt" xorm:"varchar(1000)"`
+	WordNum          int64          `json:"word_num" xorm:"notnull default(0)"`
+	ReadMinute       int64          `json:"read_minute" xorm:"notnull default(0)"`
+	PublishAt        int64          `json:"publish_at,omitempty" xorm:"notnull default(0) index"`   // schedule publish the draft, 0 no schedule
+	UnpublishAt      int64          `json:"unpublish_at,omitempty" xorm:"notnull default(0) index"` // schedule hide the content
+	Revision         int64          `json:"revision" xorm:"notnull default(1)"`                     // add one every write, client send back the one it saw
+	EditLockId       string         `json:"edit_lock_id,omitempty" xorm:"varchar(100)"`             // who is editing, soft lock only for warning
+	EditLockTime     int64          `json:"edit_lock_time,omitempty"`                               // last heartbeat of the edit lock
+	ReviewStatus     int            `json:"review_status" xorm:"notnull default(0) index"`          // 0 not in review, 1 waiting, 2 approve, 3 need change, 4 reject
+	ReviewTime       int64          `json:"review_time,omitempty"`                                  // last time of submit or review
+	EditUserId       int64          `json:"-" xorm:"-"`                                             // who write it, the owner or a collaborator, save in history
 }
 
 var ContentSortName = []string{"=id", "-user_id", "-top", "+sort_num", "-first_publish_time", "-publish_time", "-create_time", "-update_time", "-views", "=comment_num", "=bad", "=cool", "=version", "+status", "=seo"}
@@ -58,9 +73,17 @@ type ContentHistory struct {
 	Types      int    `json:"types" xorm:"notnull default(0) comment('0 update save, 1 publish, 2 restore') TINYINT(1)"`
 	Version    int    `json:"version"`
 	CreateTime int64  `json:"create_time"`
+	EditUserId int64  `json:"edit_user_id" xorm:"bigint index"` // who make the change, the owner or a collaborator
 }
 
-var ContentHistorySortName = []string{"=id", "-user_id", "-create_time", "-content_id"}
+var ContentHistorySortName = []string{"=id", "-user_id", "-create_time", "-content_id", "=edit_user_id"}
+
+func (c *Content) editUserId() int64 {
+	if c.EditUserId != 0 {
+		return c.EditUserId
+	}
+	return c.UserId
+}
 
 func (c *Content) CountNumUnderNode() (int64, error) {
 	if c.UserId == 0 || c.NodeId == 0 {
@@ -108,6 +131,7 @@ func (c *Content) GetByRaw() (bool, error) {
 	return FaFaRdb.Client.Omit("pre_describe", "describe").Get(c)
 }
 
+// Pre is the draft before, not change only the tags write, tags nil not change
 func (c *Content) UpdateDescribeAndHistory(save bool) error {
 	if c.UserId == 0 || c.Id == 0 {
 		return errors.New("where is empty")
@@ -116,10 +140,14 @@ func (c *Content) UpdateDescribeAndHistory(save bool) error {
 	session := FaFaRdb.Client.NewSession()
 	defer session.Close()
 
-	var err error
+	err := session.Begin()
+	if err != nil {
+		return err
+	}
 
+	change := c.PreDescribe != c.Describe || c.PreTitle != c.Title
 	now := time.Now().Unix()
-	if HistoryRecord && save && c.PreFlush != 1 {
+	if change && HistoryRecord && save && c.PreFlush != 1 {
 		history := new(ContentHistory)
 		history.NodeId = c.NodeId
 		history.CreateTime = now
@@ -128,6 +156,7 @@ func (c *Content) UpdateDescribeAndHistory(save bool) error {
 		history.Describe = c.PreDescribe
 		history.ContentId = c.Id
 		history.UserId = c.UserId
+		history.EditUserId = c.editUserId()
 
 		history.Types = 0
 		_, err = session.InsertOne(history)
@@ -138,16 +167,34 @@ func (c *Content) UpdateDescribeAndHistory(save bool) error {
 	}
 
 	c.UpdateTime = now
-	c.PreDescribe = c.Describe
-	c.PreTitle = c.Title
-	c.PreFlush = 0
+	cols := []string{"update_time"}
+	if change {
+		c.PreDescribe = c.Describe
+		c.PreTitle = c.Title
+		c.PreFlush = 0
+		cols = append(cols, "pre_title", "pre_describe", "pre_flush")
+	}
 
-	_, err = session.Cols("update_time", "pre_title", "pre_describe", "pre_flush").Where("id=?", c.Id).And("user_id=?", c.UserId).Update(c)
+	num, err := c.revisionWhere(session.Cols(cols...)).Update(c)
 	if err != nil {
 		session.Rollback()
 		return err
 	}
 
+	err = c.revisionDone(num)
+	if err != nil {
+		session.Rollback()
+		return err
+	}
+
+	if c.Tags != nil {
+		err = setContentTags(session, c.UserId, c.UserName, c.Id, c.Tags)
+		if err != nil {
+			session.Rollback()
+			return err
+		}
+	}
+
 	err = session.Commit()
 	if err != nil {
 		session.Rollback()
@@ -160,14 +207,22 @@ func (c *Content) UpdateSeo() (int64, error) {
 	if c.UserId == 0 || c.Id == 0 {
 		return 0, errors.New("where is empty")
 	}
-	return FaFaRdb.Client.Cols("seo").Where("id=?", c.Id).And("user_id=?", c.UserId).Update(c)
+	num, err := c.revisionWhere(FaFaRdb.Client.Cols("seo")).Update(c)
+	if err != nil {
+		return 0, err
+	}
+	return num, c.revisionDone(num)
 }
 
 func (c *Content) UpdateImage() (int64, error) {
 	if c.UserId == 0 || c.Id == 0 {
 		return 0, errors.New("where is empty")
 	}
-	return FaFaRdb.Client.Cols("image_path").Where("id=?", c.Id).And("user_id=?", c.UserId).Update(c)
+	num, err := c.revisionWhere(FaFaRdb.Client.Cols("image_path")).Update(c)
+	if err != nil {
+		return 0, err
+	}
+	return num, c.revisionDone(num)
 }
 
 func (c *Content) UpdateStatus(normal bool, isBanChange bool) (int64, error) {
@@ -178,7 +233,7 @@ func (c *Content) UpdateStatus(normal bool, isBanChange bool) (int64, error) {
 	if !normal {
 		if c.Status == 2 {
 			c.BanTime = time.Now().Unix()
-			num, err := FaFaRdb.Client.Cols("status", "ban_time").Where("id=?", c.Id).And("user_id=?", c.UserId).And("status!=?", 2).Update(c)
+			num, err := FaFaRdb.Client.Cols("status", "ban_time").Where("id=?", c.Id).And("user_id=?", c.UserId).And("status!=?", 2).Incr("revision").Update(c)
 			if err != nil {
 				return 0, err
 			}
@@ -198,7 +253,7 @@ func (c *Content) UpdateStatus(normal bool, isBanChange bool) (int64, error) {
 
 			c.BanTime = 0
 			c.Bad = 0
-			num, err := se.Cols("status", "ban_time", "bad").Where("id=?", c.Id).And("status=?", 2).And("user_id=?", c.UserId).Update(c)
+			num, err := se.Cols("status", "ban_time", "bad").Where("id=?", c.Id).And("status=?", 2).And("user_id=?", c.UserId).Incr("revision").Update(c)
 			if err != nil {
 				se.Rollback()
 				return 0, err
@@ -229,14 +284,22 @@ func (c *Content) UpdateStatus(normal bool, isBanChange bool) (int64, error) {
 
 		return 0, nil
 	}
-	return FaFaRdb.Client.Cols("status").Where("id=?", c.Id).And("user_id=?", c.UserId).Update(c)
+	num, err := c.revisionWhere(FaFaRdb.Client.Cols("status")).Update(c)
+	if err != nil {
+		return 0, err
+	}
+	return num, c.revisionDone(num)
 }
 
 func (c *Content) UpdateTop() (int64, error) {
 	if c.UserId == 0 || c.Id == 0 {
 		return 0, errors.New("where is empty")
 	}
-	return FaFaRdb.Client.Cols("top").Where("id=?", c.Id).And("user_id=?", c.UserId).Update(c)
+	num, err := c.revisionWhere(FaFaRdb.Client.Cols("top")).Update(c)
+	if err != nil {
+		return 0, err
+	}
+	return num, c.revisionDone(num)
 }
 
 // update comment
@@ -244,7 +307,11 @@ func (c *Content) UpdateComment() (int64, error) {
 	if c.UserId == 0 || c.Id == 0 {
 		return 0, errors.New("where is empty")
 	}
-	return FaFaRdb.Client.Cols("close_comment").Where("id=?", c.Id).And("user_id=?", c.UserId).Update(c)
+	num, err := c.revisionWhere(FaFaRdb.Client.Cols("close_comment")).Update(c)
+	if err != nil {
+		return 0, err
+	}
+	return num, c.revisionDone(num)
 }
 
 // update password
@@ -252,7 +319,11 @@ func (c *Content) UpdatePassword() (int64, error) {
 	if c.UserId == 0 || c.Id == 0 {
 		return 0, errors.New("where is empty")
 	}
-	return FaFaRdb.Client.Cols("password").Where("id=?", c.Id).And("user_id=?", c.UserId).Update(c)
+	num, err := c.revisionWhere(FaFaRdb.Client.Cols("password")).Update(c)
+	if err != nil {
+		return 0, err
+	}
+	return num, c.revisionDone(num)
 }
 
 func (n *Content) UpdateNode(beforeNodeId int64) error {
@@ -281,7 +352,13 @@ func (n *Content) UpdateNode(beforeNodeId int64) error {
 
 	n.SortNum = c
 
-	_, err = session.Exec("update fafacms_content SET sort_num=?, node_id=?, node_seo=? where id = ? and user_id = ?", n.SortNum, n.NodeId, n.NodeSeo, n.Id, n.UserId)
+	num, err := n.revisionWhere(session.Cols("sort_num", "node_id", "node_seo")).Update(n)
+	if err != nil {
+		session.Rollback()
+		return err
+	}
+
+	err = n.revisionDone(num)
 	if err != nil {
 		session.Rollback()
 		return err
@@ -327,15 +404,70 @@ func (c *Content) GetBrotherContent() (pre, next *Content, err error) {
 	return
 }
 
+// Render the markdown describe into html, toc and excerpt
+func (c *Content) Render() {
+	m := util.RenderMarkdown(c.Describe)
+	c.DescribeHtml = m.Html
+	c.Toc = m.Toc
+	c.Excerpt = m.Excerpt
+	c.WordNum = m.WordNum
+	c.ReadMinute = m.ReadMinute
+}
+
+// Content publish before render not save the html, render it when read
+func (c *Content) RenderIfNeed() {
+	if c.Excerpt == "" && c.Describe != "" {
+		c.Render()
+	}
+}
+
 func (c *Content) PublishDescribe() error {
+	_, err := c.publishDescribe(0)
+	return err
+}
+
+// at not 0 is the schedule publish, only publish when the schedule still there,
+// many server run the schedule only one can take it
+func (c *Content) publishDescribe(at int64) (bool, error) {
 	if c.UserId == 0 || c.Id == 0 {
-		return errors.New("where is empty")
+		return false, errors.New("where is empty")
 	}
 
 	session := FaFaRdb.Client.NewSession()
 	defer session.Close()
 	if err := session.Begin(); err != nil {
-		return err
+		return false, err
+	}
+
+	if at != 0 {
+		num, err := session.Where("id=?", c.Id).And("publish_at=?", at).Cols("publish_at").Update(new(Content))
+		if err != nil {
+			session.Rollback()
+			return false, err
+		}
+
+		if num == 0 {
+			session.Rollback()
+			return false, nil
+		}
+
+		// the draft may change after the schedule
+		now := new(Content)
+		_, err = session.Where("id=?", c.Id).Get(now)
+		if err != nil {
+			session.Rollback()
+			return false, err
+		}
+		*c = *now
+
+		// nothing new or can not publish, just cancel the schedule
+		if c.PreFlush == 1 || c.Status == 2 || c.Status == 3 {
+			if err := session.Commit(); err != nil {
+				session.Rollback()
+				return false, err
+			}
+			return false, nil
+		}
 	}
 
 	now := time.Now().Unix()
@@ -348,12 +480,13 @@ func (c *Content) PublishDescribe() error {
 		history.Describe = c.PreDescribe
 		history.ContentId = c.Id
 		history.UserId = c.UserId
+		history.EditUserId = c.editUserId()
 		history.Version = c.Version + 1
 		history.Types = 1
 		_, err := session.InsertOne(history)
 		if err != nil {
 			session.Rollback()
-			return err
+			return false, err
 		}
 	}
 
@@ -367,17 +500,24 @@ func (c *Content) PublishDescribe() error {
 	c.Title = c.PreTitle
 	c.Describe = c.PreDescribe
 	c.PublishTime = now
-	_, err := session.Cols("title", "describe", "pre_flush", "update_time", "publish_time", "first_publish_time", "version").Where("id=?", c.Id).And("user_id=?", c.UserId).Update(c)
+	c.Render()
+	num, err := c.revisionWhere(session.Cols("title", "describe", "pre_flush", "update_time", "publish_time", "first_publish_time", "version", "describe_html", "toc", "excerpt", "word_num", "read_minute")).Update(c)
 	if err != nil {
 		session.Rollback()
-		return err
+		return false, err
+	}
+
+	err = c.revisionDone(num)
+	if err != nil {
+		session.Rollback()
+		return false, err
 	}
 
 	if err := session.Commit(); err != nil {
 		session.Rollback()
-		return err
+		return false, err
 	}
-	return nil
+	return true, nil
 }
 
 func (c *Content) ResetDescribe(save bool) error {
@@ -400,6 +540,7 @@ func (c *Content) ResetDescribe(save bool) error {
 		history.Describe = c.PreDescribe
 		history.ContentId = c.Id
 		history.UserId = c.UserId
+		history.EditUserId = c.editUserId()
 
 		history.Types = 2
 		_, err := session.InsertOne(history)
@@ -413,7 +554,13 @@ func (c *Content) ResetDescribe(save bool) error {
 	c.PreFlush = 0
 	c.PreTitle = c.Title
 	c.PreDescribe = c.Describe
-	_, err := session.Cols("pre_title", "pre_describe", "pre_flush", "update_time").Where("id=?", c.Id).And("user_id=?", c.UserId).Update(c)
+	num, err := c.revisionWhere(session.Cols("pre_title", "pre_describe", "pre_flush", "update_time")).Update(c)
+	if err != nil {
+		session.Rollback()
+		return err
+	}
+
+	err = c.revisionDone(num)
 	if err != nil {
 		session.Rollback()
 		return err
@@ -426,6 +573,14 @@ func (c *Content) ResetDescribe(save bool) error {
 	return nil
 }
 
+// import from other blog keep the time before
+func (c *Content) UpdatePublishTime() (int64, error) {
+	if c.UserId == 0 || c.Id == 0 {
+		return 0, errors.New("where is empty")
+	}
+	return FaFaRdb.Client.Cols("create_time", "first_publish_time", "publish_time").Where("id=?", c.Id).And("user_id=?", c.UserId).Update(c)
+}
+
 func (c *Content) Delete() error {
 	if c.UserId == 0 || c.Id == 0 {
 		return errors.New("where is empty")
@@ -457,6 +612,11 @@ func (c *Content) Delete() error {
 		return err
 	}
 
+	if _, err := session.Where("content_id=?", c.Id).Delete(new(ContentTag)); err != nil {
+		session.Rollback()
+		return err
+	}
+
 	if _, err := session.Where("content_id=?", c.Id).Delete(new(Comment)); err != nil {
 		session.Rollback()
 		return err
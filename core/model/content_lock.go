@@ -0,0 +1,73 @@
+package model
+
+import (
+	"errors"
+	"github.com/go-xorm/xorm"
+	"time"
+)
+
+var ErrContentRevisionConflict = errors.New("content revision not match")
+
+// The write only happen when the revision still is what the client saw, and add one to it in the same update,
+// revision 0 is force write, only for admin and inner job
+func (c *Content) revisionWhere(session *xorm.Session) *xorm.Session {
+	session.Where("id=?", c.Id).And("user_id=?", c.UserId)
+	if c.Revision != 0 {
+		session.And("revision=?", c.Revision)
+	}
+	return session.Incr("revision")
+}
+
+// Nothing update is other write before, after write the revision is the new one
+func (c *Content) revisionDone(num int64) error {
+	if c.Revision == 0 {
+		return nil
+	}
+
+	if num == 0 {
+		return ErrContentRevisionConflict
+	}
+
+	c.Revision = c.Revision + 1
+	return nil
+}
+
+// The revision now, tell the client when conflict
+func ContentRevision(id int64) (int64, error) {
+	c := new(Content)
+	_, err := FaFaRdb.Client.Where("id=?", id).Cols("revision").Get(c)
+	return c.Revision, err
+}
+
+// Take the edit lock or heartbeat, other's lock not expire only force can take,
+// after it the content has the lock now
+func (c *Content) EditLock(clientId string, force bool, expire int64) error {
+	if c.Id == 0 || c.UserId == 0 {
+		return errors.New("where is empty")
+	}
+
+	now := time.Now().Unix()
+	session := FaFaRdb.Client.Where("id=?", c.Id).And("user_id=?", c.UserId)
+	if !force {
+		session.And("(edit_lock_id=? or edit_lock_id=? or edit_lock_time<?)", clientId, "", now-expire)
+	}
+
+	_, err := session.Cols("edit_lock_id", "edit_lock_time").Update(&Content{EditLockId: clientId, EditLockTime: now})
+	if err != nil {
+		return err
+	}
+
+	_, err = FaFaRdb.Client.Where("id=?", c.Id).Cols("id", "revision", "edit_lock_id", "edit_lock_time").Get(c)
+	return err
+}
+
+// Release the edit lock, only the one has it can
+func (c *Content) EditUnlock(clientId string) error {
+	if c.Id == 0 || c.UserId == 0 {
+		return errors.New("where is empty")
+	}
+
+	_, err := FaFaRdb.Client.Where("id=?", c.Id).And("user_id=?", c.UserId).And("edit_lock_id=?", clientId).
+		Cols("edit_lock_id", "edit_lock_time").Update(new(Content))
+	return err
+}
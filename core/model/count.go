@@ -43,22 +43,45 @@ func CountContentAll(userId int64) (err error) {
 	return
 }
 
+// Count the node and all its ancestors, content of children count in the parent
 func CountContentOneNode(userId int64, nodeId int64) (err error) {
-	sql := fmt.Sprintf("SELECT count(id) as count FROM `fafacms_content` WHERE first_publish_time!=0 and user_id=? and node_id=? and version>0 and status!=1 and status!=3")
-	result, err := FaFaRdb.Client.QueryString(sql, userId, nodeId)
-	if err != nil {
+	node := new(ContentNode)
+	exist, err := FaFaRdb.Client.Where("id=?", nodeId).And("user_id=?", userId).Cols("id", "path").Get(node)
+	if err != nil || !exist {
 		return err
 	}
 
-	back := 0
-	for _, v := range result {
-		back, _ = util.SI(v["count"])
-		break
+	ids := NodePathIds(node.Path)
+	if len(ids) == 0 {
+		ids = append(ids, node.Id)
 	}
 
-	n := new(ContentNode)
-	n.ContentNum = int64(back)
-	_, err = FaFaRdb.Client.Where("user_id=?", userId).Cols("content_num").Update(n)
+	for k, id := range ids {
+		path := ""
+		for _, v := range ids[:k+1] {
+			path = NodePath(path, v)
+		}
+
+		// SELECT count(c.id) as count FROM `fafacms_content` c JOIN `fafacms_content_node` n ON c.node_id=n.id WHERE c.first_publish_time!=0 and c.user_id=2 and c.version>0 and c.status!=1 and c.status!=3 and n.path like '/1/5/%'
+		sql := "SELECT count(c.id) as count FROM `fafacms_content` c JOIN `fafacms_content_node` n ON c.node_id=n.id WHERE c.first_publish_time!=0 and c.user_id=? and c.version>0 and c.status!=1 and c.status!=3 and (n.id=? or n.path like ?)"
+		result, err := FaFaRdb.Client.QueryString(sql, userId, id, path+"%")
+		if err != nil {
+			return err
+		}
+
+		back := 0
+		for _, v := range result {
+			back, _ = util.SI(v["count"])
+			break
+		}
+
+		n := new(ContentNode)
+		n.ContentNum = int64(back)
+		_, err = FaFaRdb.Client.Where("user_id=?", userId).And("id=?", id).Cols("content_num").Update(n)
+		if err != nil {
+			return err
+		}
+	}
 	return
 }
 
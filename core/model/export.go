@@ -0,0 +1,61 @@
+package model
+
+import (
+	"errors"
+	"time"
+)
+
+// Export all the content of one user into a zip, make in background
+type ContentExport struct {
+	Id           int64  `json:"id" xorm:"bigint pk autoincr"`
+	UserId       int64  `json:"user_id" xorm:"bigint index"`
+	UserName     string `json:"user_name" xorm:"index"`
+	CreateUserId int64  `json:"create_user_id" xorm:"bigint"` // admin can export other user
+	History      int    `json:"history" xorm:"notnull default(0) comment('1 include history') TINYINT(1)"`
+	File         int    `json:"file" xorm:"notnull default(0) comment('1 include upload file') TINYINT(1)"`
+	Status       int    `json:"status" xorm:"notnull default(0) comment('0 doing, 1 done, 2 fail') TINYINT(1) index"`
+	StoreType    int    `json:"store_type" xorm:"notnull default(0) comment('0 local，1 oss') TINYINT(1)"`
+	Path         string `json:"-" xorm:"varchar(700)"`
+	Size         int64  `json:"size"`
+	ContentNum   int64  `json:"content_num"`
+	FileNum      int64  `json:"file_num"`
+	Error        string `json:"error,omitempty" xorm:"TEXT"`
+	CreateTime   int64  `json:"create_time"`
+	FinishTime   int64  `json:"finish_time,omitempty"`
+}
+
+var ContentExportSortName = []string{"=id", "-create_time", "=user_id", "=status"}
+
+func (e *ContentExport) Insert() error {
+	e.CreateTime = time.Now().Unix()
+	_, err := FaFaRdb.Insert(e)
+	return err
+}
+
+func (e *ContentExport) Get() (bool, error) {
+	if e.Id == 0 {
+		return false, errors.New("where is empty")
+	}
+	return FaFaRdb.Client.Get(e)
+}
+
+// Still doing one after the time
+func (e *ContentExport) Doing(after int64) (bool, error) {
+	if e.UserId == 0 {
+		return false, errors.New("where is empty")
+	}
+
+	num, err := FaFaRdb.Client.Table(e).Where("user_id=?", e.UserId).And("status=?", 0).And("create_time>?", after).Count()
+	return num > 0, err
+}
+
+// Done or fail
+func (e *ContentExport) Finish() error {
+	if e.Id == 0 {
+		return errors.New("where is empty")
+	}
+
+	e.FinishTime = time.Now().Unix()
+	_, err := FaFaRdb.Client.Where("id=?", e.Id).Cols("status", "store_type", "path", "size", "content_num", "file_num", "error", "finish_time").Update(e)
+	return err
+}
@@ -3,6 +3,7 @@ package model
 import (
 	"errors"
 	"fmt"
+	"strings"
 	"time"
 )
 
@@ -13,26 +14,45 @@ type Group struct {
 	CreateTime int64  `json:"create_time"`
 	UpdateTime int64  `json:"update_time,omitempty"`
 	ImagePath  string `json:"image_path" xorm:"varchar(700)"`
+	Review     int    `json:"review" xorm:"notnull default(0)"` // 0 follow the site, 1 content of users need review before publish, 2 not need
 }
 
 var GroupSortName = []string{"=id", "=name", "-create_time", "=update_time"}
 
+// Every route is a resource, url end with * is a pattern resource create by admin, such as /v1/content/*
 type Resource struct {
 	Id         int64  `json:"id" xorm:"bigint pk autoincr"`
 	Name       string `json:"name"`
 	Url        string `json:"url"`
 	UrlHash    string `json:"url_hash" xorm:"unique"`
 	Describe   string `json:"describe" xorm:"TEXT"`
-	Admin      bool   `json:"admin"`
+	Admin      bool   `json:"admin"`   // admin resource default deny, others default allow
+	Pattern    bool   `json:"pattern"` // wildcard url
 	CreateTime int64  `json:"create_time"`
 }
 
 var ResourceSortName = []string{"=id", "+create_time", "-name"}
 
+// Rule of group, allow or deny some methods of resource
 type GroupResource struct {
-	Id         int64 `json:"id" xorm:"bigint pk autoincr"`
-	GroupId    int64 `json:"group_id index(gr)"`
-	ResourceId int64 `json:"resource_id index(gr)"`
+	Id         int64  `json:"id" xorm:"bigint pk autoincr"`
+	GroupId    int64  `json:"group_id index(gr)"`
+	ResourceId int64  `json:"resource_id index(gr)"`
+	Methods    string `json:"methods" xorm:"varchar(100)"` // split by comma, empty is all method
+	Deny       int    `json:"deny" xorm:"notnull default(0) comment('0 allow, 1 deny') TINYINT(1)"`
+}
+
+// User can belong to many groups
+type UserGroup struct {
+	Id      int64 `json:"id" xorm:"bigint pk autoincr"`
+	UserId  int64 `json:"user_id" xorm:"bigint unique(ug)"`
+	GroupId int64 `json:"group_id" xorm:"bigint unique(ug) index"`
+}
+
+// Rule of group with the resource url
+type GroupRule struct {
+	GroupResource `xorm:"extends"`
+	Url           string `json:"url"`
 }
 
 func (g *Group) GetById() (exist bool, err error) {
@@ -53,6 +73,16 @@ func (g *Group) Update() error {
 	return err
 }
 
+func (g *Group) UpdateReview() error {
+	if g.Id == 0 {
+		return errors.New("where is empty")
+	}
+
+	g.UpdateTime = time.Now().Unix()
+	_, err := FaFaRdb.Client.Where("id=?", g.Id).Cols("review", "update_time").Update(g)
+	return err
+}
+
 func (g *Group) Exist() (bool, error) {
 	if g.Id == 0 && g.Name == "" {
 		return false, errors.New("where is empty")
@@ -114,6 +144,22 @@ func (r *Resource) GetRaw() (bool, error) {
 	return FaFaRdb.Client.UseBool("admin").Get(r)
 }
 
+func (r *Resource) GetByHash() (bool, error) {
+	if r.UrlHash == "" {
+		return false, errors.New("where is empty")
+	}
+	return FaFaRdb.Client.Where("url_hash=?", r.UrlHash).Get(r)
+}
+
+// Route change the admin flag, resource follow it
+func (r *Resource) UpdateAdmin() error {
+	if r.Id == 0 {
+		return errors.New("where is empty")
+	}
+	_, err := FaFaRdb.Client.Where("id=?", r.Id).Cols("admin", "name").UseBool("admin").Update(r)
+	return err
+}
+
 func (r *Resource) InsertOne() (err error) {
 	_, err = FaFaRdb.Client.InsertOne(r)
 	if err != nil {
@@ -148,3 +194,104 @@ func (gr *GroupResource) Exist() (bool, error) {
 
 	return false, err
 }
+
+func (gr *GroupResource) MethodList() []string {
+	if gr.Methods == "" {
+		return []string{}
+	}
+	return strings.Split(gr.Methods, ",")
+}
+
+// Method empty is all method
+func (gr *GroupResource) MatchMethod(method string) bool {
+	if gr.Methods == "" {
+		return true
+	}
+
+	for _, m := range gr.MethodList() {
+		if strings.EqualFold(m, method) {
+			return true
+		}
+	}
+	return false
+}
+
+// Url match the resource url, resource url end with * will match the prefix
+func MatchResourceUrl(resourceUrl string, url string) bool {
+	if strings.HasSuffix(resourceUrl, "*") {
+		return strings.HasPrefix(url, strings.TrimSuffix(resourceUrl, "*"))
+	}
+	return resourceUrl == url
+}
+
+// All the rules of those groups
+func GroupRules(groupIds []int64) ([]GroupRule, error) {
+	rules := make([]GroupRule, 0)
+	if len(groupIds) == 0 {
+		return rules, nil
+	}
+
+	err := FaFaRdb.Client.Table("fafacms_group_resource").Alias("gr").
+		Join("INNER", []string{"fafacms_resource", "r"}, "gr.resource_id = r.id").
+		Select("gr.*, r.url").In("gr.group_id", groupIds).Find(&rules)
+	return rules, err
+}
+
+func UserGroupIds(userId int64) ([]int64, error) {
+	ids := make([]int64, 0)
+	err := FaFaRdb.Client.Table(new(UserGroup)).Where("user_id=?", userId).Cols("group_id").Find(&ids)
+	return ids, err
+}
+
+func (ug *UserGroup) Exist() (bool, error) {
+	if ug.UserId == 0 && ug.GroupId == 0 {
+		return false, errors.New("where is empty")
+	}
+
+	s := FaFaRdb.Client.Table(ug)
+	s.Where("1=1")
+
+	if ug.UserId != 0 {
+		s.And("user_id=?", ug.UserId)
+	}
+	if ug.GroupId != 0 {
+		s.And("group_id=?", ug.GroupId)
+	}
+
+	c, err := s.Count()
+	if c >= 1 {
+		return true, nil
+	}
+
+	return false, err
+}
+
+// Before user can only belong to one group, move the group_id of user into user_group
+func MigrateUserGroup() error {
+	users := make([]User, 0)
+	err := FaFaRdb.Client.Where("group_id!=?", 0).Cols("id", "group_id").Find(&users)
+	if err != nil {
+		return err
+	}
+
+	for _, u := range users {
+		ug := UserGroup{UserId: u.Id, GroupId: u.GroupId}
+		exist, err := ug.Exist()
+		if err != nil {
+			return err
+		}
+
+		if !exist {
+			_, err = FaFaRdb.Client.InsertOne(&ug)
+			if err != nil {
+				return err
+			}
+		}
+
+		_, err = FaFaRdb.Client.Table(new(User)).Where("id=?", u.Id).Update(map[string]interface{}{"group_id": 0})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -1,16 +1,76 @@
 package model
 
+import (
+	"fmt"
+	"github.com/hunterhug/fafacms/core/util"
+)
+
+// Audit log of api, write by batch in background
 type Log struct {
-	Id           int    `json:"id" xorm:"bigint pk autoincr"`
-	Cid          string `json:"cid"`
-	Ip           string `json:"ip"`
-	Url          string `json:"url"`
-	LogTime      int64  `json:"log_time"`
-	Ua           string `json:"ua"`
-	UserId       int    `json:"user_id" xorm:"bigint index"`
+	Id           int64  `json:"id" xorm:"bigint pk autoincr"`
+	Cid          string `json:"cid" xorm:"varchar(100) index"`
+	Ip           string `json:"ip" xorm:"varchar(100) index"`
+	Url          string `json:"url" xorm:"varchar(255) index"`
+	Method       string `json:"method" xorm:"varchar(20)"`
+	LogTime      int64  `json:"log_time" xorm:"index"`
+	Ua           string `json:"ua" xorm:"varchar(700)"`
+	UserId       int64  `json:"user_id" xorm:"bigint index"`
 	Flag         bool   `json:"flag"`
 	In           string `json:"in" xorm:"TEXT"`
 	Out          string `json:"out" xorm:"TEXT"`
-	ErrorId      string `json:"error_id"`
-	ErrorMessage string `json:"error_message"`
+	ErrorId      string `json:"error_id" xorm:"varchar(20) index"`
+	ErrorMessage string `json:"error_message" xorm:"TEXT"`
+}
+
+var LogSortName = []string{"-log_time", "=id", "=user_id", "=url"}
+
+// mysql text column max bytes
+const logTextMaxLength = 65535
+
+// Cut every column to its size, or one bad log will fail the whole batch
+func (l *Log) Clamp() {
+	l.Cid = util.CutRunes(l.Cid, 100)
+	l.Ip = util.CutRunes(l.Ip, 100)
+	l.Url = util.CutRunes(l.Url, 255)
+	l.Method = util.CutRunes(l.Method, 20)
+	l.Ua = util.CutRunes(l.Ua, 700)
+	l.In = util.CutBytes(l.In, logTextMaxLength)
+	l.Out = util.CutBytes(l.Out, logTextMaxLength)
+	l.ErrorId = util.CutRunes(l.ErrorId, 20)
+	l.ErrorMessage = util.CutBytes(l.ErrorMessage, logTextMaxLength)
+}
+
+// Insert by batch, batch fail will insert one by one so only the bad one lost
+func InsertLogs(logs []Log) error {
+	if len(logs) == 0 {
+		return nil
+	}
+
+	for i := range logs {
+		logs[i].Clamp()
+	}
+
+	_, err := FaFaRdb.Client.Insert(&logs)
+	if err == nil {
+		return nil
+	}
+
+	fail := 0
+	for i := range logs {
+		logs[i].Id = 0
+		if _, e := FaFaRdb.Client.InsertOne(&logs[i]); e != nil {
+			fail++
+			err = e
+		}
+	}
+
+	if fail == 0 {
+		return nil
+	}
+	return fmt.Errorf("%d of %d fail, last err: %s", fail, len(logs), err.Error())
+}
+
+// Delete the log before this time
+func PruneLog(before int64) (int64, error) {
+	return FaFaRdb.Client.Where("log_time<?", before).Delete(new(Log))
 }
@@ -0,0 +1,33 @@
+package model
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestLog_Clamp(t *testing.T) {
+	l := &Log{
+		Url: "/" + strings.Repeat("中", 300),
+		Ua:  strings.Repeat("a", 800) + "\xff",
+		Out: strings.Repeat("中", 30000),
+		Ip:  "127.0.0.1\xff",
+	}
+	l.Clamp()
+
+	if utf8.RuneCountInString(l.Url) != 255 || !strings.HasPrefix(l.Url, "/中") {
+		t.Fatalf("url cut wrong: %d", utf8.RuneCountInString(l.Url))
+	}
+
+	if len(l.Ua) != 700 {
+		t.Fatalf("ua cut wrong: %d", len(l.Ua))
+	}
+
+	if len(l.Out) > logTextMaxLength || !utf8.ValidString(l.Out) {
+		t.Fatalf("out cut wrong: %d", len(l.Out))
+	}
+
+	if l.Ip != "127.0.0.1" {
+		t.Fatalf("invalid utf8 should drop: %q", l.Ip)
+	}
+}
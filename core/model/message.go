@@ -35,6 +35,15 @@ const (
 
 	// global send a message to you
 	MessageTypeGlobal = 11 // 管理员通知
+
+	// who share the node to you
+	MessageTypeCollaborator = 12 // 有人邀请你协作节点
+
+	// review of content before publish
+	MessageTypeReviewSubmit  = 13 // 有文章等待你审核
+	MessageTypeReviewApprove = 14 // 文章审核通过并已发布
+	MessageTypeReviewChange  = 15 // 文章需要修改后再提交审核
+	MessageTypeReviewReject  = 16 // 文章审核未通过
 )
 
 // Message inside
@@ -60,6 +69,10 @@ type Message struct {
 	MessageType       int    `json:"message_type" xorm:"index"`
 	CommentIsYourSelf int    `json:"comment_is_your_self"`
 	GlobalMessageId   int64  `json:"global_message_id" xorm:"index"`
+	NodeId            int64  `json:"node_id,omitempty"`
+	NodeName          string `json:"node_name,omitempty"`
+	ReviewId          int64  `json:"review_id,omitempty"`
+	ReviewComment     string `json:"review_comment,omitempty"`
 }
 
 type GlobalMessage struct {
@@ -155,6 +168,28 @@ func FollowYou(userId int64, receiveUserId int64) error {
 	return m.Insert()
 }
 
+func CollaboratorAdd(userId int64, receiveUserId int64, nodeId int64, nodeName string) error {
+	m := new(Message)
+	m.UserId = userId
+	m.ReceiveUserId = receiveUserId
+	m.NodeId = nodeId
+	m.NodeName = nodeName
+	m.MessageType = MessageTypeCollaborator
+	return m.Insert()
+}
+
+func ReviewAbout(userId int64, receiveUserId int64, contentId int64, contentTitle string, reviewId int64, comment string, messageType int) error {
+	m := new(Message)
+	m.UserId = userId
+	m.ReceiveUserId = receiveUserId
+	m.ContentId = contentId
+	m.ContentTitle = contentTitle
+	m.ReviewId = reviewId
+	m.ReviewComment = comment
+	m.MessageType = messageType
+	return m.Insert()
+}
+
 func PublishContent(userId int64, receiveUserId int64, contentId int64, contentTitle string, PublishAgain bool) error {
 	if receiveUserId != 0 {
 		m := new(Message)
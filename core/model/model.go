@@ -47,14 +47,4 @@ func CreateTable(tables []interface{}) {
 			continue
 		}
 	}
-
-	u := new(User)
-	u.Name = "admin"
-	u.Email = "admin@admin"
-	u.NickName = "admin"
-	u.Password = "admin"
-	u.Status = 1
-	u.Vip = 1
-	u.ShortDescribe = "super man"
-	u.InsertOne()
 }
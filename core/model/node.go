@@ -2,6 +2,9 @@ package model
 
 import (
 	"errors"
+	"fmt"
+	"strconv"
+	"strings"
 	"time"
 )
 
@@ -17,9 +20,44 @@ type ContentNode struct {
 	UpdateTime   int64  `json:"update_time,omitempty"`
 	ImagePath    string `json:"image_path" xorm:"varchar(700)"`
 	ParentNodeId int64  `json:"parent_node_id" xorm:"bigint index"`
-	Level        int    `json:"level"`
+	Level        int    `json:"level"`                    // depth of the node, root is 0
+	Path         string `json:"path" xorm:"varchar(700)"` // id of ancestors and itself, like /1/5/9/
 	SortNum      int64  `json:"sort_num" xorm:"notnull default(0)"`
-	ContentNum   int64  `json:"content_num" xorm:"notnull default(0)"` // normal publish content num
+	ContentNum   int64  `json:"content_num" xorm:"notnull default(0)"` // normal publish content num, include its children
+	Review       int    `json:"review" xorm:"notnull default(0)"`      // 0 follow the parent, 1 content need review before publish, 2 not need
+}
+
+// path is long as the column, node can not be deeper
+const ContentNodePathMaxLen = 700
+
+var (
+	ErrContentNodeMoveIntoChild = errors.New("node can not move into its children")
+	ErrContentNodeTooDeep       = errors.New("node too deep")
+)
+
+// Path of the node under the parent, parent path empty is root
+func NodePath(parentPath string, id int64) string {
+	if parentPath == "" {
+		parentPath = "/"
+	}
+	return fmt.Sprintf("%s%d/", parentPath, id)
+}
+
+// Ancestors id and itself in the path, from root
+func NodePathIds(path string) []int64 {
+	ids := make([]int64, 0)
+	for _, v := range strings.Split(path, "/") {
+		id, err := strconv.ParseInt(v, 10, 64)
+		if err == nil && id != 0 {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// The path is itself or its children
+func (n *ContentNode) IsAncestorOf(path string) bool {
+	return n.Path != "" && strings.HasPrefix(path, n.Path)
 }
 
 var ContentNodeSortName = []string{"=id", "+sort_num", "-create_time", "-update_time", "+status", "=seo", "=content_num"}
@@ -51,7 +89,7 @@ func (n *ContentNode) CheckParentValid() (bool, error) {
 		return false, errors.New("where is empty")
 	}
 
-	c, err := FaFaRdb.Client.Table(n).Where("user_id=?", n.UserId).And("id=?", n.ParentNodeId).And("level=?", 0).Count()
+	c, err := FaFaRdb.Client.Table(n).Where("user_id=?", n.UserId).And("id=?", n.ParentNodeId).Count()
 
 	if c >= 1 {
 		return true, nil
@@ -67,10 +105,57 @@ func (n *ContentNode) CheckChildrenNum() (int, error) {
 	return int(num), err
 }
 
+// Insert the node under its parent, level and path follow the parent
 func (n *ContentNode) InsertOne() error {
 	n.CreateTime = time.Now().Unix()
-	_, err := FaFaRdb.Insert(n)
-	return err
+
+	session := FaFaRdb.Client.NewSession()
+	defer session.Close()
+	err := session.Begin()
+	if err != nil {
+		return err
+	}
+
+	parent := new(ContentNode)
+	if n.ParentNodeId != 0 {
+		exist, err := session.Where("id=?", n.ParentNodeId).And("user_id=?", n.UserId).Cols("id", "level", "path").Get(parent)
+		if err != nil {
+			session.Rollback()
+			return err
+		}
+		if !exist {
+			session.Rollback()
+			return errors.New("parent node not found")
+		}
+		n.Level = parent.Level + 1
+	} else {
+		n.Level = 0
+	}
+
+	_, err = session.InsertOne(n)
+	if err != nil {
+		session.Rollback()
+		return err
+	}
+
+	n.Path = NodePath(parent.Path, n.Id)
+	if len(n.Path) > ContentNodePathMaxLen {
+		session.Rollback()
+		return ErrContentNodeTooDeep
+	}
+
+	_, err = session.Where("id=?", n.Id).Cols("path").Update(n)
+	if err != nil {
+		session.Rollback()
+		return err
+	}
+
+	err = session.Commit()
+	if err != nil {
+		session.Rollback()
+		return err
+	}
+	return nil
 }
 
 func (n *ContentNode) Get() (bool, error) {
@@ -166,7 +251,19 @@ func (n *ContentNode) UpdateStatus() error {
 	return err
 }
 
-func (n *ContentNode) UpdateParent(beforeParentNode int64) error {
+func (n *ContentNode) UpdateReview() error {
+	if n.UserId == 0 || n.Id == 0 {
+		return errors.New("where is empty")
+	}
+
+	n.UpdateTime = time.Now().Unix()
+	_, err := FaFaRdb.Client.Where("id=?", n.Id).And("user_id=?", n.UserId).Cols("review", "update_time").Update(n)
+	return err
+}
+
+// Move the node with all its children under the parent, parent zero is root, the node will be
+// at the sortNum of the new level, less than zero is at the end. Nodes behind in both level will be sorted.
+func (n *ContentNode) Move(parentNodeId int64, sortNum int64) error {
 	if n.UserId == 0 || n.Id == 0 {
 		return errors.New("where is empty")
 	}
@@ -178,31 +275,213 @@ func (n *ContentNode) UpdateParent(beforeParentNode int64) error {
 		return err
 	}
 
-	_, err = session.Exec("update fafacms_content_node SET sort_num=sort_num-1 where sort_num > ? and user_id = ? and parent_node_id = ?", n.SortNum, n.UserId, beforeParentNode)
+	// lock the node and the parent in id order, move a under b and b under a at the same time
+	// will wait another, then the later one can see the cycle
+	lockIds := []int64{n.Id}
+	if parentNodeId != 0 && parentNodeId != n.Id {
+		lockIds = append(lockIds, parentNodeId)
+	}
+	locked := make([]int64, 0)
+	err = session.Table(n).In("id", lockIds).Asc("id").Cols("id").ForUpdate().Find(&locked)
 	if err != nil {
 		session.Rollback()
 		return err
 	}
 
-	c, err := session.Table(n).Where("user_id=?", n.UserId).And("parent_node_id", n.ParentNodeId).Count()
+	x := new(ContentNode)
+	exist, err := session.Where("id=?", n.Id).And("user_id=?", n.UserId).ForUpdate().Get(x)
 	if err != nil {
 		session.Rollback()
 		return err
 	}
+	if !exist {
+		session.Rollback()
+		return errors.New("node not found")
+	}
 
-	n.SortNum = c
+	parent := new(ContentNode)
+	if parentNodeId != 0 {
+		exist, err = session.Where("id=?", parentNodeId).And("user_id=?", n.UserId).Cols("id", "level", "path").ForUpdate().Get(parent)
+		if err != nil {
+			session.Rollback()
+			return err
+		}
+		if !exist {
+			session.Rollback()
+			return errors.New("parent node not found")
+		}
+
+		// parent is itself or its children
+		if x.IsAncestorOf(parent.Path) {
+			session.Rollback()
+			return ErrContentNodeMoveIntoChild
+		}
+	}
 
-	_, err = session.Exec("update fafacms_content_node SET sort_num=?, level=?, parent_node_id=? where id = ? and user_id = ?", n.SortNum, n.Level, n.ParentNodeId, n.Id, n.UserId)
+	children := make([]ContentNode, 0)
+	err = session.Where("user_id=?", n.UserId).And("path like ?", x.Path+"%").And("id!=?", x.Id).Cols("id", "level", "path").ForUpdate().Find(&children)
 	if err != nil {
 		session.Rollback()
 		return err
 	}
 
+	path := NodePath(parent.Path, x.Id)
+	level := 0
+	if parentNodeId != 0 {
+		level = parent.Level + 1
+	}
+
+	if len(path) > ContentNodePathMaxLen {
+		session.Rollback()
+		return ErrContentNodeTooDeep
+	}
+	for _, v := range children {
+		if len(path)+len(v.Path)-len(x.Path) > ContentNodePathMaxLen {
+			session.Rollback()
+			return ErrContentNodeTooDeep
+		}
+	}
+
+	// pretend x is delete, nodes behind x replace it
+	_, err = session.Exec("update fafacms_content_node SET sort_num=sort_num-1 where sort_num > ? and user_id = ? and parent_node_id = ? and id != ?", x.SortNum, n.UserId, x.ParentNodeId, x.Id)
+	if err != nil {
+		session.Rollback()
+		return err
+	}
+
+	c, err := session.Table(n).Where("user_id=?", n.UserId).And("parent_node_id=?", parentNodeId).And("id!=?", x.Id).Count()
+	if err != nil {
+		session.Rollback()
+		return err
+	}
+
+	if sortNum < 0 || sortNum > c {
+		sortNum = c
+	}
+
+	// make a empty position to x
+	_, err = session.Exec("update fafacms_content_node SET sort_num=sort_num+1 where sort_num >= ? and user_id = ? and parent_node_id = ? and id != ?", sortNum, n.UserId, parentNodeId, x.Id)
+	if err != nil {
+		session.Rollback()
+		return err
+	}
+
+	_, err = session.Exec("update fafacms_content_node SET sort_num=?, level=?, parent_node_id=?, path=? where id = ? and user_id = ?", sortNum, level, parentNodeId, path, x.Id, n.UserId)
+	if err != nil {
+		session.Rollback()
+		return err
+	}
+
+	// children follow, /1/5/9/ move to /2/ then /1/5/9/10/ is /2/9/10/
+	for _, v := range children {
+		_, err = session.Exec("update fafacms_content_node SET level=?, path=? where id = ? and user_id = ?", v.Level+level-x.Level, path+strings.TrimPrefix(v.Path, x.Path), v.Id, n.UserId)
+		if err != nil {
+			session.Rollback()
+			return err
+		}
+	}
+
 	err = session.Commit()
 	if err != nil {
 		session.Rollback()
 		return err
 	}
 
+	n.ParentNodeId = parentNodeId
+	n.SortNum = sortNum
+	n.Level = level
+	n.Path = path
+	return nil
+}
+
+// All the children under the node, and their children, order by level. Only normal node return when onlyNormal,
+// and child of hidden node not return too.
+func (n *ContentNode) Children(onlyNormal bool) ([]ContentNode, error) {
+	if n.UserId == 0 || n.Id == 0 || n.Path == "" {
+		return nil, errors.New("where is empty")
+	}
+
+	session := FaFaRdb.Client.Where("user_id=?", n.UserId).And("path like ?", n.Path+"%").And("id!=?", n.Id)
+	if onlyNormal {
+		session.And("status=?", 0)
+	}
+
+	nodes := make([]ContentNode, 0)
+	err := session.Asc("level", "sort_num").Find(&nodes)
+	if err != nil {
+		return nil, err
+	}
+
+	if !onlyNormal {
+		return nodes, nil
+	}
+
+	in := map[int64]bool{n.Id: true}
+	back := make([]ContentNode, 0, len(nodes))
+	for _, v := range nodes {
+		if in[v.ParentNodeId] {
+			in[v.Id] = true
+			back = append(back, v)
+		}
+	}
+	return back, nil
+}
+
+// Before node only has two level without path, fill the path and level
+func MigrateNodePath() error {
+	nodes := make([]ContentNode, 0)
+	err := FaFaRdb.Client.Cols("id", "user_id", "parent_node_id", "level", "path").Find(&nodes)
+	if err != nil {
+		return err
+	}
+
+	m := make(map[int64]*ContentNode, len(nodes))
+	empty := false
+	for k := range nodes {
+		m[nodes[k].Id] = &nodes[k]
+		if nodes[k].Path == "" {
+			empty = true
+		}
+	}
+
+	if !empty {
+		return nil
+	}
+
+	paths := make(map[int64]string, len(nodes))
+	for _, v := range nodes {
+		if v.Path != "" {
+			paths[v.Id] = v.Path
+		}
+	}
+
+	var pathOf func(v *ContentNode, depth int) string
+	pathOf = func(v *ContentNode, depth int) string {
+		if p, ok := paths[v.Id]; ok {
+			return p
+		}
+
+		// parent not found or loop, be root
+		parentPath := ""
+		if p, ok := m[v.ParentNodeId]; ok && p.UserId == v.UserId && depth < len(nodes) {
+			parentPath = pathOf(p, depth+1)
+		}
+
+		paths[v.Id] = NodePath(parentPath, v.Id)
+		return paths[v.Id]
+	}
+
+	for k := range nodes {
+		v := &nodes[k]
+		if v.Path != "" {
+			continue
+		}
+
+		path := pathOf(v, 0)
+		_, err = FaFaRdb.Client.Table(new(ContentNode)).Where("id=?", v.Id).Update(map[string]interface{}{"path": path, "level": len(NodePathIds(path)) - 1})
+		if err != nil {
+			return err
+		}
+	}
 	return nil
 }
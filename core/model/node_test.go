@@ -9,3 +9,22 @@ func TestContentNode_Get(t *testing.T) {
 	n.Id = 2
 	n.Get()
 }
+
+func TestNodePath(t *testing.T) {
+	p := NodePath("", 1)
+	p = NodePath(p, 5)
+	p = NodePath(p, 9)
+	if p != "/1/5/9/" {
+		t.Fatalf("path wrong: %s", p)
+	}
+
+	ids := NodePathIds(p)
+	if len(ids) != 3 || ids[0] != 1 || ids[2] != 9 {
+		t.Fatalf("ids wrong: %v", ids)
+	}
+
+	n := &ContentNode{Id: 5, Path: "/1/5/"}
+	if !n.IsAncestorOf(p) || !n.IsAncestorOf(n.Path) || n.IsAncestorOf("/1/55/") || n.IsAncestorOf("/1/") {
+		t.Fatalf("ancestor wrong")
+	}
+}
@@ -0,0 +1,205 @@
+package model
+
+import (
+	"errors"
+	"time"
+)
+
+const (
+	ContentReviewNone    = 0 // not in review, or publish not need review
+	ContentReviewWaiting = 1 // submit by author, waiting reviewer
+	ContentReviewApprove = 2 // approve and publish already
+	ContentReviewChange  = 3 // reviewer want author change it and submit again
+	ContentReviewReject  = 4 // reject, can still submit again
+)
+
+// Review setting of node and group
+const (
+	ReviewFollow = 0 // node follow its parent, group follow the site
+	ReviewNeed   = 1
+	ReviewSkip   = 2
+)
+
+// Every step of the review, submit by author or the decision of reviewer
+type ContentReview struct {
+	Id           int64  `json:"id" xorm:"bigint pk autoincr"`
+	ContentId    int64  `json:"content_id" xorm:"bigint index"`
+	ContentTitle string `json:"content_title"`
+	UserId       int64  `json:"user_id" xorm:"bigint index"` // owner of the content
+	NodeId       int64  `json:"node_id" xorm:"bigint index"`
+	SubmitUserId int64  `json:"submit_user_id" xorm:"bigint index"`
+	ReviewUserId int64  `json:"review_user_id" xorm:"bigint index"` // 0 is the submit
+	Status       int    `json:"status" xorm:"notnull default(0) comment('1 submit, 2 approve, 3 need change, 4 reject') TINYINT(1) index"`
+	Comment      string `json:"comment" xorm:"TEXT"`
+	Revision     int64  `json:"revision"` // revision of content when submit, change after it can not approve
+	CreateTime   int64  `json:"create_time"`
+}
+
+var ContentReviewSortName = []string{"=id", "-create_time", "=status", "=submit_user_id", "=review_user_id"}
+
+// Queue of contents waiting review, the early one first
+var ContentReviewQueueSortName = []string{"+review_time", "=id", "-user_id", "=node_id"}
+
+// Insert the step and change the review status of content together
+func (r *ContentReview) Insert() error {
+	if r.ContentId == 0 || r.UserId == 0 {
+		return errors.New("where is empty")
+	}
+
+	session := FaFaRdb.Client.NewSession()
+	defer session.Close()
+
+	err := session.Begin()
+	if err != nil {
+		return err
+	}
+
+	r.CreateTime = time.Now().Unix()
+	_, err = session.InsertOne(r)
+	if err != nil {
+		session.Rollback()
+		return err
+	}
+
+	_, err = session.Where("id=?", r.ContentId).And("user_id=?", r.UserId).Cols("review_status", "review_time").
+		Update(&Content{ReviewStatus: r.Status, ReviewTime: r.CreateTime})
+	if err != nil {
+		session.Rollback()
+		return err
+	}
+
+	return session.Commit()
+}
+
+// The last submit of content, who submit and the revision
+func ContentReviewLastSubmit(contentId int64) (*ContentReview, bool, error) {
+	r := new(ContentReview)
+	exist, err := FaFaRdb.Client.Where("content_id=?", contentId).And("status=?", ContentReviewWaiting).Desc("id").Get(r)
+	return r, exist, err
+}
+
+// Content publish under the node by the user need review or not,
+// node is set by the owner so it can only add the need, never relax the group or site,
+// then the groups of user, skip in any group is skip, last the site
+func ReviewNeeded(userId int64, nodeId int64, site bool) (bool, error) {
+	node := new(ContentNode)
+	exist, err := FaFaRdb.Client.Where("id=?", nodeId).Cols("id", "path").Get(node)
+	if err != nil {
+		return false, err
+	}
+
+	nodes := make([]ContentNode, 0)
+	if exist {
+		ids := NodePathIds(node.Path)
+		if len(ids) == 0 {
+			ids = append(ids, node.Id)
+		}
+
+		err = FaFaRdb.Client.In("id", ids).And("review!=?", ReviewFollow).Cols("id", "level", "review").Find(&nodes)
+		if err != nil {
+			return false, err
+		}
+	}
+
+	groups := make([]Group, 0)
+	groupIds, err := UserGroupIds(userId)
+	if err != nil {
+		return false, err
+	}
+
+	if len(groupIds) > 0 {
+		err = FaFaRdb.Client.In("id", groupIds).And("review!=?", ReviewFollow).Cols("id", "review").Find(&groups)
+		if err != nil {
+			return false, err
+		}
+	}
+
+	return reviewNeeded(nodes, groups, site), nil
+}
+
+func reviewNeeded(nodes []ContentNode, groups []Group, site bool) bool {
+	// the nearest node first, skip only cancel the need of parent node
+	level := -1
+	review := ReviewFollow
+	for _, v := range nodes {
+		if v.Review != ReviewFollow && v.Level > level {
+			level = v.Level
+			review = v.Review
+		}
+	}
+
+	if review == ReviewNeed {
+		return true
+	}
+
+	review = ReviewFollow
+	for _, v := range groups {
+		if v.Review == ReviewSkip {
+			return false
+		}
+		if v.Review == ReviewNeed {
+			review = ReviewNeed
+		}
+	}
+
+	if review == ReviewFollow {
+		return site
+	}
+	return true
+}
+
+// Reviewer is the super admin, or user in the group
+func IsReviewer(user *User, groupName string) (bool, error) {
+	if user.IsSuperAdmin == 1 {
+		return true, nil
+	}
+
+	if groupName == "" {
+		return false, nil
+	}
+
+	g := new(Group)
+	exist, err := FaFaRdb.Client.Where("name=?", groupName).Cols("id").Get(g)
+	if err != nil || !exist {
+		return false, err
+	}
+
+	ug := new(UserGroup)
+	ug.UserId = user.Id
+	ug.GroupId = g.Id
+	return ug.Exist()
+}
+
+// All reviewer, they will be told when submit
+func ReviewerIds(groupName string) ([]int64, error) {
+	ids := make([]int64, 0)
+	err := FaFaRdb.Client.Table(new(User)).Where("is_super_admin=?", 1).And("status=?", 1).Cols("id").Find(&ids)
+	if err != nil || groupName == "" {
+		return ids, err
+	}
+
+	g := new(Group)
+	exist, err := FaFaRdb.Client.Where("name=?", groupName).Cols("id").Get(g)
+	if err != nil || !exist {
+		return ids, err
+	}
+
+	groupUserIds := make([]int64, 0)
+	err = FaFaRdb.Client.Table(new(UserGroup)).Where("group_id=?", g.Id).Cols("user_id").Find(&groupUserIds)
+	if err != nil {
+		return ids, err
+	}
+
+	seen := make(map[int64]bool, len(ids))
+	for _, v := range ids {
+		seen[v] = true
+	}
+
+	for _, v := range groupUserIds {
+		if !seen[v] {
+			seen[v] = true
+			ids = append(ids, v)
+		}
+	}
+	return ids, nil
+}
@@ -0,0 +1,37 @@
+package model
+
+import "testing"
+
+func TestReviewNeeded(t *testing.T) {
+	if reviewNeeded(nil, nil, false) || !reviewNeeded(nil, nil, true) {
+		t.Fatalf("site wrong")
+	}
+
+	// the nearest node first, skip cancel the need of parent node
+	nodes := []ContentNode{{Id: 1, Level: 0, Review: ReviewNeed}, {Id: 5, Level: 1, Review: ReviewSkip}}
+	if reviewNeeded(nodes, nil, false) {
+		t.Fatalf("node wrong")
+	}
+
+	if !reviewNeeded(nodes[:1], []Group{{Review: ReviewSkip}}, false) {
+		t.Fatalf("node need before group wrong")
+	}
+
+	// node skip can not relax the group or site
+	if !reviewNeeded(nodes, []Group{{Review: ReviewNeed}}, false) {
+		t.Fatalf("node skip relax the group")
+	}
+
+	if !reviewNeeded(nodes[1:], nil, true) {
+		t.Fatalf("node skip relax the site")
+	}
+
+	// skip in any group is skip
+	if reviewNeeded(nil, []Group{{Review: ReviewNeed}, {Review: ReviewSkip}}, true) {
+		t.Fatalf("group skip wrong")
+	}
+
+	if !reviewNeeded(nil, []Group{{Review: ReviewNeed}}, false) {
+		t.Fatalf("group need wrong")
+	}
+}
@@ -0,0 +1,63 @@
+package model
+
+import (
+	"errors"
+)
+
+var ContentScheduleSortName = []string{"+publish_at", "+unpublish_at", "=id"}
+
+func (c *Content) UpdateSchedule() error {
+	if c.UserId == 0 || c.Id == 0 {
+		return errors.New("where is empty")
+	}
+
+	_, err := FaFaRdb.Client.Cols("publish_at", "unpublish_at").Where("id=?", c.Id).And("user_id=?", c.UserId).Update(c)
+	return err
+}
+
+// Content should publish or hide before the time
+func ContentScheduleDue(now int64, publish bool, limit int) ([]Content, error) {
+	col := "unpublish_at"
+	if publish {
+		col = "publish_at"
+	}
+
+	cs := make([]Content, 0)
+	err := FaFaRdb.Client.Where(col+">?", 0).And(col+"<=?", now).Asc(col).Limit(limit).Cols("id", "user_id", "publish_at", "unpublish_at").Find(&cs)
+	return cs, err
+}
+
+// Publish the draft by schedule, false is other server do it or nothing to publish
+func (c *Content) SchedulePublish() (bool, error) {
+	if c.PublishAt == 0 {
+		return false, errors.New("schedule is empty")
+	}
+	return c.publishDescribe(c.PublishAt)
+}
+
+// Hide the content by schedule, only normal content will hide, false is other server do it or no need to hide
+func (c *Content) ScheduleUnpublish() (bool, error) {
+	if c.Id == 0 || c.UnpublishAt == 0 {
+		return false, errors.New("where is empty")
+	}
+
+	num, err := FaFaRdb.Client.Where("id=?", c.Id).And("unpublish_at=?", c.UnpublishAt).And("status=?", 0).Cols("status", "unpublish_at").Incr("revision").Update(&Content{Status: 1})
+	if err != nil {
+		return false, err
+	}
+
+	if num == 0 {
+		// ban or rubbish or hide already, just cancel the schedule
+		_, err = FaFaRdb.Client.Where("id=?", c.Id).And("unpublish_at=?", c.UnpublishAt).Cols("unpublish_at").Update(new(Content))
+		return false, err
+	}
+
+	now := new(Content)
+	_, err = FaFaRdb.Client.Where("id=?", c.Id).Omit("describe", "pre_describe", "describe_html", "toc").Get(now)
+	if err != nil {
+		return false, err
+	}
+
+	*c = *now
+	return true, nil
+}
@@ -0,0 +1,279 @@
+package model
+
+import (
+	"fmt"
+	"github.com/hunterhug/fafacms/core/util"
+	"strings"
+)
+
+// what kind of search doc
+const (
+	SearchContent = 1
+	SearchUser    = 2
+	SearchComment = 3
+)
+
+// title word more important than describe
+const (
+	SearchWeightTitle    int64 = 10
+	SearchWeightName     int64 = 10
+	SearchWeightDescribe int64 = 1
+
+	// one word repeat too many times in describe not count
+	SearchWordMaxCount int64 = 20
+)
+
+// Inverted index, every word of a doc a row
+type SearchWord struct {
+	Id     int64  `json:"id" xorm:"bigint pk autoincr"`
+	Types  int    `json:"types" xorm:"notnull default(0) comment('1 content, 2 user, 3 comment') TINYINT(1) index(sw)"`
+	Word   string `json:"word" xorm:"varchar(50) notnull index(sw)"`
+	DocId  int64  `json:"doc_id" xorm:"bigint index"`
+	Weight int64  `json:"weight" xorm:"notnull default(0)"`
+}
+
+func addSearchWeight(weights map[string]int64, text string, weight int64) {
+	for w, num := range util.SearchWordCount(text) {
+		if num > SearchWordMaxCount {
+			num = SearchWordMaxCount
+		}
+		weights[w] = weights[w] + num*weight
+	}
+}
+
+// Replace all the word of a doc
+func SearchIndex(types int, docId int64, weights map[string]int64) error {
+	se := FaFaRdb.Client.NewSession()
+	defer se.Close()
+	err := se.Begin()
+	if err != nil {
+		return err
+	}
+
+	_, err = se.Where("types=?", types).And("doc_id=?", docId).Delete(new(SearchWord))
+	if err != nil {
+		se.Rollback()
+		return err
+	}
+
+	if len(weights) > 0 {
+		words := make([]SearchWord, 0, len(weights))
+		for w, weight := range weights {
+			words = append(words, SearchWord{Types: types, Word: w, DocId: docId, Weight: weight})
+		}
+
+		_, err = se.Insert(&words)
+		if err != nil {
+			se.Rollback()
+			return err
+		}
+	}
+
+	err = se.Commit()
+	if err != nil {
+		se.Rollback()
+		return err
+	}
+	return nil
+}
+
+// Index may be old, join the doc and filter again in sql, so the page and total are right,
+// must be the same as the index rule
+var searchDocJoin = map[int]string{
+	SearchContent: "INNER JOIN `fafacms_content` d ON d.id=w.doc_id AND d.status=0 AND d.version>0 AND COALESCE(d.password,'')=''",
+	SearchUser:    "INNER JOIN `fafacms_user` d ON d.id=w.doc_id AND d.status=1",
+	SearchComment: "INNER JOIN `fafacms_comment` d ON d.id=w.doc_id AND d.status=0 AND d.is_delete=0 " +
+		"INNER JOIN `fafacms_content` c ON c.id=d.content_id AND c.status=0 AND c.version>0 AND COALESCE(c.password,'')=''",
+}
+
+// Remove doc from index, when it can not see any more
+func SearchRemove(types int, docId int64) error {
+	_, err := FaFaRdb.Client.Where("types=?", types).And("doc_id=?", docId).Delete(new(SearchWord))
+	return err
+}
+
+// Only publish, not hide, not ban, not rubbish and no password content can be search
+func (c *Content) Searchable() bool {
+	return c.Status == 0 && c.Version > 0 && c.Password == ""
+}
+
+// Index the content, or remove it when can not search
+func SearchIndexContent(id int64) error {
+	c := new(Content)
+	exist, err := FaFaRdb.Client.Where("id=?", id).Get(c)
+	if err != nil {
+		return err
+	}
+
+	if !exist || !c.Searchable() {
+		return SearchRemove(SearchContent, id)
+	}
+
+	weights := make(map[string]int64)
+	addSearchWeight(weights, c.Title, SearchWeightTitle)
+	addSearchWeight(weights, c.Describe, SearchWeightDescribe)
+	return SearchIndex(SearchContent, id, weights)
+}
+
+// Only normal user can be search
+func SearchIndexUser(id int64) error {
+	u := new(User)
+	exist, err := FaFaRdb.Client.Where("id=?", id).Get(u)
+	if err != nil {
+		return err
+	}
+
+	if !exist || u.Status != 1 {
+		return SearchRemove(SearchUser, id)
+	}
+
+	weights := make(map[string]int64)
+	addSearchWeight(weights, u.Name, SearchWeightName)
+	addSearchWeight(weights, u.NickName, SearchWeightName)
+	addSearchWeight(weights, u.ShortDescribe, SearchWeightDescribe)
+	addSearchWeight(weights, u.Describe, SearchWeightDescribe)
+	return SearchIndex(SearchUser, id, weights)
+}
+
+// Ban or delete comment, or comment under hidden content can not be search
+func SearchIndexComment(id int64) error {
+	c := new(Comment)
+	exist, err := FaFaRdb.Client.Where("id=?", id).Get(c)
+	if err != nil {
+		return err
+	}
+
+	if !exist || c.Status != 0 || c.IsDelete == 1 {
+		return SearchRemove(SearchComment, id)
+	}
+
+	content := new(Content)
+	exist, err = FaFaRdb.Client.Where("id=?", c.ContentId).Cols("id", "status", "version", "password").Get(content)
+	if err != nil {
+		return err
+	}
+
+	if !exist || !content.Searchable() {
+		return SearchRemove(SearchComment, id)
+	}
+
+	weights := make(map[string]int64)
+	addSearchWeight(weights, c.Describe, SearchWeightDescribe)
+	return SearchIndex(SearchComment, id, weights)
+}
+
+// Content change can be search or not, the comments under it follow
+func SearchIndexCommentsOfContent(contentId int64) error {
+	ids := make([]int64, 0)
+	err := FaFaRdb.Client.Table(new(Comment)).Where("content_id=?", contentId).Cols("id").Find(&ids)
+	if err != nil {
+		return err
+	}
+
+	for _, id := range ids {
+		err = SearchIndexComment(id)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Result doc ids order by relevance
+func SearchQuery(types int, words []string, offset, limit int) (ids []int64, total int64, err error) {
+	ids = make([]int64, 0)
+	if len(words) == 0 {
+		return
+	}
+
+	join, ok := searchDocJoin[types]
+	if !ok {
+		err = fmt.Errorf("search types %d wrong", types)
+		return
+	}
+
+	// first one is the sql, fill it later
+	args := make([]interface{}, 0, len(words)+2)
+	args = append(args, "", types)
+	holder := make([]string, 0, len(words))
+	for _, w := range words {
+		holder = append(holder, "?")
+		args = append(args, w)
+	}
+	where := fmt.Sprintf("w.types=? and w.word in (%s)", strings.Join(holder, ","))
+
+	// SELECT count(distinct w.doc_id) as count FROM `fafacms_search_word` w INNER JOIN `fafacms_content` d ON ... WHERE w.types=1 and w.word in ('fa','cms')
+	sql := fmt.Sprintf("SELECT count(distinct w.doc_id) as count FROM `fafacms_search_word` w %s WHERE %s", join, where)
+	args[0] = sql
+	result, err := FaFaRdb.Client.QueryString(args...)
+	if err != nil {
+		return
+	}
+
+	for _, v := range result {
+		num, _ := util.SI(v["count"])
+		total = int64(num)
+		break
+	}
+
+	if total == 0 {
+		return
+	}
+
+	// more word match first, then the weight
+	sql = fmt.Sprintf("SELECT w.doc_id as doc_id, count(w.id) as hit, sum(w.weight) as score FROM `fafacms_search_word` w %s WHERE %s GROUP BY w.doc_id ORDER BY hit desc, score desc, doc_id desc LIMIT %d OFFSET %d", join, where, limit, offset)
+	args[0] = sql
+	result, err = FaFaRdb.Client.QueryString(args...)
+	if err != nil {
+		return
+	}
+
+	for _, v := range result {
+		id, _ := util.SI(v["doc_id"])
+		ids = append(ids, int64(id))
+	}
+	return
+}
+
+// Clear the index and build again
+func SearchRebuild(types int) (num int64, err error) {
+	_, err = FaFaRdb.Client.Where("types=?", types).Delete(new(SearchWord))
+	if err != nil {
+		return
+	}
+
+	var table interface{}
+	var index func(int64) error
+	switch types {
+	case SearchContent:
+		table, index = new(Content), SearchIndexContent
+	case SearchUser:
+		table, index = new(User), SearchIndexUser
+	case SearchComment:
+		table, index = new(Comment), SearchIndexComment
+	default:
+		return 0, fmt.Errorf("search types %d wrong", types)
+	}
+
+	var lastId int64
+	for {
+		ids := make([]int64, 0)
+		err = FaFaRdb.Client.Table(table).Where("id>?", lastId).Asc("id").Limit(500).Cols("id").Find(&ids)
+		if err != nil {
+			return
+		}
+
+		if len(ids) == 0 {
+			return
+		}
+
+		for _, id := range ids {
+			err = index(id)
+			if err != nil {
+				return
+			}
+			num = num + 1
+		}
+		lastId = ids[len(ids)-1]
+	}
+}
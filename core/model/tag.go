@@ -0,0 +1,291 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"github.com/go-xorm/xorm"
+	"github.com/hunterhug/fafacms/core/util"
+	"strings"
+	"time"
+)
+
+// Tag belong to user, same name of different user is different tag, but site wide list will put them together
+type Tag struct {
+	Id         int64  `json:"id" xorm:"bigint pk autoincr"`
+	UserId     int64  `json:"user_id" xorm:"bigint unique(un)"`
+	UserName   string `json:"user_name" xorm:"index"`
+	Name       string `json:"name" xorm:"varchar(50) notnull unique(un) index"`
+	Status     int    `json:"status" xorm:"notnull default(0) comment('0 normal, 1 ban') TINYINT(1) index"`
+	ContentNum int64  `json:"content_num" xorm:"notnull default(0)"` // normal publish content num
+	CreateTime int64  `json:"create_time"`
+	UpdateTime int64  `json:"update_time,omitempty"`
+}
+
+type ContentTag struct {
+	Id         int64 `json:"id" xorm:"bigint pk autoincr"`
+	ContentId  int64 `json:"content_id" xorm:"bigint unique(ct)"`
+	TagId      int64 `json:"tag_id" xorm:"bigint unique(ct) index"`
+	UserId     int64 `json:"user_id" xorm:"bigint index"`
+	CreateTime int64 `json:"create_time"`
+}
+
+var TagSortName = []string{"=id", "-content_num", "=name", "-create_time", "=user_id"}
+
+// one content at most so many tags
+var TagMaxNumOfContent = 10
+
+// Trim and remove the repeat tag name, ignore case as the db collation, first one keep
+func CleanTagNames(names []string) ([]string, error) {
+	result := make([]string, 0, len(names))
+	exist := make(map[string]bool)
+	for _, v := range names {
+		v = strings.TrimSpace(v)
+		if v == "" || exist[strings.ToLower(v)] {
+			continue
+		}
+
+		if len([]rune(v)) > 30 {
+			return nil, fmt.Errorf("tag %s too long", v)
+		}
+
+		exist[strings.ToLower(v)] = true
+		result = append(result, v)
+	}
+
+	if len(result) > TagMaxNumOfContent {
+		return nil, fmt.Errorf("tag more than %d", TagMaxNumOfContent)
+	}
+	return result, nil
+}
+
+func (t *Tag) Get() (bool, error) {
+	if t.Id == 0 && (t.UserId == 0 || t.Name == "") {
+		return false, errors.New("where is empty")
+	}
+	return FaFaRdb.Client.Get(t)
+}
+
+// Replace the tags of content, tag not exist will create
+func SetContentTags(userId int64, userName string, contentId int64, names []string) error {
+	if userId == 0 || contentId == 0 {
+		return errors.New("where is empty")
+	}
+
+	se := FaFaRdb.Client.NewSession()
+	defer se.Close()
+	err := se.Begin()
+	if err != nil {
+		se.Rollback()
+		return err
+	}
+
+	err = setContentTags(se, userId, userName, contentId, names)
+	if err != nil {
+		se.Rollback()
+		return err
+	}
+
+	err = se.Commit()
+	if err != nil {
+		se.Rollback()
+		return err
+	}
+	return nil
+}
+
+// Replace the tags in the transaction of other write
+func setContentTags(se *xorm.Session, userId int64, userName string, contentId int64, names []string) error {
+	_, err := se.Where("content_id=?", contentId).Delete(new(ContentTag))
+	if err != nil {
+		return err
+	}
+
+	now := time.Now().Unix()
+	for _, name := range names {
+		t := new(Tag)
+		exist, err := se.Where("user_id=?", userId).And("name=?", name).Get(t)
+		if err != nil {
+			return err
+		}
+
+		if !exist {
+			t.UserId = userId
+			t.UserName = userName
+			t.Name = name
+			t.CreateTime = now
+			_, err = se.InsertOne(t)
+			if err != nil {
+				return err
+			}
+		}
+
+		_, err = se.InsertOne(&ContentTag{ContentId: contentId, TagId: t.Id, UserId: userId, CreateTime: now})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Tag names of content, ban tag not show
+func ContentTagNames(contentId int64) ([]string, error) {
+	names := make([]string, 0)
+	err := FaFaRdb.Client.Table(new(Tag)).Join("INNER", "fafacms_content_tag", "fafacms_content_tag.tag_id=fafacms_tag.id").
+		Where("fafacms_content_tag.content_id=?", contentId).And("fafacms_tag.status=?", 0).
+		Asc("fafacms_content_tag.id").Cols("fafacms_tag.name").Find(&names)
+	return names, err
+}
+
+// Rename, new name can not be used by other tag of the user
+func (t *Tag) Rename(name string) error {
+	if t.Id == 0 {
+		return errors.New("where is empty")
+	}
+
+	t.Name = name
+	t.UpdateTime = time.Now().Unix()
+	_, err := FaFaRdb.Client.Where("id=?", t.Id).Cols("name", "update_time").Update(t)
+	return err
+}
+
+// Merge the tags into target tag, content has both will keep one
+func MergeTags(userId int64, fromIds []int64, toId int64) error {
+	se := FaFaRdb.Client.NewSession()
+	defer se.Close()
+	err := se.Begin()
+	if err != nil {
+		se.Rollback()
+		return err
+	}
+
+	// content already has target tag, delete the old relation
+	sql := "DELETE a FROM `fafacms_content_tag` a INNER JOIN `fafacms_content_tag` b ON a.content_id=b.content_id AND b.tag_id=? WHERE a.tag_id IN (%s)"
+	args := []interface{}{"", toId}
+	holder := make([]string, 0, len(fromIds))
+	for _, v := range fromIds {
+		holder = append(holder, "?")
+		args = append(args, v)
+	}
+	args[0] = fmt.Sprintf(sql, strings.Join(holder, ","))
+	_, err = se.Exec(args...)
+	if err != nil {
+		se.Rollback()
+		return err
+	}
+
+	_, err = se.Where("user_id=?", userId).In("tag_id", fromIds).Cols("tag_id").Update(&ContentTag{TagId: toId})
+	if err != nil {
+		se.Rollback()
+		return err
+	}
+
+	_, err = se.Where("user_id=?", userId).In("id", fromIds).Delete(new(Tag))
+	if err != nil {
+		se.Rollback()
+		return err
+	}
+
+	err = se.Commit()
+	if err != nil {
+		se.Rollback()
+		return err
+	}
+	return nil
+}
+
+// Delete tag and the relation of content
+func DeleteTag(id int64) error {
+	se := FaFaRdb.Client.NewSession()
+	defer se.Close()
+	err := se.Begin()
+	if err != nil {
+		se.Rollback()
+		return err
+	}
+
+	_, err = se.Where("tag_id=?", id).Delete(new(ContentTag))
+	if err != nil {
+		se.Rollback()
+		return err
+	}
+
+	_, err = se.Where("id=?", id).Delete(new(Tag))
+	if err != nil {
+		se.Rollback()
+		return err
+	}
+
+	err = se.Commit()
+	if err != nil {
+		se.Rollback()
+		return err
+	}
+	return nil
+}
+
+func (t *Tag) UpdateStatus() error {
+	if t.Id == 0 {
+		return errors.New("where is empty")
+	}
+
+	t.UpdateTime = time.Now().Unix()
+	_, err := FaFaRdb.Client.Where("id=?", t.Id).Cols("status", "update_time").Update(t)
+	return err
+}
+
+// Count the normal publish content num of every tag of user, same as the count of content
+func CountTagContent(userId int64) (err error) {
+	// SELECT tag_id, count(a.id) as count FROM `fafacms_content_tag` a INNER JOIN `fafacms_content` b ON a.content_id=b.id WHERE a.user_id=2 and b.version>0 and b.status!=1 and b.status!=3 GROUP BY tag_id
+	sql := "SELECT a.tag_id as tag_id, count(a.id) as count FROM `fafacms_content_tag` a INNER JOIN `fafacms_content` b ON a.content_id=b.id WHERE a.user_id=? and b.version>0 and b.status!=1 and b.status!=3 GROUP BY a.tag_id"
+	result, err := FaFaRdb.Client.QueryString(sql, userId)
+	if err != nil {
+		return err
+	}
+
+	counts := make(map[int64]int64)
+	for _, v := range result {
+		id, _ := util.SI(v["tag_id"])
+		num, _ := util.SI(v["count"])
+		counts[int64(id)] = int64(num)
+	}
+
+	tags := make([]Tag, 0)
+	err = FaFaRdb.Client.Where("user_id=?", userId).Cols("id", "content_num").Find(&tags)
+	if err != nil {
+		return err
+	}
+
+	for _, t := range tags {
+		if t.ContentNum == counts[t.Id] {
+			continue
+		}
+
+		t.ContentNum = counts[t.Id]
+		_, err = FaFaRdb.Client.Where("id=?", t.Id).Cols("content_num").Update(&t)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type TagCloud struct {
+	Name       string `json:"name"`
+	ContentNum int64  `json:"content_num"`
+}
+
+// Site wide tag cloud, same name put together
+func TagClouds(limit int) ([]TagCloud, error) {
+	clouds := make([]TagCloud, 0)
+	sql := fmt.Sprintf("SELECT name, sum(content_num) as num FROM `fafacms_tag` WHERE status=0 and content_num>0 GROUP BY name ORDER BY num desc, name asc LIMIT %d", limit)
+	result, err := FaFaRdb.Client.QueryString(sql)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, v := range result {
+		num, _ := util.SI(v["num"])
+		clouds = append(clouds, TagCloud{Name: v["name"], ContentNum: int64(num)})
+	}
+	return clouds, nil
+}
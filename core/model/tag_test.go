@@ -0,0 +1,30 @@
+package model
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCleanTagNames(t *testing.T) {
+	names, err := CleanTagNames([]string{" go ", "go", "", "数据库", "go ", "Go", "GO"})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if !reflect.DeepEqual(names, []string{"go", "数据库"}) {
+		t.Fatalf("names %v wrong", names)
+	}
+
+	if _, err := CleanTagNames([]string{strings.Repeat("长", 31)}); err == nil {
+		t.Fatal("too long tag should wrong")
+	}
+
+	many := make([]string, 0)
+	for i := 0; i <= TagMaxNumOfContent; i++ {
+		many = append(many, strings.Repeat("a", i+1))
+	}
+	if _, err := CleanTagNames(many); err == nil {
+		t.Fatal("too many tag should wrong")
+	}
+}
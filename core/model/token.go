@@ -0,0 +1,84 @@
+package model
+
+import (
+	"errors"
+	"strings"
+	"time"
+)
+
+// Personal access token, for script and CI, secret only show once when create
+type AccessToken struct {
+	Id           int64  `json:"id" xorm:"bigint pk autoincr"`
+	UserId       int64  `json:"user_id" xorm:"bigint index"`
+	Name         string `json:"name" xorm:"varchar(100) notnull"`
+	TokenPrefix  string `json:"token_prefix" xorm:"varchar(20)"`      // show to user which token it is
+	TokenHash    string `json:"-" xorm:"varchar(100) notnull unique"` // sha256 of token, raw token not save
+	Scopes       string `json:"scopes" xorm:"varchar(700)"`           // split by comma, such as content:write,file:upload
+	ExpireTime   int64  `json:"expire_time"`                          // 0 never expire
+	CreateTime   int64  `json:"create_time"`
+	LastUsedTime int64  `json:"last_used_time"`
+	LastUsedIp   string `json:"last_used_ip"`
+	Status       int    `json:"status" xorm:"notnull default(0) comment('0 normal, 1 revoke') TINYINT(1) index"`
+	RevokeTime   int64  `json:"revoke_time,omitempty"`
+}
+
+var AccessTokenSortName = []string{"=id", "-create_time", "=last_used_time", "=expire_time", "=status"}
+
+func (t *AccessToken) Insert() error {
+	t.CreateTime = time.Now().Unix()
+	_, err := FaFaRdb.Client.InsertOne(t)
+	return err
+}
+
+func (t *AccessToken) GetByHash() (bool, error) {
+	if t.TokenHash == "" {
+		return false, errors.New("where is empty")
+	}
+	return FaFaRdb.Client.Get(t)
+}
+
+func (t *AccessToken) ScopeList() []string {
+	if t.Scopes == "" {
+		return []string{}
+	}
+	return strings.Split(t.Scopes, ",")
+}
+
+func (t *AccessToken) IsValid() bool {
+	if t.Status != 0 {
+		return false
+	}
+
+	if t.ExpireTime != 0 && t.ExpireTime < time.Now().Unix() {
+		return false
+	}
+
+	return true
+}
+
+// Record the last used ip and time, not every request update
+func (t *AccessToken) UpdateLastUsed(ip string) error {
+	if t.Id == 0 {
+		return errors.New("where is empty")
+	}
+
+	now := time.Now().Unix()
+	if now-t.LastUsedTime < 60 && ip == t.LastUsedIp {
+		return nil
+	}
+
+	t.LastUsedTime = now
+	t.LastUsedIp = ip
+	_, err := FaFaRdb.Client.Where("id=?", t.Id).Cols("last_used_time", "last_used_ip").Update(t)
+	return err
+}
+
+func (t *AccessToken) Revoke() (int64, error) {
+	if t.Id == 0 || t.UserId == 0 {
+		return 0, errors.New("where is empty")
+	}
+
+	t.Status = 1
+	t.RevokeTime = time.Now().Unix()
+	return FaFaRdb.Client.Where("id=?", t.Id).And("user_id=?", t.UserId).And("status=?", 0).Cols("status", "revoke_time").Update(t)
+}
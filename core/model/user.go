@@ -1,9 +1,11 @@
 package model
 
 import (
+	"crypto/subtle"
 	"errors"
 	"fmt"
 	"github.com/hunterhug/fafacms/core/util"
+	"strings"
 	"time"
 )
 
@@ -28,16 +30,25 @@ type User struct {
 	ActivateCode        string `json:"activate_code,omitempty" xorm:"index"` // activate code
 	ActivateCodeExpired int64  `json:"activate_code_expired,omitempty"`      // activate code expired time
 	Status              int    `json:"status" xorm:"notnull default(0) comment('0 un active, 1 normal, 2 black') TINYINT(1) index"`
-	GroupId             int64  `json:"group_id,omitempty" xorm:"bigint index"`
-	ResetCode           string `json:"reset_code,omitempty" xorm:"index"` // forget password code
-	ResetCodeExpired    int64  `json:"reset_code_expired,omitempty"`      // forget password code expired
-	LoginTime           int64  `json:"login_time,omitempty"`              // login time last time
-	LoginIp             string `json:"login_ip,omitempty"`                // login ip last time
-	Vip                 int    `json:"vip"`                               // only vip can op node and content
+	GroupId             int64  `json:"group_id,omitempty" xorm:"bigint index"` // not use, user group move to user_group table
+	ResetCode           string `json:"reset_code,omitempty" xorm:"index"`      // forget password code
+	ResetCodeExpired    int64  `json:"reset_code_expired,omitempty"`           // forget password code expired
+	LoginTime           int64  `json:"login_time,omitempty"`                   // login time last time
+	LoginIp             string `json:"login_ip,omitempty"`                     // login ip last time
+	Vip                 int    `json:"vip"`                                    // only vip can op node and content
 	FollowedNum         int64  `json:"followed_num" xorm:"notnull default(0)"`
 	FollowingNum        int64  `json:"following_num" xorm:"notnull default(0)"`
 	ContentNum          int64  `json:"content_num" xorm:"notnull default(0)"`      // normal publish content num
 	ContentCoolNum      int64  `json:"content_cool_num" xorm:"notnull default(0)"` // normal content cool num
+	IsSuperAdmin        int    `json:"is_super_admin" xorm:"notnull default(0) comment('0 normal, 1 super admin') TINYINT(1) index"`
+	TwoFactorEnable     int    `json:"two_factor_enable" xorm:"notnull default(0) comment('0 close, 1 open') TINYINT(1)"`
+	TwoFactorSecret     string `json:"-" xorm:"varchar(100)"`                      // totp secret, not confirm when enable is 0
+	TwoFactorRecovery   string `json:"-" xorm:"TEXT"`                              // sha256 of one time recovery codes, split by comma
+	FailAttemptNum      int64  `json:"fail_attempt_num" xorm:"notnull default(0)"` // password or reset code wrong times
+	FailAttemptTime     int64  `json:"fail_attempt_time,omitempty"`                // last fail time
+	FailAttemptIp       string `json:"fail_attempt_ip,omitempty"`                  // last fail ip
+	FeedFullText        int    `json:"feed_full_text" xorm:"notnull default(0) comment('0 excerpt, 1 full text') TINYINT(1)"`
+	Theme               string `json:"theme" xorm:"varchar(100)"` // theme of the html page, empty use the site theme
 }
 
 var UserSortName = []string{"=id", "=name", "-vip", "-activate_time", "=followed_num", "=following_num", "=content_num", "=content_cool_num", "=create_time", "=update_time", "=gender"}
@@ -194,6 +205,37 @@ func (u *User) UpdatePassword() error {
 	return err
 }
 
+// Hash the raw password and keep it in the struct, never save plain text
+func (u *User) SetPassword(raw string) error {
+	hashed, err := util.HashPassword(raw)
+	if err != nil {
+		return err
+	}
+
+	u.Password = hashed
+	return nil
+}
+
+// Check the raw password, rehash is true when the row still save a plain text or weak hash
+func (u *User) CheckPassword(raw string) (ok bool, rehash bool) {
+	return util.CheckPassword(raw, u.Password)
+}
+
+// Rehash the password after login, legacy plain text row will migrate one by one
+func (u *User) UpdatePasswordHash(raw string) error {
+	if u.Id == 0 {
+		return errors.New("where is empty")
+	}
+
+	err := u.SetPassword(raw)
+	if err != nil {
+		return err
+	}
+
+	_, err = FaFaRdb.Client.Where("id=?", u.Id).Cols("password").Update(u)
+	return err
+}
+
 func (u *User) UpdateInfo() error {
 	if u.Id == 0 {
 		return errors.New("where is empty")
@@ -214,6 +256,26 @@ func (u *User) UpdateInfoMustVip() error {
 	return err
 }
 
+func (u *User) UpdateTheme() error {
+	if u.Id == 0 {
+		return errors.New("where is empty")
+	}
+
+	u.UpdateTime = time.Now().Unix()
+	_, err := FaFaRdb.Client.Where("id=?", u.Id).Cols("theme", "update_time").Update(u)
+	return err
+}
+
+func (u *User) UpdateFeedFullText() error {
+	if u.Id == 0 {
+		return errors.New("where is empty")
+	}
+
+	u.UpdateTime = time.Now().Unix()
+	_, err := FaFaRdb.Client.Where("id=?", u.Id).Cols("feed_full_text", "update_time").Update(u)
+	return err
+}
+
 func (u *User) UpdateLoginInfo() error {
 	if u.Id == 0 {
 		return errors.New("where is empty")
@@ -223,6 +285,108 @@ func (u *User) UpdateLoginInfo() error {
 	return err
 }
 
+// Record the wrong password or reset code, so suspicious activity can be visible
+func (u *User) RecordFailAttempt(ip string) error {
+	if u.Id == 0 {
+		return errors.New("where is empty")
+	}
+
+	u.FailAttemptTime = time.Now().Unix()
+	u.FailAttemptIp = ip
+	_, err := FaFaRdb.Client.Where("id=?", u.Id).Incr("fail_attempt_num").Cols("fail_attempt_time", "fail_attempt_ip").Update(u)
+	return err
+}
+
+// Set or cancel the super admin, only command line can do it
+func (u *User) UpdateSuperAdmin() error {
+	if u.Id == 0 {
+		return errors.New("where is empty")
+	}
+
+	u.UpdateTime = time.Now().Unix()
+	_, err := FaFaRdb.Client.Where("id=?", u.Id).Cols("is_super_admin", "update_time").Update(u)
+	return err
+}
+
+// Table create before seed a user admin with password admin, the one still use it get a random password,
+// return the id to destroy the session, admin can change the password for it later
+func MigrateSeedAdmin() (int64, error) {
+	u := new(User)
+	exist, err := FaFaRdb.Client.Where("name=?", "admin").And("email=?", "admin@admin").Get(u)
+	if err != nil || !exist {
+		return 0, err
+	}
+
+	if ok, _ := u.CheckPassword("admin"); !ok {
+		return 0, nil
+	}
+
+	err = u.SetPassword(util.GetGUID())
+	if err != nil {
+		return 0, err
+	}
+
+	err = u.UpdatePassword()
+	if err != nil {
+		return 0, err
+	}
+	return u.Id, nil
+}
+
+func SuperAdminCount() (int64, error) {
+	return FaFaRdb.Client.Where("is_super_admin=?", 1).Count(new(User))
+}
+
+// Save the totp secret and recovery codes, enable is 0 means waiting confirm
+func (u *User) UpdateTwoFactor() error {
+	if u.Id == 0 {
+		return errors.New("where is empty")
+	}
+
+	u.UpdateTime = time.Now().Unix()
+	_, err := FaFaRdb.Client.Where("id=?", u.Id).Cols("two_factor_enable", "two_factor_secret", "two_factor_recovery", "update_time").Update(u)
+	return err
+}
+
+// Use one recovery code, it will be remove after used
+func (u *User) UseTwoFactorRecovery(code string) (bool, error) {
+	if u.Id == 0 {
+		return false, errors.New("where is empty")
+	}
+
+	h, err := util.Sha256([]byte(strings.TrimSpace(code)))
+	if err != nil {
+		return false, nil
+	}
+
+	codes := strings.Split(u.TwoFactorRecovery, ",")
+	remain := make([]string, 0, len(codes))
+	found := false
+	for _, v := range codes {
+		if v == "" {
+			continue
+		}
+		if !found && subtle.ConstantTimeCompare([]byte(v), []byte(h)) == 1 {
+			found = true
+			continue
+		}
+		remain = append(remain, v)
+	}
+
+	if !found {
+		return false, nil
+	}
+
+	// old recovery must the same, one code can not use twice
+	num, err := FaFaRdb.Client.Table(u).Where("id=?", u.Id).And("two_factor_recovery=?", u.TwoFactorRecovery).Update(map[string]interface{}{"two_factor_recovery": strings.Join(remain, ",")})
+	if err != nil {
+		return false, err
+	}
+
+	u.TwoFactorRecovery = strings.Join(remain, ",")
+	return num == 1, nil
+}
+
 func UserAllExist(userIds []int64) bool {
 	num, _ := FaFaRdb.Client.Where("status!=?", 0).In("id", userIds).Count(new(User))
 	return len(userIds) == int(num)
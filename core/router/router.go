@@ -10,6 +10,7 @@ type HttpHandle struct {
 	Func   gin.HandlerFunc
 	Method []string
 	Admin  bool
+	Scope  string // personal access token need this scope, empty can not use token
 }
 
 var (
@@ -22,128 +23,205 @@ var (
 var (
 	HomeRouter = map[string]HttpHandle{
 		// Home Router, not need auth
-		"/": {"Home", controllers.Home, GP, false},
-
-		"/u":               {"List Peoples", controllers.Peoples, GP, false},                    // 列出用户
-		"/u/node":          {"List User Nodes One", controllers.NodeInfo, GP, false},            // 查找某一个节点
-		"/u/nodes":         {"List User Nodes", controllers.NodesInfo, GP, false},               // 列出某用户下的节点
-		"/u/info":          {"List User Info", controllers.UserInfo, GP, false},                 // 获取某用户信息
-		"/u/count":         {"Count User Content", controllers.UserCount, GP, false},            // 统计某用户文章情况（某用户可留空）
-		"/u/content":       {"List User Content", controllers.Contents, GP, false},              // 列出某用户下文章（某用户可留空）
-		"/content":         {"Get Content", controllers.Content, GP, false},                     // 获取文章
-		"/content/comment": {"List Comment of Content", controllers.ListHomeComment, GP, false}, // 列出文章下的评论
-
-		"/user/token/get":       {"User Token get", controllers.Login, GP, false},
-		"/user/token/refresh":   {"User Token refresh", controllers.Refresh, GP, false},
-		"/user/token/delete":    {"User Token delete", controllers.Logout, GP, false},
-		"/user/register":        {"User Register", controllers.RegisterUser, GP, false},
-		"/user/activate":        {"User Verify Email To Activate", controllers.ActivateUser, GP, false},               // 用户自己激活
-		"/user/activate/code":   {"User Resend Email Activate Code", controllers.ResendActivateCodeToUser, GP, false}, // 激活码过期重新获取
-		"/user/password/forget": {"User Forget Password Gen Code", controllers.ForgetPasswordOfUser, GP, false},       // 忘记密码，验证码发往邮箱
-		"/user/password/change": {"User Change Password", controllers.ChangePasswordOfUser, GP, false},                // 根据邮箱验证码修改密码
+		"/": {"Home", controllers.Home, GP, false, ""},
+
+		"/u":                {"List Peoples", controllers.Peoples, GP, false, ""},                    // 列出用户
+		"/u/node":           {"List User Nodes One", controllers.NodeInfo, GP, false, ""},            // 查找某一个节点
+		"/u/nodes":          {"List User Nodes", controllers.NodesInfo, GP, false, ""},               // 列出某用户下的节点
+		"/u/info":           {"List User Info", controllers.UserInfo, GP, false, ""},                 // 获取某用户信息
+		"/u/count":          {"Count User Content", controllers.UserCount, GP, false, ""},            // 统计某用户文章情况（某用户可留空）
+		"/u/content":        {"List User Content", controllers.Contents, GP, false, ""},              // 列出某用户下文章（某用户可留空）
+		"/content":          {"Get Content", controllers.Content, GP, false, ""},                     // 获取文章
+		"/content/comment":  {"List Comment of Content", controllers.ListHomeComment, GP, false, ""}, // 列出文章下的评论
+		"/search":           {"Search", controllers.Search, GP, false, ""},                           // 全文搜索文章、用户和评论，按相关度排序
+		"/tags":             {"List Tags", controllers.Tags, GP, false, ""},                          // 标签云，某用户可留空则为全站
+		"/tag/content":      {"List Content of Tag", controllers.TagContents, GP, false, ""},         // 列出标签下的文章（某用户可留空）
+		"/feed.xml":         {"Site Feed RSS", controllers.FeedSite, GET, false, ""},                 // 全站订阅，RSS 2.0
+		"/feed.atom":        {"Site Feed Atom", controllers.FeedSite, GET, false, ""},                // 全站订阅，Atom
+		"/feed.json":        {"Site Feed JSON", controllers.FeedSite, GET, false, ""},                // 全站订阅，JSON Feed
+		"/feed/:name":       {"User Feed", controllers.FeedUser, GET, false, ""},                     // 某用户订阅，如 /feed/hunterhug.xml，后缀 xml，atom，json
+		"/feed/:name/:node": {"Node Feed", controllers.FeedNode, GET, false, ""},                     // 某用户节点订阅，如 /feed/hunterhug/golang.atom
+		"/sitemap.xml":      {"Site Sitemap", controllers.Sitemap, GET, false, ""},                   // 全站站点地图，超过五万链接为索引，分页 /sitemap.xml?page=1
+		"/sitemap/:name":    {"User Sitemap", controllers.SitemapUser, GET, false, ""},               // 某用户站点地图，如 /sitemap/hunterhug.xml
+		"/robots.txt":       {"Robots", controllers.RobotsTxt, GET, false, ""},                       // 爬虫协议
+		"/themes":           {"List Themes", controllers.Themes, GP, false, ""},                      // 可选的页面主题，浏览器访问首页接口时渲染为HTML
+
+		"/captcha/new":          {"Get Captcha", controllers.NewCaptcha, GP, false, ""}, // 获取验证码，60秒有效，验证一次后失效
+		"/user/token/get":       {"User Token get", controllers.Login, GP, false, ""},
+		"/user/token/refresh":   {"User Token refresh", controllers.Refresh, GP, false, ""},
+		"/user/token/delete":    {"User Token delete", controllers.Logout, GP, false, ""},
+		"/user/token/2fa":       {"User Token get Two Factor", controllers.LoginTwoFactor, GP, false, ""}, // 开启两步验证后，用挑战码和动态码获取令牌
+		"/user/register":        {"User Register", controllers.RegisterUser, GP, false, ""},
+		"/user/activate":        {"User Verify Email To Activate", controllers.ActivateUser, GP, false, ""},               // 用户自己激活
+		"/user/activate/code":   {"User Resend Email Activate Code", controllers.ResendActivateCodeToUser, GP, false, ""}, // 激活码过期重新获取
+		"/user/password/forget": {"User Forget Password Gen Code", controllers.ForgetPasswordOfUser, GP, false, ""},       // 忘记密码，验证码发往邮箱
+		"/user/password/change": {"User Change Password", controllers.ChangePasswordOfUser, GP, false, ""},                // 根据邮箱验证码修改密码
 	}
 
 	// /v1/user/create
 	// need login group auth
 	V1Router = map[string]HttpHandle{
 		// 用户组操作
-		"/group/create":        {"Create Group", controllers.CreateGroup, POST, true},
-		"/group/update":        {"Update Group", controllers.UpdateGroup, POST, true},
-		"/group/delete":        {"Delete Group", controllers.DeleteGroup, POST, true},
-		"/group/take":          {"Take Group", controllers.TakeGroup, GP, true},
-		"/group/list":          {"List Group", controllers.ListGroup, GP, true},
-		"/group/user/list":     {"Group List User", controllers.ListGroupUser, GP, true},         // 超级管理员列出组下的用户
-		"/group/resource/list": {"Group List Resource", controllers.ListGroupResource, GP, true}, // 超级管理员列出组下的资源
+		"/group/create":        {"Create Group", controllers.CreateGroup, POST, true, "admin"},
+		"/group/update":        {"Update Group", controllers.UpdateGroup, POST, true, "admin"},
+		"/group/update/review": {"Update Group Review", controllers.UpdateReviewOfGroup, POST, true, "admin"}, // 组内用户发布文章是否需要审核
+		"/group/delete":        {"Delete Group", controllers.DeleteGroup, POST, true, "admin"},
+		"/group/take":          {"Take Group", controllers.TakeGroup, GP, true, "admin"},
+		"/group/list":          {"List Group", controllers.ListGroup, GP, true, "admin"},
+		"/group/user/list":     {"Group List User", controllers.ListGroupUser, GP, true, "admin"},         // 超级管理员列出组下的用户
+		"/group/resource/list": {"Group List Resource", controllers.ListGroupResource, GP, true, "admin"}, // 超级管理员列出组下的资源
 
 		// 用户操作
-		"/user/list":         {"User List All", controllers.ListUser, GP, true},              // 超级管理员列出用户列表
-		"/user/create":       {"User Create", controllers.CreateUser, GP, true},              // 超级管理员创建用户，默认激活
-		"/user/assign":       {"User Assign Group", controllers.AssignGroupToUser, GP, true}, // 超级管理员给用户分配用户组
-		"/user/update":       {"User Update Self", controllers.UpdateUser, GP, false},        // 更新自己的信息
-		"/user/admin/update": {"User Update Admin", controllers.UpdateUserAdmin, GP, true},   // 管理员修改其他用户信息，可以修改用户密码，以及将用户加入黑名单，禁止使用等
-		"/user/info":         {"User Info Self", controllers.TakeUser, GP, false},            // 获取自己的信息
+		"/user/list":           {"User List All", controllers.ListUser, GP, true, "admin"},                      // 超级管理员列出用户列表
+		"/user/create":         {"User Create", controllers.CreateUser, GP, true, "admin"},                      // 超级管理员创建用户，默认激活
+		"/user/assign":         {"User Assign Group", controllers.AssignGroupToUser, GP, true, "admin"},         // 超级管理员给用户分配用户组
+		"/user/update":         {"User Update Self", controllers.UpdateUser, GP, false, "user:write"},           // 更新自己的信息
+		"/user/admin/update":   {"User Update Admin", controllers.UpdateUserAdmin, GP, true, "admin"},           // 管理员修改其他用户信息，可以修改用户密码，以及将用户加入黑名单，禁止使用等
+		"/user/attempt/clear":  {"User Attempt Lock Clear", controllers.ClearAttemptAdmin, POST, true, "admin"}, // 管理员解除账号或IP的登录锁定
+		"/user/permissions":    {"User Permissions Self", controllers.UserPermissions, GP, false, "user:read"},  // 获取自己可以访问的接口及方法
+		"/user/info":           {"User Info Self", controllers.TakeUser, GP, false, "user:read"},                // 获取自己的信息
+		"/user/2fa/enroll":     {"User Two Factor Enroll", controllers.EnrollTwoFactor, POST, false, ""},        // 生成两步验证密钥
+		"/user/2fa/confirm":    {"User Two Factor Confirm", controllers.ConfirmTwoFactor, POST, false, ""},      // 确认动态码后开启两步验证，返回恢复码
+		"/user/2fa/disable":    {"User Two Factor Disable", controllers.DisableTwoFactor, POST, false, ""},      // 关闭两步验证
+		"/user/session/list":   {"User Session List", controllers.ListUserSession, GP, false, "user:read"},      // 列出自己已登录的设备
+		"/user/session/revoke": {"User Session Revoke", controllers.RevokeUserSession, POST, false, ""},         // 下线某个设备，或下线除当前设备外的所有设备
 
 		// 资源操作
-		"/resource/list":   {"Resource List All", controllers.ListResource, GP, true},              // 列出资源
-		"/resource/assign": {"Resource Assign Group", controllers.AssignResourceToGroup, GP, true}, // 资源分配给组
+		"/resource/list":   {"Resource List All", controllers.ListResource, GP, true, "admin"},              // 列出资源
+		"/resource/assign": {"Resource Assign Group", controllers.AssignResourceToGroup, GP, true, "admin"}, // 资源批量分配给组或撤销，可指定方法和拒绝规则
+		"/resource/create": {"Resource Create", controllers.CreateResource, GP, true, "admin"},              // 创建通配资源，如 /v1/content/*
+		"/resource/delete": {"Resource Delete", controllers.DeleteResource, GP, true, "admin"},              // 删除没有组使用的通配资源
+
+		// 内容标签
+		"/tag/list":         {"Tag List Self", controllers.ListTag, GP, false, "content:read"},
+		"/tag/rename":       {"Tag Rename Self", controllers.RenameTag, POST, false, "content:write"},    // 重命名标签，名字已存在需合并
+		"/tag/merge":        {"Tag Merge Self", controllers.MergeTag, POST, false, "content:write"},      // 合并多个标签到一个标签
+		"/tag/delete":       {"Tag Delete Self", controllers.DeleteTag, POST, false, "content:write"},    // 删除标签，文章不删除
+		"/tag/admin/list":   {"Tag List All", controllers.ListTagAdmin, GP, true, "admin"},               // 管理员查看所有标签
+		"/tag/admin/update": {"Tag Update All", controllers.UpdateStatusOfTagAdmin, POST, true, "admin"}, // 管理员违禁标签，违禁标签前端不显示
+		"/tag/admin/delete": {"Tag Delete All", controllers.DeleteTagAdmin, POST, true, "admin"},         // 管理员删除标签
+
+		// 全文搜索
+		"/search":         {"Search", controllers.Search, GP, false, "content:read"},                // 登录后全文搜索
+		"/search/rebuild": {"Search Index Rebuild", controllers.RebuildSearch, POST, true, "admin"}, // 管理员重建搜索索引
+
+		// 审计日志
+		"/log/list": {"Log List All", controllers.ListLog, GP, true, "admin"}, // 按用户、地址、错误码、Cid和时间范围查询审计日志
 
 		// 文件操作
-		"/file/upload":       {"File Upload", controllers.UploadFile, POST, false},
-		"/file/list":         {"File List Self", controllers.ListFile, POST, false},
-		"/file/admin/list":   {"File List All", controllers.ListFileAdmin, POST, true}, // 管理员查看所有文件
-		"/file/update":       {"File Update Self", controllers.UpdateFile, POST, false},
-		"/file/admin/update": {"File Update All", controllers.UpdateFileAdmin, POST, true}, // 管理员修改文件
+		"/file/upload":       {"File Upload", controllers.UploadFile, POST, false, "file:upload"},
+		"/file/list":         {"File List Self", controllers.ListFile, POST, false, "file:read"},
+		"/file/admin/list":   {"File List All", controllers.ListFileAdmin, POST, true, "admin"}, // 管理员查看所有文件
+		"/file/update":       {"File Update Self", controllers.UpdateFile, POST, false, "file:write"},
+		"/file/admin/update": {"File Update All", controllers.UpdateFileAdmin, POST, true, "admin"}, // 管理员修改文件
 
 		// 比较重要的, 节点和文章都应该支持拖曳，文章首页排序还是按照创建时间，但是后台使用排序字段
 		// 内容节点操作
-		"/node/create":        {"Create Node Self", controllers.CreateNode, POST, false},
-		"/node/update/seo":    {"Update Node Self Seo", controllers.UpdateSeoOfNode, POST, false},          // 更新节点SEO
-		"/node/update/info":   {"Update Node Self Info", controllers.UpdateInfoOfNode, POST, false},        // 更新节点名字和描述
-		"/node/update/image":  {"Update Node Self Info Image", controllers.UpdateImageOfNode, POST, false}, // 更新图片地址
-		"/node/update/status": {"Update Node Self Status", controllers.UpdateStatusOfNode, POST, false},    // 更新状态，可以设置隐藏
-		"/node/update/parent": {"Update Node Self Parent", controllers.UpdateParentOfNode, POST, false},    // 这个接口不如下面这个全功能的接口
-		"/node/sort":          {"Sort Node Self", controllers.SortNode, POST, false},                       // 拖曳超级函数
-		"/node/delete":        {"Delete Node Self", controllers.DeleteNode, POST, false},
-
-		"/node/take":       {"Take Node Self", controllers.TakeNode, GP, false}, //  和前端的那部分一毛一样
-		"/node/list":       {"List Node Self", controllers.ListNode, GP, false},
-		"/node/admin/list": {"List Node All", controllers.ListNodeAdmin, GP, true}, // 管理员查看其他用户节点
+		"/node/create":        {"Create Node Self", controllers.CreateNode, POST, false, "node:write"},
+		"/node/update/seo":    {"Update Node Self Seo", controllers.UpdateSeoOfNode, POST, false, "node:write"},          // 更新节点SEO
+		"/node/update/info":   {"Update Node Self Info", controllers.UpdateInfoOfNode, POST, false, "node:write"},        // 更新节点名字和描述
+		"/node/update/image":  {"Update Node Self Info Image", controllers.UpdateImageOfNode, POST, false, "node:write"}, // 更新图片地址
+		"/node/update/status": {"Update Node Self Status", controllers.UpdateStatusOfNode, POST, false, "node:write"},    // 更新状态，可以设置隐藏
+		"/node/update/parent": {"Update Node Self Parent", controllers.UpdateParentOfNode, POST, false, "node:write"},    // 这个接口不如下面这个全功能的接口
+		"/node/update/review": {"Update Node Self Review", controllers.UpdateReviewOfNode, POST, false, "node:write"},    // 节点及子节点下的文章发布前是否需要审核
+		"/node/sort":          {"Sort Node Self", controllers.SortNode, POST, false, "node:write"},                       // 拖曳超级函数
+		"/node/delete":        {"Delete Node Self", controllers.DeleteNode, POST, false, "node:write"},
+
+		"/node/take":       {"Take Node Self", controllers.TakeNode, GP, false, "node:read"}, //  和前端的那部分一毛一样
+		"/node/list":       {"List Node Self", controllers.ListNode, GP, false, "node:read"},
+		"/node/admin/list": {"List Node All", controllers.ListNodeAdmin, GP, true, "admin"}, // 管理员查看其他用户节点
+
+		// 节点协作
+		"/node/collaborator/add":    {"Add Node Collaborator Self", controllers.AddCollaborator, POST, false, "node:write"},       // 把节点共享给其他用户，角色为查看、编辑或发布，子节点一样生效，再次添加可修改角色
+		"/node/collaborator/delete": {"Delete Node Collaborator Self", controllers.DeleteCollaborator, POST, false, "node:write"}, // 节点主人移除协作者，或协作者自己退出
+		"/node/collaborator/list":   {"List Node Collaborator Self", controllers.ListCollaborator, GP, false, "node:read"},        // 列出共享给谁了，或者谁共享给了你
 
 		// 内容操作
-		"/content/create":              {"Create Content Self", controllers.CreateContent, POST, false},                             // 创建文章内容(必须归属一个节点)
-		"/content/update/seo":          {"Update Content Self Seo", controllers.UpdateSeoOfContent, POST, false},                    // 更新内容SEO
-		"/content/update/image":        {"Update Content Self Image", controllers.UpdateImageOfContent, POST, false},                // 更新内容图片
-		"/content/update/status":       {"Update Content Self Status", controllers.UpdateStatusOfContent, POST, false},              // 更新内容的状态，如设置隐藏
-		"/content/admin/update/status": {"Update Content All Status", controllers.UpdateStatusOfContentAdmin, POST, true},           // 超级管理员修改文章，比如禁用或者逻辑删除/恢复文章
-		"/content/update/node":         {"Update Content Self Node", controllers.UpdateNodeOfContent, POST, false},                  // 更改内容的节点，顺便需要重新排序
-		"/content/update/top":          {"Update Content Self Top", controllers.UpdateTopOfContent, POST, false},                    // 设置内容的置顶与否
-		"/content/update/comment":      {"Update Content Self Comment", controllers.UpdateCommentOfContent, POST, false},            // 设置内容可以评论与否
-		"/content/update/password":     {"Update Content Self Password", controllers.UpdatePasswordOfContent, POST, false},          // 更改内容的密码保护
-		"/content/update/info":         {"Update Content Self Info", controllers.UpdateInfoOfContent, POST, false},                  // 更新内容标题和内容
-		"/content/sort":                {"Sort Content Self", controllers.SortContent, POST, false},                                 // 对内容进行拖曳排序
-		"/content/publish":             {"Publish Content Self", controllers.PublishContent, POST, false},                           // 将预览刷进另外一个字段
-		"/content/restore":             {"Restore Content Self", controllers.RestoreContent, POST, false},                           // 恢复历史，刷回来
-		"/content/rubbish":             {"Sent Content Self To Rubbish", controllers.SentContentToRubbish, POST, false},             // 一般回收站
-		"/content/recycle":             {"Sent Rubbish Content Self To Origin", controllers.ReCycleOfContentInRubbish, POST, false}, // 一般回收站恢复
-		"/content/delete":              {"Delete Content Self Real", controllers.ReallyDeleteContent, POST, false},                  // 逻辑删除文章 已经修正为真删除
-		"/content/take":                {"Take Content Self", controllers.TakeContent, GP, false},                                   // 获取文章内容
-		"/content/admin/take":          {"Take Content Admin", controllers.TakeContentAdmin, GP, true},                              // 管理员获取文章内容
-		"/content/history/take":        {"Take Content History Self", controllers.TakeContentHistory, GP, false},                    // 获取文章历史内容
-		"/content/history/admin/take":  {"Take Content History Admin", controllers.TakeContentHistoryAdmin, GP, true},               // 管理员获取文章历史内容
-		"/content/list":                {"List Content Self", controllers.ListContent, GP, false},                                   // 列出文章
-		"/content/admin/list":          {"List Content All", controllers.ListContentAdmin, GP, true},                                // 管理员列出文章，什么类型都可以
-		"/content/history/list":        {"List Content History Self", controllers.ListContentHistory, GP, false},                    // 列出文章的历史记录
-		"/content/history/admin/list":  {"List Content History All", controllers.ListContentHistoryAdmin, GP, true},                 // 管理员列出文章的历史纪录
-		"/content/history/delete":      {"Delete Content History Self Real", controllers.ReallyDeleteHistoryContent, POST, false},   // 真删除历史内容
-		"/content/cool":                {"Cool the Content Self", controllers.CoolContent, GP, false},                               // 点赞内容
-		"/content/bad":                 {"Bad the Content Self", controllers.BadContent, GP, false},                                 // 举报内容
-		"/comment/create":              {"Create the Comment Self", controllers.CreateComment, POST, false},                         // 创建评论
-		"/comment/real/name":           {"Real Name the Comment Self", controllers.RealNameComment, POST, false},                    // 评论取消匿名
-		"/comment/delete":              {"Delete the Comment Self", controllers.DeleteComment, POST, false},                         // 删除评论，逻辑删除
-		"/comment/take":                {"Take the Comment Self", controllers.TakeComment, GP, false},                               // 获取评论
-		"/comment/cool":                {"Cool the Comment Self", controllers.CoolComment, GP, false},                               // 点赞评论
-		"/comment/bad":                 {"Bad the Comment Self", controllers.BadComment, GP, false},                                 // 举报评论
-		"/comment/admin/list":          {"List the Comment Admin", controllers.ListComment, GP, true},                               // 管理员列出评论
-		"/comment/admin/update/status": {"Update the Comment Status Admin", controllers.UpdateComment, GP, true},                    // 管理员评论违禁处理
-
-		"/relation/follow/add":     {"Follow add Who", controllers.AddRelation, GP, false},                    // 关注
-		"/relation/follow/minute":  {"Follow Minute Who", controllers.MinuteRelation, GP, false},              // 关注解除
-		"/relation/followed/me":    {"List Who Follow You", controllers.ListFollowedRelationOfMe, GP, false},  // 查看谁关注了你
-		"/relation/following/me":   {"List You Follow Who", controllers.ListFollowingRelationOfMe, GP, false}, // 查看你关注了谁
-		"/relation/followed/list":  {"List Who Follow You", controllers.ListFollowedRelation, GP, false},      // 查看谁关注了用户B，然后你和谁的关系
-		"/relation/following/list": {"List You Follow Who", controllers.ListFollowingRelation, GP, false},     // 查看用户A关注了谁，然后你和谁的关系
-		"/relation/admin/list":     {"List Who Follow Who Admin", controllers.ListAllRelation, GP, true},      // 查看所有关系
-
-		"/message/list":                       {"List Your Message Can Include Private Message", controllers.ListMessage, GP, false}, // 列出自己的系统消息，包括与其他用户间的私信
-		"/message/admin/list":                 {"List All Message", controllers.ListAllMessage, GP, false},                           // 管理员列出所有系统消息
-		"/message/read":                       {"Read Your Message", controllers.ReadMessage, GP, false},                             // 读取系统消息
-		"/message/delete":                     {"Delete Your Message", controllers.DeleteMessage, GP, false},                         // 删除系统消息
-		"/message/admin/global/create":        {"Admin Create Global Message", controllers.CreateGlobalMessage, GP, true},            // 管理员创建全局站内信
-		"/message/admin/global/list":          {"Admin List Global Message", controllers.ListGlobalMessage, GP, true},                // 管理员列出全局站内信
-		"/message/admin/global/update/status": {"Admin Change Global Message Status", controllers.UpdateGlobalMessage, GP, true},     // 管理员更改全局站内信状态
-
-		"/message/private/send":   {"Send Message To Private People", controllers.SendPrivateMessage, GP, false}, // 私信
-		"/message/private/delete": {"Delete Message Has Sent", controllers.DeletePrivateMessage, GP, false},      // 删除自己发出的私信，但收件方还是可以看到
+		"/content/create":              {"Create Content Self", controllers.CreateContent, POST, false, "content:write"},                             // 创建文章内容(必须归属一个节点)
+		"/content/import":              {"Import Content Self", controllers.ImportContent, POST, false, "content:write"},                             // 导入Markdown压缩包或WordPress导出文件，可试运行
+		"/content/update/seo":          {"Update Content Self Seo", controllers.UpdateSeoOfContent, POST, false, "content:write"},                    // 更新内容SEO
+		"/content/update/image":        {"Update Content Self Image", controllers.UpdateImageOfContent, POST, false, "content:write"},                // 更新内容图片
+		"/content/update/status":       {"Update Content Self Status", controllers.UpdateStatusOfContent, POST, false, "content:write"},              // 更新内容的状态，如设置隐藏
+		"/content/admin/update/status": {"Update Content All Status", controllers.UpdateStatusOfContentAdmin, POST, true, "admin"},                   // 超级管理员修改文章，比如禁用或者逻辑删除/恢复文章
+		"/content/update/node":         {"Update Content Self Node", controllers.UpdateNodeOfContent, POST, false, "content:write"},                  // 更改内容的节点，顺便需要重新排序
+		"/content/update/top":          {"Update Content Self Top", controllers.UpdateTopOfContent, POST, false, "content:write"},                    // 设置内容的置顶与否
+		"/content/update/comment":      {"Update Content Self Comment", controllers.UpdateCommentOfContent, POST, false, "content:write"},            // 设置内容可以评论与否
+		"/content/update/password":     {"Update Content Self Password", controllers.UpdatePasswordOfContent, POST, false, "content:write"},          // 更改内容的密码保护
+		"/content/update/info":         {"Update Content Self Info", controllers.UpdateInfoOfContent, POST, false, "content:write"},                  // 更新内容标题和内容
+		"/content/sort":                {"Sort Content Self", controllers.SortContent, POST, false, "content:write"},                                 // 对内容进行拖曳排序
+		"/content/publish":             {"Publish Content Self", controllers.PublishContent, POST, false, "content:write"},                           // 将预览刷进另外一个字段
+		"/content/schedule":            {"Schedule Content Self", controllers.ScheduleContent, POST, false, "content:write"},                         // 定时发布或定时隐藏文章
+		"/content/schedule/cancel":     {"Cancel Schedule Content Self", controllers.CancelScheduleContent, POST, false, "content:write"},            // 取消定时
+		"/content/schedule/list":       {"List Schedule Content Self", controllers.ListScheduleContent, GP, false, "content:read"},                   // 列出定时的文章
+		"/content/schedule/admin/list": {"List Schedule Content All", controllers.ListScheduleContentAdmin, GP, true, "admin"},                       // 管理员列出定时的文章
+		"/content/lock":                {"Edit Lock Content Self", controllers.EditLockOfContent, POST, false, "content:write"},                      // 编辑锁，定时调用续期，仅用于提示他人正在编辑
+		"/content/unlock":              {"Edit Unlock Content Self", controllers.EditUnlockOfContent, POST, false, "content:write"},                  // 离开编辑释放编辑锁
+		"/content/restore":             {"Restore Content Self", controllers.RestoreContent, POST, false, "content:write"},                           // 恢复历史，刷回来
+		"/content/rubbish":             {"Sent Content Self To Rubbish", controllers.SentContentToRubbish, POST, false, "content:write"},             // 一般回收站
+		"/content/recycle":             {"Sent Rubbish Content Self To Origin", controllers.ReCycleOfContentInRubbish, POST, false, "content:write"}, // 一般回收站恢复
+		"/content/delete":              {"Delete Content Self Real", controllers.ReallyDeleteContent, POST, false, "content:write"},                  // 逻辑删除文章 已经修正为真删除
+		"/content/take":                {"Take Content Self", controllers.TakeContent, GP, false, "content:read"},                                    // 获取文章内容
+		"/content/admin/take":          {"Take Content Admin", controllers.TakeContentAdmin, GP, true, "admin"},                                      // 管理员获取文章内容
+		"/content/history/take":        {"Take Content History Self", controllers.TakeContentHistory, GP, false, "content:read"},                     // 获取文章历史内容
+		"/content/history/admin/take":  {"Take Content History Admin", controllers.TakeContentHistoryAdmin, GP, true, "admin"},                       // 管理员获取文章历史内容
+		"/content/history/diff":        {"Diff Content History Self", controllers.DiffContentHistory, GP, false, "content:read"},                     // 对比文章历史内容
+		"/content/history/admin/diff":  {"Diff Content History Admin", controllers.DiffContentHistoryAdmin, GP, true, "admin"},                       // 管理员对比文章历史内容
+		"/content/list":                {"List Content Self", controllers.ListContent, GP, false, "content:read"},                                    // 列出文章
+		"/content/admin/list":          {"List Content All", controllers.ListContentAdmin, GP, true, "admin"},                                        // 管理员列出文章，什么类型都可以
+		"/content/history/list":        {"List Content History Self", controllers.ListContentHistory, GP, false, "content:read"},                     // 列出文章的历史记录
+		"/content/history/admin/list":  {"List Content History All", controllers.ListContentHistoryAdmin, GP, true, "admin"},                         // 管理员列出文章的历史纪录
+		"/content/history/delete":      {"Delete Content History Self Real", controllers.ReallyDeleteHistoryContent, POST, false, "content:write"},   // 真删除历史内容
+		"/content/cool":                {"Cool the Content Self", controllers.CoolContent, GP, false, "content:write"},                               // 点赞内容
+		"/content/bad":                 {"Bad the Content Self", controllers.BadContent, GP, false, "content:write"},                                 // 举报内容
+		"/comment/create":              {"Create the Comment Self", controllers.CreateComment, POST, false, "comment:write"},                         // 创建评论
+		"/comment/real/name":           {"Real Name the Comment Self", controllers.RealNameComment, POST, false, "comment:write"},                    // 评论取消匿名
+		"/comment/delete":              {"Delete the Comment Self", controllers.DeleteComment, POST, false, "comment:write"},                         // 删除评论，逻辑删除
+		"/comment/take":                {"Take the Comment Self", controllers.TakeComment, GP, false, "comment:read"},                                // 获取评论
+		"/comment/cool":                {"Cool the Comment Self", controllers.CoolComment, GP, false, "comment:write"},                               // 点赞评论
+		"/comment/bad":                 {"Bad the Comment Self", controllers.BadComment, GP, false, "comment:write"},                                 // 举报评论
+		"/comment/admin/list":          {"List the Comment Admin", controllers.ListComment, GP, true, "admin"},                                       // 管理员列出评论
+		"/comment/admin/update/status": {"Update the Comment Status Admin", controllers.UpdateComment, GP, true, "admin"},                            // 管理员评论违禁处理
+
+		// 内容审核
+		"/content/review/submit": {"Submit Content Self Review", controllers.SubmitReviewOfContent, POST, false, "content:write"}, // 提交草稿等待审核，审核通过后才发布
+		"/content/review/do":     {"Review Content", controllers.ReviewContent, POST, false, "content:write"},                     // 审核员通过并发布，要求修改或拒绝
+		"/content/review/queue":  {"List Review Queue", controllers.ListReviewQueue, GP, false, "content:read"},                   // 审核员列出等待审核的文章
+		"/content/review/list":   {"List Content Review", controllers.ListReviewOfContent, GP, false, "content:read"},             // 列出文章的审核记录
+
+		// 内容导出
+		"/content/export":                {"Export Content Self", controllers.ExportContent, POST, false, "content:write"},               // 后台导出全部内容为压缩包，可包含历史和上传文件
+		"/content/export/list":           {"List Export Content Self", controllers.ListExportContent, GP, false, "content:read"},         // 列出自己的导出任务
+		"/content/export/download":       {"Download Export Content Self", controllers.DownloadExportContent, GP, false, "content:read"}, // 下载导出的压缩包
+		"/content/export/admin":          {"Export Content Admin", controllers.ExportContentAdmin, POST, true, "admin"},                  // 管理员导出某用户全部内容
+		"/content/export/admin/list":     {"List Export Content Admin", controllers.ListExportContentAdmin, GP, true, "admin"},           // 管理员列出导出任务
+		"/content/export/admin/download": {"Download Export Content Admin", controllers.DownloadExportContentAdmin, GP, true, "admin"},   // 管理员下载导出的压缩包
+
+		// 静态站点
+		"/static/admin/build":  {"Build Static Site Admin", controllers.StaticBuildAdmin, POST, true, "admin"}, // 管理员后台生成静态站点，默认只渲染发布时间变化的内容
+		"/static/admin/status": {"Static Site Status Admin", controllers.StaticStatusAdmin, GP, true, "admin"}, // 查看是否正在生成和上次生成的结果
+
+		"/relation/follow/add":     {"Follow add Who", controllers.AddRelation, GP, false, "relation:write"},                   // 关注
+		"/relation/follow/minute":  {"Follow Minute Who", controllers.MinuteRelation, GP, false, "relation:write"},             // 关注解除
+		"/relation/followed/me":    {"List Who Follow You", controllers.ListFollowedRelationOfMe, GP, false, "relation:read"},  // 查看谁关注了你
+		"/relation/following/me":   {"List You Follow Who", controllers.ListFollowingRelationOfMe, GP, false, "relation:read"}, // 查看你关注了谁
+		"/relation/followed/list":  {"List Who Follow You", controllers.ListFollowedRelation, GP, false, "relation:read"},      // 查看谁关注了用户B，然后你和谁的关系
+		"/relation/following/list": {"List You Follow Who", controllers.ListFollowingRelation, GP, false, "relation:read"},     // 查看用户A关注了谁，然后你和谁的关系
+		"/relation/admin/list":     {"List Who Follow Who Admin", controllers.ListAllRelation, GP, true, "admin"},              // 查看所有关系
+
+		"/message/list":                       {"List Your Message Can Include Private Message", controllers.ListMessage, GP, false, "message:read"}, // 列出自己的系统消息，包括与其他用户间的私信
+		"/message/admin/list":                 {"List All Message", controllers.ListAllMessage, GP, false, "admin"},                                  // 管理员列出所有系统消息
+		"/message/read":                       {"Read Your Message", controllers.ReadMessage, GP, false, "message:write"},                            // 读取系统消息
+		"/message/delete":                     {"Delete Your Message", controllers.DeleteMessage, GP, false, "message:write"},                        // 删除系统消息
+		"/message/admin/global/create":        {"Admin Create Global Message", controllers.CreateGlobalMessage, GP, true, "admin"},                   // 管理员创建全局站内信
+		"/message/admin/global/list":          {"Admin List Global Message", controllers.ListGlobalMessage, GP, true, "admin"},                       // 管理员列出全局站内信
+		"/message/admin/global/update/status": {"Admin Change Global Message Status", controllers.UpdateGlobalMessage, GP, true, "admin"},            // 管理员更改全局站内信状态
+
+		"/message/private/send":   {"Send Message To Private People", controllers.SendPrivateMessage, GP, false, "message:write"}, // 私信
+		"/message/private/delete": {"Delete Message Has Sent", controllers.DeletePrivateMessage, GP, false, "message:write"},      // 删除自己发出的私信，但收件方还是可以看到
+
+		"/token/create": {"Create Personal Access Token", controllers.CreateAccessToken, POST, false, ""}, // 创建个人访问令牌，令牌只显示一次
+		"/token/list":   {"List Personal Access Token", controllers.ListAccessToken, GP, false, ""},       // 列出个人访问令牌
+		"/token/revoke": {"Revoke Personal Access Token", controllers.RevokeAccessToken, POST, false, ""}, // 撤销个人访问令牌
 	}
 )
 
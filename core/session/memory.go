@@ -0,0 +1,246 @@
+package session
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// expired key clean and persist interval
+var memoryGCInterval = 60 * time.Second
+
+type memoryItem struct {
+	Value  []byte            `json:"value,omitempty"`
+	Hash   map[string][]byte `json:"hash,omitempty"`
+	Expire int64             `json:"expire"`
+}
+
+func (i *memoryItem) expired(now int64) bool {
+	return i.Expire <= now
+}
+
+// Store in process memory, for single node install or test which no redis
+type MemoryStore struct {
+	lock  sync.RWMutex
+	items map[string]*memoryItem
+	file  string // persist to file, empty will not
+	dirty bool
+}
+
+// New a memory store, file not empty will load it, then save into it every minute
+func NewMemoryStore(file string) (*MemoryStore, error) {
+	s := &MemoryStore{
+		items: make(map[string]*memoryItem),
+		file:  file,
+	}
+
+	if file != "" {
+		raw, err := ioutil.ReadFile(file)
+		if err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+
+		if len(raw) > 0 {
+			err = json.Unmarshal(raw, &s.items)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	go s.loop()
+	return s, nil
+}
+
+func (s *MemoryStore) loop() {
+	ticker := time.NewTicker(memoryGCInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		s.GC()
+		s.Save()
+	}
+}
+
+// Remove all the expired key
+func (s *MemoryStore) GC() {
+	now := time.Now().Unix()
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	for k, v := range s.items {
+		if v.expired(now) {
+			delete(s.items, k)
+			s.dirty = true
+		}
+	}
+}
+
+// Save to file, write a temp file then rename, so file will not be broken
+func (s *MemoryStore) Save() error {
+	if s.file == "" {
+		return nil
+	}
+
+	s.lock.Lock()
+	if !s.dirty {
+		s.lock.Unlock()
+		return nil
+	}
+	raw, err := json.Marshal(s.items)
+	s.dirty = false
+	s.lock.Unlock()
+	if err != nil {
+		return err
+	}
+
+	temp := s.file + ".tmp"
+	err = ioutil.WriteFile(temp, raw, 0600)
+	if err != nil {
+		return err
+	}
+	return os.Rename(temp, s.file)
+}
+
+func expireTime(expireSecond int64) int64 {
+	if expireSecond <= 0 {
+		expireSecond = 7 * 24 * 3600
+	}
+	return time.Now().Unix() + expireSecond
+}
+
+// get the item not expired, must hold the lock
+func (s *MemoryStore) get(key string) *memoryItem {
+	i, ok := s.items[key]
+	if !ok {
+		return nil
+	}
+
+	if i.expired(time.Now().Unix()) {
+		return nil
+	}
+	return i
+}
+
+func (s *MemoryStore) Set(key string, value []byte, expireSecond int64) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.items[key] = &memoryItem{Value: value, Expire: expireTime(expireSecond)}
+	s.dirty = true
+	return nil
+}
+
+func (s *MemoryStore) Get(key string) (value []byte, exist bool, err error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	i := s.get(key)
+	if i == nil || i.Hash != nil {
+		return nil, false, nil
+	}
+	return i.Value, true, nil
+}
+
+func (s *MemoryStore) Incr(key string, expireSecond int64) (value int64, err error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	i := s.get(key)
+	if i == nil || i.Hash != nil {
+		i = &memoryItem{Expire: expireTime(expireSecond)}
+		s.items[key] = i
+	} else if len(i.Value) > 0 {
+		value, err = strconv.ParseInt(string(i.Value), 10, 64)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	value = value + 1
+	i.Value = []byte(strconv.FormatInt(value, 10))
+	s.dirty = true
+	return value, nil
+}
+
+func (s *MemoryStore) Delete(key string) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	delete(s.items, key)
+	s.dirty = true
+	return nil
+}
+
+func (s *MemoryStore) Expire(key string, expireSecond int64) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	i := s.get(key)
+	if i != nil {
+		i.Expire = expireTime(expireSecond)
+		s.dirty = true
+	}
+	return nil
+}
+
+func (s *MemoryStore) Exists(key string) (exist bool, err error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	return s.get(key) != nil, nil
+}
+
+func (s *MemoryStore) HSet(key string, field string, value []byte, expireSecond int64) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	i := s.get(key)
+	if i == nil || i.Hash == nil {
+		i = &memoryItem{Hash: make(map[string][]byte), Expire: expireTime(expireSecond)}
+		s.items[key] = i
+	} else if expireSecond > 0 {
+		i.Expire = expireTime(expireSecond)
+	}
+
+	i.Hash[field] = value
+	s.dirty = true
+	return nil
+}
+
+func (s *MemoryStore) HGet(key string, field string) (value []byte, exist bool, err error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	i := s.get(key)
+	if i == nil || i.Hash == nil {
+		return nil, false, nil
+	}
+
+	value, exist = i.Hash[field]
+	return value, exist, nil
+}
+
+func (s *MemoryStore) HGetAll(key string) (result map[string]string, err error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	result = make(map[string]string)
+	i := s.get(key)
+	if i == nil {
+		return
+	}
+
+	for k, v := range i.Hash {
+		result[k] = string(v)
+	}
+	return
+}
+
+func (s *MemoryStore) HDel(key string, field string) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	i := s.get(key)
+	if i == nil || i.Hash == nil {
+		return nil
+	}
+
+	delete(i.Hash, field)
+	if len(i.Hash) == 0 {
+		delete(s.items, key)
+	}
+	s.dirty = true
+	return nil
+}
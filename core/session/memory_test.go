@@ -0,0 +1,115 @@
+package session
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestMemoryStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "fafacms_session")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	file := filepath.Join(dir, "session.json")
+	s, err := NewMemoryStore(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s.Set("a", []byte("1"), 100)
+	s.HSet("h", "f1", []byte("v1"), 100)
+	s.HSet("h", "f2", []byte("v2"), 0)
+
+	// expired will not get
+	s.items["old"] = &memoryItem{Value: []byte("1"), Expire: time.Now().Unix() - 1}
+	if _, exist, _ := s.Get("old"); exist {
+		t.Fatal("expired key should not exist")
+	}
+
+	s.GC()
+	if _, ok := s.items["old"]; ok {
+		t.Fatal("expired key should be gc")
+	}
+
+	if err := s.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	// load from file
+	s2, err := NewMemoryStore(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	v, exist, _ := s2.Get("a")
+	if !exist || string(v) != "1" {
+		t.Fatalf("load key wrong: %s", v)
+	}
+
+	all, _ := s2.HGetAll("h")
+	if len(all) != 2 || all["f2"] != "v2" {
+		t.Fatalf("load hash wrong: %v", all)
+	}
+
+	// incr keep the first expire time
+	s2.Incr("n", 100)
+	expire := s2.items["n"].Expire
+	if n, _ := s2.Incr("n", 1000); n != 2 || s2.items["n"].Expire != expire {
+		t.Fatalf("incr wrong: %d", n)
+	}
+
+	s2.HDel("h", "f1")
+	s2.HDel("h", "f2")
+	if exist, _ := s2.Exists("h"); exist {
+		t.Fatal("empty hash should be delete")
+	}
+}
+
+func TestStatelessSession(t *testing.T) {
+	store, _ := NewMemoryStore("")
+	s := &StatelessSession{Secret: []byte("secret"), Store: store}
+
+	token := s.genToken(3, 100)
+	id, err := s.check(token)
+	if err != nil || id != 3 {
+		t.Fatalf("token should valid: %v", err)
+	}
+
+	// other secret sign
+	other := &StatelessSession{Secret: []byte("other"), Store: store}
+	if _, err := other.check(token); err == nil {
+		t.Fatal("token of other secret should invalid")
+	}
+
+	if _, err := s.check(s.genToken(3, -1)); err == nil {
+		t.Fatal("expired token should invalid")
+	}
+
+	s.revoke(token)
+	if _, err := s.check(token); err == nil {
+		t.Fatal("revoked token should invalid")
+	}
+
+	token = s.genToken(3, 100)
+	s.DeleteUserToken(3)
+	if _, err := s.check(token); err == nil {
+		t.Fatal("token issue before revoke all should invalid")
+	}
+
+	if _, err := s.check(s.genToken(3, 100)); err != nil {
+		t.Fatalf("token issue after revoke all should valid: %v", err)
+	}
+
+	// restart lost the max valid times, revoke all still keep as long as config
+	TokenValidTime = 30 * 24 * 3600
+	restart := &StatelessSession{Secret: []byte("secret"), Store: store}
+	restart.DeleteUserToken(3)
+	if store.items[revokeUserKey(3)].Expire < time.Now().Unix()+TokenValidTime {
+		t.Fatal("revoke all should keep as long as the token valid time")
+	}
+}
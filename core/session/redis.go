@@ -0,0 +1,183 @@
+package session
+
+import (
+	"github.com/gomodule/redigo/redis"
+)
+
+// Store on redis, the default
+type RedisStore struct {
+	Pool *redis.Pool
+}
+
+func (s *RedisStore) Set(key string, value []byte, expireSecond int64) (err error) {
+	conn := s.Pool.Get()
+	if conn.Err() != nil {
+		err = conn.Err()
+		return
+	}
+
+	defer conn.Close()
+	err = conn.Send("MULTI")
+	if err != nil {
+		return err
+	}
+	err = conn.Send("SET", key, value)
+	if err != nil {
+		return err
+	}
+
+	if expireSecond <= 0 {
+		expireSecond = 7 * 24 * 3600
+	}
+	err = conn.Send("EXPIRE", key, expireSecond)
+	if err != nil {
+		return err
+	}
+	_, err = conn.Do("EXEC")
+	return
+}
+
+// incr and set the expire time only when new create, in one script so it is atomic
+var redisIncrScript = redis.NewScript(1, `
+local n = redis.call("INCR", KEYS[1])
+if n == 1 then
+	redis.call("EXPIRE", KEYS[1], ARGV[1])
+end
+return n`)
+
+func (s *RedisStore) Incr(key string, expireSecond int64) (value int64, err error) {
+	conn := s.Pool.Get()
+	if conn.Err() != nil {
+		err = conn.Err()
+		return
+	}
+
+	defer conn.Close()
+	if expireSecond <= 0 {
+		expireSecond = 7 * 24 * 3600
+	}
+	return redis.Int64(redisIncrScript.Do(conn, key, expireSecond))
+}
+
+func (s *RedisStore) Delete(key string) (err error) {
+	conn := s.Pool.Get()
+	if conn.Err() != nil {
+		err = conn.Err()
+		return
+	}
+
+	defer conn.Close()
+	_, err = conn.Do("DEL", key)
+	return err
+}
+
+func (s *RedisStore) Expire(key string, expireSecond int64) (err error) {
+	conn := s.Pool.Get()
+	if conn.Err() != nil {
+		err = conn.Err()
+		return
+	}
+
+	defer conn.Close()
+	if expireSecond <= 0 {
+		expireSecond = 7 * 24 * 3600
+	}
+	_, err = conn.Do("EXPIRE", key, expireSecond)
+	return err
+}
+
+func (s *RedisStore) Get(key string) (value []byte, exist bool, err error) {
+	conn := s.Pool.Get()
+	if conn.Err() != nil {
+		err = conn.Err()
+		return
+	}
+
+	defer conn.Close()
+	value, err = redis.Bytes(conn.Do("GET", key))
+	if err == redis.ErrNil {
+		return nil, false, nil
+	} else if err != nil {
+		return nil, false, err
+	}
+	return value, true, nil
+}
+
+func (s *RedisStore) HSet(key string, field string, value []byte, expireSecond int64) (err error) {
+	conn := s.Pool.Get()
+	if conn.Err() != nil {
+		err = conn.Err()
+		return
+	}
+
+	defer conn.Close()
+	err = conn.Send("MULTI")
+	if err != nil {
+		return err
+	}
+	err = conn.Send("HSET", key, field, value)
+	if err != nil {
+		return err
+	}
+
+	// not set will keep the old expire time
+	if expireSecond > 0 {
+		err = conn.Send("EXPIRE", key, expireSecond)
+		if err != nil {
+			return err
+		}
+	}
+	_, err = conn.Do("EXEC")
+	return
+}
+
+func (s *RedisStore) HGet(key string, field string) (value []byte, exist bool, err error) {
+	conn := s.Pool.Get()
+	if conn.Err() != nil {
+		err = conn.Err()
+		return
+	}
+
+	defer conn.Close()
+	value, err = redis.Bytes(conn.Do("HGET", key, field))
+	if err == redis.ErrNil {
+		return nil, false, nil
+	} else if err != nil {
+		return nil, false, err
+	}
+	return value, true, nil
+}
+
+func (s *RedisStore) HGetAll(key string) (result map[string]string, err error) {
+	conn := s.Pool.Get()
+	if conn.Err() != nil {
+		err = conn.Err()
+		return
+	}
+
+	defer conn.Close()
+	return redis.StringMap(conn.Do("HGETALL", key))
+}
+
+func (s *RedisStore) HDel(key string, field string) (err error) {
+	conn := s.Pool.Get()
+	if conn.Err() != nil {
+		err = conn.Err()
+		return
+	}
+
+	defer conn.Close()
+	_, err = conn.Do("HDEL", key, field)
+	return err
+}
+
+func (s *RedisStore) Exists(key string) (exist bool, err error) {
+	conn := s.Pool.Get()
+	if conn.Err() != nil {
+		err = conn.Err()
+		return
+	}
+
+	defer conn.Close()
+	return redis.Bool(conn.Do("EXISTS", key))
+}
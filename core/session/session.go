@@ -4,155 +4,232 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
-	"github.com/gomodule/redigo/redis"
 	"github.com/hunterhug/fafacms/core/model"
 	"github.com/hunterhug/fafacms/core/util"
 	"github.com/hunterhug/fafacms/core/util/kv"
+	"sort"
 	"strconv"
 	"strings"
+	"time"
 )
 
 var (
 	// redis key
-	redisToken = "ff_tokens"
-	redisUser  = "ff_users"
+	redisToken     = "ff_tokens"
+	redisUser      = "ff_users"
+	redisUserToken = "ff_user_tokens"
+
+	// last seen of token update interval
+	touchInterval int64 = 60
+)
+
+// session backend type
+const (
+	TypeRedis     = "redis"
+	TypeMemory    = "memory"
+	TypeStateless = "stateless"
 )
 
+// Session config, old config which only redis field still work
+type MySessionConf struct {
+	kv.MyRedisConf
+	Type        string // redis(default), memory or stateless
+	MemoryFile  string // memory store persist to this file, empty will not persist
+	Secret      string // stateless token hmac secret, must set when stateless
+	RevokeStore string // stateless revoke list keep in memory(default) or redis
+}
+
 // diy user redis
 type TokenManage interface {
-	CheckAndSetToken(token string, validTimes int64) (user *model.User, err error) // Check the token, when redis exist direct return user info, others hit the mysql db and save in redis then return
-	SetToken(user *model.User, validTimes int64) (token string, err error)         // Set token, expire 7 days
-	RefreshToken(token string, validTime int64) error                              // Refresh token，token expire time will be again 7 days
-	DeleteToken(token string) error                                                // Delete token when logout
-	RefreshUser(id []int64, validTime int64) error                                 // Refresh redis cache of user info
-	DeleteUserToken(id int64) error                                                // Delete all token of those user
-	DeleteUser(id int64) error                                                     // Delete user info in redis cache
-	AddUser(id int64, validTime int64) (user *model.User, err error)               // Add the user info to session redis，expire days:7
+	CheckAndSetToken(token string, validTimes int64) (user *model.User, err error)         // Check the token, when redis exist direct return user info, others hit the mysql db and save in redis then return
+	SetToken(user *model.User, validTimes int64, info TokenInfo) (token string, err error) // Set token, expire 7 days, info is the login device
+	RefreshToken(token string, validTime int64) error                                      // Refresh token，token expire time will be again 7 days
+	TouchToken(token string, ip string, ua string) error                                   // Record the last seen of token
+	DeleteToken(token string) error                                                        // Delete token when logout
+	RefreshUser(id []int64, validTime int64) error                                         // Refresh redis cache of user info
+	ListUserToken(id int64) ([]TokenInfo, error)                                           // List all login device of user
+	DeleteUserToken(id int64) error                                                        // Delete all token of those user
+	DeleteUserTokenById(id int64, tokenId string) error                                    // Delete one login device of user
+	DeleteUserTokenExcept(id int64, token string) error                                    // Delete all token of user except this one
+	DeleteUser(id int64) error                                                             // Delete user info in redis cache
+	AddUser(id int64, validTime int64) (user *model.User, err error)                       // Add the user info to session redis，expire days:7
 }
 
-type RedisSession struct {
-	Pool *redis.Pool
+// One login device of user
+type TokenInfo struct {
+	Id         string `json:"id"`              // hash of token, can show to user
+	Token      string `json:"token,omitempty"` // only keep inside, not show to user
+	CreateTime int64  `json:"create_time"`
+	LastSeen   int64  `json:"last_seen"`
+	Ip         string `json:"ip"`
+	Ua         string `json:"ua"`
 }
 
-func (s *RedisSession) Set(key string, value []byte, expireSecond int64) (err error) {
-	conn := s.Pool.Get()
-	if conn.Err() != nil {
-		err = conn.Err()
-		return
-	}
+// short live key value, such as two factor login challenge
+type KV interface {
+	Set(key string, value []byte, expireSecond int64) error
+	Get(key string) (value []byte, exist bool, err error)
+	Delete(key string) error
+	Incr(key string, expireSecond int64) (value int64, err error) // atomic add one, expire only set when the key new create
+}
 
-	defer conn.Close()
-	err = conn.Send("MULTI")
-	if err != nil {
-		return err
-	}
-	err = conn.Send("SET", key, value)
-	if err != nil {
-		return err
+// The storage which session build on, like redis
+type Store interface {
+	KV
+	Expire(key string, expireSecond int64) error
+	Exists(key string) (exist bool, err error)
+	HSet(key string, field string, value []byte, expireSecond int64) error // expireSecond <= 0 will keep the old expire time
+	HGet(key string, field string) (value []byte, exist bool, err error)
+	HGetAll(key string) (result map[string]string, err error)
+	HDel(key string, field string) error
+}
+
+func HashTokenKey(token string) string {
+	return fmt.Sprintf("%s_%s", redisToken, token)
+}
+
+func GenToken(id int64) string {
+	return fmt.Sprintf("%d_%s", id, util.GetGUID())
+}
+
+func HashUserKey(id int64, name string) string {
+	return fmt.Sprintf("%s_%d_%s", redisUser, id, name)
+}
+
+// every user has a hash keep all the token, field is token id
+func HashUserTokenKey(id int64) string {
+	return fmt.Sprintf("%s_%d", redisUserToken, id)
+}
+
+func TokenId(token string) string {
+	h, _ := util.Sha256([]byte(token))
+	if len(h) > 16 {
+		return h[0:16]
 	}
+	return h
+}
 
-	if expireSecond <= 0 {
-		expireSecond = 7 * 24 * 3600
+// token gen by GenToken, user id is the prefix
+func TokenUserId(token string) (int64, error) {
+	temp := strings.SplitN(token, "_", 2)
+	if len(temp) != 2 {
+		return 0, errors.New("token invalid")
 	}
-	err = conn.Send("EXPIRE", key, expireSecond)
+	return strconv.ParseInt(temp[0], 10, 64)
+}
+
+// those secret can not save in session
+func cleanUser(user *model.User) {
+	user.Password = ""
+	user.ActivateCode = ""
+	user.ActivateCodeExpired = 0
+	user.ResetCode = ""
+	user.ResetCodeExpired = 0
+}
+
+func getUser(id int64) (user *model.User, err error) {
+	user = new(model.User)
+	user.Id = id
+	exist, err := user.GetRaw()
 	if err != nil {
-		return err
+		return nil, err
 	}
-	_, err = conn.Do("EXEC")
-	return
-}
 
-func (s *RedisSession) Delete(key string) (err error) {
-	conn := s.Pool.Get()
-	if conn.Err() != nil {
-		err = conn.Err()
-		return
+	if !exist {
+		return nil, errors.New("user not exist in db")
 	}
 
-	defer conn.Close()
-	_, err = conn.Do("DEL", key)
-	return err
+	cleanUser(user)
+	return user, nil
+}
+
+// record the device, so user can see where login
+func addTokenInfo(s Store, id int64, token string, info TokenInfo, validTimes int64) error {
+	now := time.Now().Unix()
+	info.Id = TokenId(token)
+	info.Token = token
+	info.CreateTime = now
+	info.LastSeen = now
+	raw, _ := json.Marshal(info)
+	return s.HSet(HashUserTokenKey(id), info.Id, raw, validTimes)
 }
 
-func (s *RedisSession) EXPIRE(key string, expireSecond int) (err error) {
-	conn := s.Pool.Get()
-	if conn.Err() != nil {
-		err = conn.Err()
+func touchTokenInfo(s Store, token string, ip string, ua string) (err error) {
+	id, err := TokenUserId(token)
+	if err != nil {
 		return
 	}
 
-	defer conn.Close()
-	if expireSecond <= 0 {
-		expireSecond = 7 * 24 * 3600
+	tokenId := TokenId(token)
+	value, exist, err := s.HGet(HashUserTokenKey(id), tokenId)
+	if err != nil || !exist {
+		return
 	}
-	_, err = conn.Do("EXPIRE", key, expireSecond)
-	return err
-}
 
-func (s *RedisSession) Keys(pattern string) (result []string, exist bool, err error) {
-	conn := s.Pool.Get()
-	if conn.Err() != nil {
-		err = conn.Err()
+	info := TokenInfo{}
+	err = json.Unmarshal(value, &info)
+	if err != nil {
 		return
 	}
 
-	defer conn.Close()
-	keys, err := redis.ByteSlices(conn.Do("KEYS", pattern))
-	if err == redis.ErrNil {
-		return nil, false, nil
-	} else if err != nil {
-		return nil, false, err
+	// not every request update
+	now := time.Now().Unix()
+	if now-info.LastSeen < touchInterval && info.Ip == ip {
+		return
 	}
 
-	result = make([]string, len(keys))
-	for k, v := range keys {
-		result[k] = string(v)
-	}
-	return result, true, nil
+	info.LastSeen = now
+	info.Ip = ip
+	info.Ua = ua
+	raw, _ := json.Marshal(info)
+	return s.HSet(HashUserTokenKey(id), tokenId, raw, 0)
 }
 
-func (s *RedisSession) Get(key string) (value []byte, exist bool, err error) {
-	conn := s.Pool.Get()
-	if conn.Err() != nil {
-		err = conn.Err()
+// list the device of user, token not alive will be remove
+func listTokenInfo(s Store, id int64, alive func(token string) (bool, error)) (result []TokenInfo, err error) {
+	all, err := s.HGetAll(HashUserTokenKey(id))
+	if err != nil {
 		return
 	}
 
-	value, err = redis.Bytes(conn.Do("GET", key))
-	if err == redis.ErrNil {
-		return nil, false, nil
-	} else if err != nil {
-		return nil, false, err
-	}
-	return value, true, nil
-}
+	result = make([]TokenInfo, 0, len(all))
+	for tokenId, v := range all {
+		info := TokenInfo{}
+		if json.Unmarshal([]byte(v), &info) != nil {
+			s.HDel(HashUserTokenKey(id), tokenId)
+			continue
+		}
 
-func HashTokenKey(token string) string {
-	return fmt.Sprintf("%s_%s", redisToken, token)
-}
+		ok, err := alive(info.Token)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			s.HDel(HashUserTokenKey(id), tokenId)
+			continue
+		}
 
-func GenToken(id int64) string {
-	return fmt.Sprintf("%d_%s", id, util.GetGUID())
-}
+		result = append(result, info)
+	}
 
-func HashUserKey(id int64, name string) string {
-	return fmt.Sprintf("%s_%d_%s", redisUser, id, name)
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].LastSeen > result[j].LastSeen
+	})
+	return
 }
 
-func UserKeys(id int64) string {
-	return fmt.Sprintf("%s_%d_*", redisUser, id)
+// Session keep token in store, store can be redis or memory
+type StoreSession struct {
+	Store Store
 }
 
-func UserTokenKeys(id int64) string {
-	return fmt.Sprintf("%s_%d_*", redisToken, id)
-}
-func (s *RedisSession) CheckAndSetToken(token string, validTimes int64) (user *model.User, err error) {
+func (s *StoreSession) CheckAndSetToken(token string, validTimes int64) (user *model.User, err error) {
 	if token == "" {
 		err = errors.New("token nil")
 		return
 	}
 
-	value, exist, err := s.Get(HashTokenKey(token))
+	value, exist, err := s.Store.Get(HashTokenKey(token))
 	if err != nil {
 		return nil, err
 	}
@@ -162,7 +239,7 @@ func (s *RedisSession) CheckAndSetToken(token string, validTimes int64) (user *m
 	}
 
 	userKey := string(value)
-	value, exist, err = s.Get(userKey)
+	value, exist, err = s.Store.Get(userKey)
 	if err != nil {
 		return nil, err
 	}
@@ -186,54 +263,118 @@ func (s *RedisSession) CheckAndSetToken(token string, validTimes int64) (user *m
 	return
 }
 
-func (s *RedisSession) RefreshToken(token string, validTimes int64) (err error) {
-	return s.EXPIRE(HashTokenKey(token), int(validTimes))
+func (s *StoreSession) RefreshToken(token string, validTimes int64) (err error) {
+	err = s.Store.Expire(HashTokenKey(token), validTimes)
+	if err != nil {
+		return
+	}
+
+	id, err := TokenUserId(token)
+	if err != nil {
+		return nil
+	}
+	return s.Store.Expire(HashUserTokenKey(id), validTimes)
+}
+
+func (s *StoreSession) TouchToken(token string, ip string, ua string) (err error) {
+	return touchTokenInfo(s.Store, token, ip, ua)
+}
+
+func (s *StoreSession) DeleteToken(token string) (err error) {
+	err = s.Store.Delete(HashTokenKey(token))
+	if err != nil {
+		return
+	}
+
+	id, err := TokenUserId(token)
+	if err != nil {
+		return nil
+	}
+	return s.Store.HDel(HashUserTokenKey(id), TokenId(token))
 }
 
-func (s *RedisSession) DeleteToken(token string) (err error) {
-	return s.Delete(HashTokenKey(token))
+func (s *StoreSession) ListUserToken(id int64) (result []TokenInfo, err error) {
+	return listTokenInfo(s.Store, id, func(token string) (bool, error) {
+		return s.Store.Exists(HashTokenKey(token))
+	})
 }
 
-func (s *RedisSession) DeleteUserToken(id int64) (err error) {
-	result, exist, err := s.Keys(UserTokenKeys(id))
-	if err == nil && exist {
-		for _, v := range result {
-			s.Delete(v)
+func (s *StoreSession) DeleteUserToken(id int64) (err error) {
+	all, err := s.Store.HGetAll(HashUserTokenKey(id))
+	if err != nil {
+		return
+	}
+
+	for _, v := range all {
+		info := TokenInfo{}
+		if json.Unmarshal([]byte(v), &info) != nil {
+			continue
 		}
+		s.Store.Delete(HashTokenKey(info.Token))
 	}
-	return
+	return s.Store.Delete(HashUserTokenKey(id))
+}
+
+func (s *StoreSession) DeleteUserTokenById(id int64, tokenId string) (err error) {
+	value, exist, err := s.Store.HGet(HashUserTokenKey(id), tokenId)
+	if err != nil {
+		return
+	}
+
+	if !exist {
+		return errors.New("token not found")
+	}
+
+	info := TokenInfo{}
+	err = json.Unmarshal(value, &info)
+	if err == nil {
+		err = s.Store.Delete(HashTokenKey(info.Token))
+		if err != nil {
+			return
+		}
+	}
+
+	return s.Store.HDel(HashUserTokenKey(id), tokenId)
 }
 
-func (s *RedisSession) DeleteUser(id int64) (err error) {
-	result, exist, err := s.Keys(UserKeys(id))
-	if err == nil && exist {
-		for _, v := range result {
-			return s.Delete(v)
+func (s *StoreSession) DeleteUserTokenExcept(id int64, token string) (err error) {
+	all, err := s.Store.HGetAll(HashUserTokenKey(id))
+	if err != nil {
+		return
+	}
+
+	for tokenId, v := range all {
+		info := TokenInfo{}
+		if json.Unmarshal([]byte(v), &info) == nil {
+			if info.Token == token {
+				continue
+			}
+			s.Store.Delete(HashTokenKey(info.Token))
 		}
+		s.Store.HDel(HashUserTokenKey(id), tokenId)
 	}
 	return
 }
 
-func (s *RedisSession) AddUser(id int64, validTimes int64) (user *model.User, err error) {
-	user = new(model.User)
+func (s *StoreSession) DeleteUser(id int64) (err error) {
+	user := new(model.User)
 	user.Id = id
 	exist, err := user.GetRaw()
-	if err != nil {
-		return nil, err
+	if err != nil || !exist {
+		return
 	}
+	return s.Store.Delete(HashUserKey(user.Id, user.Name))
+}
 
-	if !exist {
-		return nil, errors.New("user not exist in db")
+func (s *StoreSession) AddUser(id int64, validTimes int64) (user *model.User, err error) {
+	user, err = getUser(id)
+	if err != nil {
+		return nil, err
 	}
 
-	user.Password = ""
-	user.ActivateCode = ""
-	user.ActivateCodeExpired = 0
-	user.ResetCode = ""
-	user.ResetCodeExpired = 0
 	userKey := HashUserKey(user.Id, user.Name)
 	raw, _ := json.Marshal(user)
-	err = s.Set(userKey, raw, validTimes)
+	err = s.Store.Set(userKey, raw, validTimes)
 	if err != nil {
 		return nil, err
 	}
@@ -241,44 +382,84 @@ func (s *RedisSession) AddUser(id int64, validTimes int64) (user *model.User, er
 	return
 }
 
-func (s *RedisSession) RefreshUser(ids []int64, validTime int64) (err error) {
+func (s *StoreSession) RefreshUser(ids []int64, validTime int64) (err error) {
 	for _, id := range ids {
 		s.AddUser(id, validTime)
 	}
 	return
 }
 
-func (s *RedisSession) SetToken(user *model.User, validTimes int64) (token string, err error) {
+func (s *StoreSession) SetToken(user *model.User, validTimes int64, info TokenInfo) (token string, err error) {
 	if user == nil || user.Id == 0 {
 		err = errors.New("user nil")
 		return
 	}
 
-	user.Password = ""
-	user.ActivateCode = ""
-	user.ActivateCodeExpired = 0
-	user.ResetCode = ""
-	user.ResetCodeExpired = 0
+	cleanUser(user)
 
 	token = GenToken(user.Id)
 	userKey := HashUserKey(user.Id, user.Name)
-	err = s.Set(HashTokenKey(token), []byte(userKey), validTimes)
+	err = s.Store.Set(HashTokenKey(token), []byte(userKey), validTimes)
 	if err != nil {
 		return
 	}
 
 	raw, _ := json.Marshal(user)
-	s.Set(userKey, raw, validTimes)
+	s.Store.Set(userKey, raw, validTimes)
+
+	err = addTokenInfo(s.Store, user.Id, token, info, validTimes)
 	return
 }
 
-var FafaSessionMgr TokenManage
+var (
+	FafaSessionMgr TokenManage
+	FafaKV         KV
+)
 
-func InitSession(redisConf kv.MyRedisConf) error {
-	pool, err := kv.NewRedis(&redisConf)
-	if err != nil {
-		return err
+func InitSession(conf MySessionConf) error {
+	switch conf.Type {
+	case "", TypeRedis:
+		pool, err := kv.NewRedis(&conf.MyRedisConf)
+		if err != nil {
+			return err
+		}
+		s := &RedisStore{Pool: pool}
+		FafaSessionMgr = &StoreSession{Store: s}
+		FafaKV = s
+	case TypeMemory:
+		s, err := NewMemoryStore(conf.MemoryFile)
+		if err != nil {
+			return err
+		}
+		FafaSessionMgr = &StoreSession{Store: s}
+		FafaKV = s
+	case TypeStateless:
+		if conf.Secret == "" {
+			return errors.New("stateless session secret empty")
+		}
+
+		// revoke list and device info is small, keep in memory or redis
+		var s Store
+		switch conf.RevokeStore {
+		case "", TypeMemory:
+			m, err := NewMemoryStore(conf.MemoryFile)
+			if err != nil {
+				return err
+			}
+			s = m
+		case TypeRedis:
+			pool, err := kv.NewRedis(&conf.MyRedisConf)
+			if err != nil {
+				return err
+			}
+			s = &RedisStore{Pool: pool}
+		default:
+			return fmt.Errorf("stateless session revoke store %s not support", conf.RevokeStore)
+		}
+		FafaSessionMgr = &StatelessSession{Secret: []byte(conf.Secret), Store: s}
+		FafaKV = s
+	default:
+		return fmt.Errorf("session type %s not support", conf.Type)
 	}
-	FafaSessionMgr = &RedisSession{Pool: pool}
 	return nil
 }
@@ -21,11 +21,11 @@ func TestRedisSession_CheckToken(t *testing.T) {
 		return
 	}
 
-	s := &RedisSession{Pool: pool}
+	s := &StoreSession{Store: &RedisStore{Pool: pool}}
 	token, err := s.SetToken(&model.User{
 		Id:   3,
 		Name: "SSSSS",
-	}, 2000)
+	}, 2000, TokenInfo{Ip: "127.0.0.1"})
 	if err != nil {
 		fmt.Println(err.Error())
 		return
@@ -0,0 +1,297 @@
+package session
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/hunterhug/fafacms/core/model"
+	"github.com/hunterhug/fafacms/core/util"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+var (
+	redisRevokeToken = "ff_revoke_tokens"
+	redisRevokeUser  = "ff_revoke_users"
+
+	// token valid times of config, revoke all of user keep at least so long,
+	// the max valid times ever issue is only in memory and lost when restart
+	TokenValidTime int64 = 7 * 24 * 3600
+)
+
+// Stateless session, token is signed by hmac and carry its expire time, no need store.
+// Store only keep a small revoke list, device info and user cache.
+// Token format: <user id>_<random id>_<issue nano>_<expire unix>_<sign>
+type StatelessSession struct {
+	Secret []byte
+	Store  Store
+
+	// the max token valid times ever issue, revoke all of user must keep so long
+	maxValid int64
+}
+
+func revokeTokenKey(token string) string {
+	return fmt.Sprintf("%s_%s", redisRevokeToken, TokenId(token))
+}
+
+func revokeUserKey(id int64) string {
+	return fmt.Sprintf("%s_%d", redisRevokeUser, id)
+}
+
+func statelessUserKey(id int64) string {
+	return fmt.Sprintf("%s_%d", redisUser, id)
+}
+
+func (s *StatelessSession) sign(payload string) string {
+	h := hmac.New(sha256.New, s.Secret)
+	h.Write([]byte(payload))
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func (s *StatelessSession) genToken(id int64, validTimes int64) string {
+	now := time.Now()
+	payload := fmt.Sprintf("%d_%s_%d_%d", id, util.GetGUID(), now.UnixNano(), now.Unix()+validTimes)
+	return payload + "_" + s.sign(payload)
+}
+
+// check the sign and expire time
+func (s *StatelessSession) parse(token string) (id int64, issue int64, expire int64, err error) {
+	temp := strings.Split(token, "_")
+	if len(temp) != 5 {
+		err = errors.New("token invalid")
+		return
+	}
+
+	payload := strings.Join(temp[0:4], "_")
+	if !hmac.Equal([]byte(s.sign(payload)), []byte(temp[4])) {
+		err = errors.New("token invalid")
+		return
+	}
+
+	id, err = strconv.ParseInt(temp[0], 10, 64)
+	if err != nil {
+		err = errors.New("token invalid")
+		return
+	}
+
+	issue, err = strconv.ParseInt(temp[2], 10, 64)
+	if err != nil {
+		err = errors.New("token invalid")
+		return
+	}
+
+	expire, err = strconv.ParseInt(temp[3], 10, 64)
+	if err != nil {
+		err = errors.New("token invalid")
+		return
+	}
+
+	if expire <= time.Now().Unix() {
+		err = errors.New("token expired")
+		return
+	}
+	return
+}
+
+// token sign right, not expire, and not in revoke list
+func (s *StatelessSession) check(token string) (id int64, err error) {
+	id, issue, _, err := s.parse(token)
+	if err != nil {
+		return
+	}
+
+	revoked, err := s.Store.Exists(revokeTokenKey(token))
+	if err != nil {
+		return
+	}
+
+	if revoked {
+		return 0, errors.New("token revoked")
+	}
+
+	// all token of user issue before this time is revoked
+	value, exist, err := s.Store.Get(revokeUserKey(id))
+	if err != nil {
+		return
+	}
+
+	if exist {
+		before, _ := strconv.ParseInt(string(value), 10, 64)
+		if issue <= before {
+			return 0, errors.New("token revoked")
+		}
+	}
+
+	return id, nil
+}
+
+func (s *StatelessSession) revoke(token string) error {
+	_, _, expire, err := s.parse(token)
+	if err != nil {
+		// invalid or expired token no need revoke
+		return nil
+	}
+
+	return s.Store.Set(revokeTokenKey(token), []byte("1"), expire-time.Now().Unix())
+}
+
+func (s *StatelessSession) CheckAndSetToken(token string, validTimes int64) (user *model.User, err error) {
+	if token == "" {
+		err = errors.New("token nil")
+		return
+	}
+
+	id, err := s.check(token)
+	if err != nil {
+		return nil, err
+	}
+
+	value, exist, err := s.Store.Get(statelessUserKey(id))
+	if err != nil {
+		return nil, err
+	}
+
+	if exist {
+		user = new(model.User)
+		json.Unmarshal(value, user)
+		return
+	}
+
+	return s.AddUser(id, validTimes)
+}
+
+func (s *StatelessSession) SetToken(user *model.User, validTimes int64, info TokenInfo) (token string, err error) {
+	if user == nil || user.Id == 0 {
+		err = errors.New("user nil")
+		return
+	}
+
+	if validTimes <= 0 {
+		validTimes = 7 * 24 * 3600
+	}
+
+	if validTimes > atomic.LoadInt64(&s.maxValid) {
+		atomic.StoreInt64(&s.maxValid, validTimes)
+	}
+
+	cleanUser(user)
+	token = s.genToken(user.Id, validTimes)
+
+	raw, _ := json.Marshal(user)
+	s.Store.Set(statelessUserKey(user.Id), raw, validTimes)
+
+	err = addTokenInfo(s.Store, user.Id, token, info, validTimes)
+	return
+}
+
+// Token carry its expire time, can not be longer, only check it still valid
+func (s *StatelessSession) RefreshToken(token string, validTime int64) error {
+	_, err := s.check(token)
+	return err
+}
+
+func (s *StatelessSession) TouchToken(token string, ip string, ua string) error {
+	return touchTokenInfo(s.Store, token, ip, ua)
+}
+
+func (s *StatelessSession) DeleteToken(token string) (err error) {
+	err = s.revoke(token)
+	if err != nil {
+		return
+	}
+
+	id, err := TokenUserId(token)
+	if err != nil {
+		return nil
+	}
+	return s.Store.HDel(HashUserTokenKey(id), TokenId(token))
+}
+
+func (s *StatelessSession) ListUserToken(id int64) ([]TokenInfo, error) {
+	return listTokenInfo(s.Store, id, func(token string) (bool, error) {
+		_, err := s.check(token)
+		return err == nil, nil
+	})
+}
+
+func (s *StatelessSession) DeleteUserToken(id int64) error {
+	expire := atomic.LoadInt64(&s.maxValid)
+	if TokenValidTime > expire {
+		expire = TokenValidTime
+	}
+	err := s.Store.Set(revokeUserKey(id), []byte(strconv.FormatInt(time.Now().UnixNano(), 10)), expire)
+	if err != nil {
+		return err
+	}
+	return s.Store.Delete(HashUserTokenKey(id))
+}
+
+func (s *StatelessSession) DeleteUserTokenById(id int64, tokenId string) (err error) {
+	value, exist, err := s.Store.HGet(HashUserTokenKey(id), tokenId)
+	if err != nil {
+		return
+	}
+
+	if !exist {
+		return errors.New("token not found")
+	}
+
+	info := TokenInfo{}
+	if json.Unmarshal(value, &info) == nil {
+		err = s.revoke(info.Token)
+		if err != nil {
+			return
+		}
+	}
+
+	return s.Store.HDel(HashUserTokenKey(id), tokenId)
+}
+
+func (s *StatelessSession) DeleteUserTokenExcept(id int64, token string) (err error) {
+	all, err := s.Store.HGetAll(HashUserTokenKey(id))
+	if err != nil {
+		return
+	}
+
+	for tokenId, v := range all {
+		info := TokenInfo{}
+		if json.Unmarshal([]byte(v), &info) == nil {
+			if info.Token == token {
+				continue
+			}
+			s.revoke(info.Token)
+		}
+		s.Store.HDel(HashUserTokenKey(id), tokenId)
+	}
+	return
+}
+
+func (s *StatelessSession) DeleteUser(id int64) error {
+	return s.Store.Delete(statelessUserKey(id))
+}
+
+func (s *StatelessSession) AddUser(id int64, validTimes int64) (user *model.User, err error) {
+	user, err = getUser(id)
+	if err != nil {
+		return nil, err
+	}
+
+	raw, _ := json.Marshal(user)
+	err = s.Store.Set(statelessUserKey(user.Id), raw, validTimes)
+	if err != nil {
+		return nil, err
+	}
+	return
+}
+
+func (s *StatelessSession) RefreshUser(ids []int64, validTime int64) (err error) {
+	for _, id := range ids {
+		s.AddUser(id, validTime)
+	}
+	return
+}
@@ -0,0 +1,32 @@
+package util
+
+import (
+	"bytes"
+	"image/png"
+	"testing"
+)
+
+func TestCaptchaPNG(t *testing.T) {
+	code, err := GenCaptchaCode(5)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(code) != 5 {
+		t.Fatalf("code len wrong: %s", code)
+	}
+
+	raw, err := CaptchaPNG(code)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	img, err := png.Decode(bytes.NewReader(raw))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if img.Bounds().Dx() != captchaCell*5+2*captchaMargin || img.Bounds().Dy() != captchaHeight {
+		t.Fatalf("size wrong: %v", img.Bounds())
+	}
+}
@@ -0,0 +1,389 @@
+package util
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// edit too many will not find the shortest one, just delete all and insert all
+var DiffMaxEdit = 2000
+
+// unchanged lines around the change
+var DiffContext = 3
+
+const (
+	DiffEqual  = " "
+	DiffInsert = "+"
+	DiffDelete = "-"
+)
+
+type DiffWord struct {
+	Op   string `json:"op"`
+	Text string `json:"text"`
+}
+
+type DiffLine struct {
+	Op    string     `json:"op"`
+	Text  string     `json:"text"`
+	OldNo int        `json:"old_no,omitempty"`
+	NewNo int        `json:"new_no,omitempty"`
+	Words []DiffWord `json:"words,omitempty"` // word mode, change line will have
+}
+
+type DiffHunk struct {
+	OldStart int        `json:"old_start"`
+	OldLines int        `json:"old_lines"`
+	NewStart int        `json:"new_start"`
+	NewLines int        `json:"new_lines"`
+	Lines    []DiffLine `json:"lines"`
+}
+
+type Diff struct {
+	Hunks     []DiffHunk `json:"hunks"`
+	InsertNum int        `json:"insert_num"`
+	DeleteNum int        `json:"delete_num"`
+}
+
+// Split into lines, windows line end also ok
+func DiffSplitLines(s string) []string {
+	if s == "" {
+		return []string{}
+	}
+	s = strings.Replace(s, "\r\n", "\n", -1)
+	s = strings.TrimSuffix(s, "\n")
+	return strings.Split(s, "\n")
+}
+
+// Split into words, space keep as a word so join them can get the raw text, every cjk char is a word
+func DiffSplitWords(s string) []string {
+	words := make([]string, 0)
+	run := make([]rune, 0)
+	kind := 0
+
+	flush := func() {
+		if len(run) > 0 {
+			words = append(words, string(run))
+			run = run[:0]
+		}
+	}
+
+	for _, r := range s {
+		k := 3
+		if unicode.IsSpace(r) {
+			k = 1
+		} else if isCJK(r) {
+			k = 0
+		} else if unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_' {
+			k = 2
+		}
+
+		// cjk and punctuation every char one word
+		if k != kind || k == 0 || k == 3 {
+			flush()
+		}
+		kind = k
+		run = append(run, r)
+	}
+	flush()
+	return words
+}
+
+type diffOp struct {
+	op   string
+	text string
+}
+
+// Myers diff, the shortest edit script from a to b
+func diffTokens(a, b []string) []diffOp {
+	ops := make([]diffOp, 0, len(a)+len(b))
+
+	// same head and tail not need to diff
+	head := 0
+	for head < len(a) && head < len(b) && a[head] == b[head] {
+		head++
+	}
+	tail := 0
+	for tail < len(a)-head && tail < len(b)-head && a[len(a)-1-tail] == b[len(b)-1-tail] {
+		tail++
+	}
+
+	for _, v := range a[:head] {
+		ops = append(ops, diffOp{DiffEqual, v})
+	}
+	ops = append(ops, diffMiddle(a[head:len(a)-tail], b[head:len(b)-tail])...)
+	for _, v := range a[len(a)-tail:] {
+		ops = append(ops, diffOp{DiffEqual, v})
+	}
+	return ops
+}
+
+func diffMiddle(a, b []string) []diffOp {
+	n, m := len(a), len(b)
+	max := n + m
+	if max == 0 {
+		return nil
+	}
+
+	// v[k] is the furthest x on diagonal k, keep every step to back track
+	offset := max + 1
+	v := make([]int, 2*max+3)
+	trace := make([][]int, 0)
+	found := false
+	for d := 0; d <= max && d <= DiffMaxEdit; d++ {
+		snap := make([]int, 2*d+1)
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1]
+			} else {
+				x = v[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[offset+k] = x
+			if x >= n && y >= m {
+				found = true
+			}
+		}
+		for k := -d; k <= d; k++ {
+			snap[k+d] = v[offset+k]
+		}
+		trace = append(trace, snap)
+		if found {
+			break
+		}
+	}
+
+	if !found {
+		ops := make([]diffOp, 0, n+m)
+		for _, t := range a {
+			ops = append(ops, diffOp{DiffDelete, t})
+		}
+		for _, t := range b {
+			ops = append(ops, diffOp{DiffInsert, t})
+		}
+		return ops
+	}
+
+	// back track from the end
+	ops := make([]diffOp, 0, n+m)
+	x, y := n, m
+	for d := len(trace) - 1; d > 0; d-- {
+		prev := trace[d-1]
+		get := func(k int) int {
+			return prev[k+d-1]
+		}
+		k := x - y
+		var prevK int
+		if k == -d || (k != d && get(k-1) < get(k+1)) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+		prevX := get(prevK)
+		prevY := prevX - prevK
+		for x > prevX && y > prevY {
+			x--
+			y--
+			ops = append(ops, diffOp{DiffEqual, a[x]})
+		}
+		if x == prevX {
+			y--
+			ops = append(ops, diffOp{DiffInsert, b[y]})
+		} else {
+			x--
+			ops = append(ops, diffOp{DiffDelete, a[x]})
+		}
+	}
+	for x > 0 && y > 0 {
+		x--
+		y--
+		ops = append(ops, diffOp{DiffEqual, a[x]})
+	}
+
+	for i, j := 0, len(ops)-1; i < j; i, j = i+1, j-1 {
+		ops[i], ops[j] = ops[j], ops[i]
+	}
+	return ops
+}
+
+// Diff words of two text, near same op merge together
+func DiffWords(a, b string) []DiffWord {
+	words := make([]DiffWord, 0)
+	for _, o := range diffTokens(DiffSplitWords(a), DiffSplitWords(b)) {
+		if len(words) > 0 && words[len(words)-1].Op == o.op {
+			words[len(words)-1].Text += o.text
+			continue
+		}
+		words = append(words, DiffWord{Op: o.op, Text: o.text})
+	}
+	return words
+}
+
+// Line diff of two text, change lines group into hunks with context lines,
+// word is true will diff the words of the changed line pair
+func DiffText(a, b string, word bool) Diff {
+	result := Diff{Hunks: make([]DiffHunk, 0)}
+
+	lines := make([]DiffLine, 0)
+	oldNo, newNo := 0, 0
+	for _, o := range diffTokens(DiffSplitLines(a), DiffSplitLines(b)) {
+		l := DiffLine{Op: o.op, Text: o.text}
+		switch o.op {
+		case DiffEqual:
+			oldNo++
+			newNo++
+			l.OldNo, l.NewNo = oldNo, newNo
+		case DiffDelete:
+			oldNo++
+			l.OldNo = oldNo
+			result.DeleteNum++
+		case DiffInsert:
+			newNo++
+			l.NewNo = newNo
+			result.InsertNum++
+		}
+		lines = append(lines, l)
+	}
+
+	if word {
+		diffPairWords(lines)
+	}
+
+	context := DiffContext
+	if context < 0 {
+		context = 0
+	}
+
+	for i := 0; i < len(lines); {
+		if lines[i].Op == DiffEqual {
+			i++
+			continue
+		}
+
+		// hunk begin with context before, end when equal lines more than two context
+		start := i - context
+		if start < 0 {
+			start = 0
+		}
+		end := i
+		for end < len(lines) {
+			if lines[end].Op != DiffEqual {
+				end++
+				continue
+			}
+			same := 0
+			for end+same < len(lines) && lines[end+same].Op == DiffEqual {
+				same++
+			}
+			if end+same == len(lines) || same > 2*context {
+				end = end + same
+				if same > context {
+					end = end - same + context
+				}
+				break
+			}
+			end = end + same
+		}
+
+		h := DiffHunk{Lines: lines[start:end]}
+		for _, l := range h.Lines {
+			if l.Op != DiffInsert {
+				h.OldLines++
+				if h.OldStart == 0 {
+					h.OldStart = l.OldNo
+				}
+			}
+			if l.Op != DiffDelete {
+				h.NewLines++
+				if h.NewStart == 0 {
+					h.NewStart = l.NewNo
+				}
+			}
+		}
+
+		// no line in one side, start is the line before
+		if h.OldLines == 0 {
+			h.OldStart = diffLineBefore(lines, start, true)
+		}
+		if h.NewLines == 0 {
+			h.NewStart = diffLineBefore(lines, start, false)
+		}
+
+		result.Hunks = append(result.Hunks, h)
+		i = end
+	}
+	return result
+}
+
+func diffLineBefore(lines []DiffLine, index int, old bool) int {
+	for i := index - 1; i >= 0; i-- {
+		if old && lines[i].OldNo > 0 {
+			return lines[i].OldNo
+		}
+		if !old && lines[i].NewNo > 0 {
+			return lines[i].NewNo
+		}
+	}
+	return 0
+}
+
+// delete lines follow by insert lines, pair them one by one
+func diffPairWords(lines []DiffLine) {
+	for i := 0; i < len(lines); {
+		if lines[i].Op != DiffDelete {
+			i++
+			continue
+		}
+		del := i
+		for i < len(lines) && lines[i].Op == DiffDelete {
+			i++
+		}
+		ins := i
+		for i < len(lines) && lines[i].Op == DiffInsert {
+			i++
+		}
+
+		for j := 0; del+j < ins && ins+j < i; j++ {
+			oldLine, newLine := &lines[del+j], &lines[ins+j]
+			for _, w := range DiffWords(oldLine.Text, newLine.Text) {
+				if w.Op != DiffInsert {
+					oldLine.Words = append(oldLine.Words, w)
+				}
+				if w.Op != DiffDelete {
+					newLine.Words = append(newLine.Words, w)
+				}
+			}
+		}
+	}
+}
+
+// Unified diff text like git
+func (d Diff) Unified(oldName, newName string) string {
+	if len(d.Hunks) == 0 {
+		return ""
+	}
+
+	b := new(strings.Builder)
+	fmt.Fprintf(b, "--- %s\n+++ %s\n", oldName, newName)
+	for _, h := range d.Hunks {
+		fmt.Fprintf(b, "@@ -%s +%s @@\n", diffRange(h.OldStart, h.OldLines), diffRange(h.NewStart, h.NewLines))
+		for _, l := range h.Lines {
+			b.WriteString(l.Op)
+			b.WriteString(l.Text)
+			b.WriteString("\n")
+		}
+	}
+	return b.String()
+}
+
+func diffRange(start, lines int) string {
+	if lines == 1 {
+		return fmt.Sprintf("%d", start)
+	}
+	return fmt.Sprintf("%d,%d", start, lines)
+}
@@ -0,0 +1,75 @@
+package util
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDiffText(t *testing.T) {
+	a := "a\nb\nc\nd\ne\nf\ng\nh\ni\nj"
+	b := "a\nb\nc\nd\nE\nf\ng\nh\ni\nj\nk"
+
+	d := DiffText(a, b, false)
+	if d.InsertNum != 2 || d.DeleteNum != 1 {
+		t.Fatalf("insert %d delete %d wrong", d.InsertNum, d.DeleteNum)
+	}
+
+	want := "--- old\n+++ new\n@@ -2,9 +2,10 @@\n b\n c\n d\n-e\n+E\n f\n g\n h\n i\n j\n+k\n"
+	if got := d.Unified("old", "new"); got != want {
+		t.Fatalf("unified:\n%s\nwant:\n%s", got, want)
+	}
+
+	// far change split into two hunks
+	a = strings.Repeat("x\n", 10) + "a"
+	b = "y\n" + strings.Repeat("x\n", 10) + "b"
+	d = DiffText(a, b, false)
+	if len(d.Hunks) != 2 {
+		t.Fatalf("hunks %d wrong", len(d.Hunks))
+	}
+	if h := d.Hunks[0]; h.OldStart != 1 || h.OldLines != 3 || h.NewStart != 1 || h.NewLines != 4 {
+		t.Fatalf("first hunk %+v wrong", h)
+	}
+
+	if d = DiffText("same", "same", false); len(d.Hunks) != 0 || d.Unified("a", "b") != "" {
+		t.Fatalf("same text should no diff")
+	}
+
+	if d = DiffText("", "new", false); d.InsertNum != 1 || d.DeleteNum != 0 {
+		t.Fatalf("empty old diff wrong")
+	}
+}
+
+func TestDiffWords(t *testing.T) {
+	got := DiffWords("hello world 你好", "hello go 你们好")
+	want := []DiffWord{
+		{DiffEqual, "hello "},
+		{DiffDelete, "world"},
+		{DiffInsert, "go"},
+		{DiffEqual, " 你"},
+		{DiffInsert, "们"},
+		{DiffEqual, "好"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("words %v, want %v", got, want)
+	}
+
+	d := DiffText("one two\nsame", "one three\nsame", true)
+	lines := d.Hunks[0].Lines
+	if len(lines[0].Words) != 2 || lines[0].Words[1].Text != "two" || lines[1].Words[1].Text != "three" {
+		t.Fatalf("line words %+v wrong", lines)
+	}
+}
+
+func TestDiffMaxEdit(t *testing.T) {
+	old := DiffMaxEdit
+	DiffMaxEdit = 1
+	defer func() {
+		DiffMaxEdit = old
+	}()
+
+	d := DiffText("a\nb\nc", "x\nb\ny", false)
+	if d.InsertNum != 3 || d.DeleteNum != 3 {
+		t.Fatalf("too many edit should replace all, insert %d delete %d", d.InsertNum, d.DeleteNum)
+	}
+}
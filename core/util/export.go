@@ -0,0 +1,31 @@
+package util
+
+import (
+	"bytes"
+	"gopkg.in/yaml.v2"
+)
+
+// Markdown with yaml front matter, key in order, empty value not write, can import again
+func ExportMarkdown(keys []string, meta map[string]interface{}, body string) ([]byte, error) {
+	slice := make(yaml.MapSlice, 0, len(keys))
+	for _, k := range keys {
+		v, ok := meta[k]
+		if !ok || v == nil || v == "" {
+			continue
+		}
+		slice = append(slice, yaml.MapItem{Key: k, Value: v})
+	}
+
+	head, err := yaml.Marshal(slice)
+	if err != nil {
+		return nil, err
+	}
+
+	b := new(bytes.Buffer)
+	b.WriteString("---\n")
+	b.Write(head)
+	b.WriteString("---\n\n")
+	b.WriteString(body)
+	b.WriteString("\n")
+	return b.Bytes(), nil
+}
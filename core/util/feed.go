@@ -0,0 +1,318 @@
+package util
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	FeedRss  = "rss"
+	FeedAtom = "atom"
+	FeedJson = "json"
+)
+
+var feedContentType = map[string]string{
+	FeedRss:  "application/rss+xml; charset=utf-8",
+	FeedAtom: "application/atom+xml; charset=utf-8",
+	FeedJson: "application/feed+json; charset=utf-8",
+}
+
+type FeedItem struct {
+	Id        string
+	Title     string
+	Link      string
+	Author    string
+	Summary   string // plain text
+	Content   string // html, empty only has summary
+	Tags      []string
+	Published int64
+	Updated   int64
+}
+
+type Feed struct {
+	Title       string
+	Description string
+	Link        string // the web page
+	FeedLink    string // the feed self
+	Author      string
+	Updated     int64
+	Location    *time.Location // time show in this zone
+	Items       []FeedItem
+}
+
+// Split name like hunterhug.xml into hunterhug and rss, suffix not support return false
+func FeedFormat(name string) (string, string, bool) {
+	i := strings.LastIndex(name, ".")
+	if i < 0 {
+		return name, "", false
+	}
+
+	switch name[i+1:] {
+	case "xml", "rss":
+		return name[:i], FeedRss, true
+	case "atom":
+		return name[:i], FeedAtom, true
+	case "json":
+		return name[:i], FeedJson, true
+	}
+	return name, "", false
+}
+
+func (f *Feed) time(t int64) time.Time {
+	loc := f.Location
+	if loc == nil {
+		loc = time.UTC
+	}
+	return time.Unix(t, 0).In(loc)
+}
+
+// Render the feed, return the body and the content type
+func (f *Feed) Render(format string) ([]byte, string, error) {
+	var raw []byte
+	var err error
+	switch format {
+	case FeedRss:
+		raw, err = f.rss()
+	case FeedAtom:
+		raw, err = f.atom()
+	case FeedJson:
+		raw, err = f.json()
+	default:
+		err = fmt.Errorf("feed format %s not support", format)
+	}
+
+	if err != nil {
+		return nil, "", err
+	}
+	return raw, feedContentType[format], nil
+}
+
+type rssFeed struct {
+	XMLName xml.Name   `xml:"rss"`
+	Version string     `xml:"version,attr"`
+	AtomNS  string     `xml:"xmlns:atom,attr"`
+	DcNS    string     `xml:"xmlns:dc,attr"`
+	Channel rssChannel `xml:"channel"`
+}
+
+type rssChannel struct {
+	Title         string    `xml:"title"`
+	Link          string    `xml:"link"`
+	Description   string    `xml:"description"`
+	AtomLink      atomLink  `xml:"atom:link"`
+	LastBuildDate string    `xml:"lastBuildDate,omitempty"`
+	Items         []rssItem `xml:"item"`
+}
+
+type rssGuid struct {
+	IsPermaLink string `xml:"isPermaLink,attr"`
+	Value       string `xml:",chardata"`
+}
+
+type rssItem struct {
+	Title       string   `xml:"title"`
+	Link        string   `xml:"link"`
+	Guid        rssGuid  `xml:"guid"`
+	Creator     string   `xml:"dc:creator,omitempty"`
+	PubDate     string   `xml:"pubDate"`
+	Category    []string `xml:"category"`
+	Description string   `xml:"description"`
+}
+
+func (f *Feed) rss() ([]byte, error) {
+	r := rssFeed{Version: "2.0", AtomNS: "http://www.w3.org/2005/Atom", DcNS: "http://purl.org/dc/elements/1.1/"}
+	r.Channel = rssChannel{
+		Title:       f.Title,
+		Link:        f.Link,
+		Description: f.Description,
+		AtomLink:    atomLink{Href: f.FeedLink, Rel: "self", Type: "application/rss+xml"},
+		Items:       make([]rssItem, 0, len(f.Items)),
+	}
+
+	if f.Updated > 0 {
+		r.Channel.LastBuildDate = f.time(f.Updated).Format(time.RFC1123Z)
+	}
+
+	for _, v := range f.Items {
+		item := rssItem{
+			Title:       v.Title,
+			Link:        v.Link,
+			Guid:        rssGuid{IsPermaLink: "false", Value: v.Id},
+			Creator:     v.Author,
+			PubDate:     f.time(v.Published).Format(time.RFC1123Z),
+			Category:    v.Tags,
+			Description: v.Summary,
+		}
+		if v.Content != "" {
+			item.Description = v.Content
+		}
+		r.Channel.Items = append(r.Channel.Items, item)
+	}
+
+	raw, err := xml.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), raw...), nil
+}
+
+type atomFeed struct {
+	XMLName  xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	Title    string      `xml:"title"`
+	Subtitle string      `xml:"subtitle,omitempty"`
+	Id       string      `xml:"id"`
+	Updated  string      `xml:"updated"`
+	Links    []atomLink  `xml:"link"`
+	Author   *atomPerson `xml:"author,omitempty"`
+	Entries  []atomEntry `xml:"entry"`
+}
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr,omitempty"`
+	Type string `xml:"type,attr,omitempty"`
+}
+
+type atomPerson struct {
+	Name string `xml:"name"`
+}
+
+type atomText struct {
+	Type string `xml:"type,attr"`
+	Body string `xml:",chardata"`
+}
+
+type atomCategory struct {
+	Term string `xml:"term,attr"`
+}
+
+type atomEntry struct {
+	Title      string         `xml:"title"`
+	Id         string         `xml:"id"`
+	Link       atomLink       `xml:"link"`
+	Published  string         `xml:"published"`
+	Updated    string         `xml:"updated"`
+	Author     *atomPerson    `xml:"author,omitempty"`
+	Categories []atomCategory `xml:"category"`
+	Summary    *atomText      `xml:"summary,omitempty"`
+	Content    *atomText      `xml:"content,omitempty"`
+}
+
+func (f *Feed) atom() ([]byte, error) {
+	a := atomFeed{
+		Title:    f.Title,
+		Subtitle: f.Description,
+		Id:       f.FeedLink,
+		Updated:  f.time(f.Updated).Format(time.RFC3339),
+		Links:    []atomLink{{Href: f.Link, Rel: "alternate"}, {Href: f.FeedLink, Rel: "self"}},
+		Entries:  make([]atomEntry, 0, len(f.Items)),
+	}
+
+	if f.Author != "" {
+		a.Author = &atomPerson{Name: f.Author}
+	}
+
+	for _, v := range f.Items {
+		e := atomEntry{
+			Title:     v.Title,
+			Id:        v.Id,
+			Link:      atomLink{Href: v.Link, Rel: "alternate"},
+			Published: f.time(v.Published).Format(time.RFC3339),
+			Updated:   f.time(v.Updated).Format(time.RFC3339),
+		}
+
+		if v.Author != "" {
+			e.Author = &atomPerson{Name: v.Author}
+		}
+
+		for _, t := range v.Tags {
+			e.Categories = append(e.Categories, atomCategory{Term: t})
+		}
+
+		if v.Summary != "" {
+			e.Summary = &atomText{Type: "text", Body: v.Summary}
+		}
+
+		if v.Content != "" {
+			e.Content = &atomText{Type: "html", Body: v.Content}
+		}
+		a.Entries = append(a.Entries, e)
+	}
+
+	raw, err := xml.MarshalIndent(a, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), raw...), nil
+}
+
+type jsonFeed struct {
+	Version     string         `json:"version"`
+	Title       string         `json:"title"`
+	HomePageUrl string         `json:"home_page_url"`
+	FeedUrl     string         `json:"feed_url"`
+	Description string         `json:"description,omitempty"`
+	Authors     []jsonAuthor   `json:"authors,omitempty"`
+	Items       []jsonFeedItem `json:"items"`
+}
+
+type jsonAuthor struct {
+	Name string `json:"name"`
+}
+
+type jsonFeedItem struct {
+	Id            string       `json:"id"`
+	Url           string       `json:"url"`
+	Title         string       `json:"title"`
+	ContentHtml   string       `json:"content_html,omitempty"`
+	ContentText   string       `json:"content_text,omitempty"`
+	Summary       string       `json:"summary,omitempty"`
+	DatePublished string       `json:"date_published"`
+	DateModified  string       `json:"date_modified"`
+	Authors       []jsonAuthor `json:"authors,omitempty"`
+	Tags          []string     `json:"tags,omitempty"`
+}
+
+func (f *Feed) json() ([]byte, error) {
+	j := jsonFeed{
+		Version:     "https://jsonfeed.org/version/1.1",
+		Title:       f.Title,
+		HomePageUrl: f.Link,
+		FeedUrl:     f.FeedLink,
+		Description: f.Description,
+		Items:       make([]jsonFeedItem, 0, len(f.Items)),
+	}
+
+	if f.Author != "" {
+		j.Authors = []jsonAuthor{{Name: f.Author}}
+	}
+
+	for _, v := range f.Items {
+		item := jsonFeedItem{
+			Id:            v.Id,
+			Url:           v.Link,
+			Title:         v.Title,
+			Summary:       v.Summary,
+			DatePublished: f.time(v.Published).Format(time.RFC3339),
+			DateModified:  f.time(v.Updated).Format(time.RFC3339),
+			Tags:          v.Tags,
+		}
+
+		// one of content html and text must have
+		if v.Content != "" {
+			item.ContentHtml = v.Content
+		} else {
+			item.ContentText = v.Summary
+		}
+
+		if v.Author != "" {
+			item.Authors = []jsonAuthor{{Name: v.Author}}
+		}
+		j.Items = append(j.Items, item)
+	}
+
+	return json.MarshalIndent(j, "", "  ")
+}
@@ -0,0 +1,89 @@
+package util
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testFeed() *Feed {
+	return &Feed{
+		Title:    "FaFa",
+		Link:     "http://127.0.0.1/u/fafa",
+		FeedLink: "http://127.0.0.1/feed/fafa.xml",
+		Author:   "fafa",
+		Updated:  1572000000,
+		Location: time.FixedZone("", 8*3600),
+		Items: []FeedItem{
+			{Id: "1", Title: "a<b", Link: "http://127.0.0.1/u/fafa/c/1", Author: "fafa", Summary: "sum", Content: "<p>x</p>",
+				Tags: []string{"go"}, Published: 1572000000, Updated: 1572000000},
+		},
+	}
+}
+
+func TestFeedFormat(t *testing.T) {
+	cases := map[string][]string{
+		"fafa.xml":  {"fafa", FeedRss},
+		"fafa.rss":  {"fafa", FeedRss},
+		"a.b.atom":  {"a.b", FeedAtom},
+		"fafa.json": {"fafa", FeedJson},
+		"fafa.html": nil,
+		"fafa":      nil,
+	}
+
+	for in, want := range cases {
+		name, format, ok := FeedFormat(in)
+		if want == nil {
+			if ok {
+				t.Fatalf("%s should not support", in)
+			}
+			continue
+		}
+		if !ok || name != want[0] || format != want[1] {
+			t.Fatalf("%s got %s %s", in, name, format)
+		}
+	}
+}
+
+func TestFeedRender(t *testing.T) {
+	f := testFeed()
+
+	raw, contentType, err := f.Render(FeedRss)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := string(raw)
+	if !strings.HasPrefix(contentType, "application/rss+xml") || !strings.Contains(s, "<title>a&lt;b</title>") ||
+		!strings.Contains(s, "+0800</pubDate>") || !strings.Contains(s, "<dc:creator>fafa</dc:creator>") ||
+		!strings.Contains(s, "&lt;p&gt;x&lt;/p&gt;") {
+		t.Fatalf("rss wrong:\n%s", s)
+	}
+
+	raw, _, err = f.Render(FeedAtom)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s = string(raw)
+	if !strings.Contains(s, `<feed xmlns="http://www.w3.org/2005/Atom">`) || !strings.Contains(s, "<updated>2019-10-25T18:40:00+08:00</updated>") ||
+		!strings.Contains(s, `<content type="html">`) {
+		t.Fatalf("atom wrong:\n%s", s)
+	}
+
+	raw, _, err = f.Render(FeedJson)
+	if err != nil {
+		t.Fatal(err)
+	}
+	j := make(map[string]interface{})
+	if err := json.Unmarshal(raw, &j); err != nil {
+		t.Fatal(err)
+	}
+	items := j["items"].([]interface{})
+	if len(items) != 1 || items[0].(map[string]interface{})["content_html"] != "<p>x</p>" {
+		t.Fatalf("json wrong:\n%s", raw)
+	}
+
+	if _, _, err = f.Render("html"); err == nil {
+		t.Fatalf("html should not support")
+	}
+}
@@ -0,0 +1,217 @@
+package util
+
+import (
+	"fmt"
+	"html"
+	"regexp"
+	"strings"
+)
+
+// A small html to markdown, for the content import from other blog,
+// tag not know will keep the text only
+
+type htmlNode struct {
+	tag      string // empty is text
+	text     string
+	attr     map[string]string
+	children []*htmlNode
+	parent   *htmlNode
+}
+
+var (
+	htmlVoid     = map[string]bool{"br": true, "img": true, "hr": true, "input": true, "meta": true, "link": true, "source": true, "wbr": true, "col": true, "embed": true}
+	htmlRaw      = map[string]bool{"script": true, "style": true, "pre": true, "textarea": true}
+	htmlBlock    = map[string]bool{"p": true, "div": true, "section": true, "article": true, "figure": true, "figcaption": true, "table": true, "tr": true, "header": true, "footer": true, "center": true}
+	htmlAttr     = regexp.MustCompile(`([a-zA-Z_:][-a-zA-Z0-9_:.]*)(?:\s*=\s*(?:"([^"]*)"|'([^']*)'|([^\s"'=<>` + "`" + `]+)))?`)
+	htmlTagName  = regexp.MustCompile(`^</?([a-zA-Z][a-zA-Z0-9]*)`)
+	htmlSpace    = regexp.MustCompile(`[ \t\r\f\v]+`)
+	htmlNewLines = regexp.MustCompile(`\n{3,}`)
+)
+
+func htmlParse(s string) *htmlNode {
+	root := &htmlNode{tag: "root"}
+	cur := root
+	for i := 0; i < len(s); {
+		if s[i] != '<' {
+			end := strings.IndexByte(s[i:], '<')
+			if end < 0 {
+				end = len(s) - i
+			}
+			cur.children = append(cur.children, &htmlNode{text: s[i : i+end], parent: cur})
+			i = i + end
+			continue
+		}
+
+		if strings.HasPrefix(s[i:], "<!--") {
+			end := strings.Index(s[i:], "-->")
+			if end < 0 {
+				break
+			}
+			i = i + end + 3
+			continue
+		}
+
+		end := strings.IndexByte(s[i:], '>')
+		m := htmlTagName.FindStringSubmatch(s[i:])
+		if end < 0 || m == nil {
+			cur.children = append(cur.children, &htmlNode{text: s[i : i+1], parent: cur})
+			i++
+			continue
+		}
+
+		raw := s[i+1 : i+end]
+		i = i + end + 1
+		tag := strings.ToLower(m[1])
+
+		// close the tag, not open will ignore
+		if strings.HasPrefix(raw, "/") {
+			for n := cur; n != root; n = n.parent {
+				if n.tag == tag {
+					cur = n.parent
+					break
+				}
+			}
+			continue
+		}
+
+		node := &htmlNode{tag: tag, attr: make(map[string]string), parent: cur}
+		for _, a := range htmlAttr.FindAllStringSubmatch(strings.TrimSuffix(raw[len(m[1]):], "/"), -1) {
+			node.attr[strings.ToLower(a[1])] = html.UnescapeString(a[2] + a[3] + a[4])
+		}
+		cur.children = append(cur.children, node)
+
+		if htmlVoid[tag] || strings.HasSuffix(raw, "/") {
+			continue
+		}
+
+		// text inside not parse, until the close tag
+		if htmlRaw[tag] {
+			closeAt := strings.Index(strings.ToLower(s[i:]), "</"+tag)
+			if closeAt < 0 {
+				closeAt = len(s) - i
+			}
+			inner := s[i : i+closeAt]
+			if tag == "pre" {
+				inner = mdTag.ReplaceAllString(inner, "")
+			}
+			node.children = append(node.children, &htmlNode{text: inner, parent: node})
+			i = i + closeAt
+			if end := strings.IndexByte(s[i:], '>'); end >= 0 {
+				i = i + end + 1
+			}
+			continue
+		}
+
+		cur = node
+	}
+	return root
+}
+
+func (n *htmlNode) inPre() bool {
+	for p := n.parent; p != nil; p = p.parent {
+		if p.tag == "pre" || p.tag == "code" {
+			return true
+		}
+	}
+	return false
+}
+
+func (n *htmlNode) markdown(b *strings.Builder) {
+	if n.tag == "" {
+		text := html.UnescapeString(n.text)
+		if !n.inPre() {
+			text = htmlSpace.ReplaceAllString(text, " ")
+		}
+		b.WriteString(text)
+		return
+	}
+
+	inner := func() string {
+		c := new(strings.Builder)
+		for _, v := range n.children {
+			v.markdown(c)
+		}
+		return c.String()
+	}
+
+	switch n.tag {
+	case "script", "style", "textarea", "head", "title":
+	case "li":
+		b.WriteString(inner())
+	case "br":
+		b.WriteString("\n")
+	case "hr":
+		b.WriteString("\n\n---\n\n")
+	case "h1", "h2", "h3", "h4", "h5", "h6":
+		text := strings.TrimSpace(strings.Replace(inner(), "\n", " ", -1))
+		fmt.Fprintf(b, "\n\n%s %s\n\n", strings.Repeat("#", int(n.tag[1]-'0')), text)
+	case "strong", "b":
+		if text := strings.TrimSpace(inner()); text != "" {
+			b.WriteString("**" + text + "**")
+		}
+	case "em", "i":
+		if text := strings.TrimSpace(inner()); text != "" {
+			b.WriteString("*" + text + "*")
+		}
+	case "code":
+		b.WriteString("`" + inner() + "`")
+	case "pre":
+		text := strings.Trim(inner(), "\n")
+		fmt.Fprintf(b, "\n\n```\n%s\n```\n\n", strings.Trim(text, "`"))
+	case "a":
+		text := strings.TrimSpace(inner())
+		href := n.attr["href"]
+		if href == "" || text == "" {
+			b.WriteString(text)
+		} else {
+			fmt.Fprintf(b, "[%s](%s)", text, strings.Replace(href, " ", "%20", -1))
+		}
+	case "img":
+		if src := n.attr["src"]; src != "" {
+			fmt.Fprintf(b, "![%s](%s)", strings.NewReplacer("[", "", "]", "").Replace(n.attr["alt"]), strings.Replace(src, " ", "%20", -1))
+		}
+	case "blockquote":
+		lines := strings.Split(strings.TrimSpace(inner()), "\n")
+		for k, v := range lines {
+			lines[k] = strings.TrimRight("> "+v, " ")
+		}
+		b.WriteString("\n\n" + strings.Join(lines, "\n") + "\n\n")
+	case "ul", "ol":
+		b.WriteString("\n\n")
+		num := 0
+		for _, v := range n.children {
+			if v.tag != "li" {
+				continue
+			}
+			num++
+			mark := "- "
+			if n.tag == "ol" {
+				mark = fmt.Sprintf("%d. ", num)
+			}
+			c := new(strings.Builder)
+			v.markdown(c)
+			text := strings.TrimSpace(htmlNewLines.ReplaceAllString(c.String(), "\n"))
+			text = strings.Replace(text, "\n\n", "\n", -1)
+			b.WriteString(mark + strings.Replace(text, "\n", "\n   ", -1) + "\n")
+		}
+		b.WriteString("\n")
+	default:
+		if htmlBlock[n.tag] {
+			b.WriteString("\n\n" + strings.TrimSpace(inner()) + "\n\n")
+			return
+		}
+		b.WriteString(inner())
+	}
+}
+
+// Html to markdown, blank line between the text still be a new paragraph like wordpress
+func HtmlToMarkdown(s string) string {
+	b := new(strings.Builder)
+	htmlParse(strings.Replace(s, "\r\n", "\n", -1)).markdown(b)
+
+	lines := strings.Split(b.String(), "\n")
+	for k, v := range lines {
+		lines[k] = strings.TrimRight(v, " ")
+	}
+	return strings.TrimSpace(htmlNewLines.ReplaceAllString(strings.Join(lines, "\n"), "\n\n"))
+}
@@ -0,0 +1,563 @@
+package util
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"gopkg.in/yaml.v2"
+	"html"
+	"io/ioutil"
+	"net/url"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// One post parse from markdown file or wordpress export
+type ImportPost struct {
+	Path       string   `json:"path"` // file in the zip, or link of wordpress
+	Title      string   `json:"title"`
+	Seo        string   `json:"seo"`
+	Describe   string   `json:"-"` // markdown
+	Categories []string `json:"categories"`
+	Tags       []string `json:"tags"`
+	Date       int64    `json:"date"`    // first publish time, 0 not know
+	Updated    int64    `json:"updated"` // last publish time
+	Draft      bool     `json:"draft"`
+	Hide       bool     `json:"hide"`
+	Password   string   `json:"-"`
+	Error      string   `json:"error,omitempty"` // parse fail
+}
+
+var importDateLayouts = []string{
+	time.RFC3339,
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05 -0700",
+	"2006-01-02 15:04:05 -07:00",
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04 -0700",
+	"2006-01-02 15:04",
+	"2006-01-02",
+	time.RFC1123Z,
+	time.RFC1123,
+}
+
+// jekyll post file name: 2019-10-01-hello-world.md
+var importJekyllName = regexp.MustCompile(`^(\d{4}-\d{2}-\d{2})-(.+)$`)
+
+// Unzip all the file into memory, all file size sum more than maxSize will fail
+func ImportUnzip(raw []byte, maxSize int64) (map[string][]byte, error) {
+	r, err := zip.NewReader(bytes.NewReader(raw), int64(len(raw)))
+	if err != nil {
+		return nil, err
+	}
+
+	var size int64
+	files := make(map[string][]byte)
+	for _, f := range r.File {
+		name := path.Clean(strings.Replace(f.Name, "\\", "/", -1))
+		if f.FileInfo().IsDir() || strings.HasPrefix(name, "__MACOSX/") || strings.HasPrefix(name, "../") || path.IsAbs(name) {
+			continue
+		}
+
+		size = size + int64(f.UncompressedSize64)
+		if size > maxSize {
+			return nil, fmt.Errorf("file in zip too big, more than %d", maxSize)
+		}
+
+		rc, err := f.Open()
+		if err != nil {
+			return nil, err
+		}
+
+		b, err := ioutil.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			return nil, err
+		}
+		files[name] = b
+	}
+	return files, nil
+}
+
+// Find all the post in the files, markdown file one post, wordpress export many post,
+// file begin with _ or . is not a post, such as _index.md of hugo
+func ImportParse(files map[string][]byte, loc *time.Location) []ImportPost {
+	names := make([]string, 0, len(files))
+	for k := range files {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+
+	posts := make([]ImportPost, 0)
+	for _, name := range names {
+		base := path.Base(name)
+		if strings.HasPrefix(base, "_") || strings.HasPrefix(base, ".") {
+			continue
+		}
+
+		switch strings.ToLower(path.Ext(name)) {
+		case ".md", ".markdown", ".mdown":
+			posts = append(posts, ImportMarkdown(name, files[name], loc))
+		case ".xml":
+			if !bytes.Contains(files[name], []byte("wordpress.org/export/")) {
+				continue
+			}
+
+			ps, err := ImportWxr(files[name], loc)
+			if err != nil {
+				posts = append(posts, ImportPost{Path: name, Error: err.Error()})
+				continue
+			}
+			posts = append(posts, ps...)
+		}
+	}
+	return posts
+}
+
+// Split the front matter, yaml between ---, toml between +++
+func ImportFrontMatter(raw []byte) (map[string]interface{}, string, error) {
+	s := strings.TrimPrefix(strings.Replace(string(raw), "\r\n", "\n", -1), "\ufeff")
+	meta := make(map[string]interface{})
+	for _, sep := range []string{"---", "+++"} {
+		if !strings.HasPrefix(s, sep+"\n") {
+			continue
+		}
+
+		rest := s[len(sep)+1:]
+		end := strings.Index(rest, "\n"+sep)
+		var head, body string
+		if strings.HasPrefix(rest, sep) {
+			body = rest[len(sep):]
+		} else if end < 0 {
+			return nil, "", errors.New("front matter not close")
+		} else {
+			head = rest[:end]
+			body = rest[end+len(sep)+1:]
+		}
+
+		var err error
+		if sep == "---" {
+			err = yaml.Unmarshal([]byte(head), &meta)
+		} else {
+			meta, err = importToml(head)
+		}
+		if err != nil {
+			return nil, "", err
+		}
+
+		lower := make(map[string]interface{}, len(meta))
+		for k, v := range meta {
+			lower[strings.ToLower(k)] = v
+		}
+		return lower, strings.TrimLeft(body, "\n"), nil
+	}
+	return meta, s, nil
+}
+
+// Only the key = value line of toml, table will be the key prefix
+func importToml(s string) (map[string]interface{}, error) {
+	meta := make(map[string]interface{})
+	prefix := ""
+	lines := strings.Split(s, "\n")
+	for i := 0; i < len(lines); i++ {
+		line := strings.TrimSpace(lines[i])
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			prefix = strings.Trim(line, "[] ") + "."
+			continue
+		}
+
+		eq := strings.Index(line, "=")
+		if eq <= 0 {
+			return nil, fmt.Errorf("toml line %d wrong: %s", i+1, line)
+		}
+
+		key := strings.Trim(strings.TrimSpace(line[:eq]), `"'`)
+		value := strings.TrimSpace(line[eq+1:])
+
+		// array can be many lines
+		for strings.HasPrefix(value, "[") && !strings.HasSuffix(value, "]") && i+1 < len(lines) {
+			i++
+			value = value + " " + strings.TrimSpace(lines[i])
+		}
+
+		v, err := importTomlValue(value)
+		if err != nil {
+			return nil, fmt.Errorf("toml line %d wrong: %s", i+1, err.Error())
+		}
+		meta[prefix+key] = v
+	}
+	return meta, nil
+}
+
+func importTomlValue(s string) (interface{}, error) {
+	switch {
+	case strings.HasPrefix(s, "["):
+		inner := strings.TrimSpace(strings.TrimSuffix(strings.TrimPrefix(s, "["), "]"))
+		list := make([]interface{}, 0)
+		for inner != "" {
+			var item string
+			if inner[0] == '"' || inner[0] == '\'' {
+				end := strings.IndexByte(inner[1:], inner[0])
+				if end < 0 {
+					return nil, errors.New("string not close")
+				}
+				item = inner[:end+2]
+				inner = inner[end+2:]
+			} else if comma := strings.IndexByte(inner, ','); comma >= 0 {
+				item = inner[:comma]
+				inner = inner[comma:]
+			} else {
+				item = inner
+				inner = ""
+			}
+
+			v, err := importTomlValue(strings.TrimSpace(item))
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, v)
+			inner = strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(inner), ","))
+		}
+		return list, nil
+	case strings.HasPrefix(s, `"`):
+		end := strings.LastIndex(s, `"`)
+		if end <= 0 {
+			return nil, errors.New("string not close")
+		}
+		return strconv.Unquote(s[:end+1])
+	case strings.HasPrefix(s, "'"):
+		end := strings.LastIndex(s, "'")
+		if end <= 0 {
+			return nil, errors.New("string not close")
+		}
+		return s[1:end], nil
+	}
+
+	// comment after the value
+	if i := strings.Index(s, " #"); i >= 0 {
+		s = strings.TrimSpace(s[:i])
+	}
+
+	if s == "true" || s == "false" {
+		return s == "true", nil
+	}
+
+	if i, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return i, nil
+	}
+	return s, nil
+}
+
+func importString(v interface{}) string {
+	switch x := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return strings.TrimSpace(x)
+	case time.Time:
+		return x.Format(time.RFC3339)
+	default:
+		return strings.TrimSpace(fmt.Sprint(x))
+	}
+}
+
+// list or string, string split by comma, or space when no comma
+func importStrings(v interface{}) []string {
+	result := make([]string, 0)
+	switch x := v.(type) {
+	case []interface{}:
+		for _, item := range x {
+			if s := importString(item); s != "" {
+				result = append(result, s)
+			}
+		}
+	case string:
+		sep := strings.Fields
+		if strings.Contains(x, ",") {
+			sep = func(s string) []string { return strings.Split(s, ",") }
+		}
+		for _, s := range sep(x) {
+			if s = strings.TrimSpace(s); s != "" {
+				result = append(result, s)
+			}
+		}
+	}
+	return result
+}
+
+func importBool(v interface{}) (bool, bool) {
+	switch x := v.(type) {
+	case bool:
+		return x, true
+	case string:
+		b, err := strconv.ParseBool(strings.TrimSpace(x))
+		return b, err == nil
+	}
+	return false, false
+}
+
+// Time string to unix, no zone use the loc
+func ImportDate(v interface{}, loc *time.Location) int64 {
+	if t, ok := v.(time.Time); ok {
+		return t.Unix()
+	}
+
+	s := importString(v)
+	if s == "" {
+		return 0
+	}
+
+	if loc == nil {
+		loc = time.UTC
+	}
+
+	for _, layout := range importDateLayouts {
+		if t, err := time.ParseInLocation(layout, s, loc); err == nil {
+			return t.Unix()
+		}
+	}
+	return 0
+}
+
+// Seo only can be unicode letter and number
+func ImportSeo(s string) string {
+	b := new(strings.Builder)
+	for _, r := range s {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			b.WriteRune(r)
+		}
+	}
+	return Substr(b.String(), 0, 100)
+}
+
+// Markdown with front matter of hugo or jekyll
+func ImportMarkdown(name string, raw []byte, loc *time.Location) ImportPost {
+	post := ImportPost{Path: name}
+	meta, body, err := ImportFrontMatter(raw)
+	if err != nil {
+		post.Error = err.Error()
+		return post
+	}
+
+	// hugo page bundle: post/hello/index.md, the dir is the name
+	slug := strings.TrimSuffix(path.Base(name), path.Ext(name))
+	if strings.ToLower(slug) == "index" && path.Dir(name) != "." {
+		slug = path.Base(path.Dir(name))
+	}
+
+	if m := importJekyllName.FindStringSubmatch(slug); m != nil {
+		post.Date = ImportDate(m[1], loc)
+		slug = m[2]
+	}
+
+	post.Title = importString(meta["title"])
+	if post.Title == "" {
+		post.Title = slug
+	}
+
+	post.Seo = ImportSeo(importString(meta["slug"]))
+	if post.Seo == "" {
+		post.Seo = ImportSeo(importString(meta["seo"]))
+	}
+	if post.Seo == "" {
+		post.Seo = ImportSeo(slug)
+	}
+
+	for _, k := range []string{"date", "publishdate"} {
+		if d := ImportDate(meta[k], loc); d > 0 {
+			post.Date = d
+			break
+		}
+	}
+
+	for _, k := range []string{"lastmod", "last_modified_at", "updated"} {
+		if d := ImportDate(meta[k], loc); d > 0 {
+			post.Updated = d
+			break
+		}
+	}
+
+	post.Categories = importStrings(meta["categories"])
+	if c := importString(meta["category"]); c != "" {
+		post.Categories = append([]string{c}, post.Categories...)
+	}
+	post.Tags = importStrings(meta["tags"])
+
+	if draft, ok := importBool(meta["draft"]); ok && draft {
+		post.Draft = true
+	}
+
+	if published, ok := importBool(meta["published"]); ok && !published {
+		post.Draft = true
+	}
+
+	post.Describe = strings.TrimSpace(body)
+	if post.Describe == "" {
+		post.Error = "describe empty"
+	}
+	return post
+}
+
+type wxrRss struct {
+	Items []wxrItem `xml:"channel>item"`
+}
+
+type wxrCategory struct {
+	Domain string `xml:"domain,attr"`
+	Name   string `xml:",chardata"`
+}
+
+type wxrItem struct {
+	Title       string        `xml:"title"`
+	Link        string        `xml:"link"`
+	PubDate     string        `xml:"pubDate"`
+	Content     string        `xml:"http://purl.org/rss/1.0/modules/content/ encoded"`
+	PostName    string        `xml:"post_name"`
+	PostDate    string        `xml:"post_date"`
+	PostDateGmt string        `xml:"post_date_gmt"`
+	Modified    string        `xml:"post_modified_gmt"`
+	Status      string        `xml:"status"`
+	PostType    string        `xml:"post_type"`
+	Password    string        `xml:"post_password"`
+	Categories  []wxrCategory `xml:"category"`
+}
+
+// WordPress export, only post and page, html will change to markdown
+func ImportWxr(raw []byte, loc *time.Location) ([]ImportPost, error) {
+	rss := new(wxrRss)
+	d := xml.NewDecoder(bytes.NewReader(raw))
+	d.Strict = false
+	if err := d.Decode(rss); err != nil {
+		return nil, err
+	}
+
+	posts := make([]ImportPost, 0, len(rss.Items))
+	for _, v := range rss.Items {
+		if v.PostType != "post" && v.PostType != "page" {
+			continue
+		}
+
+		if v.Status == "trash" || v.Status == "auto-draft" || v.Status == "inherit" {
+			continue
+		}
+
+		post := ImportPost{Path: v.Link, Title: strings.TrimSpace(v.Title), Password: v.Password}
+		post.Seo = ImportSeo(v.PostName)
+		if post.Seo == "" {
+			if name, err := url.PathUnescape(v.PostName); err == nil {
+				post.Seo = ImportSeo(name)
+			}
+		}
+
+		post.Date = ImportDate(v.PostDateGmt, time.UTC)
+		if post.Date <= 0 {
+			post.Date = ImportDate(v.PostDate, loc)
+		}
+		if post.Date <= 0 {
+			post.Date = ImportDate(v.PubDate, loc)
+		}
+		post.Updated = ImportDate(v.Modified, time.UTC)
+
+		for _, c := range v.Categories {
+			name := strings.TrimSpace(html.UnescapeString(c.Name))
+			if name == "" {
+				continue
+			}
+			if c.Domain == "category" {
+				post.Categories = append(post.Categories, name)
+			} else if c.Domain == "post_tag" {
+				post.Tags = append(post.Tags, name)
+			}
+		}
+
+		switch v.Status {
+		case "draft", "pending", "future":
+			post.Draft = true
+		case "private":
+			post.Hide = true
+		}
+
+		post.Describe = HtmlToMarkdown(v.Content)
+		if post.Title == "" {
+			post.Title = post.Seo
+		}
+		if post.Describe == "" {
+			post.Error = "describe empty"
+		}
+		posts = append(posts, post)
+	}
+	return posts, nil
+}
+
+var (
+	importMdImage  = regexp.MustCompile(`!\[[^\]]*\]\(\s*<?([^)\s>]+)>?(?:\s+"[^"]*")?\s*\)`)
+	importHtmlSkip = regexp.MustCompile(`^(?i)(https?:)?//|^(?i)(data|mailto):|^#`)
+)
+
+// Image in the markdown, remote one not include
+func ImportImages(body string) []string {
+	images := make([]string, 0)
+	exist := make(map[string]bool)
+	for _, m := range importMdImage.FindAllStringSubmatch(body, -1) {
+		if !exist[m[1]] {
+			exist[m[1]] = true
+			images = append(images, m[1])
+		}
+	}
+	return images
+}
+
+// Find the image in the files, relative the post first, then the root and static dir of hugo,
+// remote url like wordpress upload will find by the path end
+func ImportFindFile(files map[string][]byte, postPath string, ref string) (string, bool) {
+	if u, err := url.Parse(ref); err == nil {
+		if importHtmlSkip.MatchString(ref) {
+			if u.Host == "" || u.Path == "" {
+				return "", false
+			}
+			ref = u.Path
+			suffix := "/" + strings.TrimPrefix(path.Clean(ref), "/")
+			names := make([]string, 0)
+			for k := range files {
+				if strings.HasSuffix("/"+k, suffix) {
+					names = append(names, k)
+				}
+			}
+			if len(names) == 0 {
+				return "", false
+			}
+			sort.Strings(names)
+			return names[0], true
+		}
+		ref = u.Path
+	}
+
+	if p, err := url.PathUnescape(ref); err == nil {
+		ref = p
+	}
+
+	candidates := make([]string, 0, 3)
+	if strings.HasPrefix(ref, "/") {
+		candidates = append(candidates, path.Clean(ref[1:]), path.Join("static", ref))
+	} else {
+		candidates = append(candidates, path.Join(path.Dir(postPath), ref), path.Clean(ref), path.Join("static", ref))
+	}
+
+	for _, v := range candidates {
+		if _, ok := files[v]; ok {
+			return v, true
+		}
+	}
+	return "", false
+}
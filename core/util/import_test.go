@@ -0,0 +1,152 @@
+package util
+
+import (
+	"archive/zip"
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestImportMarkdown(t *testing.T) {
+	loc := time.FixedZone("", 8*3600)
+
+	yml := "---\ntitle: Hello World\ndate: 2019-10-01 12:00:00 +0800\ncategories: [Golang]\ntags: go web\ndraft: true\n---\n\n# Hi\n"
+	post := ImportMarkdown("_posts/2019-09-01-hello-world.md", []byte(yml), loc)
+	if post.Error != "" || post.Title != "Hello World" || post.Seo != "helloworld" || !post.Draft ||
+		post.Date != 1569902400 || !reflect.DeepEqual(post.Categories, []string{"Golang"}) ||
+		!reflect.DeepEqual(post.Tags, []string{"go", "web"}) || post.Describe != "# Hi" {
+		t.Fatalf("yaml wrong: %#v", post)
+	}
+
+	toml := "+++\ntitle = \"你好\"\nslug = \"ni-hao\"\ndate = 2019-10-01T12:00:00+08:00\ntags = [\n  \"a\",\n  'b, c',\n]\n[params]\ndraft = false\n+++\nbody\n"
+	post = ImportMarkdown("content/post/hi/index.md", []byte(toml), loc)
+	if post.Error != "" || post.Title != "你好" || post.Seo != "nihao" || post.Draft || post.Date != 1569902400 ||
+		!reflect.DeepEqual(post.Tags, []string{"a", "b, c"}) || post.Describe != "body" {
+		t.Fatalf("toml wrong: %#v", post)
+	}
+
+	post = ImportMarkdown("post/hi/index.md", []byte("no front matter"), loc)
+	if post.Title != "hi" || post.Seo != "hi" || post.Describe != "no front matter" {
+		t.Fatalf("no front matter wrong: %#v", post)
+	}
+
+	post = ImportMarkdown("a.md", []byte("---\ntitle: a\n"), loc)
+	if post.Error == "" {
+		t.Fatalf("front matter not close should fail")
+	}
+}
+
+func TestImportWxr(t *testing.T) {
+	wxr := `<?xml version="1.0" encoding="UTF-8" ?>
+<rss version="2.0" xmlns:content="http://purl.org/rss/1.0/modules/content/" xmlns:wp="http://wordpress.org/export/1.2/">
+<channel>
+	<item>
+		<title>Hello &amp; World</title>
+		<link>https://old.com/hello</link>
+		<content:encoded><![CDATA[<p>One <strong>two</strong></p>
+<img src="https://old.com/wp-content/uploads/2019/10/a.png" alt="a" />
+
+<ul><li>x</li><li>y<ul><li>z</li></ul></li></ul>]]></content:encoded>
+		<wp:post_name>hello-world</wp:post_name>
+		<wp:post_date_gmt>2019-10-01 04:00:00</wp:post_date_gmt>
+		<wp:status>private</wp:status>
+		<wp:post_type>post</wp:post_type>
+		<category domain="category" nicename="go"><![CDATA[Go]]></category>
+		<category domain="post_tag" nicename="web"><![CDATA[Web]]></category>
+	</item>
+	<item>
+		<title>a.png</title>
+		<wp:post_type>attachment</wp:post_type>
+	</item>
+</channel>
+</rss>`
+
+	posts, err := ImportWxr([]byte(wxr), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(posts) != 1 {
+		t.Fatalf("post num wrong: %d", len(posts))
+	}
+
+	post := posts[0]
+	want := "One **two**\n\n![a](https://old.com/wp-content/uploads/2019/10/a.png)\n\n- x\n- y\n   - z"
+	if post.Title != "Hello & World" || post.Seo != "helloworld" || !post.Hide || post.Date != 1569902400 ||
+		!reflect.DeepEqual(post.Categories, []string{"Go"}) || !reflect.DeepEqual(post.Tags, []string{"Web"}) || post.Describe != want {
+		t.Fatalf("wxr wrong: %#v\n%s", post, post.Describe)
+	}
+
+	files := map[string][]byte{"uploads/wp-content/uploads/2019/10/a.png": nil}
+	images := ImportImages(post.Describe)
+	if len(images) != 1 {
+		t.Fatalf("images wrong: %v", images)
+	}
+
+	if name, ok := ImportFindFile(files, post.Path, images[0]); !ok || name != "uploads/wp-content/uploads/2019/10/a.png" {
+		t.Fatalf("find file wrong: %s", name)
+	}
+}
+
+func TestImportFindFile(t *testing.T) {
+	files := map[string][]byte{"post/a/1.png": nil, "static/img/2.png": nil, "3 b.png": nil}
+	for ref, want := range map[string]string{
+		"1.png":              "post/a/1.png",
+		"/img/2.png":         "static/img/2.png",
+		"../../3%20b.png":    "3 b.png",
+		"http://x.com/1.jpg": "",
+		"data:image/png":     "",
+	} {
+		name, ok := ImportFindFile(files, "post/a/index.md", ref)
+		if name != want || ok != (want != "") {
+			t.Fatalf("find %s wrong: %s", ref, name)
+		}
+	}
+}
+
+func TestImportUnzip(t *testing.T) {
+	buf := new(bytes.Buffer)
+	w := zip.NewWriter(buf)
+	for _, name := range []string{"blog/a.md", "__MACOSX/blog/._a.md", "blog/_index.md"} {
+		f, _ := w.Create(name)
+		f.Write([]byte("---\ntitle: " + name + "\n---\nbody"))
+	}
+	w.Close()
+
+	files, err := ImportUnzip(buf.Bytes(), 1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	posts := ImportParse(files, nil)
+	if len(files) != 2 || len(posts) != 1 || posts[0].Title != "blog/a.md" {
+		t.Fatalf("unzip wrong: %d %v", len(files), posts)
+	}
+
+	_, err = ImportUnzip(buf.Bytes(), 10)
+	if err == nil || !strings.Contains(err.Error(), "too big") {
+		t.Fatalf("size limit wrong: %v", err)
+	}
+}
+
+func TestExportMarkdown(t *testing.T) {
+	raw, err := ExportMarkdown([]string{"title", "seo", "date", "tags", "draft", "password"}, map[string]interface{}{
+		"title": "a: b", "seo": "ab", "date": "2019-10-01T12:00:00+08:00", "tags": []string{"x", "y"}, "draft": true, "password": "",
+	}, "# Hi")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := "---\ntitle: 'a: b'\nseo: ab\ndate: \"2019-10-01T12:00:00+08:00\"\ntags:\n- x\n- \"y\"\ndraft: true\n---\n\n# Hi\n"
+	if string(raw) != want {
+		t.Fatalf("export wrong:\n%s", raw)
+	}
+
+	// import it again
+	post := ImportMarkdown("content/go/ab.md", raw, nil)
+	if post.Title != "a: b" || post.Seo != "ab" || post.Date != 1569902400 || !post.Draft || len(post.Tags) != 2 || post.Describe != "# Hi" {
+		t.Fatalf("import again wrong: %#v", post)
+	}
+}
@@ -0,0 +1,472 @@
+package util
+
+import (
+	"fmt"
+	"html"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// A small markdown render, raw html in source will be escaped so the output is safe,
+// support heading, paragraph, emphasis, code, link, image, quote, list and rule
+
+// excerpt rune length
+var MarkdownExcerptLength = 200
+
+// words read one minute
+var MarkdownReadSpeed int64 = 300
+
+type TocItem struct {
+	Level int    `json:"level"`
+	Text  string `json:"text"`
+	Id    string `json:"id"`
+}
+
+type Markdown struct {
+	Html       string
+	Toc        []TocItem
+	Excerpt    string
+	WordNum    int64
+	ReadMinute int64
+}
+
+type markdownRender struct {
+	toc []TocItem
+	ids map[string]int
+}
+
+var (
+	mdHeading  = regexp.MustCompile(`^(#{1,6})[ \t]+(.*?)[ \t]*#*[ \t]*$`)
+	mdRule     = regexp.MustCompile(`^ {0,3}((\*[ \t]*){3,}|(-[ \t]*){3,}|(_[ \t]*){3,})$`)
+	mdFence    = regexp.MustCompile("^ {0,3}(```|~~~)[ \t]*([A-Za-z0-9_+-]*)")
+	mdUnorder  = regexp.MustCompile(`^( {0,3})[-*+][ \t]+`)
+	mdOrder    = regexp.MustCompile(`^( {0,3})(\d{1,9})[.)][ \t]+`)
+	mdQuote    = regexp.MustCompile(`^ {0,3}> ?`)
+	mdBlockEnd = regexp.MustCompile(`</(p|h[1-6]|li|blockquote|pre)>|<br>|<hr>`)
+	mdTag      = regexp.MustCompile(`<[^>]*>`)
+	mdSpace    = regexp.MustCompile(`\s+`)
+)
+
+// Render markdown into safe html, toc, excerpt and word num
+func RenderMarkdown(src string) Markdown {
+	r := &markdownRender{toc: make([]TocItem, 0), ids: make(map[string]int)}
+	src = strings.Replace(src, "\r\n", "\n", -1)
+	src = strings.Replace(src, "\t", "    ", -1)
+	out := r.blocks(strings.Split(src, "\n"))
+
+	m := Markdown{Html: out, Toc: r.toc}
+
+	text := mdBlockEnd.ReplaceAllString(out, " ")
+	text = html.UnescapeString(mdTag.ReplaceAllString(text, ""))
+	text = strings.TrimSpace(mdSpace.ReplaceAllString(text, " "))
+
+	rs := []rune(text)
+	if len(rs) > MarkdownExcerptLength {
+		m.Excerpt = string(rs[:MarkdownExcerptLength]) + "..."
+	} else {
+		m.Excerpt = text
+	}
+
+	m.WordNum = MarkdownWordNum(text)
+	if m.WordNum > 0 {
+		m.ReadMinute = (m.WordNum + MarkdownReadSpeed - 1) / MarkdownReadSpeed
+	}
+	return m
+}
+
+// Cjk char is a word, latin split by space
+func MarkdownWordNum(text string) int64 {
+	var num int64
+	inWord := false
+	for _, r := range text {
+		switch {
+		case isCJK(r):
+			num = num + 1
+			inWord = false
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			if !inWord {
+				num = num + 1
+			}
+			inWord = true
+		default:
+			inWord = false
+		}
+	}
+	return num
+}
+
+func isBlank(line string) bool {
+	return strings.TrimSpace(line) == ""
+}
+
+// line begin a new block, paragraph will stop
+func isBlockStart(line string) bool {
+	return mdHeading.MatchString(line) || mdRule.MatchString(line) || mdFence.MatchString(line) ||
+		mdQuote.MatchString(line) || mdUnorder.MatchString(line) || mdOrder.MatchString(line)
+}
+
+func (r *markdownRender) blocks(lines []string) string {
+	b := new(strings.Builder)
+	for i := 0; i < len(lines); {
+		line := lines[i]
+		switch {
+		case isBlank(line):
+			i++
+
+		case mdFence.MatchString(line):
+			m := mdFence.FindStringSubmatch(line)
+			fence := m[1]
+			code := make([]string, 0)
+			i++
+			for i < len(lines) && !strings.HasPrefix(strings.TrimSpace(lines[i]), fence) {
+				code = append(code, lines[i])
+				i++
+			}
+			i++ // the close fence
+			if m[2] != "" {
+				fmt.Fprintf(b, "<pre><code class=\"language-%s\">", m[2])
+			} else {
+				b.WriteString("<pre><code>")
+			}
+			b.WriteString(html.EscapeString(strings.Join(code, "\n")))
+			b.WriteString("</code></pre>\n")
+
+		case mdHeading.MatchString(line):
+			m := mdHeading.FindStringSubmatch(line)
+			level := len(m[1])
+			inner := r.inline(m[2])
+			text := strings.TrimSpace(html.UnescapeString(mdTag.ReplaceAllString(inner, "")))
+			id := r.slug(text)
+			r.toc = append(r.toc, TocItem{Level: level, Text: text, Id: id})
+			fmt.Fprintf(b, "<h%d id=\"%s\">%s</h%d>\n", level, html.EscapeString(id), inner, level)
+			i++
+
+		case mdRule.MatchString(line):
+			b.WriteString("<hr>\n")
+			i++
+
+		case mdQuote.MatchString(line):
+			quote := make([]string, 0)
+			for i < len(lines) && !isBlank(lines[i]) {
+				if mdQuote.MatchString(lines[i]) {
+					quote = append(quote, mdQuote.ReplaceAllString(lines[i], ""))
+				} else if len(quote) > 0 && !isBlockStart(lines[i]) {
+					// lazy line belong to the quote
+					quote = append(quote, lines[i])
+				} else {
+					break
+				}
+				i++
+			}
+			b.WriteString("<blockquote>\n")
+			b.WriteString(r.blocks(quote))
+			b.WriteString("</blockquote>\n")
+
+		case mdUnorder.MatchString(line) || mdOrder.MatchString(line):
+			i = r.list(lines, i, b)
+
+		default:
+			para := make([]string, 0)
+			for i < len(lines) && !isBlank(lines[i]) && (len(para) == 0 || !isBlockStart(lines[i])) {
+				para = append(para, lines[i])
+				i++
+			}
+			b.WriteString("<p>")
+			b.WriteString(r.paragraph(para))
+			b.WriteString("</p>\n")
+		}
+	}
+	return b.String()
+}
+
+// Line end with two space or \ is a hard break
+func (r *markdownRender) paragraph(lines []string) string {
+	parts := make([]string, 0, len(lines))
+	for i, line := range lines {
+		line = strings.TrimLeft(line, " ")
+		brk := false
+		if i < len(lines)-1 {
+			if strings.HasSuffix(line, "  ") {
+				brk = true
+			} else if strings.HasSuffix(line, "\\") {
+				line = strings.TrimSuffix(line, "\\")
+				brk = true
+			}
+		}
+		line = r.inline(strings.TrimRight(line, " "))
+		if brk {
+			line = line + "<br>"
+		}
+		parts = append(parts, line)
+	}
+	return strings.Join(parts, "\n")
+}
+
+func (r *markdownRender) list(lines []string, i int, b *strings.Builder) int {
+	ordered := !mdUnorder.MatchString(lines[i])
+	marker := mdUnorder
+	tag := "ul"
+	if ordered {
+		marker = mdOrder
+		tag = "ol"
+		m := mdOrder.FindStringSubmatch(lines[i])
+		if m[2] != "1" {
+			fmt.Fprintf(b, "<ol start=\"%s\">\n", strings.TrimLeft(m[2], "0"))
+		} else {
+			b.WriteString("<ol>\n")
+		}
+	} else {
+		b.WriteString("<ul>\n")
+	}
+
+	for i < len(lines) && marker.MatchString(lines[i]) {
+		m := marker.FindString(lines[i])
+		indent := len(m)
+		item := []string{lines[i][len(m):]}
+		i++
+
+		// lines indent belong to the item, blank line followed by indent line too
+		for i < len(lines) {
+			line := lines[i]
+			if isBlank(line) {
+				if i+1 < len(lines) && strings.HasPrefix(lines[i+1], strings.Repeat(" ", indent)) && !isBlank(lines[i+1]) {
+					item = append(item, "")
+					i++
+					continue
+				}
+				break
+			}
+
+			if strings.HasPrefix(line, strings.Repeat(" ", indent)) {
+				item = append(item, line[indent:])
+			} else if strings.HasPrefix(line, "  ") && (mdUnorder.MatchString(line[2:]) || mdOrder.MatchString(line[2:])) {
+				item = append(item, line[2:])
+			} else if !isBlockStart(line) {
+				item = append(item, strings.TrimLeft(line, " "))
+			} else {
+				break
+			}
+			i++
+		}
+
+		inner := strings.TrimSpace(r.blocks(item))
+
+		// tight list item not need <p>
+		if strings.HasPrefix(inner, "<p>") && strings.Count(inner, "<p>") == 1 {
+			end := strings.Index(inner, "</p>")
+			inner = inner[3:end] + inner[end+4:]
+		}
+		b.WriteString("<li>")
+		b.WriteString(strings.TrimSpace(inner))
+		b.WriteString("</li>\n")
+
+		// blank line between items
+		if i+1 < len(lines) && isBlank(lines[i]) && marker.MatchString(lines[i+1]) {
+			i++
+		}
+	}
+
+	fmt.Fprintf(b, "</%s>\n", tag)
+	return i
+}
+
+// Id of heading, same text add a num
+func (r *markdownRender) slug(text string) string {
+	b := new(strings.Builder)
+	dash := false
+	for _, c := range strings.ToLower(text) {
+		if unicode.IsLetter(c) || unicode.IsDigit(c) || c == '_' {
+			b.WriteRune(c)
+			dash = false
+		} else if !dash && b.Len() > 0 {
+			b.WriteRune('-')
+			dash = true
+		}
+	}
+
+	id := strings.TrimRight(b.String(), "-")
+	if id == "" {
+		id = "section"
+	}
+
+	n := r.ids[id]
+	r.ids[id] = n + 1
+	if n > 0 {
+		id = fmt.Sprintf("%s-%d", id, n)
+	}
+	return id
+}
+
+// Only those link can use, javascript: and others will be #
+func SafeUrl(u string) string {
+	u = strings.TrimSpace(u)
+	lower := strings.ToLower(u)
+	for _, scheme := range []string{"http://", "https://", "mailto:"} {
+		if strings.HasPrefix(lower, scheme) {
+			return u
+		}
+	}
+
+	// relative url, no scheme before the path
+	colon := strings.Index(u, ":")
+	if colon == -1 {
+		return u
+	}
+	slash := strings.IndexAny(u, "/?#")
+	if slash != -1 && slash < colon {
+		return u
+	}
+	return "#"
+}
+
+func isPunct(c byte) bool {
+	return strings.IndexByte("!\"#$%&'()*+,-./:;<=>?@[\\]^_`{|}~", c) != -1
+}
+
+// find the close ] of [, skip the nest
+func closeBracket(s string, i int) int {
+	depth := 0
+	for ; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '[':
+			depth++
+		case ']':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// parse (url "title") after ], return url, title and end index
+func linkDest(s string, i int) (string, string, int, bool) {
+	if i >= len(s) || s[i] != '(' {
+		return "", "", 0, false
+	}
+
+	end := strings.IndexByte(s[i:], ')')
+	if end == -1 {
+		return "", "", 0, false
+	}
+	end = i + end
+
+	inner := strings.TrimSpace(s[i+1 : end])
+	dest, title := inner, ""
+	if sp := strings.IndexAny(inner, " \t"); sp != -1 {
+		dest = inner[:sp]
+		title = strings.TrimSpace(inner[sp:])
+		if len(title) >= 2 && (title[0] == '"' || title[0] == '\'') && title[len(title)-1] == title[0] {
+			title = title[1 : len(title)-1]
+		}
+	}
+	dest = strings.TrimSuffix(strings.TrimPrefix(dest, "<"), ">")
+	return dest, title, end + 1, true
+}
+
+func (r *markdownRender) inline(s string) string {
+	b := new(strings.Builder)
+	for i := 0; i < len(s); {
+		c := s[i]
+		switch {
+		case c == '\\' && i+1 < len(s) && isPunct(s[i+1]):
+			b.WriteString(html.EscapeString(s[i+1 : i+2]))
+			i += 2
+			continue
+
+		case c == '`':
+			n := 0
+			for i+n < len(s) && s[i+n] == '`' {
+				n++
+			}
+			delim := strings.Repeat("`", n)
+			if end := strings.Index(s[i+n:], delim); end != -1 {
+				code := strings.TrimSpace(s[i+n : i+n+end])
+				b.WriteString("<code>" + html.EscapeString(code) + "</code>")
+				i = i + n + end + n
+				continue
+			}
+			b.WriteString(delim)
+			i += n
+			continue
+
+		case c == '!' && i+1 < len(s) && s[i+1] == '[':
+			if end := closeBracket(s, i+1); end != -1 {
+				if dest, title, next, ok := linkDest(s, end+1); ok {
+					alt := html.UnescapeString(mdTag.ReplaceAllString(r.inline(s[i+2:end]), ""))
+					fmt.Fprintf(b, "<img src=\"%s\" alt=\"%s\"", html.EscapeString(SafeUrl(dest)), html.EscapeString(alt))
+					if title != "" {
+						fmt.Fprintf(b, " title=\"%s\"", html.EscapeString(title))
+					}
+					b.WriteString(">")
+					i = next
+					continue
+				}
+			}
+
+		case c == '[':
+			if end := closeBracket(s, i); end != -1 {
+				if dest, title, next, ok := linkDest(s, end+1); ok {
+					fmt.Fprintf(b, "<a href=\"%s\"", html.EscapeString(SafeUrl(dest)))
+					if title != "" {
+						fmt.Fprintf(b, " title=\"%s\"", html.EscapeString(title))
+					}
+					b.WriteString(" rel=\"nofollow noopener\">" + r.inline(s[i+1:end]) + "</a>")
+					i = next
+					continue
+				}
+			}
+
+		case c == '<':
+			// auto link <http://xx>
+			if end := strings.IndexByte(s[i:], '>'); end != -1 {
+				u := s[i+1 : i+end]
+				lower := strings.ToLower(u)
+				if !strings.ContainsAny(u, " <") && (strings.HasPrefix(lower, "http://") || strings.HasPrefix(lower, "https://")) {
+					fmt.Fprintf(b, "<a href=\"%s\" rel=\"nofollow noopener\">%s</a>", html.EscapeString(u), html.EscapeString(u))
+					i = i + end + 1
+					continue
+				}
+			}
+
+		case c == '*' || c == '_' || c == '~':
+			n := 0
+			for i+n < len(s) && s[i+n] == c && n < 2 {
+				n++
+			}
+
+			// _ inside word is not emphasis, such as snake_case
+			wordBefore := i > 0 && (isWordByte(s[i-1]))
+			if (c == '~' && n == 2) || (c != '~' && !(c == '_' && wordBefore)) {
+				delim := s[i : i+n]
+				rest := s[i+n:]
+				if end := strings.Index(rest, delim); end > 0 && rest[0] != ' ' && rest[end-1] != ' ' {
+					tag := "em"
+					if c == '~' {
+						tag = "del"
+					} else if n == 2 {
+						tag = "strong"
+					}
+					b.WriteString("<" + tag + ">" + r.inline(rest[:end]) + "</" + tag + ">")
+					i = i + n + end + n
+					continue
+				}
+			}
+			b.WriteString(html.EscapeString(s[i : i+n]))
+			i += n
+			continue
+		}
+
+		b.WriteString(html.EscapeString(s[i : i+1]))
+		i++
+	}
+	return b.String()
+}
+
+func isWordByte(c byte) bool {
+	return c >= 0x80 || c == '_' || (c >= '0' && c <= '9') || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
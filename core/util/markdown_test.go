@@ -0,0 +1,90 @@
+package util
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRenderMarkdown(t *testing.T) {
+	src := `# Hello FaFa
+
+Some **bold** and *em* text with ` + "`code<b>`" + ` and [link](http://a.com "t").
+
+## Hello FaFa
+
+- one
+- two [bad](javascript:alert(1))
+
+1. first
+2. second
+
+> quote <script>alert(1)</script>
+
+` + "```go\nfmt.Println(\"<hi>\")\n```" + `
+
+---
+
+![pic](/storage/a.png) snake_case_word`
+
+	m := RenderMarkdown(src)
+	for _, want := range []string{
+		`<h1 id="hello-fafa">Hello FaFa</h1>`,
+		`<h2 id="hello-fafa-1">Hello FaFa</h2>`,
+		`<strong>bold</strong>`,
+		`<em>em</em>`,
+		`<code>code&lt;b&gt;</code>`,
+		`<a href="http://a.com" title="t" rel="nofollow noopener">link</a>`,
+		`<ul>`, `<li>one</li>`,
+		`<a href="#" rel="nofollow noopener">bad</a>`,
+		`<ol>`, `<li>second</li>`,
+		`<blockquote>`,
+		`&lt;script&gt;`,
+		`<pre><code class="language-go">fmt.Println(&#34;&lt;hi&gt;&#34;)</code></pre>`,
+		`<hr>`,
+		`<img src="/storage/a.png" alt="pic">`,
+		`snake_case_word`,
+	} {
+		if !strings.Contains(m.Html, want) {
+			t.Fatalf("html not contain %s:\n%s", want, m.Html)
+		}
+	}
+
+	if strings.Contains(m.Html, "<script>") {
+		t.Fatalf("html not safe:\n%s", m.Html)
+	}
+
+	if len(m.Toc) != 2 || m.Toc[1].Level != 2 || m.Toc[1].Id != "hello-fafa-1" {
+		t.Fatalf("toc wrong: %#v", m.Toc)
+	}
+
+	if !strings.HasPrefix(m.Excerpt, "Hello FaFa Some bold and em text") {
+		t.Fatalf("excerpt wrong: %s", m.Excerpt)
+	}
+
+	if m.WordNum == 0 || m.ReadMinute != 1 {
+		t.Fatalf("word num %d read minute %d wrong", m.WordNum, m.ReadMinute)
+	}
+}
+
+func TestMarkdownWordNum(t *testing.T) {
+	if n := MarkdownWordNum("你好 world, go1.13"); n != 5 {
+		t.Fatalf("word num %d wrong", n)
+	}
+}
+
+func TestSafeUrl(t *testing.T) {
+	cases := map[string]string{
+		"https://a.com":         "https://a.com",
+		"/v1/a":                 "/v1/a",
+		"#title":                "#title",
+		"a/b:c":                 "a/b:c",
+		"JavaScript:alert(1)":   "#",
+		"data:text/html;base64": "#",
+		"mailto:a@b.com":        "mailto:a@b.com",
+	}
+	for in, want := range cases {
+		if got := SafeUrl(in); got != want {
+			t.Fatalf("%s safe url %s, want %s", in, got, want)
+		}
+	}
+}
@@ -3,6 +3,7 @@ package oss
 import (
 	"bytes"
 	"github.com/aliyun/aliyun-oss-go-sdk/oss"
+	"io/ioutil"
 )
 
 type Key struct {
@@ -33,3 +34,23 @@ func SaveFile(K Key, ObjectName string, raw []byte) error {
 
 	return nil
 }
+
+func GetFile(K Key, ObjectName string) ([]byte, error) {
+	client, err := oss.New(K.Endpoint, K.AccessKeyId, K.AccessKeySecret)
+	if err != nil {
+		return nil, err
+	}
+
+	bucket, err := client.Bucket(K.BucketName)
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := bucket.GetObject(ObjectName)
+	if err != nil {
+		return nil, err
+	}
+
+	defer body.Close()
+	return ioutil.ReadAll(body)
+}
@@ -0,0 +1,50 @@
+package util
+
+import (
+	"crypto/subtle"
+	"errors"
+	"golang.org/x/crypto/bcrypt"
+	"strings"
+)
+
+// bcrypt cost of new hash, the cost is inside the result so it can be raise later
+const PasswordHashCost = 12
+
+// Hash the raw password by bcrypt with a random salt
+func HashPassword(raw string) (string, error) {
+	if raw == "" {
+		return "", errors.New("password empty")
+	}
+
+	hashed, err := bcrypt.GenerateFromPassword([]byte(raw), PasswordHashCost)
+	if err != nil {
+		return "", err
+	}
+	return string(hashed), nil
+}
+
+// Is the password stored hash, old row save password plain text
+func IsPasswordHashed(stored string) bool {
+	return strings.HasPrefix(stored, "$2a$") || strings.HasPrefix(stored, "$2b$") || strings.HasPrefix(stored, "$2y$")
+}
+
+// Check the raw password with the stored one
+// ok is true when password right, rehash is true when the stored one should be hash again (plain text or cost too low)
+func CheckPassword(raw string, stored string) (ok bool, rehash bool) {
+	if raw == "" || stored == "" {
+		return false, false
+	}
+
+	// legacy plain text row
+	if !IsPasswordHashed(stored) {
+		ok = subtle.ConstantTimeCompare([]byte(raw), []byte(stored)) == 1
+		return ok, ok
+	}
+
+	if bcrypt.CompareHashAndPassword([]byte(stored), []byte(raw)) != nil {
+		return false, false
+	}
+
+	cost, err := bcrypt.Cost([]byte(stored))
+	return true, err == nil && cost < PasswordHashCost
+}
@@ -0,0 +1,44 @@
+package util
+
+import (
+	"golang.org/x/crypto/bcrypt"
+	"testing"
+)
+
+func TestCheckPassword(t *testing.T) {
+	hashed, err := HashPassword("hunterhug")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !IsPasswordHashed(hashed) {
+		t.Fatalf("not hashed: %s", hashed)
+	}
+
+	if ok, rehash := CheckPassword("hunterhug", hashed); !ok || rehash {
+		t.Fatalf("check hashed wrong: %v %v", ok, rehash)
+	}
+
+	if ok, _ := CheckPassword("hunterhug1", hashed); ok {
+		t.Fatal("wrong password pass")
+	}
+
+	// legacy plain text row should pass and ask rehash
+	if ok, rehash := CheckPassword("admin", "admin"); !ok || !rehash {
+		t.Fatalf("check legacy wrong: %v %v", ok, rehash)
+	}
+
+	// low cost hash should pass and ask rehash
+	low, err := bcrypt.GenerateFromPassword([]byte("hunterhug"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if ok, rehash := CheckPassword("hunterhug", string(low)); !ok || !rehash {
+		t.Fatalf("check low cost wrong: %v %v", ok, rehash)
+	}
+
+	if ok, _ := CheckPassword("", ""); ok {
+		t.Fatal("empty password pass")
+	}
+}
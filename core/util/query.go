@@ -0,0 +1,92 @@
+package util
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// Query of url into json by the json tag of the struct, so GET can use the same request as POST body
+// ?user_name=a&limit=10&sort=-id&sort=+sort_num is {"user_name":"a","limit":10,"sort":["-id","+sort_num"]}
+func QueryToJson(values url.Values, v interface{}) ([]byte, error) {
+	m := make(map[string]interface{})
+	t := reflect.TypeOf(v)
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	if t.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("query can not into %s", t.Kind())
+	}
+
+	err := queryFields(values, t, m)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(m)
+}
+
+func queryFields(values url.Values, t reflect.Type, m map[string]interface{}) error {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name := strings.Split(f.Tag.Get("json"), ",")[0]
+		if f.Anonymous && name == "" && f.Type.Kind() == reflect.Struct {
+			err := queryFields(values, f.Type, m)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+
+		if name == "" || name == "-" {
+			continue
+		}
+
+		vs, ok := values[name]
+		if !ok || len(vs) == 0 {
+			continue
+		}
+
+		if f.Type.Kind() == reflect.Slice {
+			list := make([]interface{}, 0, len(vs))
+			for _, s := range vs {
+				x, err := queryValue(f.Type.Elem().Kind(), s)
+				if err != nil {
+					return fmt.Errorf("%s: %s", name, err.Error())
+				}
+				list = append(list, x)
+			}
+			m[name] = list
+			continue
+		}
+
+		x, err := queryValue(f.Type.Kind(), vs[0])
+		if err != nil {
+			return fmt.Errorf("%s: %s", name, err.Error())
+		}
+		m[name] = x
+	}
+	return nil
+}
+
+func queryValue(kind reflect.Kind, s string) (interface{}, error) {
+	switch kind {
+	case reflect.String:
+		return s, nil
+	case reflect.Bool:
+		if s == "" {
+			return true, nil
+		}
+		return strconv.ParseBool(s)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.ParseInt(s, 10, 64)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.ParseUint(s, 10, 64)
+	case reflect.Float32, reflect.Float64:
+		return strconv.ParseFloat(s, 64)
+	}
+	return nil, fmt.Errorf("type %s not support", kind)
+}
@@ -0,0 +1,38 @@
+package util
+
+import (
+	"net/url"
+	"testing"
+)
+
+type queryPage struct {
+	Limit int `json:"limit"`
+	Page  int `json:"page"`
+}
+
+type queryRequest struct {
+	UserName string   `json:"user_name"`
+	NodeId   int64    `json:"node_id"`
+	Render   bool     `json:"render"`
+	Sort     []string `json:"sort"`
+	Skip     string   `json:"-"`
+	queryPage
+}
+
+func TestQueryToJson(t *testing.T) {
+	q, _ := url.ParseQuery("user_name=123&node_id=7&render&sort=-id&sort=%2Bsort_num&limit=10&Skip=x&other=1")
+	raw, err := QueryToJson(q, new(queryRequest))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := `{"limit":10,"node_id":7,"render":true,"sort":["-id","+sort_num"],"user_name":"123"}`
+	if string(raw) != want {
+		t.Fatalf("json wrong: %s", raw)
+	}
+
+	q, _ = url.ParseQuery("node_id=abc")
+	if _, err = QueryToJson(q, new(queryRequest)); err == nil {
+		t.Fatalf("node_id not int should fail")
+	}
+}
@@ -0,0 +1,141 @@
+package util
+
+import (
+	"html"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// word too long will cut, db column is varchar(50)
+const SearchWordMaxLength = 50
+
+// chinese, japanese and korean has no space between words
+func isCJK(r rune) bool {
+	return unicode.Is(unicode.Han, r) ||
+		unicode.Is(unicode.Hiragana, r) ||
+		unicode.Is(unicode.Katakana, r) ||
+		unicode.Is(unicode.Hangul, r)
+}
+
+// Split text into search words, latin word split by space and punctuation,
+// cjk has no space so every two near char will be a word, single char keep when the run only one char
+func SearchTokens(s string) []string {
+	words := make([]string, 0)
+	latin := make([]rune, 0)
+	cjk := make([]rune, 0)
+
+	flushLatin := func() {
+		if len(latin) > 0 {
+			w := string(latin)
+			if len(w) > SearchWordMaxLength {
+				w = w[:SearchWordMaxLength]
+				for !utf8.ValidString(w) {
+					w = w[:len(w)-1]
+				}
+			}
+			words = append(words, w)
+			latin = latin[:0]
+		}
+	}
+
+	flushCJK := func() {
+		if len(cjk) == 1 {
+			words = append(words, string(cjk))
+		}
+		for i := 0; i+1 < len(cjk); i++ {
+			words = append(words, string(cjk[i:i+2]))
+		}
+		cjk = cjk[:0]
+	}
+
+	for _, r := range strings.ToLower(s) {
+		switch {
+		case isCJK(r):
+			flushLatin()
+			cjk = append(cjk, r)
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			flushCJK()
+			latin = append(latin, r)
+		default:
+			flushLatin()
+			flushCJK()
+		}
+	}
+
+	flushLatin()
+	flushCJK()
+	return words
+}
+
+// Count of every word
+func SearchWordCount(s string) map[string]int64 {
+	m := make(map[string]int64)
+	for _, w := range SearchTokens(s) {
+		m[w] = m[w] + 1
+	}
+	return m
+}
+
+// Cut a snippet around the first match word, html escape it and wrap the match word by <em>
+func SearchHighlight(text string, words []string, size int) string {
+	rs := []rune(text)
+	lower := []rune(strings.ToLower(text))
+	if len(lower) != len(rs) {
+		// lower change the length, give up highlight
+		lower = rs
+	}
+
+	// find all the match, mark it
+	mark := make([]bool, len(rs))
+	first := -1
+	for _, w := range words {
+		wr := []rune(w)
+		if len(wr) == 0 {
+			continue
+		}
+		for i := 0; i+len(wr) <= len(lower); i++ {
+			if string(lower[i:i+len(wr)]) == w {
+				for j := i; j < i+len(wr); j++ {
+					mark[j] = true
+				}
+				if first == -1 || i < first {
+					first = i
+				}
+			}
+		}
+	}
+
+	// snippet begin a little before the first match
+	begin := 0
+	if first > size/4 {
+		begin = first - size/4
+	}
+	end := begin + size
+	if end > len(rs) {
+		end = len(rs)
+	}
+
+	b := new(strings.Builder)
+	if begin > 0 {
+		b.WriteString("...")
+	}
+	in := false
+	for i := begin; i < end; i++ {
+		if mark[i] && !in {
+			b.WriteString("<em>")
+			in = true
+		} else if !mark[i] && in {
+			b.WriteString("</em>")
+			in = false
+		}
+		b.WriteString(html.EscapeString(string(rs[i])))
+	}
+	if in {
+		b.WriteString("</em>")
+	}
+	if end < len(rs) {
+		b.WriteString("...")
+	}
+	return b.String()
+}
@@ -0,0 +1,36 @@
+package util
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSearchTokens(t *testing.T) {
+	cases := map[string][]string{
+		"Hello, FaFa CMS!": {"hello", "fafa", "cms"},
+		"全文搜索":             {"全文", "文搜", "搜索"},
+		"go语言v2":           {"go", "语言", "v2"},
+		"字":                {"字"},
+		"":                 {},
+	}
+
+	for in, want := range cases {
+		got := SearchTokens(in)
+		if !reflect.DeepEqual(got, want) {
+			t.Fatalf("%q tokens %v, want %v", in, got, want)
+		}
+	}
+}
+
+func TestSearchHighlight(t *testing.T) {
+	got := SearchHighlight("一个<b>全文</b>搜索", []string{"全文", "搜索"}, 100)
+	want := "一个&lt;b&gt;<em>全文</em>&lt;/b&gt;<em>搜索</em>"
+	if got != want {
+		t.Fatalf("highlight %s, want %s", got, want)
+	}
+
+	got = SearchHighlight("0123456789abcdefghij", []string{"f"}, 8)
+	if got != "...de<em>f</em>ghij" {
+		t.Fatalf("snippet %s wrong", got)
+	}
+}
@@ -0,0 +1,63 @@
+package util
+
+import (
+	"encoding/xml"
+	"time"
+)
+
+// one sitemap file at most so many url, more should split and use sitemap index
+const SitemapMaxUrl = 50000
+
+type SitemapUrl struct {
+	Loc     string
+	LastMod int64 // 0 not show
+}
+
+type sitemapUrlSet struct {
+	XMLName xml.Name       `xml:"http://www.sitemaps.org/schemas/sitemap/0.9 urlset"`
+	Urls    []sitemapEntry `xml:"url"`
+}
+
+type sitemapIndex struct {
+	XMLName  xml.Name       `xml:"http://www.sitemaps.org/schemas/sitemap/0.9 sitemapindex"`
+	Sitemaps []sitemapEntry `xml:"sitemap"`
+}
+
+type sitemapEntry struct {
+	Loc     string `xml:"loc"`
+	LastMod string `xml:"lastmod,omitempty"`
+}
+
+func sitemapEntries(urls []SitemapUrl, loc *time.Location) []sitemapEntry {
+	if loc == nil {
+		loc = time.UTC
+	}
+
+	entries := make([]sitemapEntry, 0, len(urls))
+	for _, v := range urls {
+		e := sitemapEntry{Loc: v.Loc}
+		if v.LastMod > 0 {
+			e.LastMod = time.Unix(v.LastMod, 0).In(loc).Format(time.RFC3339)
+		}
+		entries = append(entries, e)
+	}
+	return entries
+}
+
+// Url set of sitemap, urls should not more than SitemapMaxUrl
+func Sitemap(urls []SitemapUrl, loc *time.Location) ([]byte, error) {
+	raw, err := xml.MarshalIndent(sitemapUrlSet{Urls: sitemapEntries(urls, loc)}, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), raw...), nil
+}
+
+// Index of many sitemap
+func SitemapIndex(sitemaps []SitemapUrl, loc *time.Location) ([]byte, error) {
+	raw, err := xml.MarshalIndent(sitemapIndex{Sitemaps: sitemapEntries(sitemaps, loc)}, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), raw...), nil
+}
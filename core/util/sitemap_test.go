@@ -0,0 +1,32 @@
+package util
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSitemap(t *testing.T) {
+	raw, err := Sitemap([]SitemapUrl{{Loc: "http://127.0.0.1/u/fafa/c/1?a=1&b=2", LastMod: 1572000000}, {Loc: "http://127.0.0.1/"}}, time.FixedZone("", 8*3600))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := string(raw)
+	if !strings.Contains(s, `<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">`) ||
+		!strings.Contains(s, "<loc>http://127.0.0.1/u/fafa/c/1?a=1&amp;b=2</loc>") ||
+		!strings.Contains(s, "<lastmod>2019-10-25T18:40:00+08:00</lastmod>") ||
+		strings.Count(s, "<lastmod>") != 1 {
+		t.Fatalf("sitemap wrong:\n%s", s)
+	}
+
+	raw, err = SitemapIndex([]SitemapUrl{{Loc: "http://127.0.0.1/sitemap.xml?page=1"}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s = string(raw)
+	if !strings.Contains(s, "<sitemapindex") || !strings.Contains(s, "<sitemap>") {
+		t.Fatalf("sitemap index wrong:\n%s", s)
+	}
+}
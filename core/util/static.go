@@ -0,0 +1,323 @@
+package util
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
+	"html/template"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// the template must define those, one for each kind of page
+var StaticTemplateNames = []string{"index.html", "user.html", "node.html", "content.html"}
+
+type StaticSite struct {
+	Name      string
+	Url       string
+	BuildTime int64
+}
+
+type StaticUser struct {
+	Name          string
+	NickName      string
+	ShortDescribe string
+	HeadPhoto     string
+	Url           string
+}
+
+type StaticNode struct {
+	Id         int64
+	Seo        string
+	Name       string
+	Describe   string
+	ImagePath  string
+	Url        string
+	ContentNum int
+	Children   []*StaticNode
+}
+
+type StaticContent struct {
+	Id               int64
+	Title            string
+	Url              string
+	User             *StaticUser
+	Node             *StaticNode
+	Top              bool
+	Tags             []string
+	ImagePath        string
+	Excerpt          string
+	Html             template.HTML // render by the markdown, dangerous tag already filter
+	Toc              []TocItem
+	WordNum          int64
+	ReadMinute       int64
+	FirstPublishTime int64
+	PublishTime      int64
+}
+
+// Data of one page, the field not about the page will be nil
+type StaticPage struct {
+	Site     StaticSite
+	Title    string
+	Users    []*StaticUser // index only
+	User     *StaticUser
+	Nodes    []*StaticNode // node tree of user
+	Node     *StaticNode
+	Content  *StaticContent
+	Contents []*StaticContent
+	Page     int
+	Pages    int
+	PrevUrl  string
+	NextUrl  string
+}
+
+const staticDefaultTemplate = `
+{{define "header"}}<!DOCTYPE html>
+<html lang="zh-CN">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{if .Title}}{{.Title}} - {{end}}{{.Site.Name}}</title>
+<style>
+body{max-width:860px;margin:0 auto;padding:0 16px;font-family:-apple-system,"PingFang SC","Microsoft YaHei",sans-serif;line-height:1.7;color:#333}
+a{color:#1a6fb5;text-decoration:none}header,footer{padding:16px 0;color:#888}
+.item{border-bottom:1px solid #eee;padding:12px 0}.meta{color:#999;font-size:13px}
+pre{overflow:auto;background:#f6f8fa;padding:12px}img{max-width:100%}
+</style>
+</head>
+<body>
+<header><a href="/">{{.Site.Name}}</a>{{if .User}} / <a href="{{.User.Url}}">{{.User.NickName}}</a>{{end}}</header>
+{{end}}
+
+{{define "footer"}}
+<footer>{{.Site.Name}} · {{date .Site.BuildTime}}</footer>
+</body>
+</html>
+{{end}}
+
+{{define "nodes"}}{{if .}}<ul>{{range .}}<li><a href="{{.Url}}">{{.Name}}</a>{{template "nodes" .Children}}</li>{{end}}</ul>{{end}}{{end}}
+
+{{define "list"}}
+{{range .Contents}}<div class="item">
+<h3>{{if .Top}}[置顶] {{end}}<a href="{{.Url}}">{{.Title}}</a></h3>
+<div class="meta">{{if .User}}<a href="{{.User.Url}}">{{.User.NickName}}</a> · {{end}}{{date .FirstPublishTime}}{{if .Node}} · <a href="{{.Node.Url}}">{{.Node.Name}}</a>{{end}}</div>
+<p>{{.Excerpt}}</p>
+</div>{{end}}
+<p>{{if .PrevUrl}}<a href="{{.PrevUrl}}">上一页</a> {{end}}{{if gt .Pages 1}}{{.Page}}/{{.Pages}}{{end}}{{if .NextUrl}} <a href="{{.NextUrl}}">下一页</a>{{end}}</p>
+{{end}}
+
+{{define "index.html"}}{{template "header" .}}
+{{if .Users}}<p>{{range .Users}}<a href="{{.Url}}">{{.NickName}}</a> {{end}}</p>{{end}}
+{{template "list" .}}
+{{template "footer" .}}{{end}}
+
+{{define "user.html"}}{{template "header" .}}
+<h2>{{.User.NickName}}</h2><p>{{.User.ShortDescribe}}</p>
+{{template "nodes" .Nodes}}
+{{template "list" .}}
+{{template "footer" .}}{{end}}
+
+{{define "node.html"}}{{template "header" .}}
+<h2>{{.Node.Name}}</h2><p>{{.Node.Describe}}</p>
+{{template "nodes" .Node.Children}}
+{{template "list" .}}
+{{template "footer" .}}{{end}}
+
+{{define "content.html"}}{{template "header" .}}
+<article>
+<h1>{{.Content.Title}}</h1>
+<div class="meta">{{date .Content.FirstPublishTime}}{{if .Content.Node}} · <a href="{{.Content.Node.Url}}">{{.Content.Node.Name}}</a>{{end}} · {{.Content.WordNum}}字 · {{.Content.ReadMinute}}分钟{{range .Content.Tags}} · #{{.}}{{end}}</div>
+{{.Content.Html}}
+</article>
+{{template "footer" .}}{{end}}
+`
+
+// Default template, the *.html in dir can redefine any of it, hash change when template change
+func StaticTemplate(dir string, loc *time.Location) (*template.Template, string, error) {
+	if loc == nil {
+		loc = time.Local
+	}
+
+	t := template.New("static").Funcs(template.FuncMap{
+		"date": func(t int64) string {
+			if t <= 0 {
+				return ""
+			}
+			return time.Unix(t, 0).In(loc).Format("2006-01-02 15:04")
+		},
+	})
+
+	t, err := t.Parse(staticDefaultTemplate)
+	if err != nil {
+		return nil, "", err
+	}
+
+	h := md5.New()
+	h.Write([]byte(staticDefaultTemplate))
+	if dir != "" {
+		names, err := filepath.Glob(filepath.Join(dir, "*.html"))
+		if err != nil {
+			return nil, "", err
+		}
+
+		sort.Strings(names)
+		for _, name := range names {
+			raw, err := ioutil.ReadFile(name)
+			if err != nil {
+				return nil, "", err
+			}
+
+			h.Write(raw)
+			_, err = t.Parse(string(raw))
+			if err != nil {
+				return nil, "", err
+			}
+		}
+	}
+
+	return t, hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func StaticRender(t *template.Template, name string, page *StaticPage) ([]byte, error) {
+	buf := new(strings.Builder)
+	err := t.ExecuteTemplate(buf, name, page)
+	if err != nil {
+		return nil, err
+	}
+	return []byte(buf.String()), nil
+}
+
+// Url of page, first page is the url itself: /u/hunterhug/ /u/hunterhug/page/2/
+func StaticPageUrl(url string, page int) string {
+	if page <= 1 {
+		return url
+	}
+	return url + "page/" + IS(page) + "/"
+}
+
+// File of the url in dir: /u/hunterhug/ is u/hunterhug/index.html
+func StaticUrlFile(dir string, url string) string {
+	return filepath.Join(dir, filepath.FromSlash(strings.TrimPrefix(url, "/")), "index.html")
+}
+
+// Name use as one part of the path
+func StaticSafeName(name string) bool {
+	return name != "" && name != "." && name != ".." && !strings.ContainsAny(name, "/\\?#")
+}
+
+// Write only when change, keep the modify time of the file not change
+func StaticWrite(name string, raw []byte) (bool, error) {
+	if old, err := ioutil.ReadFile(name); err == nil && string(old) == string(raw) {
+		return false, nil
+	}
+
+	err := MakeDir(filepath.Dir(name))
+	if err != nil {
+		return false, err
+	}
+	return true, ioutil.WriteFile(name, raw, 0666)
+}
+
+var staticFileRegexp = regexp.MustCompile(`/(storage|storage_x)/[^"'\s()<>?#]+`)
+
+// Local upload file refer in the html, like storage/hunterhug/image/a.png
+func StaticFiles(s string) []string {
+	files := make([]string, 0)
+	exist := make(map[string]bool)
+	for _, v := range staticFileRegexp.FindAllString(s, -1) {
+		v = strings.TrimPrefix(v, "/")
+		if exist[v] {
+			continue
+		}
+
+		exist[v] = true
+		ok := true
+		for _, p := range strings.Split(v, "/") {
+			if !StaticSafeName(p) {
+				ok = false
+				break
+			}
+		}
+		if ok {
+			files = append(files, v)
+		}
+	}
+	return files
+}
+
+// Copy when the file in dst not the same size or older
+func StaticCopy(src, dst string) (bool, error) {
+	si, err := os.Stat(src)
+	if err != nil {
+		return false, err
+	}
+
+	if di, err := os.Stat(dst); err == nil && di.Size() == si.Size() && !di.ModTime().Before(si.ModTime()) {
+		return false, nil
+	}
+
+	err = MakeDir(filepath.Dir(dst))
+	if err != nil {
+		return false, err
+	}
+
+	f, err := os.Open(src)
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+
+	err = CopyFS(f, dst)
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+type StaticManifestItem struct {
+	UserId      int64  `json:"user_id"`
+	Url         string `json:"url"`
+	PublishTime int64  `json:"publish_time"`
+}
+
+// Keep in the output dir, the content publish time not change will not render again
+type StaticManifest struct {
+	Template string                       `json:"template"`
+	Contents map[int64]StaticManifestItem `json:"contents"`
+}
+
+// Not exist is empty
+func StaticManifestLoad(name string) (*StaticManifest, error) {
+	m := &StaticManifest{Contents: make(map[int64]StaticManifestItem)}
+	raw, err := ioutil.ReadFile(name)
+	if os.IsNotExist(err) {
+		return m, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	err = json.Unmarshal(raw, m)
+	if err != nil {
+		return nil, err
+	}
+
+	if m.Contents == nil {
+		m.Contents = make(map[int64]StaticManifestItem)
+	}
+	return m, nil
+}
+
+func (m *StaticManifest) Save(name string) error {
+	raw, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	_, err = StaticWrite(name, raw)
+	return err
+}
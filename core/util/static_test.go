@@ -0,0 +1,110 @@
+package util
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStaticTemplate(t *testing.T) {
+	dir, err := ioutil.TempDir("", "static")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	tpl, hash, err := StaticTemplate("", time.FixedZone("", 8*3600))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	u := &StaticUser{Name: "fafa", NickName: "<FaFa>", Url: "/u/fafa/"}
+	c := &StaticContent{Id: 1, Title: "Hi", Url: "/u/fafa/c/1/", User: u, Html: "<p>body</p>", FirstPublishTime: 1569902400, Tags: []string{"go"}}
+	raw, err := StaticRender(tpl, "content.html", &StaticPage{Site: StaticSite{Name: "FaFa"}, Title: c.Title, User: u, Content: c})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := string(raw)
+	if !strings.Contains(s, "<title>Hi - FaFa</title>") || !strings.Contains(s, "<p>body</p>") ||
+		!strings.Contains(s, "&lt;FaFa&gt;") || !strings.Contains(s, "2019-10-01 12:00") || !strings.Contains(s, "#go") {
+		t.Fatalf("content wrong:\n%s", s)
+	}
+
+	// redefine one, others still default
+	err = ioutil.WriteFile(filepath.Join(dir, "index.html"), []byte(`{{define "index.html"}}my {{len .Contents}}{{end}}`), 0666)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tpl, hash2, err := StaticTemplate(dir, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	raw, err = StaticRender(tpl, "index.html", &StaticPage{Contents: []*StaticContent{c}})
+	if err != nil || string(raw) != "my 1" || hash == hash2 {
+		t.Fatalf("redefine wrong: %s %v", raw, err)
+	}
+
+	for _, name := range StaticTemplateNames {
+		if tpl.Lookup(name) == nil {
+			t.Fatalf("template %s miss", name)
+		}
+	}
+}
+
+func TestStaticFiles(t *testing.T) {
+	files := StaticFiles(`<img src="/storage/fafa/image/a.png"><img src='/storage_x/fafa/image/a.png?x=1'> /storage/fafa/image/a.png /storage/../etc/passwd http://oss.com/x.png`)
+	if !reflect.DeepEqual(files, []string{"storage/fafa/image/a.png", "storage_x/fafa/image/a.png"}) {
+		t.Fatalf("files wrong: %v", files)
+	}
+
+	if StaticPageUrl("/u/fafa/", 1) != "/u/fafa/" || StaticPageUrl("/u/fafa/", 3) != "/u/fafa/page/3/" {
+		t.Fatalf("page url wrong")
+	}
+
+	if StaticUrlFile("out", "/") != filepath.Join("out", "index.html") || StaticUrlFile("out", "/u/fafa/") != filepath.Join("out", "u", "fafa", "index.html") {
+		t.Fatalf("url file wrong")
+	}
+}
+
+func TestStaticManifest(t *testing.T) {
+	dir, err := ioutil.TempDir("", "static")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	name := filepath.Join(dir, "m.json")
+	m, err := StaticManifestLoad(name)
+	if err != nil || len(m.Contents) != 0 {
+		t.Fatalf("empty manifest wrong: %v", err)
+	}
+
+	m.Template = "x"
+	m.Contents[1] = StaticManifestItem{UserId: 2, Url: "/u/fafa/c/1/", PublishTime: 3}
+	err = m.Save(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m2, err := StaticManifestLoad(name)
+	if err != nil || !reflect.DeepEqual(m, m2) {
+		t.Fatalf("manifest wrong: %v %v", m2, err)
+	}
+
+	changed, err := StaticWrite(name, []byte("{}"))
+	if err != nil || !changed {
+		t.Fatalf("write wrong: %v", err)
+	}
+
+	changed, err = StaticWrite(name, []byte("{}"))
+	if err != nil || changed {
+		t.Fatalf("write same should not change: %v", err)
+	}
+}
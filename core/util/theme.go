@@ -0,0 +1,129 @@
+package util
+
+import (
+	"fmt"
+	"html/template"
+	"io"
+	"net/url"
+	"path/filepath"
+	"time"
+)
+
+// every page clone the layout then parse itself, the layout call {{template "main" .}}
+const ThemeLayout = "layout.html"
+
+// file of one theme, the _*.html beside are partial can use in all page
+var ThemePages = []string{"home.html", "user.html", "node.html", "content.html", "comment.html"}
+
+type Theme struct {
+	Name  string
+	pages map[string]*template.Template
+}
+
+// Load a theme from the dir, all the page must exist
+func ThemeLoad(dir string, funcs template.FuncMap) (*Theme, error) {
+	base, err := template.New(ThemeLayout).Funcs(funcs).ParseFiles(filepath.Join(dir, ThemeLayout))
+	if err != nil {
+		return nil, err
+	}
+
+	partials, err := filepath.Glob(filepath.Join(dir, "_*.html"))
+	if err != nil {
+		return nil, err
+	}
+
+	if len(partials) > 0 {
+		_, err = base.ParseFiles(partials...)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	t := &Theme{Name: filepath.Base(dir), pages: make(map[string]*template.Template, len(ThemePages))}
+	for _, page := range ThemePages {
+		p, err := base.Clone()
+		if err != nil {
+			return nil, err
+		}
+
+		_, err = p.ParseFiles(filepath.Join(dir, page))
+		if err != nil {
+			return nil, err
+		}
+		t.pages[page] = p
+	}
+	return t, nil
+}
+
+func (t *Theme) Render(w io.Writer, page string, data interface{}) error {
+	p, ok := t.pages[page]
+	if !ok {
+		return fmt.Errorf("theme %s has no page %s", t.Name, page)
+	}
+	return p.ExecuteTemplate(w, ThemeLayout, data)
+}
+
+// Name of the theme is the dir name
+func ThemeExist(dir string, name string) bool {
+	return StaticSafeName(name) && FileExist(filepath.Join(dir, name, ThemeLayout))
+}
+
+// All the theme in the dir
+func ThemeList(dir string) ([]string, error) {
+	names, err := filepath.Glob(filepath.Join(dir, "*", ThemeLayout))
+	if err != nil {
+		return nil, err
+	}
+
+	themes := make([]string, 0, len(names))
+	for _, v := range names {
+		themes = append(themes, filepath.Base(filepath.Dir(v)))
+	}
+	return themes, nil
+}
+
+// Func can use in the template, date show time in the zone, html not escape only use on the html already filter,
+// link make url with query: {{link "/content" "id" .Id}} is /content?id=1,
+// query change the query of now: {{query .Query "page" (add .Data.Page 1)}} is ?limit=10&page=3
+func ThemeFuncs(loc *time.Location) template.FuncMap {
+	if loc == nil {
+		loc = time.Local
+	}
+
+	return template.FuncMap{
+		"date": func(t int64) string {
+			if t <= 0 {
+				return ""
+			}
+			return time.Unix(t, 0).In(loc).Format("2006-01-02 15:04")
+		},
+		"html": func(s string) template.HTML {
+			return template.HTML(s)
+		},
+		"link": func(path string, kv ...interface{}) string {
+			q := url.Values{}
+			for i := 0; i+1 < len(kv); i = i + 2 {
+				q.Add(fmt.Sprint(kv[i]), fmt.Sprint(kv[i+1]))
+			}
+
+			if len(q) == 0 {
+				return path
+			}
+			return path + "?" + q.Encode()
+		},
+		"query": func(now url.Values, kv ...interface{}) string {
+			q := url.Values{}
+			for k, v := range now {
+				q[k] = v
+			}
+
+			for i := 0; i+1 < len(kv); i = i + 2 {
+				q.Set(fmt.Sprint(kv[i]), fmt.Sprint(kv[i+1]))
+			}
+			return "?" + q.Encode()
+		},
+		"add": func(a, b int) int {
+			return a + b
+		},
+	}
+}
@@ -0,0 +1,50 @@
+package util
+
+import (
+	"bytes"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestThemeLoad(t *testing.T) {
+	dir := "../../theme"
+	themes, err := ThemeList(dir)
+	if err != nil || len(themes) == 0 || !ThemeExist(dir, "default") || ThemeExist(dir, "../theme") {
+		t.Fatalf("theme list wrong: %v %v", themes, err)
+	}
+
+	theme, err := ThemeLoad(dir+"/default", ThemeFuncs(time.FixedZone("", 8*3600)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	buf := new(bytes.Buffer)
+	err = theme.Render(buf, "content.html", map[string]interface{}{
+		"Site":  "FaFa",
+		"Theme": "default",
+		"Data": map[string]interface{}{
+			"Id": 1, "Title": "Hi <b>", "UserName": "fafa", "FirstPublishTimeInt": int64(1569902400),
+			"DescribeHtml": "<p>body</p>", "Tags": []string{"go"}, "CommentNum": 2,
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := buf.String()
+	if !strings.Contains(s, "<title>Hi &lt;b&gt; - FaFa</title>") || !strings.Contains(s, "<p>body</p>") ||
+		!strings.Contains(s, "2019-10-01 12:00") || !strings.Contains(s, `href="/u/info?user_name=fafa"`) {
+		t.Fatalf("content page wrong:\n%s", s)
+	}
+
+	if err = theme.Render(buf, "not.html", nil); err == nil {
+		t.Fatalf("page not exist should fail")
+	}
+
+	q, _ := url.ParseQuery("limit=10&page=2")
+	if s := ThemeFuncs(nil)["query"].(func(url.Values, ...interface{}) string)(q, "page", 3); s != "?limit=10&page=3" {
+		t.Fatalf("query wrong: %s", s)
+	}
+}
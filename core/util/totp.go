@@ -0,0 +1,97 @@
+package util
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// RFC 6238 time based one time password, google authenticator default: sha1, 6 digits, 30 seconds
+const (
+	TOTPPeriod = 30
+	TOTPDigits = 6
+)
+
+var totpEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// Gen a random base32 secret, 160 bit
+func GenTOTPSecret() (string, error) {
+	raw := make([]byte, 20)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+	return totpEncoding.EncodeToString(raw), nil
+}
+
+// The uri can be turn to a QR code, then scan by authenticator app
+func TOTPUri(issuer, account, secret string) string {
+	v := url.Values{}
+	v.Set("secret", secret)
+	v.Set("issuer", issuer)
+	v.Set("algorithm", "SHA1")
+	v.Set("digits", fmt.Sprintf("%d", TOTPDigits))
+	v.Set("period", fmt.Sprintf("%d", TOTPPeriod))
+	label := url.PathEscape(issuer + ":" + account)
+	return fmt.Sprintf("otpauth://totp/%s?%s", label, v.Encode())
+}
+
+// RFC 4226 HOTP code of counter
+func HOTPCode(secret string, counter uint64) (string, error) {
+	key, err := totpEncoding.DecodeString(strings.ToUpper(strings.TrimRight(secret, "=")))
+	if err != nil {
+		return "", err
+	}
+
+	var buf [8]byte
+	binary.BigEndian.PutUint64(buf[:], counter)
+	h := hmac.New(sha1.New, key)
+	h.Write(buf[:])
+	sum := h.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0xf
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+
+	mod := uint32(1)
+	for i := 0; i < TOTPDigits; i++ {
+		mod *= 10
+	}
+	return fmt.Sprintf("%0*d", TOTPDigits, value%mod), nil
+}
+
+func TOTPCode(secret string, t time.Time) (string, error) {
+	return HOTPCode(secret, uint64(t.Unix())/TOTPPeriod)
+}
+
+// Check the code, one period before or after also valid for clock skew
+func CheckTOTP(secret, code string, t time.Time) bool {
+	_, ok := TOTPCounter(secret, code, t)
+	return ok
+}
+
+// Check the code and return the counter it match, same counter use twice is a replay
+func TOTPCounter(secret, code string, t time.Time) (uint64, bool) {
+	code = strings.TrimSpace(code)
+	if secret == "" || len(code) != TOTPDigits {
+		return 0, false
+	}
+
+	counter := uint64(t.Unix()) / TOTPPeriod
+	for _, c := range []uint64{counter - 1, counter, counter + 1} {
+		now, err := HOTPCode(secret, c)
+		if err != nil {
+			return 0, false
+		}
+
+		if hmac.Equal([]byte(now), []byte(code)) {
+			return c, true
+		}
+	}
+
+	return 0, false
+}
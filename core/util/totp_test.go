@@ -0,0 +1,48 @@
+package util
+
+import (
+	"encoding/base32"
+	"testing"
+	"time"
+)
+
+func TestTOTPCode(t *testing.T) {
+	// RFC 6238 appendix B, sha1 secret "12345678901234567890", 8 digits 94287082 so 6 digits is 287082
+	secret := base32.StdEncoding.EncodeToString([]byte("12345678901234567890"))
+	code, err := TOTPCode(secret, time.Unix(59, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if code != "287082" {
+		t.Fatalf("totp wrong: %s", code)
+	}
+
+	if !CheckTOTP(secret, "287082", time.Unix(59+TOTPPeriod, 0)) {
+		t.Fatal("skew one period should pass")
+	}
+
+	if c, ok := TOTPCounter(secret, "287082", time.Unix(59+TOTPPeriod, 0)); !ok || c != 1 {
+		t.Fatalf("counter wrong: %d", c)
+	}
+
+	if CheckTOTP(secret, "287082", time.Unix(59+3*TOTPPeriod, 0)) {
+		t.Fatal("old code should not pass")
+	}
+}
+
+func TestGenTOTPSecret(t *testing.T) {
+	secret, err := GenTOTPSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	code, err := TOTPCode(secret, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !CheckTOTP(secret, code, time.Now()) {
+		t.Fatal("code should pass")
+	}
+}
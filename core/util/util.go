@@ -4,6 +4,7 @@ import (
 	"errors"
 	"strconv"
 	"strings"
+	"unicode/utf8"
 )
 
 // string to int
@@ -83,6 +84,32 @@ func Substr(str string, start, length int) string {
 	return string(rs[start:end])
 }
 
+// Cut to at most n char, invalid utf8 will drop, for the varchar column
+func CutRunes(s string, n int) string {
+	s = strings.ToValidUTF8(s, "")
+	count := 0
+	for i := range s {
+		if count == n {
+			return s[:i]
+		}
+		count++
+	}
+	return s
+}
+
+// Cut to at most n byte but not split a char, invalid utf8 will drop, for the text column
+func CutBytes(s string, n int) string {
+	s = strings.ToValidUTF8(s, "")
+	if len(s) <= n {
+		return s
+	}
+
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
+
 func MapToArray(m map[int64]struct{}) []int64 {
 	b := make([]int64, 0, len(m))
 	for k := range m {
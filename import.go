@@ -0,0 +1,118 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/hunterhug/fafacms/core/controllers"
+	"github.com/hunterhug/fafacms/core/model"
+	"github.com/hunterhug/fafacms/core/util"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const importUsage = `Usage:
+  fafacms [flags] import -user=hunterhug [-node=0] [-publish] [-dry_run] blog.zip|wordpress.xml|dir
+Markdown with hugo or jekyll front matter, or wordpress export, category will be the node.`
+
+// Import the old blog from command line, dir will read all the file in it
+func importCommand(args []string) error {
+	var name string
+	var nodeId int64
+	var publish, dryRun bool
+	set := flag.NewFlagSet("import", flag.ContinueOnError)
+	set.StringVar(&name, "user", "", "User name the content belong to")
+	set.Int64Var(&nodeId, "node", 0, "Node id of post without category, 0 create a node called import")
+	set.BoolVar(&publish, "publish", false, "Publish the post not draft")
+	set.BoolVar(&dryRun, "dry_run", false, "Only check, nothing will write")
+	if err := set.Parse(args); err != nil {
+		return err
+	}
+
+	if name == "" || set.NArg() != 1 {
+		return errors.New(importUsage)
+	}
+
+	u := new(model.User)
+	u.Name = name
+	exist, err := u.GetRaw()
+	if err != nil {
+		return err
+	}
+
+	if !exist {
+		return fmt.Errorf("user %s not found", name)
+	}
+
+	files, err := importFiles(set.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	result, errResp := controllers.ImportContents(u, files, controllers.ImportContentOption{NodeId: nodeId, Publish: publish, DryRun: dryRun})
+	if errResp != nil {
+		return errResp
+	}
+
+	for _, v := range result.Items {
+		fmt.Printf("%s\t%s\tcontent: %d\tnode: %s\timages: %d", v.Status, v.Path, v.ContentId, v.NodeName, v.Images)
+		if len(v.ImagesMiss) > 0 {
+			fmt.Printf("\tmiss: %s", strings.Join(v.ImagesMiss, ","))
+		}
+		if v.Error != "" {
+			fmt.Printf("\terr: %s", v.Error)
+		}
+		fmt.Println()
+	}
+
+	fmt.Printf("import total %d, success %d, fail %d, dry run %v\n", result.Total, result.Success, result.Fail, result.DryRun)
+	return nil
+}
+
+func importFiles(name string) (map[string][]byte, error) {
+	info, err := os.Stat(name)
+	if err != nil {
+		return nil, err
+	}
+
+	files := make(map[string][]byte)
+	if !info.IsDir() {
+		raw, err := ioutil.ReadFile(name)
+		if err != nil {
+			return nil, err
+		}
+
+		if strings.ToLower(util.GetFileSuffix(name)) == "zip" {
+			return util.ImportUnzip(raw, controllers.ImportUnzipBytes)
+		}
+		files[filepath.Base(name)] = raw
+		return files, nil
+	}
+
+	var size int64
+	err = filepath.Walk(name, func(p string, f os.FileInfo, err error) error {
+		if err != nil || f.IsDir() {
+			return err
+		}
+
+		size = size + f.Size()
+		if size > controllers.ImportUnzipBytes {
+			return fmt.Errorf("file in dir too big, more than %d", controllers.ImportUnzipBytes)
+		}
+
+		rel, err := filepath.Rel(name, p)
+		if err != nil {
+			return err
+		}
+
+		raw, err := ioutil.ReadFile(p)
+		if err != nil {
+			return err
+		}
+		files[filepath.ToSlash(rel)] = raw
+		return nil
+	})
+	return files, err
+}
@@ -25,6 +25,7 @@ import (
 	"github.com/hunterhug/fafacms/core/session"
 	"github.com/hunterhug/fafacms/core/util"
 	"github.com/hunterhug/fafacms/core/util/mail"
+	"os"
 	"time"
 )
 
@@ -62,6 +63,13 @@ var (
 	// Scale the picture auto
 	canScale   bool
 	scaleWidth int
+
+	// Audit log write into db, and keep days
+	auditLog      bool
+	auditKeepDays int64
+
+	// Theme template reload every request
+	themeDebug bool
 )
 
 // Parse flag when init
@@ -82,38 +90,41 @@ func init() {
 	flag.BoolVar(&historyRecord, "history_record", true, "Content history can be record")
 	flag.BoolVar(&singleLogin, "single_login", false, "User can only single point login")
 	flag.Int64Var(&sessionExpireTime, "session_expire_time", 7*3600*24, "Login session expire second time, token will destroy after this time")
+	flag.BoolVar(&auditLog, "audit_log", true, "Audit log of api write into db")
+	flag.Int64Var(&auditKeepDays, "audit_keep_days", 90, "Audit log keep days, 0 keep forever")
 
 	// When in production, please set to all false
 	flag.BoolVar(&mailDebug, "email_debug", false, "Email debug")
 	flag.BoolVar(&canSkipAuth, "auth_skip_debug", false, "Auth skip debug")
+	flag.BoolVar(&themeDebug, "theme_debug", false, "Theme template reload every request")
 
 	flag.Parse()
 }
 
-// Init the URL resource, some admin url put inside a map will save a lot of time
-func initResource() (adminUrl map[string]int64) {
-	adminUrl = make(map[string]int64)
+// Init the URL resource, every route is a resource, put inside a map will save a lot of time
+func initResource() (routeResources map[string]controllers.RouteResource) {
+	routeResources = make(map[string]controllers.RouteResource)
 	for url, handler := range router.V1Router {
-		if !handler.Admin {
-			continue
-		}
 		r := new(model.Resource)
 		url1 := fmt.Sprintf("/v1%s", url)
 		r.UrlHash, _ = util.Sha256([]byte(url1))
-		r.Admin = true
-		exist, err := r.GetRaw()
+		exist, err := r.GetByHash()
 		if err != nil {
 			panic(err)
 		}
 
 		// Exist will put in map, otherwise save in db then put in map
 		if exist {
-			adminUrl[url1] = r.Id
-			continue
+			if r.Admin != handler.Admin || r.Name != handler.Name {
+				r.Admin = handler.Admin
+				r.Name = handler.Name
+				err = r.UpdateAdmin()
+				if err != nil {
+					panic(err)
+				}
+			}
 		} else {
-			r := new(model.Resource)
 			r.Url = url1
-			r.UrlHash, _ = util.Sha256([]byte(url1))
 			r.Name = handler.Name
 			r.Describe = handler.Name
 			r.Admin = handler.Admin
@@ -122,10 +133,36 @@ func initResource() (adminUrl map[string]int64) {
 			if err != nil {
 				panic(err)
 			}
-			adminUrl[url1] = r.Id
+		}
+
+		routeResources[url1] = controllers.RouteResource{
+			Id:      r.Id,
+			Name:    handler.Name,
+			Admin:   handler.Admin,
+			Methods: handler.Method,
 		}
 	}
-	return adminUrl
+	return routeResources
+}
+
+// Init the scope of url, personal access token can only access the url its scopes allow
+func initScope() (urlScope map[string]string) {
+	urlScope = make(map[string]string)
+	for url, handler := range router.V1Router {
+		urlScope[fmt.Sprintf("/v1%s", url)] = handler.Scope
+	}
+	return urlScope
+}
+
+// Init the url which need captcha, switch in config
+func initCaptcha() (captchaUrl map[string]bool) {
+	c := config.FaFaConfig.CaptchaConfig
+	captchaUrl = map[string]bool{
+		"/user/register":        c.Register,
+		"/user/password/forget": c.ForgetPassword,
+		"/user/activate/code":   c.ResendActivateCode,
+	}
+	return captchaUrl
 }
 
 // The Beauty Main
@@ -140,6 +177,7 @@ func main() {
 	controllers.AutoBan = autoBan
 	controllers.SingleLogin = singleLogin
 	controllers.SessionExpireTime = sessionExpireTime
+	session.TokenValidTime = sessionExpireTime
 	controllers.CanScale = canScale
 	controllers.ScaleWidth = scaleWidth
 	model.HistoryRecord = historyRecord
@@ -178,37 +216,149 @@ func main() {
 	// Auto create db table
 	if createTable {
 		model.CreateTable([]interface{}{
-			model.User{},           // User Table
-			model.Group{},          // User Group, every user can assign a group
-			model.Resource{},       // Url Resource, if user not own those will be refuse to auth
-			model.GroupResource{},  // Resource will be assign to group
-			model.Content{},        // Content Table, very import
-			model.ContentCool{},    // Content Cool, user can cool your content
-			model.ContentBad{},     // Content Bad, user can bad your content and if auto ban, your content will be ban
-			model.ContentHistory{}, // Content History, when publish or edit a content, and you set history record, emm, save it
-			model.ContentNode{},    // Contents' Node, every content must belong to a node
-			model.File{},           // File Table, your picture file and some will save in.
-			model.Comment{},        // Comment Table, comment for content, comment for comment
-			model.CommentCool{},    // Like the Content Cool
-			model.CommentBad{},     // Like the Content Bad
-			model.Relation{},       // Who follow who
-			model.Message{},        // Message inside
-			model.GlobalMessage{},  // Global Message helper
-			//model.Log{},            // Log Table, not use
+			model.User{},             // User Table
+			model.Group{},            // User Group, every user can assign a group
+			model.Resource{},         // Url Resource, if user not own those will be refuse to auth
+			model.GroupResource{},    // Resource will be assign to group
+			model.Content{},          // Content Table, very import
+			model.ContentCool{},      // Content Cool, user can cool your content
+			model.ContentBad{},       // Content Bad, user can bad your content and if auto ban, your content will be ban
+			model.ContentHistory{},   // Content History, when publish or edit a content, and you set history record, emm, save it
+			model.ContentNode{},      // Contents' Node, every content must belong to a node
+			model.File{},             // File Table, your picture file and some will save in.
+			model.Comment{},          // Comment Table, comment for content, comment for comment
+			model.CommentCool{},      // Like the Content Cool
+			model.CommentBad{},       // Like the Content Bad
+			model.Relation{},         // Who follow who
+			model.Message{},          // Message inside
+			model.GlobalMessage{},    // Global Message helper
+			model.AccessToken{},      // Personal access token for script and CI
+			model.UserGroup{},        // User can belong to many groups
+			model.Log{},              // Audit log of api
+			model.SearchWord{},       // Search index, word of content, user and comment
+			model.Tag{},              // Tag of user
+			model.ContentTag{},       // Content can have many tags
+			model.ContentExport{},    // Export all content of user into zip
+			model.NodeCollaborator{}, // Node share to other user to view or edit
+			model.ContentReview{},    // Every step of content review before publish
 		})
 	}
 
-	controllers.AdminUrl = initResource()
+	// User group before is a column of user, move it
+	err = model.MigrateUserGroup()
+	if err != nil {
+		panic(err)
+	}
+
+	// Node before only has two level, fill the path of tree
+	err = model.MigrateNodePath()
+	if err != nil {
+		panic(err)
+	}
+
+	// Seed user admin before still use the password admin, give it a random one
+	seedAdminId, err := model.MigrateSeedAdmin()
+	if err != nil {
+		panic(err)
+	}
+	if seedAdminId != 0 {
+		flog.Log.Warnf("User admin still use the default password, it is reset to random, change it as admin")
+		session.FafaSessionMgr.DeleteUserToken(seedAdminId)
+	}
+
+	// Site info for feed, sitemap, robots and static site
+	if config.FaFaConfig.DefaultConfig.SiteName != "" {
+		controllers.SiteName = config.FaFaConfig.DefaultConfig.SiteName
+	}
+	controllers.SiteUrl = config.FaFaConfig.DefaultConfig.SiteUrl
+	controllers.Robots = config.FaFaConfig.DefaultConfig.Robots
+
+	// Home page render html by theme when browser visit
+	controllers.ThemePath = config.FaFaConfig.DefaultConfig.ThemePath
+	if config.FaFaConfig.DefaultConfig.Theme != "" {
+		controllers.Theme = config.FaFaConfig.DefaultConfig.Theme
+	}
+	controllers.ThemeDebug = themeDebug
+
+	// Content review before publish
+	controllers.Review = config.FaFaConfig.DefaultConfig.Review
+	controllers.ReviewGroup = config.FaFaConfig.DefaultConfig.ReviewGroup
+
+	// Command line bootstrap the super admin, then exit
+	if flag.Arg(0) == "admin" {
+		err = adminCommand(flag.Args()[1:])
+		if err != nil {
+			fmt.Println(err.Error())
+			os.Exit(1)
+		}
+		return
+	}
+
+	// Command line rebuild the search index, then exit
+	if flag.Arg(0) == "search" {
+		err = searchCommand(flag.Args()[1:])
+		if err != nil {
+			fmt.Println(err.Error())
+			os.Exit(1)
+		}
+		return
+	}
+
+	// Command line import the old blog, then exit
+	if flag.Arg(0) == "import" {
+		err = importCommand(flag.Args()[1:])
+		if err != nil {
+			fmt.Println(err.Error())
+			os.Exit(1)
+		}
+		return
+	}
+
+	// Command line build the static site, then exit
+	if flag.Arg(0) == "static" {
+		err = staticCommand(flag.Args()[1:])
+		if err != nil {
+			fmt.Println(err.Error())
+			os.Exit(1)
+		}
+		return
+	}
+
+	// No super admin, remind to create one
+	if num, err := model.SuperAdminCount(); err == nil && num == 0 {
+		flog.Log.Warnf("No super admin, please run `fafacms admin create -name=xx -email=xx` to create one")
+	}
+
+	controllers.RouteResources = initResource()
+	controllers.UrlScope = initScope()
+	controllers.CaptchaUrl = initCaptcha()
+	controllers.CaptchaLoginFailTimes = config.FaFaConfig.CaptchaConfig.LoginFailTimes
 
 	// Count ticker
 	go controllers.LoopCount()
 
+	// Search index keep up to date
+	go controllers.LoopSearch()
+
+	// Publish or hide content on time
+	go controllers.LoopSchedule()
+
+	// Audit log write by batch
+	controllers.AuditOpen = auditLog
+	controllers.AuditKeepDays = auditKeepDays
+	if auditLog {
+		go controllers.LoopAudit()
+	}
+
 	// Server Run
 	engine := server.Server()
 	// Storage static API
 	engine.Static("/storage", config.FaFaConfig.DefaultConfig.StoragePath)
 	engine.Static("/storage_x", config.FaFaConfig.DefaultConfig.StoragePath+"_x")
 
+	// Some api need captcha
+	engine.Use(controllers.CaptchaFilter)
+
 	// Web welcome home!
 	router.SetRouter(engine)
 
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/hunterhug/fafacms/core/model"
+)
+
+const searchUsage = `Usage:
+  fafacms [flags] search rebuild [-types=0]
+Types 0 all, 1 content, 2 user, 3 comment.`
+
+// Clear the search index and build again from db
+func searchCommand(args []string) error {
+	if len(args) == 0 || args[0] != "rebuild" {
+		return errors.New(searchUsage)
+	}
+
+	var types int
+	set := flag.NewFlagSet("search rebuild", flag.ContinueOnError)
+	set.IntVar(&types, "types", 0, "Types of index, 0 all")
+	if err := set.Parse(args[1:]); err != nil {
+		return err
+	}
+
+	all := []int{model.SearchContent, model.SearchUser, model.SearchComment}
+	if types != 0 {
+		if types < model.SearchContent || types > model.SearchComment {
+			return errors.New(searchUsage)
+		}
+		all = []int{types}
+	}
+
+	for _, t := range all {
+		num, err := model.SearchRebuild(t)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("search index types %d rebuild %d docs\n", t, num)
+	}
+	return nil
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/hunterhug/fafacms/core/config"
+	"github.com/hunterhug/fafacms/core/controllers"
+	"github.com/hunterhug/fafacms/core/model"
+)
+
+const staticUsage = `Usage:
+  fafacms [flags] static [-user=hunterhug] [-output=dir] [-template=dir] [-full]
+Render the publish content into html, only the content publish time change render again unless full.`
+
+// Build the static site from command line, can run by cron
+func staticCommand(args []string) error {
+	var name, output, tpl string
+	var full bool
+	set := flag.NewFlagSet("static", flag.ContinueOnError)
+	set.StringVar(&name, "user", "", "Only build the user, empty all user and the site index")
+	set.StringVar(&output, "output", controllers.StaticOutput(), "Output dir of html")
+	set.StringVar(&tpl, "template", config.FaFaConfig.DefaultConfig.StaticTplPath, "Dir of *.html redefine the default template")
+	set.BoolVar(&full, "full", false, "Render all content again")
+	if err := set.Parse(args); err != nil {
+		return err
+	}
+
+	if set.NArg() != 0 {
+		return errors.New(staticUsage)
+	}
+
+	opt := controllers.StaticOption{Output: output, Template: tpl, Full: full}
+	if name != "" {
+		u := new(model.User)
+		u.Name = name
+		exist, err := u.GetRaw()
+		if err != nil {
+			return err
+		}
+
+		if !exist {
+			return fmt.Errorf("user %s not found", name)
+		}
+		opt.UserId = u.Id
+	}
+
+	result, err := controllers.StaticBuild(opt)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("static build in %s, users %d, nodes %d, contents %d, render %d, skip %d, delete %d, pages %d, files %d, files miss %d, full %v\n",
+		result.Output, result.Users, result.Nodes, result.Contents, result.Render, result.Skip, result.Delete, result.Pages, result.Files, result.FilesMiss, result.Full)
+	return nil
+}